@@ -6,15 +6,21 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"text/template"
+	"time"
 
+	"github.com/adrg/xdg"
 	"github.com/godbus/dbus/v5"
 	"github.com/google/uuid"
 	"github.com/redhatinsights/yggdrasil"
+	"github.com/redhatinsights/yggdrasil/internal/config"
 	"github.com/redhatinsights/yggdrasil/internal/constants"
 	"github.com/redhatinsights/yggdrasil/ipc"
 	"github.com/urfave/cli/v2"
@@ -122,12 +128,12 @@ func messageJournalAction(ctx *cli.Context) error {
 	}
 
 	switch ctx.String("format") {
-	case "json":
-		data, err := json.Marshal(journalEntries)
+	case "json", "yaml":
+		data, err := marshalFormat(ctx.String("format"), journalEntries)
 		if err != nil {
 			return cli.Exit(fmt.Errorf("cannot marshal journal entries: %v", err), 1)
 		}
-		fmt.Println(string(data))
+		fmt.Print(string(data))
 	case "text":
 		journalTextTemplate := template.New("journalTextTemplate")
 		journalTextTemplate, err := journalTextTemplate.Parse(
@@ -200,12 +206,12 @@ func workersAction(c *cli.Context) error {
 	}
 
 	switch c.String("format") {
-	case "json":
-		data, err := json.Marshal(workers)
+	case "json", "yaml":
+		data, err := marshalFormat(c.String("format"), workers)
 		if err != nil {
 			return cli.Exit(fmt.Errorf("cannot marshal workers: %v", err), 1)
 		}
-		fmt.Println(string(data))
+		fmt.Print(string(data))
 	case "table":
 		writer := tabwriter.NewWriter(os.Stdout, 4, 4, 2, ' ', 0)
 		fmt.Fprintf(writer, "WORKER\tFEATURES\n")
@@ -224,6 +230,195 @@ func workersAction(c *cli.Context) error {
 	return nil
 }
 
+// statusAction is the cli action function for the "status" command.
+func statusAction(c *cli.Context) error {
+	conn, err := connectBus()
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot connect to bus: %w", err), 1)
+	}
+
+	obj := conn.Object("com.redhat.Yggdrasil1", "/com/redhat/Yggdrasil1")
+	var status map[string]string
+	if err := obj.Call("com.redhat.Yggdrasil1.Status", dbus.Flags(0)).Store(&status); err != nil {
+		return cli.Exit(fmt.Errorf("cannot get status: %v", err), 1)
+	}
+
+	switch c.String("format") {
+	case "json", "yaml":
+		data, err := marshalFormat(c.String("format"), status)
+		if err != nil {
+			return cli.Exit(fmt.Errorf("cannot marshal status: %v", err), 1)
+		}
+		fmt.Print(string(data))
+	case "table":
+		lastMessage := "never"
+		if t, err := time.Parse(time.RFC3339, status["last_message"]); err == nil {
+			lastMessage = fmt.Sprintf("%v (%v ago)", status["last_message"], time.Since(t).Round(time.Second))
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(writer, "Connected:\t%v\n", status["connected"])
+		fmt.Fprintf(writer, "Transport:\t%v\n", status["transport"])
+		fmt.Fprintf(writer, "Server:\t%v\n", status["server"])
+		fmt.Fprintf(writer, "Client ID:\t%v\n", status["client_id"])
+		fmt.Fprintf(writer, "Last message:\t%v\n", lastMessage)
+		fmt.Fprintf(writer, "Workers:\t%v (%v running)\n", status["workers"], status["workers_running"])
+		fmt.Fprintf(writer, "Pending messages:\t%v\n", status["pending_messages"])
+		fmt.Fprintf(writer, "Dead letters:\t%v\n", status["dead_letters"])
+		if status["log_level_until"] != "" {
+			fmt.Fprintf(writer, "Log level:\t%v (until %v)\n", status["log_level"], status["log_level_until"])
+		} else {
+			fmt.Fprintf(writer, "Log level:\t%v\n", status["log_level"])
+		}
+		_ = writer.Flush()
+	default:
+		return cli.Exit(fmt.Errorf("unknown format type: %v", c.String("format")), 1)
+	}
+
+	return nil
+}
+
+// workersMetricsAction is the cli action function for the "workers metrics"
+// subcommand.
+func workersMetricsAction(c *cli.Context) error {
+	conn, err := connectBus()
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot connect to bus: %w", err), 1)
+	}
+
+	obj := conn.Object("com.redhat.Yggdrasil1", "/com/redhat/Yggdrasil1")
+	var metrics map[string]map[string]string
+	if err := obj.Call("com.redhat.Yggdrasil1.WorkerMetrics", dbus.Flags(0)).Store(&metrics); err != nil {
+		return cli.Exit(fmt.Errorf("cannot get worker metrics: %v", err), 1)
+	}
+
+	switch c.String("format") {
+	case "json", "yaml":
+		data, err := marshalFormat(c.String("format"), metrics)
+		if err != nil {
+			return cli.Exit(fmt.Errorf("cannot marshal metrics: %v", err), 1)
+		}
+		fmt.Print(string(data))
+	case "table":
+		workers := make([]string, 0, len(metrics))
+		for worker := range metrics {
+			workers = append(workers, worker)
+		}
+		sort.Strings(workers)
+
+		writer := tabwriter.NewWriter(os.Stdout, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(writer, "WORKER\tRUNNING\tRESTARTS\tUPTIME\tLAST EXIT\tDISPATCHED\tFAILED\tDUPLICATE\n")
+		for _, worker := range workers {
+			m := metrics[worker]
+			fmt.Fprintf(
+				writer,
+				"%v\t%v\t%v\t%vs\t%v\t%v\t%v\t%v\n",
+				worker,
+				m["running"],
+				m["restarts"],
+				m["uptime"],
+				m["last_exit"],
+				m["messages_dispatched"],
+				m["messages_failed"],
+				m["messages_duplicate"],
+			)
+		}
+		_ = writer.Flush()
+	default:
+		return cli.Exit(fmt.Errorf("unknown format type: %v", c.String("format")), 1)
+	}
+
+	return nil
+}
+
+// workersStatusAction is the cli action function for the "workers status"
+// subcommand.
+func workersStatusAction(c *cli.Context) error {
+	conn, err := connectBus()
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot connect to bus: %w", err), 1)
+	}
+
+	obj := conn.Object("com.redhat.Yggdrasil1", "/com/redhat/Yggdrasil1")
+	var status map[string]map[string]string
+	if err := obj.Call("com.redhat.Yggdrasil1.WorkerStatus", dbus.Flags(0)).Store(&status); err != nil {
+		return cli.Exit(fmt.Errorf("cannot get worker status: %v", err), 1)
+	}
+	var features map[string]map[string]string
+	if err := obj.Call("com.redhat.Yggdrasil1.ListWorkers", dbus.Flags(0)).Store(&features); err != nil {
+		return cli.Exit(fmt.Errorf("cannot list workers: %v", err), 1)
+	}
+
+	type workerStatus struct {
+		Status   map[string]string `json:"status"`
+		Features map[string]string `json:"features"`
+	}
+	workers := make(map[string]workerStatus, len(status))
+	for worker, s := range status {
+		workers[worker] = workerStatus{Status: s, Features: features[worker]}
+	}
+
+	switch c.String("format") {
+	case "json", "yaml":
+		data, err := marshalFormat(c.String("format"), workers)
+		if err != nil {
+			return cli.Exit(fmt.Errorf("cannot marshal worker status: %v", err), 1)
+		}
+		fmt.Print(string(data))
+	case "table":
+		names := make([]string, 0, len(workers))
+		for worker := range workers {
+			names = append(names, worker)
+		}
+		sort.Strings(names)
+
+		writer := tabwriter.NewWriter(os.Stdout, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(writer, "WORKER\tSTATE\tRUNNING\tPID\tRESTARTS\tLAST EXIT\tFEATURES\n")
+		for _, worker := range names {
+			w := workers[worker]
+			featureSummary, err := json.Marshal(w.Features)
+			if err != nil {
+				return cli.Exit(fmt.Errorf("cannot marshal features: %v", err), 1)
+			}
+			fmt.Fprintf(
+				writer,
+				"%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+				worker,
+				w.Status["state"],
+				w.Status["running"],
+				w.Status["pid"],
+				w.Status["restarts"],
+				w.Status["last_exit"],
+				string(featureSummary),
+			)
+		}
+		_ = writer.Flush()
+	default:
+		return cli.Exit(fmt.Errorf("unknown format type: %v", c.String("format")), 1)
+	}
+
+	return nil
+}
+
+// workersEnableAction is the cli action function for the "workers enable" and
+// "workers disable" subcommands. It calls the com.redhat.Yggdrasil1 method
+// named method with the worker name given as the first argument.
+func workersEnableAction(method string) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		conn, err := connectBus()
+		if err != nil {
+			return cli.Exit(fmt.Errorf("cannot connect to bus: %w", err), 1)
+		}
+
+		obj := conn.Object("com.redhat.Yggdrasil1", "/com/redhat/Yggdrasil1")
+		if err := obj.Call("com.redhat.Yggdrasil1."+method, dbus.Flags(0), c.Args().First()).Store(); err != nil {
+			return cli.Exit(fmt.Errorf("cannot call %v: %v", method, err), 1)
+		}
+
+		return nil
+	}
+}
+
 func dispatchAction(c *cli.Context) error {
 	conn, err := connectBus()
 	if err != nil {
@@ -250,10 +445,18 @@ func dispatchAction(c *cli.Context) error {
 		return cli.Exit(fmt.Errorf("cannot read data: %w", err), 1)
 	}
 
-	id := uuid.New().String()
+	id := c.String("message-id")
+	if id == "" {
+		id = uuid.New().String()
+	}
 
 	obj := conn.Object("com.redhat.Yggdrasil1", "/com/redhat/Yggdrasil1")
-	if err := obj.Call("com.redhat.Yggdrasil1.Dispatch", dbus.Flags(0), c.String("worker"), id, metadata, data).Store(); err != nil {
+	if c.Bool("inbound") {
+		err = obj.Call("com.redhat.Yggdrasil1.Receive", dbus.Flags(0), c.String("worker"), id, c.String("response-to"), metadata, data).Store()
+	} else {
+		err = obj.Call("com.redhat.Yggdrasil1.Dispatch", dbus.Flags(0), c.String("worker"), id, metadata, data).Store()
+	}
+	if err != nil {
 		return cli.Exit(fmt.Errorf("cannot dispatch message: %w", err), 1)
 	}
 
@@ -262,6 +465,425 @@ func dispatchAction(c *cli.Context) error {
 	return nil
 }
 
+// deadLetterListAction is the cli action function for the "dead-letter list"
+// subcommand.
+func deadLetterListAction(c *cli.Context) error {
+	conn, err := connectBus()
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot connect to bus: %w", err), 1)
+	}
+
+	obj := conn.Object("com.redhat.Yggdrasil1", "/com/redhat/Yggdrasil1")
+	var letters []map[string]string
+	if err := obj.Call("com.redhat.Yggdrasil1.ListDeadLetters", dbus.Flags(0)).Store(&letters); err != nil {
+		return cli.Exit(fmt.Errorf("cannot list dead letters: %v", err), 1)
+	}
+
+	switch c.String("format") {
+	case "json", "yaml":
+		data, err := marshalFormat(c.String("format"), letters)
+		if err != nil {
+			return cli.Exit(fmt.Errorf("cannot marshal dead letters: %v", err), 1)
+		}
+		fmt.Print(string(data))
+	case "table":
+		writer := tabwriter.NewWriter(os.Stdout, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(writer, "MESSAGE ID\tDIRECTIVE\tTIME\tREASON\n")
+		for _, letter := range letters {
+			fmt.Fprintf(
+				writer,
+				"%v\t%v\t%v\t%v\n",
+				letter["message_id"],
+				letter["directive"],
+				letter["time"],
+				letter["reason"],
+			)
+		}
+		_ = writer.Flush()
+	default:
+		return cli.Exit(fmt.Errorf("unknown format type: %v", c.String("format")), 1)
+	}
+
+	return nil
+}
+
+// deadLetterRedriveAction is the cli action function for the "dead-letter
+// redrive" subcommand.
+func deadLetterRedriveAction(c *cli.Context) error {
+	conn, err := connectBus()
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot connect to bus: %w", err), 1)
+	}
+
+	obj := conn.Object("com.redhat.Yggdrasil1", "/com/redhat/Yggdrasil1")
+	for _, id := range c.Args().Slice() {
+		if err := obj.Call("com.redhat.Yggdrasil1.RedriveDeadLetter", dbus.Flags(0), id).Store(); err != nil {
+			return cli.Exit(fmt.Errorf("cannot redrive message %v: %v", id, err), 1)
+		}
+		fmt.Printf("Dispatched message %v\n", id)
+	}
+
+	return nil
+}
+
+func cancelAction(c *cli.Context) error {
+	conn, err := connectBus()
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot connect to bus: %w", err), 1)
+	}
+
+	obj := conn.Object("com.redhat.Yggdrasil1", "/com/redhat/Yggdrasil1")
+	if err := obj.Call("com.redhat.Yggdrasil1.Cancel", dbus.Flags(0), c.String("worker"), c.Args().First()).Store(); err != nil {
+		return cli.Exit(fmt.Errorf("cannot cancel message: %w", err), 1)
+	}
+
+	fmt.Printf("Cancelled message %v on worker %v\n", c.Args().First(), c.String("worker"))
+
+	return nil
+}
+
+// eventsAction is the cli action function for the "events" command.
+func eventsAction(c *cli.Context) error {
+	switch c.String("format") {
+	case "json", "yaml", "text":
+	default:
+		return cli.Exit(fmt.Errorf("unknown format type: %v", c.String("format")), 1)
+	}
+
+	conn, err := connectBus()
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot connect to bus: %w", err), 1)
+	}
+
+	// Subscribe before reading recent events so that no event is missed.
+	signals := make(chan *dbus.Signal)
+	if c.Bool("follow") {
+		err := conn.AddMatchSignal(
+			dbus.WithMatchObjectPath("/com/redhat/Yggdrasil1"),
+			dbus.WithMatchInterface("com.redhat.Yggdrasil1"),
+			dbus.WithMatchMember("DispatchEvent"),
+		)
+		if err != nil {
+			return cli.Exit(fmt.Errorf("cannot add match signal: %w", err), 1)
+		}
+		conn.Signal(signals)
+	}
+
+	obj := conn.Object("com.redhat.Yggdrasil1", "/com/redhat/Yggdrasil1")
+	var events []map[string]string
+	if err := obj.Call("com.redhat.Yggdrasil1.RecentDispatchEvents", dbus.Flags(0)).Store(&events); err != nil {
+		return cli.Exit(fmt.Errorf("cannot get dispatch events: %v", err), 1)
+	}
+	for _, event := range events {
+		if err := printDispatchEvent(c.String("format"), event); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+
+	if !c.Bool("follow") {
+		return nil
+	}
+	for s := range signals {
+		if s.Name != "com.redhat.Yggdrasil1.DispatchEvent" || len(s.Body) < 1 {
+			continue
+		}
+		event, ok := s.Body[0].(map[string]string)
+		if !ok {
+			return cli.Exit(fmt.Errorf("cannot cast %T as map[string]string", s.Body[0]), 1)
+		}
+		if err := printDispatchEvent(c.String("format"), event); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+	return nil
+}
+
+// printDispatchEvent prints event in format. JSON and text events are printed
+// on a single line; YAML events are printed as separate documents.
+func printDispatchEvent(format string, event map[string]string) error {
+	switch format {
+	case "json":
+		data, err := marshalFormat(format, event)
+		if err != nil {
+			return fmt.Errorf("cannot marshal event: %v", err)
+		}
+		fmt.Print(string(data))
+		return nil
+	case "yaml":
+		data, err := marshalFormat(format, event)
+		if err != nil {
+			return fmt.Errorf("cannot marshal event: %v", err)
+		}
+		fmt.Print("---\n" + string(data))
+		return nil
+	}
+	line := fmt.Sprintf("%v %v %v %v", event["time"], event["name"], event["directive"], event["message_id"])
+	if event["detail"] != "" {
+		line += ": " + event["detail"]
+	}
+	fmt.Println(line)
+	return nil
+}
+
+// disconnectAction is the cli action function for the "disconnect" command.
+func disconnectAction(c *cli.Context) error {
+	conn, err := connectBus()
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot connect to bus: %w", err), 1)
+	}
+
+	obj := conn.Object("com.redhat.Yggdrasil1", "/com/redhat/Yggdrasil1")
+	if err := obj.Call("com.redhat.Yggdrasil1.DisconnectTransport", dbus.Flags(0), c.String("reason")).Store(); err != nil {
+		return cli.Exit(fmt.Errorf("cannot disconnect: %v", err), 1)
+	}
+
+	fmt.Println("Disconnected from the server")
+
+	return nil
+}
+
+// connectAction is the cli action function for the "connect" command.
+func connectAction(c *cli.Context) error {
+	conn, err := connectBus()
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot connect to bus: %w", err), 1)
+	}
+
+	obj := conn.Object("com.redhat.Yggdrasil1", "/com/redhat/Yggdrasil1")
+	if err := obj.Call("com.redhat.Yggdrasil1.ConnectTransport", dbus.Flags(0)).Store(); err != nil {
+		return cli.Exit(fmt.Errorf("cannot connect: %v", err), 1)
+	}
+
+	fmt.Println("Connected to the server")
+
+	return nil
+}
+
+// idAction is the cli action function for the "id" command.
+func idAction(c *cli.Context) error {
+	conn, err := connectBus()
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot connect to bus: %w", err), 1)
+	}
+	obj := conn.Object("com.redhat.Yggdrasil1", "/com/redhat/Yggdrasil1")
+
+	if c.Bool("regenerate") {
+		var id string
+		if err := obj.Call("com.redhat.Yggdrasil1.RegenerateClientID", dbus.Flags(0)).Store(&id); err != nil {
+			return cli.Exit(fmt.Errorf("cannot regenerate client ID: %v", err), 1)
+		}
+		fmt.Printf("Regenerated client ID %v; restart yggd to connect with it\n", id)
+		return nil
+	}
+
+	var status map[string]string
+	if err := obj.Call("com.redhat.Yggdrasil1.Status", dbus.Flags(0)).Store(&status); err != nil {
+		return cli.Exit(fmt.Errorf("cannot get status: %v", err), 1)
+	}
+	fmt.Printf("%v (from %v)\n", status["client_id"], status["client_id_source"])
+	return nil
+}
+
+// logLevelAction is the cli action function for the "log-level" command.
+func logLevelAction(c *cli.Context) error {
+	if c.NArg() > 1 || (c.Bool("reset") && c.NArg() > 0) {
+		return cli.Exit("usage: yggctl log-level [command options] [LEVEL]", 1)
+	}
+
+	conn, err := connectBus()
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot connect to bus: %w", err), 1)
+	}
+	obj := conn.Object("com.redhat.Yggdrasil1", "/com/redhat/Yggdrasil1")
+
+	if c.NArg() == 0 && !c.Bool("reset") {
+		var status map[string]string
+		if err := obj.Call("com.redhat.Yggdrasil1.Status", dbus.Flags(0)).Store(&status); err != nil {
+			return cli.Exit(fmt.Errorf("cannot get status: %v", err), 1)
+		}
+		if status["log_level_until"] != "" {
+			fmt.Printf("%v (until %v)\n", status["log_level"], status["log_level_until"])
+		} else {
+			fmt.Println(status["log_level"])
+		}
+		return nil
+	}
+
+	duration := c.Duration("duration")
+	if duration < 0 || duration > math.MaxUint32*time.Second {
+		return cli.Exit(fmt.Errorf("invalid duration: %v", duration), 1)
+	}
+	level := c.Args().First()
+	if err := obj.Call("com.redhat.Yggdrasil1.SetLogLevel", dbus.Flags(0), level, uint32(duration.Seconds())).Store(); err != nil {
+		return cli.Exit(fmt.Errorf("cannot set log level: %v", err), 1)
+	}
+
+	switch {
+	case level == "":
+		fmt.Println("Restored the configured log level")
+	case duration > 0:
+		fmt.Printf("Set log level to %v for %v\n", level, duration)
+	default:
+		fmt.Printf("Set log level to %v\n", level)
+	}
+
+	return nil
+}
+
+// pingAction is the cli action function for the "ping" command.
+func pingAction(c *cli.Context) error {
+	conn, err := connectBus()
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot connect to bus: %w", err), 1)
+	}
+
+	obj := conn.Object("com.redhat.Yggdrasil1", "/com/redhat/Yggdrasil1")
+	timeout := uint32(c.Duration("timeout").Round(time.Second).Seconds())
+	received := uint(0)
+	for i := uint(0); i < c.Uint("count"); i++ {
+		var result map[string]string
+		if err := obj.Call("com.redhat.Yggdrasil1.Ping", dbus.Flags(0), timeout).Store(&result); err != nil {
+			return cli.Exit(fmt.Errorf("cannot ping server: %v", err), 1)
+		}
+		if result["round_trip"] == "" {
+			fmt.Printf("%v: sent in %v, no response within %v\n", result["message_id"], result["publish"], c.Duration("timeout"))
+			continue
+		}
+		received++
+		fmt.Printf("%v: sent in %v, response in %v\n", result["message_id"], result["publish"], result["round_trip"])
+	}
+
+	fmt.Printf("%v pings sent, %v responses received\n", c.Uint("count"), received)
+	if received == 0 {
+		return cli.Exit("", 1)
+	}
+
+	return nil
+}
+
+// factsAction is the cli action function for the "facts" command.
+func factsAction(c *cli.Context) error {
+	conn, err := connectBus()
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot connect to bus: %w", err), 1)
+	}
+
+	obj := conn.Object("com.redhat.Yggdrasil1", "/com/redhat/Yggdrasil1")
+	var data string
+	if err := obj.Call("com.redhat.Yggdrasil1.CanonicalFacts", dbus.Flags(0)).Store(&data); err != nil {
+		return cli.Exit(fmt.Errorf("cannot get facts: %v", err), 1)
+	}
+	var facts map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &facts); err != nil {
+		return cli.Exit(fmt.Errorf("cannot unmarshal facts: %v", err), 1)
+	}
+
+	switch c.String("format") {
+	case "json", "yaml":
+		data, err := marshalFormat(c.String("format"), facts)
+		if err != nil {
+			return cli.Exit(fmt.Errorf("cannot marshal facts: %v", err), 1)
+		}
+		fmt.Print(string(data))
+	case "table":
+		names := make([]string, 0, len(facts))
+		for name := range facts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		writer := tabwriter.NewWriter(os.Stdout, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(writer, "FACT\tVALUE\n")
+		for _, name := range names {
+			value, ok := facts[name].(string)
+			if !ok {
+				data, err := json.Marshal(facts[name])
+				if err != nil {
+					return cli.Exit(fmt.Errorf("cannot marshal fact: %v", err), 1)
+				}
+				value = string(data)
+			}
+			fmt.Fprintf(writer, "%v\t%v\n", name, value)
+		}
+		_ = writer.Flush()
+	default:
+		return cli.Exit(fmt.Errorf("unknown format type: %v", c.String("format")), 1)
+	}
+
+	return nil
+}
+
+// parseTimeFlag parses value, either a time in RFC 3339 format or a duration
+// before now, returning the time in RFC 3339 format. An empty value is
+// returned unchanged.
+func parseTimeFlag(value string, now time.Time) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Format(time.RFC3339), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid time '%v': must be a time in RFC 3339 format or a duration", value)
+	}
+	return now.Add(-d).Format(time.RFC3339), nil
+}
+
+// historyAction is the cli action function for the "history" command.
+func historyAction(c *cli.Context) error {
+	now := time.Now()
+	since, err := parseTimeFlag(c.String("since"), now)
+	if err != nil {
+		return cli.Exit(err, 1)
+	}
+	until, err := parseTimeFlag(c.String("until"), now)
+	if err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	conn, err := connectBus()
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot connect to bus: %w", err), 1)
+	}
+
+	obj := conn.Object("com.redhat.Yggdrasil1", "/com/redhat/Yggdrasil1")
+	var messages []map[string]string
+	args := []interface{}{c.String("message-id"), c.String("directive"), since, until}
+	if err := obj.Call("com.redhat.Yggdrasil1.MessageHistory", dbus.Flags(0), args...).Store(&messages); err != nil {
+		return cli.Exit(fmt.Errorf("cannot get message history: %v", err), 1)
+	}
+
+	switch c.String("format") {
+	case "json", "yaml":
+		data, err := marshalFormat(c.String("format"), messages)
+		if err != nil {
+			return cli.Exit(fmt.Errorf("cannot marshal message history: %v", err), 1)
+		}
+		fmt.Print(string(data))
+	case "table":
+		writer := tabwriter.NewWriter(os.Stdout, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(writer, "TIME\tMESSAGE ID\tDIRECTIVE\tOUTCOME\tSIZE\tDETAIL\n")
+		for _, m := range messages {
+			fmt.Fprintf(
+				writer,
+				"%v\t%v\t%v\t%v\t%v\t%v\n",
+				m["time"],
+				m["message_id"],
+				m["directive"],
+				m["outcome"],
+				m["size"],
+				m["detail"],
+			)
+		}
+		_ = writer.Flush()
+	default:
+		return cli.Exit(fmt.Errorf("unknown format type: %v", c.String("format")), 1)
+	}
+
+	return nil
+}
+
 func listenAction(ctx *cli.Context) error {
 	conn, err := connectBus()
 	if err != nil {
@@ -318,20 +940,165 @@ func listenAction(ctx *cli.Context) error {
 	return nil
 }
 
+// workerData holds the values used to render the data files generated by the
+// "generate worker-data" subcommand.
+type workerData struct {
+	User    string
+	Group   string
+	Name    string
+	Program string
+
+	// UserUnit is true if the worker runs as a systemd user unit in the
+	// session of the user installing it. User and Group are then omitted and
+	// the unit is started with the user's session.
+	UserUnit bool
+
+	// EnvironmentFile is the path to the environment file written by yggd,
+	// providing YGG_* variables that may be referenced in Program.
+	EnvironmentFile string
+
+	// WorkingDirectory, UMask and Nice set the process environment the worker
+	// is started in. Empty values are omitted, leaving the systemd defaults
+	// (the root directory, 0022 and 0) in place.
+	WorkingDirectory string
+	UMask            string
+	Nice             string
+
+	// Restart, RestartDelay, RestartMaxDelay, RestartSteps, RestartWindow and
+	// MaxRestarts make up the restart policy of the worker's systemd service
+	// unit. Empty values are omitted from the unit, leaving the systemd
+	// defaults in place. RestartMaxDelay and RestartSteps need systemd 254 or
+	// later; older versions log a warning and ignore them.
+	Restart         string
+	RestartDelay    string
+	RestartMaxDelay string
+	RestartSteps    uint
+	RestartWindow   string
+	MaxRestarts     uint
+
+	// StopTimeout is the grace period the worker is given to exit after
+	// receiving SIGTERM before it is killed.
+	StopTimeout string
+
+	// LogFile is the path to a file the worker's output is appended to
+	// instead of the journal. LogsDirectory is the directory of LogFile
+	// relative to the directory systemd creates logs directories in, or empty
+	// if it lies outside it. LogMaxSize and LogRotate control how the file is
+	// rotated by logrotate, which is not configured for user units.
+	LogFile       string
+	LogsDirectory string
+	LogMaxSize    string
+	LogRotate     uint
+
+	// Credentials and EncryptedCredentials are systemd credentials, in the
+	// form ID[:PATH], passed to the worker. The worker reads them from
+	// $CREDENTIALS_DIRECTORY rather than from its environment or command line.
+	Credentials          []string
+	EncryptedCredentials []string
+}
+
+// restartPolicy converts a worker restart policy into the equivalent systemd
+// Restart= value.
+func restartPolicy(policy string) string {
+	if policy == "never" {
+		return "no"
+	}
+	return policy
+}
+
+// systemdTimeSpan formats d as a systemd time span in seconds. A zero duration
+// is formatted as an empty string.
+func systemdTimeSpan(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// podmanCommand returns a command line that runs image as the worker described
+// by worker in a podman container. The bus yggd runs on is made available in
+// the container so the worker can claim its name and reach the dispatcher: the
+// system bus socket for a system unit, the user's bus socket for a user unit,
+// or the host network, which holds the abstract socket of the bus of the yggd
+// instance, if instance is set. The YGG_* variables the unit loads from the
+// worker environment file and the worker's credentials are passed on to the
+// container.
+func podmanCommand(worker workerData, image string, instance string) string {
+	args := []string{
+		"/usr/bin/podman", "run",
+		"--rm",
+		"--replace",
+		"--name", "ygg-worker-" + worker.Name,
+		"--env", "YGG_*",
+	}
+	switch {
+	case instance != "":
+		args = append(args,
+			"--network", "host",
+			"--env", "DBUS_SESSION_BUS_ADDRESS="+config.InstanceBusAddress(instance),
+		)
+	case worker.UserUnit:
+		args = append(args,
+			"--volume", "%t/bus:%t/bus",
+			"--env", "DBUS_SESSION_BUS_ADDRESS=unix:path=%t/bus",
+		)
+	default:
+		args = append(args, "--volume", "/run/dbus/system_bus_socket:/run/dbus/system_bus_socket")
+	}
+	if len(worker.Credentials) > 0 || len(worker.EncryptedCredentials) > 0 {
+		args = append(args,
+			"--volume", "%d:%d:ro",
+			"--env", "CREDENTIALS_DIRECTORY",
+		)
+	}
+	return strings.Join(append(args, image), " ")
+}
+
 // generateWorkerDataAction is the cli action function for the "generate
 // worker-data" subcommand. It formats and outputs files needed by workers to
 // communicate with the yggdrasil service over D-Bus.
 func generateWorkerDataAction(ctx *cli.Context) error {
-	config := struct {
-		User    string
-		Group   string
-		Name    string
-		Program string
-	}{
-		User:    ctx.String("user"),
-		Group:   ctx.String("group"),
-		Name:    ctx.String("name"),
-		Program: ctx.String("program"),
+	config := workerData{
+		EnvironmentFile: filepath.Join(constants.RuntimeDir, "worker.env"),
+		User:            ctx.String("user"),
+		Group:           ctx.String("group"),
+		Name:            ctx.String("name"),
+		Program:         ctx.String("program"),
+		UserUnit:        ctx.Bool("install") && os.Geteuid() > 0,
+		Restart:         restartPolicy(ctx.String("restart")),
+		RestartDelay:    systemdTimeSpan(ctx.Duration("restart-delay")),
+		RestartMaxDelay: systemdTimeSpan(ctx.Duration("restart-max-delay")),
+		RestartWindow:   systemdTimeSpan(ctx.Duration("restart-window")),
+		MaxRestarts:     ctx.Uint("max-restarts"),
+		RestartSteps:    ctx.Uint("restart-steps"),
+		StopTimeout:     systemdTimeSpan(ctx.Duration("stop-timeout")),
+		LogMaxSize:      ctx.String("log-max-size"),
+		LogRotate:       ctx.Uint("log-rotate"),
+
+		WorkingDirectory: ctx.Path("working-directory"),
+		UMask:            ctx.String("umask"),
+
+		Credentials:          ctx.StringSlice("credential"),
+		EncryptedCredentials: ctx.StringSlice("encrypted-credential"),
+	}
+
+	if ctx.IsSet("nice") {
+		config.Nice = strconv.Itoa(ctx.Int("nice"))
+	}
+
+	if ctx.String("image") != "" {
+		config.Program = podmanCommand(config, ctx.String("image"), ctx.String("instance"))
+	}
+
+	if ctx.Bool("log-file") {
+		logDir := filepath.Join(constants.LogDir, "workers")
+		config.LogFile = filepath.Join(logDir, config.Name+".log")
+		config.LogsDirectory = logsDirectory(logDir, config.UserUnit)
+		if config.LogsDirectory == "" && ctx.Bool("install") {
+			if err := os.MkdirAll(logDir, 0755); err != nil {
+				return cli.Exit(fmt.Errorf("cannot create log directory: %w", err), 1)
+			}
+		}
 	}
 
 	// If "Group" is unspecified, assume it matches the user.
@@ -339,12 +1106,63 @@ func generateWorkerDataAction(ctx *cli.Context) error {
 		config.Group = config.User
 	}
 
-	if err := os.MkdirAll(ctx.Path("output"), 0755); err != nil {
-		return cli.Exit(
-			fmt.Errorf("error: cannot create output directory %v: %v", ctx.Path("output"), err),
-			1,
-		)
+	if _, err := writeWorkerData(config, ctx.Path("output"), ctx.Bool("install")); err != nil {
+		return cli.Exit(fmt.Errorf("error: %v", err), 1)
+	}
+
+	return nil
+}
+
+// logsDirectory returns the value of LogsDirectory= that has systemd create
+// dir for a unit, or an empty string if dir lies outside the directory systemd
+// creates logs directories in: /var/log for system units and
+// $XDG_STATE_HOME/log for user units.
+func logsDirectory(dir string, userUnit bool) string {
+	root := filepath.Join("/", "var", "log")
+	if userUnit {
+		root = filepath.Join(xdg.StateHome, "log")
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
 	}
+	return rel
+}
+
+// workerDataFile describes a data file generated for a worker.
+type workerDataFile struct {
+	FilePath string
+	Template *template.Template
+}
+
+// workerDataDirs are the directories the data files of a worker are placed
+// in. An empty directory means the file is not needed.
+type workerDataDirs struct {
+	DBusServices string
+	DBusPolicy   string
+	SystemdUnits string
+	Logrotate    string
+}
+
+// installDirs returns the directories worker data files are installed in:
+// the system directories, or for a non-root user, the directories of the
+// user's session bus and service manager, which need no D-Bus policy or
+// logrotate configuration.
+func installDirs() workerDataDirs {
+	return workerDataDirs{
+		DBusServices: constants.DBusSystemServicesDir,
+		DBusPolicy:   constants.DBusPolicyConfigDir,
+		SystemdUnits: constants.SystemdSystemServicesDir,
+		Logrotate:    constants.LogrotateConfigDir,
+	}
+}
+
+// workerDataFiles returns the data files needed by the named worker. If
+// install is true, the files are placed in the directories returned by
+// installDirs, leaving out those without a directory for the current user.
+// Otherwise they are placed in subdirectories of outputDir.
+func workerDataFiles(name string, outputDir string, install bool, logFile bool) []workerDataFile {
+	dirs := installDirs()
 
 	joinpath := func(outputDir, installDir string, name string, install bool) string {
 		if install {
@@ -354,61 +1172,81 @@ func generateWorkerDataAction(ctx *cli.Context) error {
 		}
 	}
 
-	data := []struct {
-		FileName string
-		FilePath string
-		Template *template.Template
-	}{
+	data := []workerDataFile{
 		{
-			FileName: fmt.Sprintf("com.redhat.Yggdrasil1.Worker1.%v.service", config.Name),
 			FilePath: joinpath(
-				filepath.Join(ctx.Path("output"), "dbus-1", "system-services"),
-				constants.DBusSystemServicesDir,
-				fmt.Sprintf("com.redhat.Yggdrasil1.Worker1.%v.service", config.Name),
-				ctx.Bool("install"),
+				filepath.Join(outputDir, "dbus-1", "system-services"),
+				dirs.DBusServices,
+				fmt.Sprintf("com.redhat.Yggdrasil1.Worker1.%v.service", name),
+				install,
 			),
 			Template: template.Must(template.New("").Parse(DBusServiceTemplate)),
 		},
 		{
-			FileName: fmt.Sprintf("com.redhat.Yggdrasil1.Worker1.%v.conf", config.Name),
 			FilePath: joinpath(
-				filepath.Join(ctx.Path("output"), "dbus-1", "system.d"),
-				constants.DBusPolicyConfigDir,
-				fmt.Sprintf("com.redhat.Yggdrasil1.Worker1.%v.conf", config.Name),
-				ctx.Bool("install"),
+				filepath.Join(outputDir, "systemd", "system"),
+				dirs.SystemdUnits,
+				fmt.Sprintf("com.redhat.Yggdrasil1.Worker1.%v.service", name),
+				install,
 			),
-			Template: template.Must(template.New("").Parse(DBusPolicyConfigTemplate)),
+			Template: template.Must(template.New("").Parse(SystemdServiceTemplate)),
 		},
-		{
-			FileName: fmt.Sprintf("com.redhat.Yggdrasil1.Worker1.%v.service", config.Name),
+	}
+
+	if !install || dirs.DBusPolicy != "" {
+		data = append(data, workerDataFile{
 			FilePath: joinpath(
-				filepath.Join(ctx.Path("output"), "systemd", "system"),
-				constants.SystemdSystemServicesDir,
-				fmt.Sprintf("com.redhat.Yggdrasil1.Worker1.%v.service", config.Name),
-				ctx.Bool("install"),
+				filepath.Join(outputDir, "dbus-1", "system.d"),
+				dirs.DBusPolicy,
+				fmt.Sprintf("com.redhat.Yggdrasil1.Worker1.%v.conf", name),
+				install,
 			),
-			Template: template.Must(template.New("").Parse(SystemdServiceTemplate)),
-		},
+			Template: template.Must(template.New("").Parse(DBusPolicyConfigTemplate)),
+		})
+	}
+
+	if logFile && (!install || dirs.Logrotate != "") {
+		data = append(data, workerDataFile{
+			FilePath: joinpath(
+				filepath.Join(outputDir, "logrotate.d"),
+				dirs.Logrotate,
+				fmt.Sprintf("com.redhat.Yggdrasil1.Worker1.%v", name),
+				install,
+			),
+			Template: template.Must(template.New("").Parse(LogrotateConfigTemplate)),
+		})
+	}
+
+	return data
+}
+
+// writeWorkerData renders the data files needed by the worker described by
+// config, returning the paths of the files written.
+func writeWorkerData(config workerData, outputDir string, install bool) ([]string, error) {
+	if !install {
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			return nil, fmt.Errorf("cannot create output directory %v: %v", outputDir, err)
+		}
 	}
 
-	for _, d := range data {
+	var paths []string
+	// User units have no logrotate configuration directory.
+	for _, d := range workerDataFiles(config.Name, outputDir, install, config.LogFile != "" && !config.UserUnit) {
 		if err := os.MkdirAll(filepath.Dir(d.FilePath), 0755); err != nil {
-			return cli.Exit(
-				fmt.Errorf("cannot create directory %v: %v", filepath.Dir(d.FilePath), err),
-				1,
-			)
+			return paths, fmt.Errorf("cannot create directory %v: %v", filepath.Dir(d.FilePath), err)
 		}
 		f, err := os.Create(d.FilePath)
 		if err != nil {
-			return cli.Exit(fmt.Errorf("cannot create file %v: %v", d.FilePath, err), 1)
+			return paths, fmt.Errorf("cannot create file %v: %v", d.FilePath, err)
 		}
 		defer f.Close()
+		paths = append(paths, d.FilePath)
 		if err := d.Template.Execute(f, config); err != nil {
-			return cli.Exit(fmt.Errorf("cannot write file %v: %v", d.FilePath, err), 1)
+			return paths, fmt.Errorf("cannot write file %v: %v", d.FilePath, err)
 		}
 	}
 
-	return nil
+	return paths, nil
 }
 
 func generateMessage(
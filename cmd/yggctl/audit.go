@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/redhatinsights/yggdrasil/internal/audit"
+	"github.com/redhatinsights/yggdrasil/internal/constants"
+	"github.com/urfave/cli/v2"
+)
+
+// auditVerifyAction is the cli action function for the "audit verify"
+// subcommand.
+func auditVerifyAction(ctx *cli.Context) error {
+	path := ctx.Path("file")
+	if path == "" {
+		path = filepath.Join(constants.StateDir, audit.FileName)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot open audit log: %w", err), 1)
+	}
+	defer file.Close()
+
+	n, err := audit.Verify(file)
+	if err != nil {
+		return cli.Exit(fmt.Errorf("%v: %w", path, err), 1)
+	}
+	fmt.Printf("%v: %v records verified\n", path, n)
+	return nil
+}
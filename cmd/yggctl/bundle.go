@@ -0,0 +1,346 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/pelletier/go-toml"
+	"github.com/redhatinsights/yggdrasil/internal/constants"
+	"github.com/urfave/cli/v2"
+)
+
+// bundleManifest describes the worker contained in a worker bundle. A worker
+// bundle is a gzip-compressed tar archive containing a "manifest.toml" file
+// and the worker program.
+type bundleManifest struct {
+	// Name is the worker's directive name.
+	Name string `toml:"name"`
+
+	// Program is the file name of the worker program within the bundle. Once
+	// installed, it is the absolute path to the installed program.
+	Program string `toml:"program"`
+
+	// User and Group are the user and group the worker runs as.
+	User  string `toml:"user"`
+	Group string `toml:"group"`
+}
+
+// validate checks that the manifest values are acceptable.
+func (m *bundleManifest) validate() error {
+	if !regexp.MustCompile("^[a-zA-Z0-9_]+$").MatchString(m.Name) {
+		return fmt.Errorf("invalid worker name '%v'", m.Name)
+	}
+	if m.Program == "" || path.Base(m.Program) != m.Program {
+		return fmt.Errorf("invalid program '%v'", m.Program)
+	}
+	re := regexp.MustCompile("^[a-z][a-z0-9_]{0,31}$")
+	if !re.MatchString(m.User) {
+		return fmt.Errorf("'user' must be a valid UNIX identifier")
+	}
+	if m.Group != "" && !re.MatchString(m.Group) {
+		return fmt.Errorf("'group' must be a valid UNIX identifier")
+	}
+	return nil
+}
+
+// readBundle reads a worker bundle from r, returning its manifest and the
+// content of the worker program.
+func readBundle(r io.Reader) (*bundleManifest, []byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot decompress bundle: %w", err)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot read bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(hdr.Name)
+		if path.Base(name) != name {
+			return nil, nil, fmt.Errorf("unexpected file '%v' in bundle", hdr.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot read file '%v' from bundle: %w", hdr.Name, err)
+		}
+		files[name] = data
+	}
+
+	data, has := files["manifest.toml"]
+	if !has {
+		return nil, nil, fmt.Errorf("bundle does not contain manifest.toml")
+	}
+	var manifest bundleManifest
+	if err := toml.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("cannot parse manifest: %w", err)
+	}
+	if err := manifest.validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	program, has := files[manifest.Program]
+	if !has {
+		return nil, nil, fmt.Errorf("bundle does not contain program '%v'", manifest.Program)
+	}
+
+	return &manifest, program, nil
+}
+
+// withinDir returns true if path names dir or a file below it.
+func withinDir(path, dir string) bool {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// installedManifestPath returns the path to the manifest recorded for an
+// installed worker.
+func installedManifestPath(name string) string {
+	return filepath.Join(constants.StateDir, "workers", name+".toml")
+}
+
+// workersInstallAction is the cli action function for the "workers install"
+// subcommand. It installs the worker program and data files from a worker
+// bundle and asks the bus to activate the new worker.
+func workersInstallAction(ctx *cli.Context) error {
+	f, err := os.Open(ctx.Args().First())
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot open bundle: %w", err), 1)
+	}
+	defer f.Close()
+
+	manifest, program, err := readBundle(f)
+	if err != nil {
+		return cli.Exit(err, 1)
+	}
+	if _, err := os.Stat(installedManifestPath(manifest.Name)); err == nil {
+		return cli.Exit(fmt.Errorf("worker '%v' is already installed", manifest.Name), 1)
+	}
+
+	activate := func() error {
+		conn, err := connectBus()
+		if err != nil {
+			return fmt.Errorf("cannot connect to bus: %w", err)
+		}
+		if err := reloadServiceManagers(conn); err != nil {
+			return err
+		}
+		var reply uint32
+		if err := conn.BusObject().Call("org.freedesktop.DBus.StartServiceByName", 0, "com.redhat.Yggdrasil1.Worker1."+manifest.Name, uint32(0)).Store(&reply); err != nil {
+			return fmt.Errorf("cannot start worker: %w", err)
+		}
+		return nil
+	}
+	if err := installBundle(manifest, program, activate); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	fmt.Printf("Installed worker %v\n", manifest.Name)
+
+	return nil
+}
+
+// installBundle installs program and the data files of the worker described
+// by manifest, records the installed worker and calls activate to start it. If
+// any step fails, the files written so far are removed again, so that a failed
+// installation can be retried.
+func installBundle(manifest *bundleManifest, program []byte, activate func() error) (err error) {
+	var written []string
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, file := range written {
+			if rerr := os.Remove(file); rerr != nil && !os.IsNotExist(rerr) {
+				err = fmt.Errorf("%w; cannot remove file %v: %v", err, file, rerr)
+			}
+		}
+	}()
+
+	manifest.Program = filepath.Join(constants.WorkerExecDir, manifest.Program)
+	if err := os.MkdirAll(constants.WorkerExecDir, 0755); err != nil {
+		return fmt.Errorf("cannot create directory: %w", err)
+	}
+	written = append(written, manifest.Program)
+	if err := os.WriteFile(manifest.Program, program, 0755); err != nil {
+		return fmt.Errorf("cannot install program: %w", err)
+	}
+
+	config := workerData{
+		EnvironmentFile: filepath.Join(constants.RuntimeDir, "worker.env"),
+		User:            manifest.User,
+		Group:           manifest.Group,
+		Name:            manifest.Name,
+		Program:         manifest.Program,
+		UserUnit:        os.Geteuid() > 0,
+	}
+	if config.Group == "" {
+		config.Group = config.User
+	}
+	paths, err := writeWorkerData(config, "", true)
+	written = append(written, paths...)
+	if err != nil {
+		return fmt.Errorf("cannot install worker data: %w", err)
+	}
+
+	data, err := toml.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("cannot marshal manifest: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(installedManifestPath(manifest.Name)), 0755); err != nil {
+		return fmt.Errorf("cannot create directory: %w", err)
+	}
+	written = append(written, installedManifestPath(manifest.Name))
+	if err := os.WriteFile(installedManifestPath(manifest.Name), data, 0644); err != nil {
+		return fmt.Errorf("cannot record installed worker: %w", err)
+	}
+
+	return activate()
+}
+
+// workersRemoveAction is the cli action function for the "workers remove"
+// subcommand. It stops a worker installed from a bundle and removes its
+// program and data files.
+func workersRemoveAction(ctx *cli.Context) error {
+	name := ctx.Args().First()
+
+	data, err := os.ReadFile(installedManifestPath(name))
+	if err != nil {
+		return cli.Exit(fmt.Errorf("worker '%v' was not installed from a bundle: %w", name, err), 1)
+	}
+	var manifest bundleManifest
+	if err := toml.Unmarshal(data, &manifest); err != nil {
+		return cli.Exit(fmt.Errorf("cannot parse manifest: %w", err), 1)
+	}
+
+	conn, err := connectBus()
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot connect to bus: %w", err), 1)
+	}
+	var job dbus.ObjectPath
+	if err := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1").Call("org.freedesktop.systemd1.Manager.StopUnit", 0, "com.redhat.Yggdrasil1.Worker1."+name+".service", "replace").Store(&job); err != nil {
+		return cli.Exit(fmt.Errorf("cannot stop worker: %w", err), 1)
+	}
+
+	files := []string{manifest.Program, installedManifestPath(name)}
+	for _, d := range workerDataFiles(name, "", true, true) {
+		files = append(files, d.FilePath)
+	}
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return cli.Exit(fmt.Errorf("cannot remove file %v: %w", file, err), 1)
+		}
+	}
+
+	if err := reloadServiceManagers(conn); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	fmt.Printf("Removed worker %v\n", name)
+
+	return nil
+}
+
+// reloadServiceManagers asks the bus and systemd to reload their
+// configuration so that added or removed worker data files take effect.
+func reloadServiceManagers(conn *dbus.Conn) error {
+	if err := conn.BusObject().Call("org.freedesktop.DBus.ReloadConfig", 0).Store(); err != nil {
+		return fmt.Errorf("cannot reload bus configuration: %w", err)
+	}
+	if err := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1").Call("org.freedesktop.systemd1.Manager.Reload", 0).Store(); err != nil {
+		return fmt.Errorf("cannot reload systemd configuration: %w", err)
+	}
+	return nil
+}
+
+// renderWorkerConfig renders the commented TOML worker configuration for
+// manifest, checking that the result parses back into a valid bundle manifest.
+// The program is recorded by its file name, as it is packed in a bundle.
+func renderWorkerConfig(manifest bundleManifest) ([]byte, error) {
+	manifest.Program = filepath.Base(manifest.Program)
+
+	var buf bytes.Buffer
+	tmpl := template.Must(template.New("").Parse(WorkerConfigTemplate))
+	if err := tmpl.Execute(&buf, manifest); err != nil {
+		return nil, fmt.Errorf("cannot render worker config: %w", err)
+	}
+
+	var parsed bundleManifest
+	if err := toml.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		return nil, fmt.Errorf("cannot parse worker config: %w", err)
+	}
+	if err := parsed.validate(); err != nil {
+		return nil, fmt.Errorf("invalid worker config: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// generateWorkerConfigAction is the cli action function for the "generate
+// worker-config" subcommand. It prints a worker configuration to stdout, or
+// writes it to the file given by --output.
+func generateWorkerConfigAction(ctx *cli.Context) error {
+	manifest := bundleManifest{
+		Name:    ctx.String("name"),
+		Program: ctx.Path("exec"),
+		User:    ctx.String("user"),
+		Group:   ctx.String("group"),
+	}
+
+	data, err := renderWorkerConfig(manifest)
+	if err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	output := ctx.Path("output")
+	if output == "" || output == "-" {
+		fmt.Print(string(data))
+		return nil
+	}
+	// Files in the workers state directory record installed bundles; a
+	// generated configuration written there would look like an installed
+	// worker to "workers install" and "workers remove".
+	if withinDir(output, filepath.Join(constants.StateDir, "workers")) {
+		return cli.Exit(fmt.Errorf("cannot write worker config to '%v': the directory records installed workers", output), 1)
+	}
+	if _, err := os.Stat(output); err == nil && !ctx.Bool("force") {
+		return cli.Exit(fmt.Errorf("file '%v' already exists", output), 1)
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return cli.Exit(fmt.Errorf("cannot create directory: %w", err), 1)
+	}
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		return cli.Exit(fmt.Errorf("cannot write worker config: %w", err), 1)
+	}
+
+	fmt.Printf("Wrote %v\n", output)
+
+	return nil
+}
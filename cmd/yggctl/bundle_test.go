@@ -0,0 +1,361 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pelletier/go-toml"
+	"github.com/redhatinsights/yggdrasil/internal/constants"
+)
+
+func makeBundle(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0755,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadBundle(t *testing.T) {
+	tests := []struct {
+		description string
+		input       map[string]string
+		want        *bundleManifest
+		wantProgram string
+		wantError   bool
+	}{
+		{
+			description: "valid",
+			input: map[string]string{
+				"manifest.toml": "name = \"echo\"\nprogram = \"echo-worker\"\nuser = \"worker\"\n",
+				"./echo-worker": "#!/bin/sh\n",
+			},
+			want: &bundleManifest{
+				Name:    "echo",
+				Program: "echo-worker",
+				User:    "worker",
+			},
+			wantProgram: "#!/bin/sh\n",
+		},
+		{
+			description: "missing manifest",
+			input: map[string]string{
+				"echo-worker": "#!/bin/sh\n",
+			},
+			wantError: true,
+		},
+		{
+			description: "missing program",
+			input: map[string]string{
+				"manifest.toml": "name = \"echo\"\nprogram = \"echo-worker\"\nuser = \"worker\"\n",
+			},
+			wantError: true,
+		},
+		{
+			description: "invalid name",
+			input: map[string]string{
+				"manifest.toml": "name = \"echo-1\"\nprogram = \"echo-worker\"\nuser = \"worker\"\n",
+				"echo-worker":   "#!/bin/sh\n",
+			},
+			wantError: true,
+		},
+		{
+			description: "path traversal",
+			input: map[string]string{
+				"manifest.toml":     "name = \"echo\"\nprogram = \"echo-worker\"\nuser = \"worker\"\n",
+				"../../echo-worker": "#!/bin/sh\n",
+			},
+			wantError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got, program, err := readBundle(bytes.NewReader(makeBundle(t, test.input)))
+
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !cmp.Equal(got, test.want) {
+					t.Errorf("%#v != %#v", got, test.want)
+				}
+				if string(program) != test.wantProgram {
+					t.Errorf("%q != %q", program, test.wantProgram)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderWorkerConfig(t *testing.T) {
+	tests := []struct {
+		description string
+		input       bundleManifest
+		want        bundleManifest
+		wantError   bool
+	}{
+		{
+			description: "required fields",
+			input: bundleManifest{
+				Name:    "foo",
+				Program: "/usr/libexec/foo",
+				User:    "foo",
+			},
+			want: bundleManifest{
+				Name:    "foo",
+				Program: "foo",
+				User:    "foo",
+			},
+		},
+		{
+			description: "with group",
+			input: bundleManifest{
+				Name:    "foo",
+				Program: "/usr/libexec/foo",
+				User:    "foo",
+				Group:   "bar",
+			},
+			want: bundleManifest{
+				Name:    "foo",
+				Program: "foo",
+				User:    "foo",
+				Group:   "bar",
+			},
+		},
+		{
+			description: "relative program",
+			input: bundleManifest{
+				Name:    "foo",
+				Program: "build/foo",
+				User:    "foo",
+			},
+			want: bundleManifest{
+				Name:    "foo",
+				Program: "foo",
+				User:    "foo",
+			},
+		},
+		{
+			description: "invalid name",
+			input: bundleManifest{
+				Name:    "foo-1",
+				Program: "/usr/libexec/foo",
+				User:    "foo",
+			},
+			wantError: true,
+		},
+		{
+			description: "quoted value",
+			input: bundleManifest{
+				Name:    "foo",
+				Program: "/usr/libexec/\"foo",
+				User:    "foo",
+			},
+			wantError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			data, err := renderWorkerConfig(test.input)
+
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got bundleManifest
+				if err := toml.Unmarshal(data, &got); err != nil {
+					t.Fatal(err)
+				}
+				if !cmp.Equal(got, test.want) {
+					t.Errorf("%#v != %#v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderWorkerConfigBundle(t *testing.T) {
+	data, err := renderWorkerConfig(bundleManifest{
+		Name:    "echo",
+		Program: "/tmp/build/echo-worker",
+		User:    "worker",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := makeBundle(t, map[string]string{
+		"manifest.toml": string(data),
+		"echo-worker":   "#!/bin/sh\n",
+	})
+	got, program, err := readBundle(bytes.NewReader(bundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &bundleManifest{Name: "echo", Program: "echo-worker", User: "worker"}
+	if !cmp.Equal(got, want) {
+		t.Errorf("%#v != %#v", got, want)
+	}
+	if string(program) != "#!/bin/sh\n" {
+		t.Errorf("%q != %q", program, "#!/bin/sh\n")
+	}
+}
+
+func TestInstallBundle(t *testing.T) {
+	tests := []struct {
+		description string
+		setup       func(t *testing.T, dir string)
+		activate    error
+		wantError   bool
+	}{
+		{
+			description: "installed",
+		},
+		{
+			description: "activation fails",
+			activate:    errors.New("cannot start worker"),
+			wantError:   true,
+		},
+		{
+			description: "writing the systemd unit fails",
+			setup: func(t *testing.T, dir string) {
+				// A file in place of the units directory cannot be written to.
+				if err := os.WriteFile(filepath.Join(dir, "units"), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, d := range []struct {
+				value *string
+				path  string
+			}{
+				{&constants.WorkerExecDir, "libexec"},
+				{&constants.StateDir, "state"},
+				{&constants.RuntimeDir, "run"},
+				{&constants.DBusSystemServicesDir, "services"},
+				{&constants.DBusPolicyConfigDir, "policy"},
+				{&constants.SystemdSystemServicesDir, "units"},
+			} {
+				saved := *d.value
+				t.Cleanup(func() { *d.value = saved })
+				*d.value = filepath.Join(dir, d.path)
+			}
+			if test.setup != nil {
+				test.setup(t, dir)
+			}
+
+			manifest := &bundleManifest{Name: "echo", Program: "echo-worker", User: "worker"}
+			err := installBundle(manifest, []byte("#!/bin/sh\n"), func() error { return test.activate })
+
+			var files []string
+			if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+				if err == nil && d.Type().IsRegular() && path != filepath.Join(dir, "units") {
+					files = append(files, path)
+				}
+				return err
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+				if len(files) != 0 {
+					t.Errorf("files left behind: %v", files)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(files) != 5 {
+					t.Errorf("got %v files, want 5: %v", len(files), files)
+				}
+			}
+		})
+	}
+}
+
+func TestWithinDir(t *testing.T) {
+	tests := []struct {
+		description string
+		path        string
+		want        bool
+	}{
+		{
+			description: "file in directory",
+			path:        "/var/lib/yggdrasil/workers/foo.toml",
+			want:        true,
+		},
+		{
+			description: "directory itself",
+			path:        "/var/lib/yggdrasil/workers",
+			want:        true,
+		},
+		{
+			description: "unclean path",
+			path:        "/var/lib/yggdrasil/tmp/../workers/foo.toml",
+			want:        true,
+		},
+		{
+			description: "sibling with common prefix",
+			path:        "/var/lib/yggdrasil/workers-old/foo.toml",
+			want:        false,
+		},
+		{
+			description: "outside",
+			path:        "/tmp/foo.toml",
+			want:        false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if got := withinDir(test.path, "/var/lib/yggdrasil/workers"); got != test.want {
+				t.Errorf("%v != %v", got, test.want)
+			}
+		})
+	}
+}
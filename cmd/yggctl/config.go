@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/redhatinsights/yggdrasil/internal/clientid"
+	"github.com/redhatinsights/yggdrasil/internal/config"
+	"github.com/redhatinsights/yggdrasil/internal/constants"
+	"github.com/redhatinsights/yggdrasil/internal/http"
+	"github.com/redhatinsights/yggdrasil/internal/logging"
+	"github.com/redhatinsights/yggdrasil/internal/signature"
+	"github.com/redhatinsights/yggdrasil/internal/work"
+	"github.com/urfave/cli/v2"
+)
+
+// validateConfigFile loads the yggd configuration file at path, along with its
+// drop-in fragments, and returns the problems found, each prefixed with the
+// file it was found in; an empty list means the configuration is valid.
+func validateConfigFile(path string) []string {
+	problems, err := config.CheckFiles(path)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	conf, err := config.LoadFile(path)
+	if err != nil {
+		return problems
+	}
+	if conf.DispatchOverflow != "" {
+		if err := work.ValidateOverflowPolicy(conf.DispatchOverflow); err != nil {
+			problems = append(problems, fmt.Sprintf("%v: %v: %v", path, config.FlagNameDispatchOverflow, err))
+		}
+	}
+	if len(conf.ClientIDSources) > 0 {
+		if err := clientid.ValidateSources(conf.ClientIDSources); err != nil {
+			problems = append(problems, fmt.Sprintf("%v: %v: %v", path, config.FlagNameClientIDSources, err))
+		}
+	}
+	if _, err := signature.Load(conf.TrustedKeys); err != nil {
+		problems = append(problems, fmt.Sprintf("%v: %v: %v", path, config.FlagNameTrustedKeys, err))
+	} else if conf.RequireSignatures && len(conf.TrustedKeys) == 0 {
+		problems = append(problems, fmt.Sprintf("%v: %v: no %v are set", path, config.FlagNameRequireSignatures, config.FlagNameTrustedKeys))
+	}
+	if _, err := logging.NewRedactor(conf.RedactPatterns, conf.RedactPaths); err != nil {
+		problems = append(problems, fmt.Sprintf("%v: %v", path, err))
+	}
+	if _, err := work.ParseDirectiveAliases(conf.DirectiveAliases); err != nil {
+		problems = append(problems, fmt.Sprintf("%v: %v: %v", path, config.FlagNameDirectiveAlias, err))
+	}
+	if _, err := work.ParseRateLimits(conf.RateLimits, conf.ByteQuotas); err != nil {
+		problems = append(problems, fmt.Sprintf("%v: %v", path, err))
+	}
+	if _, err := work.ParseConcurrencyLimits(conf.MaxConcurrency); err != nil {
+		problems = append(problems, fmt.Sprintf("%v: %v: %v", path, config.FlagNameMaxConcurrency, err))
+	}
+	if _, err := http.ParseHeaders(conf.HTTPHeaders); err != nil {
+		problems = append(problems, fmt.Sprintf("%v: %v: %v", path, config.FlagNameHTTPHeader, err))
+	}
+	return problems
+}
+
+// configValidateAction is the cli action function for the "config validate"
+// subcommand. It checks the yggd configuration file and, with --workers, the
+// installed data files of every worker.
+func configValidateAction(ctx *cli.Context) error {
+	path := ctx.Path("config")
+	if ctx.String("instance") != "" && !ctx.IsSet("config") {
+		path = filepath.Join(constants.ConfigDir, "config.toml")
+	}
+
+	problems := validateConfigFile(path)
+	if len(problems) == 0 {
+		fmt.Printf("%v: OK\n", path)
+	}
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+
+	invalid := 0
+	if ctx.Bool("workers") {
+		names, err := installedWorkers()
+		if err != nil {
+			return cli.Exit(err, 1)
+		}
+		invalid = printWorkerProblems(names)
+	}
+
+	if len(problems) > 0 || invalid > 0 {
+		return cli.Exit(fmt.Sprintf("found %v configuration problems and %v invalid workers", len(problems), invalid), 1)
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// marshalFormat marshals v in format, either "json" or "yaml", terminated by a
+// newline. YAML output uses the same field names as JSON output, so that
+// either can be consumed without depending on the format.
+func marshalFormat(format string, v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "json":
+		return append(data, '\n'), nil
+	case "yaml":
+		var obj interface{}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return nil, err
+		}
+		return yaml.Marshal(obj)
+	default:
+		return nil, fmt.Errorf("unknown format type: %v", format)
+	}
+}
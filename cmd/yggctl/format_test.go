@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMarshalFormat(t *testing.T) {
+	tests := []struct {
+		description string
+		format      string
+		input       interface{}
+		want        string
+		wantError   bool
+	}{
+		{
+			description: "json",
+			format:      "json",
+			input:       map[string]map[string]string{"echo": {"running": "true"}},
+			want:        "{\"echo\":{\"running\":\"true\"}}\n",
+		},
+		{
+			description: "yaml",
+			format:      "yaml",
+			input:       map[string]map[string]string{"echo": {"running": "true"}},
+			want:        "echo:\n    running: \"true\"\n",
+		},
+		{
+			description: "yaml uses json field names",
+			format:      "yaml",
+			input: struct {
+				MessageID string `json:"message_id"`
+			}{MessageID: "1234"},
+			want: "message_id: \"1234\"\n",
+		},
+		{
+			description: "unknown format",
+			format:      "xml",
+			input:       map[string]string{},
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got, err := marshalFormat(test.format, test.input)
+
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !cmp.Equal(string(got), test.want) {
+					t.Errorf("%q != %q", got, test.want)
+				}
+			}
+		})
+	}
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"encoding/json"
+	"path/filepath"
 	"testing"
 
+	"github.com/adrg/xdg"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/redhatinsights/yggdrasil"
@@ -188,3 +190,88 @@ func TestGenerateControlMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestPodmanCommand(t *testing.T) {
+	tests := []struct {
+		description string
+		input       workerData
+		instance    string
+		want        string
+	}{
+		{
+			description: "system unit",
+			input:       workerData{Name: "echo"},
+			want:        "/usr/bin/podman run --rm --replace --name ygg-worker-echo --env YGG_* --volume /run/dbus/system_bus_socket:/run/dbus/system_bus_socket quay.io/example/echo",
+		},
+		{
+			description: "user unit",
+			input:       workerData{Name: "echo", UserUnit: true},
+			want:        "/usr/bin/podman run --rm --replace --name ygg-worker-echo --env YGG_* --volume %t/bus:%t/bus --env DBUS_SESSION_BUS_ADDRESS=unix:path=%t/bus quay.io/example/echo",
+		},
+		{
+			description: "instance",
+			input:       workerData{Name: "echo"},
+			instance:    "bunnies",
+			want:        "/usr/bin/podman run --rm --replace --name ygg-worker-echo --env YGG_* --network host --env DBUS_SESSION_BUS_ADDRESS=unix:abstract=yggd_bunnies quay.io/example/echo",
+		},
+		{
+			description: "credentials",
+			input:       workerData{Name: "echo", Credentials: []string{"token:/etc/echo/token"}},
+			want:        "/usr/bin/podman run --rm --replace --name ygg-worker-echo --env YGG_* --volume /run/dbus/system_bus_socket:/run/dbus/system_bus_socket --volume %d:%d:ro --env CREDENTIALS_DIRECTORY quay.io/example/echo",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got := podmanCommand(test.input, "quay.io/example/echo", test.instance)
+			if got != test.want {
+				t.Errorf("%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
+
+func TestLogsDirectory(t *testing.T) {
+	tests := []struct {
+		description string
+		dir         string
+		userUnit    bool
+		want        string
+	}{
+		{
+			description: "system log directory",
+			dir:         "/var/log/yggdrasil/workers",
+			want:        "yggdrasil/workers",
+		},
+		{
+			description: "instance log directory",
+			dir:         "/var/log/yggdrasil/bunnies/workers",
+			want:        "yggdrasil/bunnies/workers",
+		},
+		{
+			description: "outside /var/log",
+			dir:         "/usr/local/var/log/yggdrasil/workers",
+			want:        "",
+		},
+		{
+			description: "user log directory",
+			dir:         filepath.Join(xdg.StateHome, "log", "yggdrasil", "workers"),
+			userUnit:    true,
+			want:        "yggdrasil/workers",
+		},
+		{
+			description: "user directory outside the logs directory",
+			dir:         filepath.Join(xdg.StateHome, "yggdrasil", "log", "workers"),
+			userUnit:    true,
+			want:        "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if got := logsDirectory(test.dir, test.userUnit); got != test.want {
+				t.Errorf("%q != %q", got, test.want)
+			}
+		})
+	}
+}
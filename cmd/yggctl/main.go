@@ -3,11 +3,14 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"git.sr.ht/~spc/go-log"
 
+	"github.com/redhatinsights/yggdrasil/internal/config"
 	"github.com/redhatinsights/yggdrasil/internal/constants"
 	"github.com/urfave/cli/v2"
 )
@@ -27,6 +30,30 @@ func main() {
 			Name:   "generate-markdown",
 			Hidden: true,
 		},
+		&cli.StringFlag{
+			Name:  "instance",
+			Usage: "Control the yggd instance `NAME`, using its directories and bus",
+		},
+		&cli.PathFlag{
+			Name:  "state-dir",
+			Value: constants.StateDir,
+			Usage: "Install worker data files into `DIR`",
+		},
+		&cli.PathFlag{
+			Name:  "runtime-dir",
+			Value: constants.RuntimeDir,
+			Usage: "Point generated worker units at the environment file in `DIR`",
+		},
+		&cli.PathFlag{
+			Name:  "log-dir",
+			Value: constants.LogDir,
+			Usage: "Write worker log files into `DIR`",
+		},
+		&cli.PathFlag{
+			Name:  "worker-exec-dir",
+			Value: constants.WorkerExecDir,
+			Usage: "Install worker programs into `DIR`",
+		},
 	}
 
 	app.Commands = []*cli.Command{
@@ -104,7 +131,11 @@ from stdin.`,
 					Usage:     "Generate data files needed for workers to interact with yggd",
 					UsageText: "yggctl generate worker-data [command options]",
 					Description: `The generate worker-data command creates data files necessary for workers to
-communicate properly with yggd.`,
+communicate properly with yggd.
+
+The worker program may reference variables provided by yggd, such as
+${YGG_CLIENT_ID}, ${YGG_DATA_HOST} or ${YGG_FACT_<NAME>}; they are expanded by
+systemd when the worker is started.`,
 					Flags: []cli.Flag{
 						&cli.BoolFlag{
 							Name:    "install",
@@ -123,10 +154,13 @@ communicate properly with yggd.`,
 							Required: true,
 						},
 						&cli.StringFlag{
-							Name:     "program",
-							Aliases:  []string{"p"},
-							Usage:    "set the worker program to `PATH`",
-							Required: true,
+							Name:    "program",
+							Aliases: []string{"p"},
+							Usage:   "set the worker program to `PATH`",
+						},
+						&cli.StringFlag{
+							Name:  "image",
+							Usage: "run the worker from the container `IMAGE` using podman",
 						},
 						&cli.StringFlag{
 							Name:     "user",
@@ -139,6 +173,68 @@ communicate properly with yggd.`,
 							Aliases: []string{"g"},
 							Usage:   "set the worker group to `GROUP`",
 						},
+						&cli.PathFlag{
+							Name:  "working-directory",
+							Usage: "start the worker in `DIRECTORY`",
+						},
+						&cli.StringFlag{
+							Name:  "umask",
+							Usage: "set the worker file mode creation mask to `MODE`",
+						},
+						&cli.IntFlag{
+							Name:  "nice",
+							Usage: "set the worker scheduling priority to `LEVEL` (-20 to 19)",
+						},
+						&cli.StringFlag{
+							Name:  "restart",
+							Usage: "restart the worker according to `POLICY` (always, on-failure or never)",
+						},
+						&cli.DurationFlag{
+							Name:  "restart-delay",
+							Usage: "wait `DURATION` before restarting the worker",
+						},
+						&cli.DurationFlag{
+							Name:  "restart-max-delay",
+							Usage: "increase the restart delay up to at most `DURATION` (requires systemd 254 or later)",
+						},
+						&cli.UintFlag{
+							Name:  "restart-steps",
+							Usage: "grow the restart delay exponentially over `N` restarts up to the maximum delay (requires systemd 254 or later)",
+						},
+						&cli.UintFlag{
+							Name:  "max-restarts",
+							Usage: "give up after `N` restarts within the restart window",
+						},
+						&cli.DurationFlag{
+							Name:  "restart-window",
+							Usage: "reset the restart counter after `DURATION` of healthy uptime",
+						},
+						&cli.DurationFlag{
+							Name:  "stop-timeout",
+							Usage: "wait `DURATION` for the worker to exit after SIGTERM before killing it",
+						},
+						&cli.StringSliceFlag{
+							Name:  "credential",
+							Usage: "pass the secret `ID[:PATH]` to the worker as a systemd credential",
+						},
+						&cli.StringSliceFlag{
+							Name:  "encrypted-credential",
+							Usage: "pass the encrypted secret `ID[:PATH]` to the worker as a systemd credential",
+						},
+						&cli.BoolFlag{
+							Name:  "log-file",
+							Usage: "write worker output to a log file instead of the journal, rotated for system units",
+						},
+						&cli.StringFlag{
+							Name:  "log-max-size",
+							Usage: "rotate the worker log file once it grows beyond `SIZE`",
+							Value: "10M",
+						},
+						&cli.UintFlag{
+							Name:  "log-rotate",
+							Usage: "keep `N` rotated worker log files",
+							Value: 5,
+						},
 					},
 					Before: func(ctx *cli.Context) error {
 						if ctx.String("output") == "" && !ctx.Bool("install") {
@@ -148,6 +244,13 @@ communicate properly with yggd.`,
 							)
 						}
 
+						if (ctx.String("program") == "") == (ctx.String("image") == "") {
+							return cli.Exit(
+								"error: you must specify exactly one of --program or --image",
+								1,
+							)
+						}
+
 						if strings.Contains(ctx.String("name"), " -") {
 							return cli.Exit("'name' cannot contain spaces or dashes", 1)
 						}
@@ -162,10 +265,144 @@ communicate properly with yggd.`,
 							}
 						}
 
+						if ctx.String("working-directory") != "" && !filepath.IsAbs(ctx.String("working-directory")) {
+							return cli.Exit("'working-directory' must be an absolute path", 1)
+						}
+
+						if ctx.String("umask") != "" {
+							if !regexp.MustCompile("^[0-7]{3,4}$").MatchString(ctx.String("umask")) {
+								return cli.Exit("'umask' must be an octal mode such as 0027", 1)
+							}
+						}
+
+						if ctx.Int("nice") < -20 || ctx.Int("nice") > 19 {
+							return cli.Exit("'nice' must be between -20 and 19", 1)
+						}
+
+						switch ctx.String("restart") {
+						case "", "always", "on-failure", "never":
+						default:
+							return cli.Exit(
+								"'restart' must be one of 'always', 'on-failure' or 'never'",
+								1,
+							)
+						}
+
 						return nil
 					},
 					Action: generateWorkerDataAction,
 				},
+				{
+					Name:      "worker-config",
+					Usage:     "Generate a worker configuration file",
+					UsageText: "yggctl generate worker-config [command options]",
+					Description: `The generate worker-config command prints a TOML worker configuration, with
+comments describing each field, for use as the manifest.toml of a worker
+bundle. The program is recorded by its file name; pack the manifest and the
+program in a gzip-compressed tar archive and install it with "yggctl workers
+install". If --output is given, the configuration is written to FILE instead.
+To install a worker program already on disk, use "yggctl generate worker-data
+--install" instead.`,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:     "name",
+							Aliases:  []string{"n"},
+							Usage:    "set the worker name to `NAME`",
+							Required: true,
+						},
+						&cli.PathFlag{
+							Name:     "exec",
+							Aliases:  []string{"e", "program"},
+							Usage:    "set the worker program to the file at `PATH`",
+							Required: true,
+						},
+						&cli.StringFlag{
+							Name:     "user",
+							Aliases:  []string{"u"},
+							Usage:    "set the worker user to `USER`",
+							Required: true,
+						},
+						&cli.StringFlag{
+							Name:    "group",
+							Aliases: []string{"g"},
+							Usage:   "set the worker group to `GROUP`",
+						},
+						&cli.PathFlag{
+							Name:    "output",
+							Aliases: []string{"o"},
+							Usage:   "write the configuration to `FILE`",
+						},
+						&cli.BoolFlag{
+							Name:    "force",
+							Aliases: []string{"f"},
+							Usage:   "overwrite an existing configuration",
+						},
+					},
+					Action: generateWorkerConfigAction,
+				},
+			},
+		},
+		{
+			Name:  "config",
+			Usage: "Interact with the yggd configuration",
+			Subcommands: []*cli.Command{
+				{
+					Name:        "validate",
+					Usage:       "Check the yggd configuration",
+					UsageText:   "yggctl config validate [command options]",
+					Description: "The validate command loads the yggd configuration file and checks every value, including that the certificate and key files can be read and form a valid key pair and that server addresses are valid for the protocol. Each problem found is printed and the command exits with a non-zero status. With --workers, the data files of every installed worker are checked as well.",
+					Flags: []cli.Flag{
+						&cli.PathFlag{
+							Name:      "config",
+							Aliases:   []string{"c"},
+							Usage:     "read the configuration from `FILE`",
+							Value:     filepath.Join(constants.ConfigDir, "config.toml"),
+							TakesFile: true,
+						},
+						&cli.BoolFlag{
+							Name:  "workers",
+							Usage: "check the data files of every installed worker",
+						},
+					},
+					Action: configValidateAction,
+				},
+			},
+		},
+		{
+			Name:  "audit",
+			Usage: "Inspect the yggd audit log",
+			Subcommands: []*cli.Command{
+				{
+					Name:        "verify",
+					Usage:       "Verify the integrity of the audit log",
+					Description: "The verify command checks the hash chain of the audit log, in which yggd records every control message it receives and every action it takes on a worker. It exits with an error naming the first record that was modified, removed or reordered.",
+					Flags: []cli.Flag{
+						&cli.PathFlag{
+							Name:  "file",
+							Usage: "verify the audit log at `FILE` instead of the default location",
+						},
+					},
+					Action: auditVerifyAction,
+				},
+			},
+		},
+		{
+			Name:  "secret",
+			Usage: "Manage secrets referenced by the yggd configuration",
+			Subcommands: []*cli.Command{
+				{
+					Name:        "set",
+					Usage:       "Store a secret",
+					UsageText:   "yggctl secret set [command options] NAME",
+					Description: "The set command reads a secret from standard input and stores it as the systemd credential 'yggdrasil.NAME', encrypted with systemd-creds. yggd imports the credential when started by systemd, and a configuration value of 'secret:NAME' is replaced by the secret. With --plaintext, the secret is stored unencrypted, readable only by root, which also lets yggd read it when not started by systemd.",
+					Flags: []cli.Flag{
+						&cli.BoolFlag{
+							Name:  "plaintext",
+							Usage: "store the secret without encrypting it",
+						},
+					},
+					Action: secretSetAction,
+				},
 			},
 		},
 		{
@@ -179,19 +416,104 @@ communicate properly with yggd.`,
 					Flags: []cli.Flag{
 						&cli.StringFlag{
 							Name:  "format",
-							Usage: "Print output in `FORMAT` (json or table)",
+							Usage: "Print output in `FORMAT` (json, yaml or table)",
 							Value: "table",
 						},
 					},
 					Action: workersAction,
 				},
+				{
+					Name:        "metrics",
+					Usage:       "Print worker lifecycle metrics",
+					Description: `The metrics command prints, for each worker seen since yggd started, whether it is running, how many times it has been restarted, its cumulative uptime, the status of its last exit and the number of messages dispatched to it and failed.`,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "format",
+							Usage: "Print output in `FORMAT` (json, yaml or table)",
+							Value: "table",
+						},
+					},
+					Action: workersMetricsAction,
+				},
+				{
+					Name:        "status",
+					Usage:       "Print the status of each worker",
+					Description: `The status command prints, for each known worker, whether it is enabled, disabled or excluded, whether it is running and its process ID, how many times it has been restarted, the status of its last exit and its "features" table.`,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "format",
+							Usage: "Print output in `FORMAT` (json, yaml or table)",
+							Value: "table",
+						},
+					},
+					Action: workersStatusAction,
+				},
+				{
+					Name:        "install",
+					Usage:       "Install a worker from a bundle",
+					UsageText:   "yggctl workers install BUNDLE",
+					Description: `The install command reads BUNDLE, a gzip-compressed tar archive containing a "manifest.toml" file and a worker program. The program is installed along with the D-Bus and systemd data files required to activate the worker, and the worker is started.`,
+					Before: func(ctx *cli.Context) error {
+						if ctx.NArg() != 1 {
+							return cli.Exit("error: install requires exactly one BUNDLE argument", 1)
+						}
+						return nil
+					},
+					Action: workersInstallAction,
+				},
+				{
+					Name:        "remove",
+					Usage:       "Remove a worker installed from a bundle",
+					UsageText:   "yggctl workers remove NAME",
+					Description: "The remove command stops the worker NAME and removes its program and data files.",
+					Before: func(ctx *cli.Context) error {
+						if ctx.NArg() != 1 {
+							return cli.Exit("error: remove requires exactly one NAME argument", 1)
+						}
+						return nil
+					},
+					Action: workersRemoveAction,
+				},
+				{
+					Name:        "enable",
+					Usage:       "Enable a worker disabled at runtime",
+					UsageText:   "yggctl workers enable NAME",
+					Description: "The enable command enables the worker NAME, allowing yggd to dispatch messages to it again.",
+					Before: func(ctx *cli.Context) error {
+						if ctx.NArg() != 1 {
+							return cli.Exit("error: enable requires exactly one NAME argument", 1)
+						}
+						return nil
+					},
+					Action: workersEnableAction("EnableWorker"),
+				},
+				{
+					Name:        "disable",
+					Usage:       "Disable a worker at runtime",
+					UsageText:   "yggctl workers disable NAME",
+					Description: "The disable command stops the worker NAME and prevents yggd from dispatching messages to it until it is enabled again. The worker's data files are left in place and the state persists across restarts of yggd.",
+					Before: func(ctx *cli.Context) error {
+						if ctx.NArg() != 1 {
+							return cli.Exit("error: disable requires exactly one NAME argument", 1)
+						}
+						return nil
+					},
+					Action: workersEnableAction("DisableWorker"),
+				},
+				{
+					Name:        "validate",
+					Usage:       "Check installed worker data files",
+					UsageText:   "yggctl workers validate [NAME...]",
+					Description: "The validate command checks the D-Bus and systemd data files of each installed worker, or only the workers named NAME, and reports any problems found. The worker program must exist and be executable, and Environment assignments must be well-formed. No worker is started.",
+					Action:      workersValidateAction,
+				},
 			},
 		},
 		{
 			Name:        "dispatch",
 			Usage:       "Dispatch data to a worker locally",
 			UsageText:   "yggctl dispatch [command options] FILE",
-			Description: "The dispatch command reads FILE and sends its content to a yggdrasil worker running locally. If FILE is -, content is read from stdin.",
+			Description: "The dispatch command reads FILE and sends its content to a yggdrasil worker running locally. If FILE is -, content is read from stdin. With --inbound, the message is processed exactly as if it had been received from the server.",
 			Flags: []cli.Flag{
 				&cli.StringFlag{
 					Name:     "worker",
@@ -205,9 +527,74 @@ communicate properly with yggd.`,
 					Usage:   "Attach `JSON` as metadata to the message",
 					Value:   "{}",
 				},
+				&cli.BoolFlag{
+					Name:  "inbound",
+					Usage: "Process the message as if it had been received from the server",
+				},
+				&cli.StringFlag{
+					Name:  "message-id",
+					Usage: "Set the message ID to `ID` instead of generating one",
+				},
+				&cli.StringFlag{
+					Name:  "response-to",
+					Usage: "Set the ID of the message this message responds to (requires --inbound)",
+				},
 			},
 			Action: dispatchAction,
 		},
+		{
+			Name:        "cancel",
+			Usage:       "Cancel a message dispatched to a worker",
+			UsageText:   "yggctl cancel [command options] MESSAGE_ID",
+			Description: "The cancel command asks a yggdrasil worker running locally to cancel its work on the message MESSAGE_ID.",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "worker",
+					Aliases:  []string{"w"},
+					Usage:    "Cancel the message on `WORKER`",
+					Required: true,
+				},
+			},
+			Before: func(ctx *cli.Context) error {
+				if ctx.NArg() != 1 {
+					return cli.Exit("error: cancel requires exactly one MESSAGE_ID argument", 1)
+				}
+				return nil
+			},
+			Action: cancelAction,
+		},
+		{
+			Name:  "dead-letter",
+			Usage: "Inspect and redeliver messages that could not be delivered to workers",
+			Subcommands: []*cli.Command{
+				{
+					Name:        "list",
+					Usage:       "List undelivered messages",
+					Description: "The list command prints the messages from the server that yggd could not deliver to a worker, along with the reason delivery failed.",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "format",
+							Usage: "Print output in `FORMAT` (json, yaml or table)",
+							Value: "table",
+						},
+					},
+					Action: deadLetterListAction,
+				},
+				{
+					Name:        "redrive",
+					Usage:       "Dispatch undelivered messages again",
+					UsageText:   "yggctl dead-letter redrive MESSAGE_ID...",
+					Description: "The redrive command dispatches each message MESSAGE_ID to its worker again. A message that still cannot be delivered is returned to the dead-letter store.",
+					Before: func(ctx *cli.Context) error {
+						if ctx.NArg() < 1 {
+							return cli.Exit("error: redrive requires at least one MESSAGE_ID argument", 1)
+						}
+						return nil
+					},
+					Action: deadLetterRedriveAction,
+				},
+			},
+		},
 		{
 			Name:        "message-journal",
 			Usage:       "Show events emitted by workers",
@@ -247,13 +634,160 @@ communicate properly with yggd.`,
 				&cli.StringFlag{
 					Name:     "format",
 					Aliases:  []string{"f"},
-					Usage:    "Print output in `FORMAT` (json, table or text)",
+					Usage:    "Print output in `FORMAT` (json, yaml, table or text)",
 					Value:    "table",
 					Required: false,
 				},
 			},
 			Action: messageJournalAction,
 		},
+		{
+			Name:        "status",
+			Usage:       "Print the status of yggd",
+			Description: "The status command prints whether yggd is connected to the server, the transport and server it uses, its client ID, when it last received a message, how many workers are known and running, how many messages are awaiting delivery or in the dead-letter store, and its log level.",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "format",
+					Usage: "Print output in `FORMAT` (json, yaml or table)",
+					Value: "table",
+				},
+			},
+			Action: statusAction,
+		},
+		{
+			Name:        "id",
+			Usage:       "Print or regenerate the client ID",
+			Description: "The id command prints the client ID yggd uses to identify itself to the server and the source it was derived from. With --regenerate, yggd replaces a client ID persisted in its state directory with a new random ID, informs the server and disconnects; restart yggd to connect with the new ID.",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "regenerate",
+					Usage: "Replace the persisted client ID with a new random ID",
+				},
+			},
+			Action: idAction,
+		},
+		{
+			Name:        "log-level",
+			Usage:       "Change the log level of yggd",
+			UsageText:   "yggctl log-level [command options] [LEVEL]",
+			Description: "The log-level command sets the log level of yggd to LEVEL (error, warn, info, debug or trace) without restarting it. With --duration, the configured log level is restored once the duration elapses. With --reset, the configured log level is restored immediately. Without arguments, the current log level is printed.",
+			Flags: []cli.Flag{
+				&cli.DurationFlag{
+					Name:    "duration",
+					Aliases: []string{"d"},
+					Usage:   "Restore the configured log level after `DURATION`",
+				},
+				&cli.BoolFlag{
+					Name:  "reset",
+					Usage: "Restore the configured log level",
+				},
+			},
+			Action: logLevelAction,
+		},
+		{
+			Name:        "events",
+			Usage:       "Print dispatcher events",
+			Description: "The events command prints the most recent events recorded by the dispatcher while handling messages, such as messages received, dispatched to workers, answered or failed. With --follow, it keeps printing events as they occur.",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:    "follow",
+					Aliases: []string{"f"},
+					Usage:   "Print new events as they occur",
+				},
+				&cli.StringFlag{
+					Name:  "format",
+					Usage: "Print output in `FORMAT` (json, yaml or text)",
+					Value: "text",
+				},
+			},
+			Action: eventsAction,
+		},
+		{
+			Name:        "disconnect",
+			Usage:       "Stop receiving messages from the server",
+			Description: "The disconnect command asks yggd to inform the server that it is disconnecting and to disconnect from it. Workers keep running, but no messages are received from the server until the connect command is run.",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "reason",
+					Aliases: []string{"r"},
+					Usage:   "Send `REASON` to the server",
+					Value:   "disconnected by administrator",
+				},
+			},
+			Action: disconnectAction,
+		},
+		{
+			Name:        "connect",
+			Usage:       "Resume receiving messages from the server",
+			Description: "The connect command asks yggd to reconnect to the server after the disconnect command was run.",
+			Action:      connectAction,
+		},
+		{
+			Name:        "ping",
+			Usage:       "Check connectivity with the server",
+			Description: "The ping command asks yggd to send a \"ping\" command to the server through the configured transport and reports how long it took to send the command and to receive the server's \"pong\" response. The command exits with a non-zero status if no response is received.",
+			Flags: []cli.Flag{
+				&cli.UintFlag{
+					Name:    "count",
+					Aliases: []string{"c"},
+					Usage:   "Send `N` pings",
+					Value:   1,
+				},
+				&cli.DurationFlag{
+					Name:    "timeout",
+					Aliases: []string{"t"},
+					Usage:   "Wait `DURATION` for each response",
+					Value:   5 * time.Second,
+				},
+			},
+			Action: pingAction,
+		},
+		{
+			Name:        "facts",
+			Usage:       "Print the canonical facts sent to the server",
+			Description: "The facts command prints the canonical facts yggd sends to the server to identify the system, such as its machine ID, host name and IP addresses.",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "format",
+					Usage: "Print output in `FORMAT` (json, yaml or table)",
+					Value: "table",
+				},
+			},
+			Action: factsAction,
+		},
+		{
+			Name:        "history",
+			Usage:       "Print the outcomes of recent messages",
+			Description: "The history command prints the outcomes of the most recent messages handled by yggd, such as messages dispatched to workers, answered, failed or rejected, along with the size of their content. The history persists across restarts of yggd. TIME is either a time in RFC 3339 format or a duration, such as 1h, before the current time.",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "directive",
+					Aliases: []string{"d"},
+					Usage:   "Only print messages for `DIRECTIVE`",
+				},
+				&cli.StringFlag{
+					Name:    "message-id",
+					Aliases: []string{"m"},
+					Usage:   "Only print the message `ID`",
+				},
+				&cli.StringFlag{
+					Name:    "since",
+					Aliases: []string{"s"},
+					Usage:   "Only print messages handled since `TIME`",
+				},
+				&cli.StringFlag{
+					Name:    "until",
+					Aliases: []string{"u"},
+					Usage:   "Only print messages handled until `TIME`",
+				},
+				&cli.StringFlag{
+					Name:  "format",
+					Usage: "Print output in `FORMAT` (json, yaml or table)",
+					Value: "table",
+				},
+			},
+			Action: historyAction,
+		},
 		{
 			Name:        "listen",
 			Usage:       "Listen to worker event output",
@@ -270,6 +804,7 @@ communicate properly with yggd.`,
 		},
 	}
 
+	app.Before = setupDirectories
 	app.Action = generateManPage
 	app.EnableBashCompletion = true
 
@@ -278,6 +813,29 @@ communicate properly with yggd.`,
 	}
 }
 
+// setupDirectories replaces the compile-time state, runtime, log and worker
+// program directories with the ones given on the command line, so they match
+// a yggd instance run with isolated directories. For a named instance,
+// directories not given on the command line are those of the instance.
+func setupDirectories(c *cli.Context) error {
+	if instance := c.String("instance"); instance != "" {
+		if err := config.ApplyInstance(instance); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+	for name, dir := range map[string]*string{
+		"state-dir":       &constants.StateDir,
+		"runtime-dir":     &constants.RuntimeDir,
+		"log-dir":         &constants.LogDir,
+		"worker-exec-dir": &constants.WorkerExecDir,
+	} {
+		if c.String("instance") == "" || c.IsSet(name) {
+			*dir = c.Path(name)
+		}
+	}
+	return nil
+}
+
 func generateManPage(c *cli.Context) error {
 	if c.Bool("generate-man-page") || c.Bool("generate-markdown") {
 		type GenerationFunc func() (string, error)
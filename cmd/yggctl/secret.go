@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/redhatinsights/yggdrasil/internal/config"
+	"github.com/redhatinsights/yggdrasil/internal/constants"
+	"github.com/urfave/cli/v2"
+)
+
+// secretSetAction is the cli action function for the "secret set" subcommand.
+// It reads a secret from stdin and stores it as a systemd credential,
+// encrypted unless --plaintext is given.
+func secretSetAction(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return cli.Exit("usage: yggctl secret set [command options] NAME", 1)
+	}
+	name := ctx.Args().First()
+	credential, err := config.CredentialName(name)
+	if err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot read secret: %w", err), 1)
+	}
+	data = bytes.TrimRight(data, "\n")
+	if len(data) == 0 {
+		return cli.Exit("cannot set secret: secret is empty", 1)
+	}
+
+	var file string
+	if ctx.Bool("plaintext") {
+		file = filepath.Join(constants.CredstoreDir, credential)
+		if err := writeSecret(file, data); err != nil {
+			return cli.Exit(err, 1)
+		}
+	} else {
+		file = filepath.Join(constants.CredstoreEncryptedDir, credential)
+		if err := encryptSecret(file, credential, data); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+
+	fmt.Printf("stored secret %v in %v; reference it as '%v%v'\n", name, file, config.SecretPrefix, name)
+	return nil
+}
+
+// writeSecret writes data to file, readable only by its owner.
+func writeSecret(file string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return fmt.Errorf("cannot create directory: %w", err)
+	}
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		return fmt.Errorf("cannot write secret: %w", err)
+	}
+	return os.Chmod(file, 0600)
+}
+
+// encryptSecret encrypts data with systemd-creds as the credential named
+// credential and writes it to file.
+func encryptSecret(file, credential string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return fmt.Errorf("cannot create directory: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("systemd-creds", "encrypt", "--name="+credential, "-", file)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("cannot encrypt secret: %w: %v", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -2,7 +2,11 @@ package main
 
 var DBusServiceTemplate = `[D-BUS Service]
 Name=com.redhat.Yggdrasil1.Worker1.{{ .Name }}
-SystemdService=com.redhat.Yggdrasil1.Worker1.{{ .Name }}.Service
+Exec={{ .Program }}
+{{- if not .UserUnit }}
+User={{ .User }}
+{{- end }}
+SystemdService=com.redhat.Yggdrasil1.Worker1.{{ .Name }}.service
 `
 
 var DBusPolicyConfigTemplate = `<?xml version="1.0" encoding="UTF-8"?>
@@ -34,14 +38,92 @@ var DBusPolicyConfigTemplate = `<?xml version="1.0" encoding="UTF-8"?>
 var SystemdServiceTemplate = `[Unit]
 Description=yggdrasil {{ .Name }} worker service
 Documentation=https://github.com/RedHatInsights/yggdrasil
+{{- if .RestartWindow }}
+StartLimitIntervalSec={{ .RestartWindow }}
+{{- end }}
+{{- if .MaxRestarts }}
+StartLimitBurst={{ .MaxRestarts }}
+{{- end }}
 
 [Service]
 Type=dbus
+{{- if not .UserUnit }}
 User={{ .User }}
 Group={{ .Group }}
+{{- end }}
+EnvironmentFile=-{{ .EnvironmentFile }}
 ExecStart={{ .Program }}
 BusName=com.redhat.Yggdrasil1.Worker1.{{ .Name }}
+SyslogIdentifier=ygg-worker-{{ .Name }}
+{{- if .WorkingDirectory }}
+WorkingDirectory={{ .WorkingDirectory }}
+{{- end }}
+{{- if .UMask }}
+UMask={{ .UMask }}
+{{- end }}
+{{- if .Nice }}
+Nice={{ .Nice }}
+{{- end }}
+{{- if .Restart }}
+Restart={{ .Restart }}
+{{- end }}
+{{- if .RestartDelay }}
+RestartSec={{ .RestartDelay }}
+{{- end }}
+{{- if .RestartMaxDelay }}
+RestartMaxDelaySec={{ .RestartMaxDelay }}
+{{- end }}
+{{- if .RestartSteps }}
+RestartSteps={{ .RestartSteps }}
+{{- end }}
+{{- if .StopTimeout }}
+TimeoutStopSec={{ .StopTimeout }}
+{{- end }}
+{{- range .Credentials }}
+LoadCredential={{ . }}
+{{- end }}
+{{- range .EncryptedCredentials }}
+LoadCredentialEncrypted={{ . }}
+{{- end }}
+{{- if .LogFile }}
+{{- if .LogsDirectory }}
+LogsDirectory={{ .LogsDirectory }}
+{{- end }}
+StandardOutput=append:{{ .LogFile }}
+StandardError=append:{{ .LogFile }}
+{{- end }}
 
 [Install]
+{{- if .UserUnit }}
+WantedBy=default.target
+{{- else }}
 WantedBy=multi-user.target
+{{- end }}
+`
+
+var LogrotateConfigTemplate = `{{ .LogFile }} {
+    size {{ .LogMaxSize }}
+    rotate {{ .LogRotate }}
+    missingok
+    notifempty
+    compress
+    copytruncate
+}
+`
+
+var WorkerConfigTemplate = `# Worker configuration for the {{ .Name }} worker.
+
+# name is the worker's directive name. Messages addressed to this directive are
+# dispatched to the worker.
+name = "{{ .Name }}"
+
+# program is the file name of the worker program within the bundle. It is
+# installed to the workers directory by "yggctl workers install".
+program = "{{ .Program }}"
+
+# user is the user the worker runs as.
+user = "{{ .User }}"
+
+# group is the group the worker runs as. If unset, it matches user.
+{{ if .Group }}group = "{{ .Group }}"{{ else }}# group = "{{ .User }}"{{ end }}
 `
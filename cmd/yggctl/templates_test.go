@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDBusServiceTemplate(t *testing.T) {
+	tests := []struct {
+		description string
+		input       workerData
+		want        string
+	}{
+		{
+			description: "system bus",
+			input: workerData{
+				User:    "worker",
+				Name:    "echo",
+				Program: "/usr/libexec/echo-worker",
+			},
+			want: `[D-BUS Service]
+Name=com.redhat.Yggdrasil1.Worker1.echo
+Exec=/usr/libexec/echo-worker
+User=worker
+SystemdService=com.redhat.Yggdrasil1.Worker1.echo.service
+`,
+		},
+		{
+			description: "session bus",
+			input: workerData{
+				User:     "worker",
+				Name:     "echo",
+				Program:  "/home/user/.local/share/yggdrasil/workers/echo-worker",
+				UserUnit: true,
+			},
+			want: `[D-BUS Service]
+Name=com.redhat.Yggdrasil1.Worker1.echo
+Exec=/home/user/.local/share/yggdrasil/workers/echo-worker
+SystemdService=com.redhat.Yggdrasil1.Worker1.echo.service
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			var got bytes.Buffer
+			tmpl := template.Must(template.New("").Parse(DBusServiceTemplate))
+			if err := tmpl.Execute(&got, test.input); err != nil {
+				t.Fatal(err)
+			}
+
+			if !cmp.Equal(got.String(), test.want) {
+				t.Errorf("%v", cmp.Diff(got.String(), test.want))
+			}
+		})
+	}
+}
+
+func TestSystemdServiceTemplate(t *testing.T) {
+	tests := []struct {
+		description string
+		input       workerData
+		want        string
+	}{
+		{
+			description: "default policy",
+			input: workerData{
+				User:            "worker",
+				Group:           "worker",
+				Name:            "echo",
+				Program:         "/usr/libexec/echo-worker",
+				EnvironmentFile: "/run/yggdrasil/worker.env",
+			},
+			want: `[Unit]
+Description=yggdrasil echo worker service
+Documentation=https://github.com/RedHatInsights/yggdrasil
+
+[Service]
+Type=dbus
+User=worker
+Group=worker
+EnvironmentFile=-/run/yggdrasil/worker.env
+ExecStart=/usr/libexec/echo-worker
+BusName=com.redhat.Yggdrasil1.Worker1.echo
+SyslogIdentifier=ygg-worker-echo
+
+[Install]
+WantedBy=multi-user.target
+`,
+		},
+		{
+			description: "user unit",
+			input: workerData{
+				User:            "worker",
+				Group:           "worker",
+				Name:            "echo",
+				Program:         "/home/user/.local/share/yggdrasil/workers/echo-worker",
+				EnvironmentFile: "/run/user/1000/yggdrasil/worker.env",
+				UserUnit:        true,
+			},
+			want: `[Unit]
+Description=yggdrasil echo worker service
+Documentation=https://github.com/RedHatInsights/yggdrasil
+
+[Service]
+Type=dbus
+EnvironmentFile=-/run/user/1000/yggdrasil/worker.env
+ExecStart=/home/user/.local/share/yggdrasil/workers/echo-worker
+BusName=com.redhat.Yggdrasil1.Worker1.echo
+SyslogIdentifier=ygg-worker-echo
+
+[Install]
+WantedBy=default.target
+`,
+		},
+		{
+			description: "credentials",
+			input: workerData{
+				User:                 "worker",
+				Group:                "worker",
+				Name:                 "echo",
+				Program:              "/usr/libexec/echo-worker",
+				EnvironmentFile:      "/run/yggdrasil/worker.env",
+				Credentials:          []string{"token:/etc/echo/token"},
+				EncryptedCredentials: []string{"password"},
+			},
+			want: `[Unit]
+Description=yggdrasil echo worker service
+Documentation=https://github.com/RedHatInsights/yggdrasil
+
+[Service]
+Type=dbus
+User=worker
+Group=worker
+EnvironmentFile=-/run/yggdrasil/worker.env
+ExecStart=/usr/libexec/echo-worker
+BusName=com.redhat.Yggdrasil1.Worker1.echo
+SyslogIdentifier=ygg-worker-echo
+LoadCredential=token:/etc/echo/token
+LoadCredentialEncrypted=password
+
+[Install]
+WantedBy=multi-user.target
+`,
+		},
+		{
+			description: "log file",
+			input: workerData{
+				User:            "worker",
+				Group:           "worker",
+				Name:            "echo",
+				Program:         "/usr/libexec/echo-worker",
+				EnvironmentFile: "/run/yggdrasil/worker.env",
+				LogFile:         "/var/log/yggdrasil/workers/echo.log",
+				LogsDirectory:   "yggdrasil/workers",
+			},
+			want: `[Unit]
+Description=yggdrasil echo worker service
+Documentation=https://github.com/RedHatInsights/yggdrasil
+
+[Service]
+Type=dbus
+User=worker
+Group=worker
+EnvironmentFile=-/run/yggdrasil/worker.env
+ExecStart=/usr/libexec/echo-worker
+BusName=com.redhat.Yggdrasil1.Worker1.echo
+SyslogIdentifier=ygg-worker-echo
+LogsDirectory=yggdrasil/workers
+StandardOutput=append:/var/log/yggdrasil/workers/echo.log
+StandardError=append:/var/log/yggdrasil/workers/echo.log
+
+[Install]
+WantedBy=multi-user.target
+`,
+		},
+		{
+			description: "restart policy",
+			input: workerData{
+				User:            "worker",
+				Group:           "worker",
+				Name:            "echo",
+				Program:         "/usr/libexec/echo-worker",
+				EnvironmentFile: "/run/yggdrasil/worker.env",
+				Restart:         restartPolicy("on-failure"),
+				RestartDelay:    systemdTimeSpan(5 * time.Second),
+				RestartMaxDelay: systemdTimeSpan(2 * time.Minute),
+				RestartSteps:    5,
+				RestartWindow:   systemdTimeSpan(10 * time.Minute),
+				MaxRestarts:     5,
+				StopTimeout:     systemdTimeSpan(90 * time.Second),
+			},
+			want: `[Unit]
+Description=yggdrasil echo worker service
+Documentation=https://github.com/RedHatInsights/yggdrasil
+StartLimitIntervalSec=600
+StartLimitBurst=5
+
+[Service]
+Type=dbus
+User=worker
+Group=worker
+EnvironmentFile=-/run/yggdrasil/worker.env
+ExecStart=/usr/libexec/echo-worker
+BusName=com.redhat.Yggdrasil1.Worker1.echo
+SyslogIdentifier=ygg-worker-echo
+Restart=on-failure
+RestartSec=5
+RestartMaxDelaySec=120
+RestartSteps=5
+TimeoutStopSec=90
+
+[Install]
+WantedBy=multi-user.target
+`,
+		},
+		{
+			description: "process environment",
+			input: workerData{
+				User:             "worker",
+				Group:            "worker",
+				Name:             "echo",
+				Program:          "/usr/libexec/echo-worker",
+				EnvironmentFile:  "/run/yggdrasil/worker.env",
+				WorkingDirectory: "/var/lib/echo",
+				UMask:            "0027",
+				Nice:             "-5",
+			},
+			want: `[Unit]
+Description=yggdrasil echo worker service
+Documentation=https://github.com/RedHatInsights/yggdrasil
+
+[Service]
+Type=dbus
+User=worker
+Group=worker
+EnvironmentFile=-/run/yggdrasil/worker.env
+ExecStart=/usr/libexec/echo-worker
+BusName=com.redhat.Yggdrasil1.Worker1.echo
+SyslogIdentifier=ygg-worker-echo
+WorkingDirectory=/var/lib/echo
+UMask=0027
+Nice=-5
+
+[Install]
+WantedBy=multi-user.target
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			var got bytes.Buffer
+			tmpl := template.Must(template.New("").Parse(SystemdServiceTemplate))
+			if err := tmpl.Execute(&got, test.input); err != nil {
+				t.Fatal(err)
+			}
+
+			if !cmp.Equal(got.String(), test.want) {
+				t.Errorf("%v", cmp.Diff(got.String(), test.want))
+			}
+		})
+	}
+}
+
+func TestLogrotateConfigTemplate(t *testing.T) {
+	input := workerData{
+		LogFile:    "/var/log/yggdrasil/workers/echo.log",
+		LogMaxSize: "10M",
+		LogRotate:  5,
+	}
+	want := `/var/log/yggdrasil/workers/echo.log {
+    size 10M
+    rotate 5
+    missingok
+    notifempty
+    compress
+    copytruncate
+}
+`
+
+	var got bytes.Buffer
+	tmpl := template.Must(template.New("").Parse(LogrotateConfigTemplate))
+	if err := tmpl.Execute(&got, input); err != nil {
+		t.Fatal(err)
+	}
+
+	if !cmp.Equal(got.String(), want) {
+		t.Errorf("%v", cmp.Diff(got.String(), want))
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// parseUnitFile parses an INI-style file, such as a D-Bus service file or a
+// systemd unit file, into a map of "Section.Key" to the list of values
+// assigned to that key.
+func parseUnitFile(r io.Reader) (map[string][]string, error) {
+	values := make(map[string][]string)
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %v: invalid section header", n)
+			}
+			section = strings.Trim(line, "[]")
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("line %v: expected key=value", n)
+		}
+		if section == "" {
+			return nil, fmt.Errorf("line %v: assignment outside of a section", n)
+		}
+		k := section + "." + strings.TrimSpace(key)
+		values[k] = append(values[k], strings.TrimSpace(value))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// readUnitFile opens and parses the file at path with parseUnitFile.
+func readUnitFile(path string) (map[string][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseUnitFile(f)
+}
+
+// splitUnitWords splits value into words the way systemd splits the value of
+// a directive such as Environment=: words are separated by whitespace, single
+// or double quotes group characters, including whitespace, into a word, and
+// outside single quotes a backslash escapes the character after it.
+func splitUnitWords(value string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, c := range value {
+		switch {
+		case escaped:
+			switch c {
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			}
+			word.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			inWord = true
+			escaped = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			inWord = true
+			quote = c
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			inWord = true
+			word.WriteRune(c)
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash in '%v'", value)
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in '%v'", value)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// validateEnvironment checks that every assignment in an Environment=
+// directive value is a valid KEY=VALUE pair.
+func validateEnvironment(value string) error {
+	re := regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+	assignments, err := splitUnitWords(value)
+	if err != nil {
+		return fmt.Errorf("invalid environment: %w", err)
+	}
+	for _, assignment := range assignments {
+		key, _, found := strings.Cut(assignment, "=")
+		if !found || !re.MatchString(key) {
+			return fmt.Errorf("invalid environment assignment '%v'", assignment)
+		}
+	}
+	return nil
+}
+
+// validateWorker checks the data files of the worker name, looking for the
+// D-Bus service file and policy configuration in servicesDir and policyDir
+// and for the systemd service unit in unitsDir. An empty policyDir skips the
+// policy configuration, which the session bus does not need. It returns a
+// list of problems found; an empty list means the worker is valid.
+func validateWorker(name, servicesDir, policyDir, unitsDir string) []string {
+	problems := []string{}
+	busName := "com.redhat.Yggdrasil1.Worker1." + name
+
+	if policyDir != "" {
+		if _, err := os.Stat(filepath.Join(policyDir, busName+".conf")); err != nil {
+			problems = append(problems, fmt.Sprintf("cannot find D-Bus policy configuration: %v", err))
+		}
+	}
+
+	service, err := readUnitFile(filepath.Join(servicesDir, busName+".service"))
+	if err != nil {
+		return append(problems, fmt.Sprintf("cannot read D-Bus service file: %v", err))
+	}
+	if got := service["D-BUS Service.Name"]; len(got) != 1 || got[0] != busName {
+		problems = append(problems, fmt.Sprintf("D-Bus service Name must be '%v'", busName))
+	}
+	unitName := busName + ".service"
+	if got := service["D-BUS Service.SystemdService"]; len(got) == 1 {
+		unitName = got[0]
+	} else {
+		problems = append(problems, "D-Bus service file does not set SystemdService")
+	}
+
+	unit, err := readUnitFile(filepath.Join(unitsDir, unitName))
+	if err != nil {
+		return append(problems, fmt.Sprintf("cannot read systemd unit: %v", err))
+	}
+	if got := unit["Service.BusName"]; len(got) != 1 || got[0] != busName {
+		problems = append(problems, fmt.Sprintf("systemd unit BusName must be '%v'", busName))
+	}
+
+	execStart := unit["Service.ExecStart"]
+	if len(execStart) != 1 {
+		problems = append(problems, "systemd unit must set ExecStart exactly once")
+	} else if fields := strings.Fields(execStart[0]); len(fields) == 0 {
+		problems = append(problems, "systemd unit ExecStart is empty")
+	} else {
+		program := strings.TrimLeft(fields[0], "-@:+!")
+		if info, err := os.Stat(program); err != nil {
+			problems = append(problems, fmt.Sprintf("cannot find program: %v", err))
+		} else if info.IsDir() || info.Mode().Perm()&0111 == 0 {
+			problems = append(problems, fmt.Sprintf("program %v is not executable", program))
+		}
+	}
+
+	for _, file := range unit["Service.EnvironmentFile"] {
+		if strings.HasPrefix(file, "-") {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			problems = append(problems, fmt.Sprintf("cannot find environment file: %v", err))
+		}
+	}
+	for _, env := range unit["Service.Environment"] {
+		if err := validateEnvironment(env); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	return problems
+}
+
+// workersValidateAction is the cli action function for the "workers
+// validate" subcommand. It checks the installed data files of every worker,
+// or only the named workers, without starting anything.
+func workersValidateAction(ctx *cli.Context) error {
+	names := ctx.Args().Slice()
+	if len(names) == 0 {
+		var err error
+		names, err = installedWorkers()
+		if err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+
+	if invalid := printWorkerProblems(names); invalid > 0 {
+		return cli.Exit(fmt.Sprintf("%v of %v workers are invalid", invalid, len(names)), 1)
+	}
+
+	return nil
+}
+
+// installedWorkers returns the names of the workers with a D-Bus service
+// file installed where "generate worker-data --install" puts it, sorted by
+// name.
+func installedWorkers() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(installDirs().DBusServices, "com.redhat.Yggdrasil1.Worker1.*.service"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot list workers: %w", err)
+	}
+	names := []string{}
+	for _, match := range matches {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), "com.redhat.Yggdrasil1.Worker1."), ".service")
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// printWorkerProblems validates the data files of each worker in names,
+// installed where "generate worker-data --install" puts them, printing the
+// problems found. It returns the number of invalid workers.
+func printWorkerProblems(names []string) int {
+	dirs := installDirs()
+	invalid := 0
+	for _, name := range names {
+		problems := validateWorker(name, dirs.DBusServices, dirs.DBusPolicy, dirs.SystemdUnits)
+		if len(problems) == 0 {
+			fmt.Printf("%v: OK\n", name)
+			continue
+		}
+		invalid++
+		for _, problem := range problems {
+			fmt.Printf("%v: %v\n", name, problem)
+		}
+	}
+	return invalid
+}
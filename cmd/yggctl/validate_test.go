@@ -0,0 +1,201 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseUnitFile(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		want        map[string][]string
+		wantError   bool
+	}{
+		{
+			description: "valid",
+			input: `# comment
+[Service]
+Environment=A=1
+Environment=B=2
+ExecStart = /usr/bin/true
+`,
+			want: map[string][]string{
+				"Service.Environment": {"A=1", "B=2"},
+				"Service.ExecStart":   {"/usr/bin/true"},
+			},
+		},
+		{
+			description: "outside section",
+			input:       "ExecStart=/usr/bin/true\n",
+			wantError:   true,
+		},
+		{
+			description: "missing value",
+			input:       "[Service]\nExecStart\n",
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got, err := parseUnitFile(strings.NewReader(test.input))
+
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !cmp.Equal(got, test.want) {
+					t.Errorf("%#v != %#v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateEnvironment(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		want        []string
+		wantError   bool
+	}{
+		{
+			description: "plain",
+			input:       "A=1 B=2",
+			want:        []string{"A=1", "B=2"},
+		},
+		{
+			description: "quoted assignment",
+			input:       `"A=hello world" B=2`,
+			want:        []string{"A=hello world", "B=2"},
+		},
+		{
+			description: "quoted value",
+			input:       `A="hello world" B='x y'`,
+			want:        []string{"A=hello world", "B=x y"},
+		},
+		{
+			description: "escaped quote",
+			input:       `"A=say \"hi\""`,
+			want:        []string{`A=say "hi"`},
+		},
+		{
+			description: "invalid key in quoted value",
+			input:       `"A B=1"`,
+			wantError:   true,
+		},
+		{
+			description: "unterminated quote",
+			input:       `A="hello`,
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			err := validateEnvironment(test.input)
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := splitUnitWords(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("%#v != %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateWorker(t *testing.T) {
+	program := filepath.Join(t.TempDir(), "echo-worker")
+	if err := os.WriteFile(program, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		description string
+		input       workerData
+		userMode    bool
+		want        int
+	}{
+		{
+			description: "valid",
+			input: workerData{
+				User:            "worker",
+				Group:           "worker",
+				Name:            "echo",
+				Program:         program,
+				EnvironmentFile: "/run/yggdrasil/worker.env",
+			},
+			want: 0,
+		},
+		{
+			description: "user mode without policy",
+			input: workerData{
+				User:            "worker",
+				Group:           "worker",
+				Name:            "echo",
+				Program:         program,
+				EnvironmentFile: "/run/yggdrasil/worker.env",
+				UserUnit:        true,
+			},
+			userMode: true,
+			want:     0,
+		},
+		{
+			description: "missing program",
+			input: workerData{
+				User:    "worker",
+				Group:   "worker",
+				Name:    "echo",
+				Program: filepath.Join(t.TempDir(), "missing"),
+			},
+			want: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			dir := t.TempDir()
+			if _, err := writeWorkerData(test.input, dir, false); err != nil {
+				t.Fatal(err)
+			}
+
+			policyDir := filepath.Join(dir, "dbus-1", "system.d")
+			if test.userMode {
+				if err := os.RemoveAll(policyDir); err != nil {
+					t.Fatal(err)
+				}
+				policyDir = ""
+			}
+
+			got := validateWorker(
+				test.input.Name,
+				filepath.Join(dir, "dbus-1", "system-services"),
+				policyDir,
+				filepath.Join(dir, "systemd", "system"),
+			)
+
+			if len(got) != test.want {
+				t.Errorf("%v problems, want %v: %v", len(got), test.want, got)
+			}
+		})
+	}
+}
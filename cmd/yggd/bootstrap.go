@@ -0,0 +1,173 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/pelletier/go-toml"
+	"github.com/redhatinsights/yggdrasil/internal/config"
+)
+
+// bootstrapFragment is the name of the drop-in configuration fragment written
+// by bootstrap. Its "00-" prefix lets fragments installed by administrators
+// override the provisioned values.
+const bootstrapFragment = "00-bootstrap.toml"
+
+// bootstrapConfig is the initial configuration returned by a provisioning
+// server.
+type bootstrapConfig struct {
+	Protocol   string   `json:"protocol"`
+	Server     []string `json:"server"`
+	CARoot     string   `json:"ca_root,omitempty"`
+	PathPrefix string   `json:"path_prefix,omitempty"`
+}
+
+// validateBootstrapURL checks that rawURL is an absolute HTTPS URL. The
+// one-time token is sent to it and the CA roots it returns are trusted, so it
+// must not be fetched over an unauthenticated channel.
+func validateBootstrapURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("cannot parse bootstrap URL: %w", err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("bootstrap URL '%v' must be an https URL", rawURL)
+	}
+	return nil
+}
+
+// newBootstrapClient creates the HTTP client used to fetch the initial
+// configuration. It does not follow redirects to URLs that are not HTTPS.
+func newBootstrapClient() *http.Client {
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if err := validateBootstrapURL(req.URL.String()); err != nil {
+				return err
+			}
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// bootstrap fetches the initial configuration from the provisioning server at
+// url with client, authenticating with the one-time token read from
+// tokenFile. The configuration is written as a fragment into the drop-in
+// directory of the configuration file at configFile, creating an empty
+// configuration file if there is none. The CA root certificates, if any, are
+// written into configDir. Neither file is readable by other users. The token
+// file is removed once the configuration is written.
+//
+// If the fragment already exists, the host has already been bootstrapped and
+// nothing is fetched.
+func bootstrap(client *http.Client, url, tokenFile, configFile, configDir string) error {
+	fragment := filepath.Join(config.DropInDir(configFile), bootstrapFragment)
+	if _, err := os.Stat(fragment); err == nil {
+		log.Debugf("found '%v'; skipping bootstrap", fragment)
+		return nil
+	}
+
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return fmt.Errorf("cannot read bootstrap token: %w", err)
+	}
+
+	log.Infof("fetching configuration from %v", url)
+	conf, err := fetchBootstrapConfig(client, url, strings.TrimSpace(string(token)))
+	if err != nil {
+		return err
+	}
+
+	values := map[string]interface{}{
+		config.FlagNameProtocol: conf.Protocol,
+		config.FlagNameServer:   conf.Server,
+	}
+	if conf.PathPrefix != "" {
+		values[config.FlagNamePathPrefix] = conf.PathPrefix
+	}
+	if conf.CARoot != "" {
+		file := filepath.Join(configDir, "bootstrap-ca.pem")
+		if err := writeFileAtomic(file, []byte(conf.CARoot), 0600); err != nil {
+			return fmt.Errorf("cannot write CA root: %w", err)
+		}
+		values[config.FlagNameCaRoot] = []string{file}
+	}
+	tree, err := toml.TreeFromMap(values)
+	if err != nil {
+		return fmt.Errorf("cannot encode configuration: %w", err)
+	}
+
+	if _, err := os.Stat(configFile); os.IsNotExist(err) {
+		if err := writeFileAtomic(configFile, []byte{}, 0644); err != nil {
+			return fmt.Errorf("cannot create configuration file: %w", err)
+		}
+	}
+	data := "# Written by yggd from " + url + "\n" + tree.String()
+	if err := writeFileAtomic(fragment, []byte(data), 0640); err != nil {
+		return fmt.Errorf("cannot write configuration: %w", err)
+	}
+	log.Infof("wrote bootstrap configuration to '%v'", fragment)
+
+	if err := os.Remove(tokenFile); err != nil {
+		log.Warnf("cannot remove bootstrap token: %v", err)
+	}
+	return nil
+}
+
+// fetchBootstrapConfig requests the initial configuration from url with
+// client, passing token as a bearer token, and checks that it is usable.
+func fetchBootstrapConfig(client *http.Client, url, token string) (*bootstrapConfig, error) {
+	if err := validateBootstrapURL(url); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create HTTP request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", UserAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch bootstrap configuration: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot fetch bootstrap configuration: %v: %v", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var conf bootstrapConfig
+	if err := json.Unmarshal(body, &conf); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal bootstrap configuration: %w", err)
+	}
+
+	problems := (&config.Config{Protocol: conf.Protocol, Server: conf.Server}).Validate()
+	if conf.Protocol == "" {
+		problems = append(problems, fmt.Sprintf("%v: must be set", config.FlagNameProtocol))
+	}
+	if conf.CARoot != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(conf.CARoot)) {
+		problems = append(problems, "ca_root: does not contain a PEM encoded certificate")
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid bootstrap configuration: %v", strings.Join(problems, "; "))
+	}
+	return &conf, nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml"
+	"github.com/redhatinsights/yggdrasil/internal/config"
+)
+
+func TestValidateBootstrapURL(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		wantError   bool
+	}{
+		{
+			description: "https",
+			input:       "https://provision.example.com/config",
+		},
+		{
+			description: "http",
+			input:       "http://provision.example.com/config",
+			wantError:   true,
+		},
+		{
+			description: "no scheme",
+			input:       "provision.example.com/config",
+			wantError:   true,
+		},
+		{
+			description: "no host",
+			input:       "https:///config",
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			err := validateBootstrapURL(test.input)
+			if test.wantError && err == nil {
+				t.Errorf("expected error")
+			}
+			if !test.wantError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	tests := []struct {
+		description string
+		token       string
+		response    func(caRoot string) map[string]interface{}
+		useHTTP     bool
+		wantError   bool
+	}{
+		{
+			description: "with CA root",
+			token:       "t0ken",
+			response: func(caRoot string) map[string]interface{} {
+				return map[string]interface{}{"protocol": "mqtt", "server": []string{"mqtts://broker.example.com:8883"}, "ca_root": caRoot}
+			},
+		},
+		{
+			description: "without CA root",
+			token:       "t0ken",
+			response: func(string) map[string]interface{} {
+				return map[string]interface{}{"protocol": "http", "server": []string{"example.com"}, "path_prefix": "api"}
+			},
+		},
+		{
+			description: "wrong token",
+			token:       "stolen",
+			wantError:   true,
+		},
+		{
+			description: "invalid CA root",
+			token:       "t0ken",
+			response: func(string) map[string]interface{} {
+				return map[string]interface{}{"protocol": "mqtt", "server": []string{"mqtts://broker.example.com:8883"}, "ca_root": "not a certificate"}
+			},
+			wantError: true,
+		},
+		{
+			description: "plain http",
+			token:       "t0ken",
+			useHTTP:     true,
+			response: func(string) map[string]interface{} {
+				return map[string]interface{}{"protocol": "http", "server": []string{"example.com"}}
+			},
+			wantError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			var caRoot string
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer t0ken" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(test.response(caRoot))
+			})
+			var srv *httptest.Server
+			if test.useHTTP {
+				srv = httptest.NewServer(handler)
+			} else {
+				srv = httptest.NewTLSServer(handler)
+				caRoot = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+			}
+			defer srv.Close()
+
+			dir := t.TempDir()
+			tokenFile := filepath.Join(dir, "bootstrap-token")
+			if err := os.WriteFile(tokenFile, []byte(test.token+"\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+			configFile := filepath.Join(dir, "config.toml")
+			fragment := filepath.Join(config.DropInDir(configFile), bootstrapFragment)
+
+			err := bootstrap(srv.Client(), srv.URL, tokenFile, configFile, dir)
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				if _, err := os.Stat(fragment); !os.IsNotExist(err) {
+					t.Errorf("fragment written after failed bootstrap")
+				}
+				if _, err := os.Stat(tokenFile); err != nil {
+					t.Errorf("token removed after failed bootstrap: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := os.Stat(tokenFile); !os.IsNotExist(err) {
+				t.Errorf("token file not removed")
+			}
+			info, err := os.Stat(fragment)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm()&0007 != 0 {
+				t.Errorf("fragment is readable by other users: %v", info.Mode())
+			}
+			tree, err := toml.LoadFile(fragment)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := test.response(caRoot)
+			if got := tree.Get(config.FlagNameProtocol); got != want["protocol"] {
+				t.Errorf("got protocol %v, want %v", got, want["protocol"])
+			}
+
+			caFile := filepath.Join(dir, "bootstrap-ca.pem")
+			if caRoot, has := want["ca_root"]; has {
+				info, err := os.Stat(caFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Mode().Perm()&0077 != 0 {
+					t.Errorf("CA root is readable by other users: %v", info.Mode())
+				}
+				data, err := os.ReadFile(caFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != caRoot {
+					t.Errorf("got CA root %q, want %q", data, caRoot)
+				}
+				if !strings.Contains(tree.String(), caFile) {
+					t.Errorf("fragment does not reference %v", caFile)
+				}
+			} else if _, err := os.Stat(caFile); !os.IsNotExist(err) {
+				t.Errorf("CA root written without ca_root")
+			}
+
+			// A second run finds the fragment and fetches nothing.
+			if err := bootstrap(srv.Client(), "https://invalid.example.com", tokenFile, configFile, dir); err != nil {
+				t.Errorf("unexpected error on second run: %v", err)
+			}
+		})
+	}
+}
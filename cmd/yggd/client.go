@@ -3,23 +3,34 @@ package main
 import (
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
 	"git.sr.ht/~spc/go-log"
 	"github.com/godbus/dbus/v5"
 	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
 	"github.com/google/uuid"
 	"github.com/redhatinsights/yggdrasil"
 	internaldbus "github.com/redhatinsights/yggdrasil/dbus"
+	"github.com/redhatinsights/yggdrasil/internal/audit"
+	"github.com/redhatinsights/yggdrasil/internal/clientid"
 	"github.com/redhatinsights/yggdrasil/internal/config"
 	"github.com/redhatinsights/yggdrasil/internal/constants"
+	"github.com/redhatinsights/yggdrasil/internal/facts"
+	"github.com/redhatinsights/yggdrasil/internal/history"
+	"github.com/redhatinsights/yggdrasil/internal/hook"
+	"github.com/redhatinsights/yggdrasil/internal/logging"
 	"github.com/redhatinsights/yggdrasil/internal/messagejournal"
-	"github.com/redhatinsights/yggdrasil/internal/tags"
+	"github.com/redhatinsights/yggdrasil/internal/signature"
+	"github.com/redhatinsights/yggdrasil/internal/sync"
 	"github.com/redhatinsights/yggdrasil/internal/transport"
 	"github.com/redhatinsights/yggdrasil/internal/work"
 	"github.com/redhatinsights/yggdrasil/ipc"
@@ -38,6 +49,17 @@ type Client struct {
 	transporter         transport.Transporter
 	dispatcher          *work.Dispatcher
 	prevDispatchersHash atomic.Value
+	announcedFactsHash  atomic.Value
+	connected           atomic.Bool
+	lastReceived        atomic.Int64
+	disconnectRequested atomic.Bool
+	shuttingDown        atomic.Bool
+	pings               sync.RWMutexMap[chan struct{}]
+	props               *prop.Properties
+	configFile          string
+	clientIDSource      string
+	verifier            atomic.Pointer[signature.Verifier]
+	hooks               *hook.ConnectionHooks
 }
 
 // NewClient creates a new Client configured with dispatcher and transporter.
@@ -110,10 +132,43 @@ func (c *Client) Connect() error {
 		}
 	}()
 
+	// start receiving messages the dispatcher failed to deliver and report
+	// each to the server with a "dispatch-failed" event.
+	go func() {
+		for data := range c.dispatcher.Failures {
+			c.sendResponseEvent(data.MessageID, yggdrasil.EventNameDispatchFailed)
+		}
+	}()
+
+	// start receiving messages the dispatcher discarded as expired and report
+	// each to the server with a "message-expired" event.
+	go func() {
+		for data := range c.dispatcher.Expired {
+			c.sendResponseEvent(data.MessageID, yggdrasil.EventNameMessageExpired)
+		}
+	}()
+
+	// start receiving messages deferred by a worker's rate limit and report
+	// each to the server with a "message-deferred" event.
+	go func() {
+		for data := range c.dispatcher.Deferred {
+			c.sendResponseEvent(data.MessageID, yggdrasil.EventNameMessageDeferred)
+		}
+	}()
+
+	// start receiving messages workers did not respond to in time and report
+	// each to the server with a "response-timeout" event.
+	go func() {
+		for data := range c.dispatcher.Timeouts {
+			c.sendResponseEvent(data.MessageID, yggdrasil.EventNameResponseTimeout)
+		}
+	}()
+
 	// set a transport RxHandlerFunc that calls the client's control and data
 	// receive handler functions.
 	err := c.transporter.SetRxHandler(
 		func(addr string, metadata map[string]interface{}, data []byte) error {
+			c.lastReceived.Store(time.Now().UnixNano())
 			switch addr {
 			case "data":
 				var message yggdrasil.Data
@@ -121,6 +176,10 @@ func (c *Client) Connect() error {
 				if err := json.Unmarshal(data, &message); err != nil {
 					return fmt.Errorf("cannot unmarshal data message: %w", err)
 				}
+				if err := c.checkSignature(signature.DataPayload(&message), message.Metadata, message.Sent); err != nil {
+					c.rejectMessage(message.MessageID, err)
+					return fmt.Errorf("rejected data message %v: %w", message.MessageID, err)
+				}
 				if err := c.ReceiveDataMessage(&message); err != nil {
 					return fmt.Errorf("cannot process data message: %w", err)
 				}
@@ -130,6 +189,10 @@ func (c *Client) Connect() error {
 				if err := json.Unmarshal(data, &message); err != nil {
 					return fmt.Errorf("cannot unmarshal control message: %w", err)
 				}
+				if err := c.checkSignature(signature.ControlPayload(&message), message.Metadata, message.Sent); err != nil {
+					c.rejectMessage(message.MessageID, err)
+					return fmt.Errorf("rejected control message %v: %w", message.MessageID, err)
+				}
 				if err := c.ReceiveControlMessage(&message); err != nil {
 					return fmt.Errorf("cannot process control message: %w", err)
 				}
@@ -146,10 +209,25 @@ func (c *Client) Connect() error {
 	_ = c.transporter.SetEventHandler(func(e transport.TransporterEvent) {
 		switch e {
 		case transport.TransporterEventConnected:
+			c.setConnected(true, hook.ReasonConnected)
+			systemdStatus("connected")
 			if err := c.dispatcher.EmitEvent(ipc.DispatcherEventConnectionRestored); err != nil {
 				log.Errorf("cannot emit event: %v", err)
 			}
 		case transport.TransporterEventDisconnected:
+			switch {
+			case c.shuttingDown.Load():
+				c.setConnected(false, hook.ReasonShutdown)
+			case c.disconnectRequested.Load():
+				c.setConnected(false, hook.ReasonRequested)
+			default:
+				c.setConnected(false, hook.ReasonLost)
+			}
+			if c.disconnectRequested.Load() {
+				systemdStatus("disconnected by request")
+				return
+			}
+			systemdStatus("disconnected; reconnecting")
 			if err := c.dispatcher.EmitEvent(ipc.DispatcherEventUnexpectedDisconnect); err != nil {
 				log.Errorf("cannot emit event: %v", err)
 			}
@@ -176,6 +254,24 @@ func (c *Client) Connect() error {
 		return fmt.Errorf("cannot export org.freedesktop.DBus.Introspectable interface: %v", err)
 	}
 
+	c.props, err = prop.Export(c.conn, "/com/redhat/Yggdrasil1", prop.Map{
+		"com.redhat.Yggdrasil1": {
+			"ClientID": {
+				Value:    config.DefaultConfig.ClientID,
+				Writable: false,
+				Emit:     prop.EmitConst,
+			},
+			"ConnectionState": {
+				Value:    connectionState(c.connected.Load()),
+				Writable: false,
+				Emit:     prop.EmitTrue,
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot export org.freedesktop.DBus.Properties interface: %v", err)
+	}
+
 	reply, err := c.conn.RequestName("com.redhat.Yggdrasil1", dbus.NameFlagDoNotQueue)
 	if err != nil {
 		return fmt.Errorf("cannot request name on bus: %v", err)
@@ -191,7 +287,7 @@ func (c *Client) Connect() error {
 		for e := range c.dispatcher.WorkerEvents {
 			args := []interface{}{e.Worker, e.Name, e.MessageID, e.ResponseTo}
 			switch e.Name {
-			case ipc.WorkerEventNameWorking:
+			case ipc.WorkerEventNameWorking, ipc.WorkerEventNameCrashed:
 				args = append(args, e.Data)
 			}
 			if err := c.conn.Emit("/com/redhat/Yggdrasil1", "com.redhat.Yggdrasil1.WorkerEvent", args...); err != nil {
@@ -202,6 +298,45 @@ func (c *Client) Connect() error {
 		}
 	}()
 
+	// Start a goroutine receiving values from the dispatcher's DispatchEvents
+	// channel, emitting a D-Bus "DispatchEvent" signal for each, and a
+	// "MessageDispatched" signal for each message dispatched to a worker.
+	go func() {
+		for e := range c.dispatcher.DispatchEvents {
+			if err := c.conn.Emit("/com/redhat/Yggdrasil1", "com.redhat.Yggdrasil1.DispatchEvent", e.Map()); err != nil {
+				log.Errorf("cannot emit event: %v", err)
+			}
+			if e.Name != work.DispatchEventDispatched {
+				continue
+			}
+			if err := c.conn.Emit("/com/redhat/Yggdrasil1", "com.redhat.Yggdrasil1.MessageDispatched", e.MessageID, e.Directive); err != nil {
+				log.Errorf("cannot emit event: %v", err)
+			}
+		}
+	}()
+
+	// Start a goroutine receiving values from the dispatcher's WorkerLifecycle
+	// channel, emitting a D-Bus "WorkerStarted", "WorkerStopped",
+	// "WorkerCrashed" or "WorkerRestarted" signal for each.
+	go func() {
+		for e := range c.dispatcher.WorkerLifecycle {
+			var err error
+			switch e.Name {
+			case work.WorkerLifecycleStarted:
+				err = c.conn.Emit("/com/redhat/Yggdrasil1", "com.redhat.Yggdrasil1.WorkerStarted", e.Worker, e.PID)
+			case work.WorkerLifecycleStopped:
+				err = c.conn.Emit("/com/redhat/Yggdrasil1", "com.redhat.Yggdrasil1.WorkerStopped", e.Worker, e.PID, e.ExitStatus)
+			case work.WorkerLifecycleCrashed:
+				err = c.conn.Emit("/com/redhat/Yggdrasil1", "com.redhat.Yggdrasil1.WorkerCrashed", e.Worker, e.PID, e.ExitStatus, e.Report)
+			case work.WorkerLifecycleRestarted:
+				err = c.conn.Emit("/com/redhat/Yggdrasil1", "com.redhat.Yggdrasil1.WorkerRestarted", e.Worker, e.PID, e.Restarts)
+			}
+			if err != nil {
+				log.Errorf("cannot emit event: %v", err)
+			}
+		}
+	}()
+
 	return c.transporter.Connect()
 }
 
@@ -210,6 +345,94 @@ func (c *Client) ListWorkers() (map[string]map[string]string, *dbus.Error) {
 	return c.dispatcher.FlattenDispatchers(), nil
 }
 
+// Status implements the com.redhat.Yggdrasil1.Status method.
+func (c *Client) Status() (map[string]string, *dbus.Error) {
+	status := map[string]string{
+		"connected":        strconv.FormatBool(c.connected.Load()),
+		"transport":        config.DefaultConfig.Protocol,
+		"server":           strings.Join(config.DefaultConfig.Server, ","),
+		"client_id":        config.DefaultConfig.ClientID,
+		"client_id_source": c.clientIDSource,
+		"last_message":     "",
+	}
+	if t := c.lastReceived.Load(); t != 0 {
+		status["last_message"] = time.Unix(0, t).UTC().Format(time.RFC3339)
+	}
+
+	var workers, running int
+	for _, s := range c.dispatcher.WorkerStatus() {
+		workers++
+		if s["running"] == "true" {
+			running++
+		}
+	}
+	status["workers"] = strconv.Itoa(workers)
+	status["workers_running"] = strconv.Itoa(running)
+
+	pending, err := c.dispatcher.PendingMessages()
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	status["pending_messages"] = strconv.Itoa(pending)
+
+	deadLetters := 0
+	if letters, err := c.dispatcher.DeadLetters(); err == nil {
+		deadLetters = len(letters)
+	}
+	status["dead_letters"] = strconv.Itoa(deadLetters)
+
+	level, until := logLevelStatus()
+	status["log_level"] = level
+	status["log_level_until"] = ""
+	if !until.IsZero() {
+		status["log_level_until"] = until.UTC().Format(time.RFC3339)
+	}
+
+	return status, nil
+}
+
+// WorkerMetrics implements the com.redhat.Yggdrasil1.WorkerMetrics method.
+func (c *Client) WorkerMetrics() (map[string]map[string]string, *dbus.Error) {
+	return c.dispatcher.WorkerMetrics(), nil
+}
+
+// WorkerStatus implements the com.redhat.Yggdrasil1.WorkerStatus method.
+func (c *Client) WorkerStatus() (map[string]map[string]string, *dbus.Error) {
+	return c.dispatcher.WorkerStatus(), nil
+}
+
+// RecentDispatchEvents implements the
+// com.redhat.Yggdrasil1.RecentDispatchEvents method.
+func (c *Client) RecentDispatchEvents() ([]map[string]string, *dbus.Error) {
+	events := []map[string]string{}
+	for _, e := range c.dispatcher.RecentDispatchEvents() {
+		events = append(events, e.Map())
+	}
+	return events, nil
+}
+
+// EnableWorker implements the com.redhat.Yggdrasil1.EnableWorker method.
+func (c *Client) EnableWorker(worker string) *dbus.Error {
+	if err := c.dispatcher.SetWorkerEnabled(worker, true); err != nil {
+		return work.NewDBusError(
+			"com.redhat.Yggdrasil1.EnableWorker",
+			fmt.Sprintf("cannot enable worker: %v", err),
+		)
+	}
+	return nil
+}
+
+// DisableWorker implements the com.redhat.Yggdrasil1.DisableWorker method.
+func (c *Client) DisableWorker(worker string) *dbus.Error {
+	if err := c.dispatcher.SetWorkerEnabled(worker, false); err != nil {
+		return work.NewDBusError(
+			"com.redhat.Yggdrasil1.DisableWorker",
+			fmt.Sprintf("cannot disable worker: %v", err),
+		)
+	}
+	return nil
+}
+
 // MessageJournal implements the com.redhat.Yggdrasil1.MessageJournal method.
 func (c *Client) MessageJournal(
 	messageID string,
@@ -236,6 +459,293 @@ func (c *Client) MessageJournal(
 	return journal, nil
 }
 
+// MessageHistory implements the com.redhat.Yggdrasil1.MessageHistory method.
+func (c *Client) MessageHistory(
+	messageID string,
+	directive string,
+	since string,
+	until string,
+) ([]map[string]string, *dbus.Error) {
+	if c.dispatcher.History == nil {
+		return nil, dbus.MakeFailedError(fmt.Errorf("message history is not enabled"))
+	}
+
+	filter := history.Filter{
+		MessageID: messageID,
+		Directive: directive,
+	}
+	for _, t := range []struct {
+		value string
+		dest  *time.Time
+	}{
+		{since, &filter.Since},
+		{until, &filter.Until},
+	} {
+		if t.value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, t.value)
+		if err != nil {
+			return nil, dbus.MakeFailedError(fmt.Errorf("cannot parse time: %w", err))
+		}
+		*t.dest = parsed
+	}
+
+	entries, err := c.dispatcher.History.Entries(filter)
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	messages := []map[string]string{}
+	for _, e := range entries {
+		messages = append(messages, e.Map())
+	}
+	return messages, nil
+}
+
+// Ping implements the com.redhat.Yggdrasil1.Ping method. It sends a "ping"
+// command to the server and waits up to timeout seconds for the server to
+// respond with a "pong" event.
+func (c *Client) Ping(timeout uint32) (map[string]string, *dbus.Error) {
+	if config.DefaultConfig.Protocol == "none" {
+		return nil, dbus.MakeFailedError(fmt.Errorf("no transport protocol is configured"))
+	}
+
+	command, err := json.Marshal(yggdrasil.Command{Command: yggdrasil.CommandNamePing})
+	if err != nil {
+		return nil, dbus.MakeFailedError(fmt.Errorf("cannot marshal command: %w", err))
+	}
+	msg := yggdrasil.Control{
+		Type:      yggdrasil.MessageTypeCommand,
+		MessageID: uuid.New().String(),
+		Version:   1,
+		Sent:      time.Now(),
+		Content:   command,
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, dbus.MakeFailedError(fmt.Errorf("cannot marshal message: %w", err))
+	}
+
+	pong := make(chan struct{}, 1)
+	c.pings.Set(msg.MessageID, pong)
+	defer c.pings.Del(msg.MessageID)
+
+	start := time.Now()
+	if _, _, _, err := c.transporter.Tx("control", nil, data); err != nil {
+		return nil, dbus.MakeFailedError(fmt.Errorf("cannot send ping: %w", err))
+	}
+	result := map[string]string{
+		"message_id": msg.MessageID,
+		"publish":    time.Since(start).String(),
+		"round_trip": "",
+	}
+
+	select {
+	case <-pong:
+		result["round_trip"] = time.Since(start).String()
+	case <-time.After(time.Duration(timeout) * time.Second):
+		log.Warnf("no response to ping %v within %vs", msg.MessageID, timeout)
+	}
+	return result, nil
+}
+
+// DisconnectTransport implements the
+// com.redhat.Yggdrasil1.DisconnectTransport method. It informs the server that
+// the client is disconnecting, giving reason, and disconnects the transport
+// until ConnectTransport is called. Workers keep running.
+func (c *Client) DisconnectTransport(reason string) *dbus.Error {
+	if config.DefaultConfig.Protocol == "none" {
+		return dbus.MakeFailedError(fmt.Errorf("no transport protocol is configured"))
+	}
+	if !c.disconnectRequested.CompareAndSwap(false, true) {
+		return dbus.MakeFailedError(fmt.Errorf("transport is already disconnected"))
+	}
+
+	event := yggdrasil.Event{
+		Type:      yggdrasil.MessageTypeEvent,
+		MessageID: uuid.New().String(),
+		Version:   1,
+		Sent:      time.Now(),
+		Content:   string(yggdrasil.EventNameDisconnect),
+		Reason:    reason,
+	}
+	if _, _, _, err := c.SendEventMessage(&event); err != nil {
+		log.Warnf("cannot send disconnect event: %v", err)
+	}
+
+	log.Infof("disconnecting transport by request: %v", reason)
+	c.transporter.Disconnect(500)
+	c.setConnected(false, hook.ReasonRequested)
+	systemdStatus("disconnected by request")
+
+	return nil
+}
+
+// ConnectTransport implements the com.redhat.Yggdrasil1.ConnectTransport
+// method. It reconnects a transport disconnected with DisconnectTransport.
+func (c *Client) ConnectTransport() *dbus.Error {
+	if !c.disconnectRequested.Load() {
+		return dbus.MakeFailedError(fmt.Errorf("transport is not disconnected"))
+	}
+
+	log.Info("connecting transport by request")
+	if err := c.transporter.Connect(); err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("cannot connect transport: %w", err))
+	}
+	c.disconnectRequested.Store(false)
+	go publishConnectionStatus(c)
+
+	return nil
+}
+
+// Shutdown stops dispatching received messages and waits up to timeout for
+// the messages in progress to be handled, so their responses are still
+// transmitted. It then informs the server that the client is going offline
+// and disconnects the transport, giving pending transmissions time to
+// complete, and waits for the disconnect hook to finish.
+func (c *Client) Shutdown(timeout time.Duration) {
+	log.Infof("waiting up to %v for messages in progress", timeout)
+	if c.dispatcher.Drain(timeout) {
+		log.Info("all messages in progress were handled")
+	}
+
+	if config.DefaultConfig.Protocol == "none" || c.disconnectRequested.Load() {
+		return
+	}
+	c.shuttingDown.Store(true)
+
+	msg, err := c.ConnectionStatus()
+	if err != nil {
+		log.Errorf("cannot get connection status: %v", err)
+	} else {
+		msg.Content.State = yggdrasil.ConnectionStateOffline
+		if _, _, _, err := c.SendConnectionStatusMessage(msg); err != nil {
+			log.Warnf("cannot send connection status: %v", err)
+		}
+	}
+
+	log.Info("disconnecting transport")
+	c.transporter.Disconnect(500)
+	c.setConnected(false, hook.ReasonShutdown)
+	if c.hooks != nil && !c.hooks.Wait(30*time.Second) {
+		log.Warn("disconnect hook did not finish")
+	}
+}
+
+// RegenerateClientID implements the com.redhat.Yggdrasil1.RegenerateClientID
+// method. It replaces the persisted client ID with a new random ID and, if a
+// transport is configured, informs the server and disconnects, since the new
+// ID is only used once yggd is restarted.
+func (c *Client) RegenerateClientID() (string, *dbus.Error) {
+	if c.clientIDSource != clientid.SourceFile {
+		return "", dbus.MakeFailedError(
+			fmt.Errorf("client ID is derived from %v and cannot be regenerated", c.clientIDSource),
+		)
+	}
+
+	id, err := clientid.Generate(filepath.Join(constants.StateDir, "client-id"))
+	if err != nil {
+		return "", dbus.MakeFailedError(fmt.Errorf("cannot generate client ID: %w", err))
+	}
+	log.Infof("regenerated client ID %v; restart to use it", id)
+
+	if config.DefaultConfig.Protocol != "none" && !c.disconnectRequested.Load() {
+		if err := c.DisconnectTransport("client ID regenerated"); err != nil {
+			return "", err
+		}
+	}
+	return id, nil
+}
+
+// CanonicalFacts implements the com.redhat.Yggdrasil1.CanonicalFacts method.
+func (c *Client) CanonicalFacts() (string, *dbus.Error) {
+	msg, err := c.ConnectionStatus()
+	if err != nil {
+		return "", dbus.MakeFailedError(fmt.Errorf("cannot get connection status: %w", err))
+	}
+	facts := msg.Content.CanonicalFacts
+	if facts == nil {
+		facts = map[string]interface{}{}
+	}
+	data, err := json.Marshal(facts)
+	if err != nil {
+		return "", dbus.MakeFailedError(fmt.Errorf("cannot marshal facts: %w", err))
+	}
+	return string(data), nil
+}
+
+// setConnected records whether the transport is connected, updating the
+// ConnectionState D-Bus property. If the state changed, the hook configured
+// for the change is run, given reason.
+func (c *Client) setConnected(connected bool, reason string) {
+	changed := c.connected.Swap(connected) != connected
+	if c.props != nil {
+		c.props.SetMust("com.redhat.Yggdrasil1", "ConnectionState", connectionState(connected))
+	}
+	if changed && c.hooks != nil {
+		c.hooks.Notify(hook.Event{
+			Connected: connected,
+			Reason:    reason,
+			Time:      time.Now(),
+			Protocol:  config.DefaultConfig.Protocol,
+			Servers:   config.DefaultConfig.Server,
+			ClientID:  config.DefaultConfig.ClientID,
+		})
+	}
+}
+
+// connectionState returns the value of the ConnectionState D-Bus property.
+func connectionState(connected bool) string {
+	if connected {
+		return "connected"
+	}
+	return "disconnected"
+}
+
+// Reconnect implements the com.redhat.Yggdrasil1.Reconnect method.
+func (c *Client) Reconnect() *dbus.Error {
+	if config.DefaultConfig.Protocol == "none" {
+		return dbus.MakeFailedError(fmt.Errorf("no transport protocol is configured"))
+	}
+	if c.disconnectRequested.Load() {
+		return dbus.MakeFailedError(fmt.Errorf("transport is disconnected by request"))
+	}
+
+	log.Info("reconnecting transport by request")
+	c.transporter.Disconnect(500)
+	if err := c.transporter.Connect(); err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("cannot connect transport: %w", err))
+	}
+	go publishConnectionStatus(c)
+
+	return nil
+}
+
+// ReloadConfig implements the com.redhat.Yggdrasil1.ReloadConfig method.
+func (c *Client) ReloadConfig() *dbus.Error {
+	if c.configFile == "" {
+		return dbus.MakeFailedError(fmt.Errorf("no configuration file is in use"))
+	}
+	if err := reloadConfigAndNotify(c.configFile, c); err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("cannot reload configuration: %w", err))
+	}
+	return nil
+}
+
+// SetLogLevel implements the com.redhat.Yggdrasil1.SetLogLevel method.
+func (c *Client) SetLogLevel(level string, duration uint32) *dbus.Error {
+	if level == "" {
+		restoreLogLevel()
+		return nil
+	}
+	l, err := log.ParseLevel(level)
+	if err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("cannot parse log level: %w", err))
+	}
+	overrideLogLevel(l, time.Duration(duration)*time.Second)
+	return nil
+}
+
 // Dispatch implements the com.redhat.Yggdrasil1.Dispatch method.
 func (c *Client) Dispatch(
 	directive string,
@@ -262,10 +772,84 @@ func (c *Client) Dispatch(
 	return nil
 }
 
+// Receive implements the com.redhat.Yggdrasil1.Receive method.
+func (c *Client) Receive(
+	directive string,
+	messageID string,
+	responseTo string,
+	metadata map[string]string,
+	data []byte,
+) *dbus.Error {
+	msg := yggdrasil.Data{
+		Type:       yggdrasil.MessageTypeData,
+		MessageID:  messageID,
+		ResponseTo: responseTo,
+		Version:    1,
+		Sent:       time.Now(),
+		Directive:  directive,
+		Metadata:   metadata,
+		Content:    data,
+	}
+	log.Infof("received message %v for directive %v over D-Bus", messageID, directive)
+	if err := c.ReceiveDataMessage(&msg); err != nil {
+		return work.NewDBusError(
+			"com.redhat.Yggdrasil1.Receive",
+			fmt.Sprintf("cannot receive message: %v", err),
+		)
+	}
+	return nil
+}
+
+// ListDeadLetters implements the com.redhat.Yggdrasil1.ListDeadLetters method.
+func (c *Client) ListDeadLetters() ([]map[string]string, *dbus.Error) {
+	letters, err := c.dispatcher.DeadLetters()
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	entries := []map[string]string{}
+	for _, letter := range letters {
+		entries = append(entries, map[string]string{
+			"message_id": letter.Data.MessageID,
+			"directive":  letter.Data.Directive,
+			"time":       letter.Time.Format(time.RFC3339),
+			"reason":     letter.Reason,
+		})
+	}
+	return entries, nil
+}
+
+// RedriveDeadLetter implements the com.redhat.Yggdrasil1.RedriveDeadLetter
+// method.
+func (c *Client) RedriveDeadLetter(messageID string) *dbus.Error {
+	if err := c.dispatcher.RedriveDeadLetter(messageID); err != nil {
+		return work.NewDBusError(
+			"com.redhat.Yggdrasil1.RedriveDeadLetter",
+			fmt.Sprintf("cannot redrive message: %v", err),
+		)
+	}
+	return nil
+}
+
+// Cancel implements the com.redhat.Yggdrasil1.Cancel method.
+func (c *Client) Cancel(directive string, cancelID string) *dbus.Error {
+	directive, err := work.ScrubName(directive)
+	if err != nil {
+		log.Debug(err)
+	}
+	if err := c.dispatcher.CancelMessage(directive, uuid.New().String(), cancelID); err != nil {
+		return work.NewDBusError(
+			"com.redhat.Yggdrasil1.Cancel",
+			fmt.Sprintf("cannot cancel message: %v", err),
+		)
+	}
+	return nil
+}
+
 func (c *Client) SendDataMessage(
 	msg *yggdrasil.Data,
 	metadata map[string]string,
 ) (int, map[string]string, []byte, error) {
+	log.Tracef("sending message %v: %v", msg.MessageID, logging.Payload(msg.Content, msg.Metadata))
 	return c.sendMessage("data", metadata, msg)
 }
 
@@ -276,9 +860,21 @@ func (c *Client) SendConnectionStatusMessage(
 	if err != nil {
 		return transport.TxResponseErr, nil, nil, err
 	}
+	c.announcedFactsHash.Store(factsHash(msg.Content.CanonicalFacts))
 	return code, metadata, data, nil
 }
 
+// factsHash returns a checksum of facts, used to detect changes to the facts
+// announced to the server.
+func factsHash(facts map[string]interface{}) string {
+	data, err := json.Marshal(facts)
+	if err != nil {
+		log.Errorf("cannot marshal facts to JSON: %v", err)
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
 func (c *Client) SendEventMessage(msg *yggdrasil.Event) (int, map[string]string, []byte, error) {
 	code, metadata, data, err := c.sendMessage("control", nil, msg)
 	if err != nil {
@@ -287,6 +883,22 @@ func (c *Client) SendEventMessage(msg *yggdrasil.Event) (int, map[string]string,
 	return code, metadata, data, nil
 }
 
+// sendResponseEvent sends an event named name to the server in response to
+// the message identified by responseTo, logging any error.
+func (c *Client) sendResponseEvent(responseTo string, name yggdrasil.EventName) {
+	event := yggdrasil.Event{
+		Type:       yggdrasil.MessageTypeEvent,
+		MessageID:  uuid.New().String(),
+		ResponseTo: responseTo,
+		Version:    1,
+		Sent:       time.Now(),
+		Content:    string(name),
+	}
+	if _, _, _, err := c.SendEventMessage(&event); err != nil {
+		log.Errorf("cannot send event message: %v", err)
+	}
+}
+
 // sendMessage marshals msg as data and transmits it via the transport.
 func (c *Client) sendMessage(
 	dest string,
@@ -300,8 +912,33 @@ func (c *Client) sendMessage(
 	return c.transporter.Tx(dest, metadata, data)
 }
 
+// checkSignature verifies the signature in the metadata of a message received
+// from the server against the trusted keys. A message without a signature is
+// accepted unless require-signatures is set, and no message is checked if
+// neither trusted keys nor require-signatures are set.
+func (c *Client) checkSignature(payload []byte, metadata map[string]string, sent time.Time) error {
+	verifier := c.verifier.Load()
+	if verifier.Len() == 0 && !config.DefaultConfig.RequireSignatures {
+		return nil
+	}
+	err := verifier.Verify(payload, metadata, sent)
+	if errors.Is(err, signature.ErrUnsigned) && !config.DefaultConfig.RequireSignatures {
+		return nil
+	}
+	return err
+}
+
+// rejectMessage logs and audits a message that failed signature verification.
+func (c *Client) rejectMessage(messageID string, reason error) {
+	log.Warnf("rejected message %v: %v", messageID, reason)
+	if err := c.dispatcher.Audit.Append(audit.ActionRejected, "", messageID, reason.Error()); err != nil {
+		log.Errorf("cannot add audit record: %v", err)
+	}
+}
+
 // ReceiveDataMessage sends a value to a channel for dispatching to worker processes.
 func (c *Client) ReceiveDataMessage(msg *yggdrasil.Data) error {
+	log.Tracef("received message %v: %v", msg.MessageID, logging.Payload(msg.Content, msg.Metadata))
 	c.dispatcher.Inbound <- *msg
 
 	return nil
@@ -318,7 +955,17 @@ func (c *Client) ReceiveControlMessage(msg *yggdrasil.Control) error {
 
 		log.Debugf("received message %v", msg.MessageID)
 		log.Tracef("command: %+v", cmd.Command)
-		log.Tracef("Control message: %v", msg)
+		detail := fmt.Sprintf("command %v %v", cmd.Command, cmd.Arguments)
+		if err := c.dispatcher.Audit.Append(audit.ActionControlReceived, "", msg.MessageID, detail); err != nil {
+			log.Errorf("cannot add audit record: %v", err)
+		}
+		log.Tracef("control message %v: %v", msg.MessageID, logging.Payload(msg.Content, msg.Metadata))
+
+		if work.MessageExpired(msg.Sent, nil, config.DefaultConfig.MessageMaxAge, time.Now()) {
+			log.Warnf("discarding expired command %v", msg.MessageID)
+			c.sendResponseEvent(msg.MessageID, yggdrasil.EventNameMessageExpired)
+			return nil
+		}
 
 		switch cmd.Command {
 		case yggdrasil.CommandNamePing:
@@ -376,9 +1023,27 @@ func (c *Client) ReceiveControlMessage(msg *yggdrasil.Control) error {
 			if err := c.dispatcher.CancelMessage(directive, msg.MessageID, cancelID); err != nil {
 				return fmt.Errorf("cannot dispatch cancel message: %w", err)
 			}
+		case yggdrasil.CommandNameWorker:
+			return c.controlWorker(msg.MessageID, cmd.Arguments["name"], cmd.Arguments["action"], c.dispatcher.ControlWorker)
 		default:
 			return fmt.Errorf("unknown command: %v", cmd.Command)
 		}
+	case yggdrasil.MessageTypeEvent:
+		var event yggdrasil.EventName
+		if err := json.Unmarshal(msg.Content, &event); err != nil {
+			return fmt.Errorf("cannot unmarshal event message: %w", err)
+		}
+		if event != yggdrasil.EventNamePong {
+			return fmt.Errorf("unsupported event: %v", event)
+		}
+		pong, ok := c.pings.Get(msg.ResponseTo)
+		if !ok {
+			return fmt.Errorf("unexpected pong in response to %v", msg.ResponseTo)
+		}
+		select {
+		case pong <- struct{}{}:
+		default:
+		}
 	default:
 		return fmt.Errorf("unsupported control message: %v", msg)
 	}
@@ -386,30 +1051,42 @@ func (c *Client) ReceiveControlMessage(msg *yggdrasil.Control) error {
 	return nil
 }
 
-// ConnectionStatus creates a connection-status message using the current state
-// of the client.
-func (c *Client) ConnectionStatus() (*yggdrasil.ConnectionStatus, error) {
-	var facts map[string]interface{}
-
-	if config.DefaultConfig.FactsFile != "" {
-		data, err := os.ReadFile(config.DefaultConfig.FactsFile)
-		if err != nil {
-			log.Errorf("cannot read facts file: %v", err)
-		}
-		if err := json.Unmarshal(data, &facts); err != nil {
-			log.Errorf("cannot unmarshal facts: %v", err)
+// controlWorker carries out the "worker" command identified by messageID,
+// asking control to apply action to the worker named name if remote control of
+// the worker is allowed. Refused commands are audited, and the outcome is sent
+// to the server as an event in response to the command.
+func (c *Client) controlWorker(messageID, name, action string, control func(worker, action string) error) error {
+	var err error
+	switch {
+	case !slices.Contains(config.DefaultConfig.RemoteWorkerControl, name):
+		err = fmt.Errorf("remote control of worker %v is not allowed", name)
+	case !slices.Contains([]string{"start", "stop", "restart"}, action):
+		err = fmt.Errorf("unsupported action: %v", action)
+	}
+	if err != nil {
+		log.Warnf("rejected request %v to %v worker %v: %v", messageID, action, name, err)
+		if err := c.dispatcher.Audit.Append(audit.ActionRejected, name, messageID, err.Error()); err != nil {
+			log.Errorf("cannot add audit record: %v", err)
 		}
+		c.sendResponseEvent(messageID, yggdrasil.EventNameWorkerControlRejected)
+		return err
 	}
 
-	tagsFilePath := filepath.Join(constants.ConfigDir, "tags.toml")
+	log.Infof("accepted request %v to %v worker %v", messageID, action, name)
+	if err := control(name, action); err != nil {
+		c.sendResponseEvent(messageID, yggdrasil.EventNameWorkerControlFailed)
+		return fmt.Errorf("cannot %v worker %v: %w", action, name, err)
+	}
+	c.sendResponseEvent(messageID, yggdrasil.EventNameWorkerControlled)
+	return nil
+}
 
-	var tagMap map[string]string
-	if _, err := os.Stat(tagsFilePath); !os.IsNotExist(err) {
-		var err error
-		tagMap, err = tags.ReadTagsFile(tagsFilePath)
-		if err != nil {
-			log.Errorf("cannot load tags: %v", err)
-		}
+// ConnectionStatus creates a connection-status message using the current state
+// of the client.
+func (c *Client) ConnectionStatus() (*yggdrasil.ConnectionStatus, error) {
+	tagMap := hostTags()
+	if len(tagMap) == 0 {
+		tagMap = nil
 	}
 
 	msg := yggdrasil.ConnectionStatus{
@@ -424,7 +1101,7 @@ func (c *Client) ConnectionStatus() (*yggdrasil.ConnectionStatus, error) {
 			Tags           map[string]string            "json:\"tags,omitempty\""
 			ClientVersion  string                       "json:\"client_version,omitempty\""
 		}{
-			CanonicalFacts: facts,
+			CanonicalFacts: facts.DefaultRegistry.Collect(),
 			Dispatchers:    c.dispatcher.FlattenDispatchers(),
 			State:          yggdrasil.ConnectionStateOnline,
 			Tags:           tagMap,
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/redhatinsights/yggdrasil"
+	"github.com/redhatinsights/yggdrasil/internal/audit"
+	"github.com/redhatinsights/yggdrasil/internal/config"
+	"github.com/redhatinsights/yggdrasil/internal/transport"
+	"github.com/redhatinsights/yggdrasil/internal/work"
+)
+
+// recordingTransport is a transport.Transporter that records the data sent
+// with Tx.
+type recordingTransport struct {
+	sent [][]byte
+}
+
+func (r *recordingTransport) Connect() error          { return nil }
+func (r *recordingTransport) Disconnect(quiesce uint) {}
+func (r *recordingTransport) Tx(addr string, metadata map[string]string, data []byte) (int, map[string]string, []byte, error) {
+	r.sent = append(r.sent, data)
+	return transport.TxResponseOK, nil, nil, nil
+}
+func (r *recordingTransport) SetRxHandler(f transport.RxHandlerFunc) error       { return nil }
+func (r *recordingTransport) ReloadTLSConfig(tlsConfig *tls.Config) error        { return nil }
+func (r *recordingTransport) SetEventHandler(f transport.EventHandlerFunc) error { return nil }
+
+func TestControlWorker(t *testing.T) {
+	tests := []struct {
+		description string
+		name        string
+		action      string
+		controlErr  error
+		wantEvent   yggdrasil.EventName
+		wantControl bool
+		wantAudit   string
+		wantError   bool
+	}{
+		{
+			description: "success",
+			name:        "echo",
+			action:      "restart",
+			wantEvent:   yggdrasil.EventNameWorkerControlled,
+			wantControl: true,
+		},
+		{
+			description: "not allowed",
+			name:        "other",
+			action:      "restart",
+			wantEvent:   yggdrasil.EventNameWorkerControlRejected,
+			wantAudit:   audit.ActionRejected,
+			wantError:   true,
+		},
+		{
+			description: "unknown action",
+			name:        "echo",
+			action:      "reload",
+			wantEvent:   yggdrasil.EventNameWorkerControlRejected,
+			wantAudit:   audit.ActionRejected,
+			wantError:   true,
+		},
+		{
+			description: "failure",
+			name:        "echo",
+			action:      "start",
+			controlErr:  errors.New("unit not found"),
+			wantEvent:   yggdrasil.EventNameWorkerControlFailed,
+			wantControl: true,
+			wantError:   true,
+		},
+	}
+
+	defaults := config.DefaultConfig
+	t.Cleanup(func() { config.DefaultConfig = defaults })
+	config.DefaultConfig.RemoteWorkerControl = []string{"echo"}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), audit.FileName)
+			auditLog, err := audit.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer auditLog.Close()
+
+			tr := &recordingTransport{}
+			c := NewClient(work.NewDispatcher(nil), tr)
+			c.dispatcher.Audit = auditLog
+
+			controlled := false
+			err = c.controlWorker("m1", test.name, test.action, func(worker, action string) error {
+				controlled = true
+				return test.controlErr
+			})
+
+			if test.wantError && err == nil {
+				t.Errorf("expected error")
+			}
+			if !test.wantError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if controlled != test.wantControl {
+				t.Errorf("controlled: %v != %v", controlled, test.wantControl)
+			}
+
+			if len(tr.sent) != 1 {
+				t.Fatalf("got %v messages, want 1", len(tr.sent))
+			}
+			var event yggdrasil.Event
+			if err := json.Unmarshal(tr.sent[0], &event); err != nil {
+				t.Fatal(err)
+			}
+			if event.ResponseTo != "m1" || event.Content != string(test.wantEvent) {
+				t.Errorf("got event %v in response to %v, want %v in response to m1", event.Content, event.ResponseTo, test.wantEvent)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var record audit.Record
+			if len(data) > 0 {
+				if err := json.Unmarshal(data, &record); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if record.Action != test.wantAudit {
+				t.Errorf("audit action: %q != %q", record.Action, test.wantAudit)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/redhatinsights/yggdrasil/internal/config"
+	"github.com/redhatinsights/yggdrasil/internal/constants"
+	"github.com/urfave/cli/v2"
+	"github.com/urfave/cli/v2/altsrc"
+)
+
+// containerEnvPrefix prefixes the names of the environment variables yggd
+// reads its configuration from in container mode.
+const containerEnvPrefix = "YGGD_"
+
+// containerEnvName returns the name of the environment variable holding the
+// value of the flag name in container mode, such as YGGD_LOG_LEVEL for
+// log-level.
+func containerEnvName(name string) string {
+	return containerEnvPrefix + environmentName(name)
+}
+
+// applyContainerEnv sets every flag not given on the command line from its
+// environment variable, if set. Lists are given as comma-separated values.
+// Values may reference secrets, as in the configuration file.
+func applyContainerEnv(c *cli.Context) error {
+	for _, flag := range c.App.Flags {
+		name := flag.Names()[0]
+		value, ok := os.LookupEnv(containerEnvName(name))
+		if !ok || c.IsSet(name) {
+			continue
+		}
+
+		values := []string{value}
+		switch flag.(type) {
+		case *altsrc.StringSliceFlag, *cli.StringSliceFlag:
+			values = strings.Split(value, ",")
+		}
+		for _, v := range values {
+			v, err := config.ResolveSecret(strings.TrimSpace(v))
+			if err != nil {
+				return fmt.Errorf("cannot resolve %v: %w", containerEnvName(name), err)
+			}
+			if err := c.Set(name, v); err != nil {
+				return fmt.Errorf("cannot set %v from %v: %w", name, containerEnvName(name), err)
+			}
+		}
+	}
+	return nil
+}
+
+// containerBusAddress returns the address of the bus yggd and its workers
+// share in container mode: a socket in the runtime directory, which can be
+// placed on a volume shared with the worker containers.
+func containerBusAddress() string {
+	return "unix:path=" + filepath.Join(constants.RuntimeDir, "bus")
+}
+
+// writableDir creates dir if it does not exist and checks that files can be
+// created in it.
+func writableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".yggd-")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// fallbackDir returns dir if it is writable. Otherwise, such as on a read-only
+// root file system, it returns a directory named name in the temporary
+// directory, which does not outlive the container.
+func fallbackDir(dir, name string) (string, error) {
+	err := writableDir(dir)
+	if err == nil {
+		return dir, nil
+	}
+	fallback := filepath.Join(os.TempDir(), "yggdrasil", name)
+	if err := writableDir(fallback); err != nil {
+		return "", fmt.Errorf("cannot create directory '%v': %w", fallback, err)
+	}
+	log.Warnf("directory '%v' is not writable (%v); using '%v', which is not persisted", dir, err, fallback)
+	return fallback, nil
+}
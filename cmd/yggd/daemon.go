@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/redhatinsights/yggdrasil/internal/constants"
+)
+
+// daemonizedEnv is set in the environment of the background yggd process
+// started by daemonize, so it does not detach again.
+const daemonizedEnv = "YGGD_DAEMONIZED"
+
+// daemonized returns true if yggd was started in the background by
+// daemonize.
+func daemonized() bool {
+	return os.Getenv(daemonizedEnv) != ""
+}
+
+// daemonize starts yggd again with the same arguments as a background process
+// in a new session, detached from the terminal, and returns its process ID.
+// Go cannot safely fork a running process, so the executable is re-run
+// instead. Standard input is read from /dev/null and the output of the new
+// process is appended to yggd.log in constants.LogDir.
+func daemonize() (int, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("cannot find executable: %w", err)
+	}
+
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		return 0, fmt.Errorf("cannot open %v: %w", os.DevNull, err)
+	}
+	defer null.Close()
+
+	if err := os.MkdirAll(constants.LogDir, 0755); err != nil {
+		return 0, fmt.Errorf("cannot create directory '%v': %w", constants.LogDir, err)
+	}
+	logFile, err := os.OpenFile(filepath.Join(constants.LogDir, "yggd.log"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return 0, fmt.Errorf("cannot open log file: %w", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), daemonizedEnv+"=1")
+	cmd.Dir = "/"
+	cmd.Stdin = null
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("cannot start background process: %w", err)
+	}
+	pid := cmd.Process.Pid
+	if err := cmd.Process.Release(); err != nil {
+		return 0, fmt.Errorf("cannot release background process: %w", err)
+	}
+	return pid, nil
+}
+
+// writePIDFile writes the process ID of yggd to file. It fails if file holds
+// the ID of another process that is still running, so two instances of yggd
+// cannot share a PID file.
+func writePIDFile(file string) error {
+	if data, err := os.ReadFile(file); err == nil {
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err == nil && pid != os.Getpid() && processRunning(pid) {
+			return fmt.Errorf("yggd is already running with PID %v", pid)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("cannot read PID file: %w", err)
+	}
+
+	return writeFileAtomic(file, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
+
+// removePIDFile removes file if it still holds the process ID of yggd.
+func removePIDFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		return nil
+	}
+	return os.Remove(file)
+}
+
+// processRunning returns true if a process with the given ID exists.
+func processRunning(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
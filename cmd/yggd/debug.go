@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/redhatinsights/yggdrasil/internal/work"
+)
+
+// debugState is the JSON body returned by the /debug/state endpoint.
+type debugState struct {
+	Time             string                       `json:"time"`
+	Connected        bool                         `json:"connected"`
+	LogLevel         string                       `json:"log_level"`
+	Goroutines       int                          `json:"goroutines"`
+	Workers          map[string]map[string]string `json:"workers"`
+	DispatchLanes    map[string]int               `json:"dispatch_lanes"`
+	DispatchQueues   map[string]work.QueueState   `json:"dispatch_queues"`
+	AwaitingResponse []string                     `json:"awaiting_response"`
+	PendingMessages  int                          `json:"pending_messages"`
+	RecentEvents     []work.DispatchEvent         `json:"recent_events"`
+}
+
+// serveDebug serves pprof profiles under "/debug/pprof/" and a JSON dump of
+// the internal state of client and its dispatcher at "/debug/state" on l.
+// Goroutine dumps are served at "/debug/pprof/goroutine?debug=2".
+func serveDebug(l net.Listener, client *Client) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/state", func(w http.ResponseWriter, r *http.Request) {
+		data, err := json.MarshalIndent(client.debugState(), "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	})
+
+	server := http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	log.Infof("serving debug endpoints on %v", l.Addr())
+	if err := server.Serve(l); err != nil {
+		log.Errorf("cannot serve debug endpoints: %v", err)
+	}
+}
+
+// debugState collects the internal state of c for diagnosing leaks and
+// deadlocks. It does not go through the dispatcher's event loop, so it can be
+// collected while the dispatcher is unresponsive.
+func (c *Client) debugState() debugState {
+	level, _ := logLevelStatus()
+	state := debugState{
+		Time:             time.Now().UTC().Format(time.RFC3339),
+		Connected:        c.connected.Load(),
+		LogLevel:         level,
+		Goroutines:       runtime.NumGoroutine(),
+		Workers:          c.dispatcher.WorkerStatus(),
+		DispatchLanes:    c.dispatcher.LaneDepths(),
+		DispatchQueues:   c.dispatcher.DispatchQueues(),
+		AwaitingResponse: c.dispatcher.AwaitingResponse(),
+		RecentEvents:     c.dispatcher.RecentDispatchEvents(),
+	}
+	if pending, err := c.dispatcher.PendingMessages(); err == nil {
+		state.PendingMessages = pending
+	} else {
+		log.Debugf("cannot count pending messages: %v", err)
+	}
+	return state
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redhatinsights/yggdrasil/internal/clientid"
+	"github.com/redhatinsights/yggdrasil/internal/config"
+	"github.com/redhatinsights/yggdrasil/internal/constants"
+	"github.com/redhatinsights/yggdrasil/internal/doctor"
+	internalhttp "github.com/redhatinsights/yggdrasil/internal/http"
+	"github.com/redhatinsights/yggdrasil/internal/transport"
+	"github.com/redhatinsights/yggdrasil/internal/work"
+	"github.com/urfave/cli/v2"
+)
+
+// doctorTimeout is how long each connection attempt of the doctor checks may
+// take.
+const doctorTimeout = 10 * time.Second
+
+// runDoctor checks the configuration and the host yggd runs on without
+// starting yggd, printing a report of every check. It fails if any check
+// failed.
+func runDoctor(c *cli.Context) error {
+	conf := config.DefaultConfig
+
+	results := checkConfiguration(c.String("config"), &conf)
+	results = append(results, checkCertificates(&conf, time.Now())...)
+	results = append(results, checkServers(&conf)...)
+	results = append(results, checkWorkers(&conf)...)
+	results = append(results, checkDirectories(&conf)...)
+
+	if failed := doctor.Report(os.Stdout, results); failed > 0 {
+		return cli.Exit(fmt.Sprintf("%v of %v checks failed", failed, len(results)), 1)
+	}
+	return nil
+}
+
+// checkConfiguration checks the syntax of the configuration file and its
+// drop-in fragments and the values of the configuration conf yggd would run
+// with.
+func checkConfiguration(file string, conf *config.Config) []doctor.Result {
+	if file != "" {
+		problems, err := config.CheckFiles(file)
+		if err != nil {
+			return []doctor.Result{doctor.Fail("configuration", "%v", err)}
+		}
+		if len(problems) > 0 {
+			results := []doctor.Result{}
+			for _, problem := range problems {
+				results = append(results, doctor.Fail("configuration", "%v", problem))
+			}
+			return results
+		}
+		if _, err := newConfigSource(file); err != nil {
+			return []doctor.Result{doctor.Fail("configuration", "%v", err)}
+		}
+	}
+
+	results := []doctor.Result{}
+	for _, problem := range conf.Validate() {
+		results = append(results, doctor.Fail("configuration", "%v", problem))
+	}
+	if len(conf.ClientIDSources) > 0 {
+		if err := clientid.ValidateSources(conf.ClientIDSources); err != nil {
+			results = append(results, doctor.Fail("configuration", "%v: %v", config.FlagNameClientIDSources, err))
+		}
+	}
+	if _, err := internalhttp.ParseHeaders(conf.HTTPHeaders); err != nil {
+		results = append(results, doctor.Fail("configuration", "%v: %v", config.FlagNameHTTPHeader, err))
+	}
+	if conf.Protocol == "mqtt" && conf.MQTTTopicTemplate != "" {
+		if _, err := transport.ParseTopicTemplate(conf.MQTTTopicTemplate); err != nil {
+			results = append(results, doctor.Fail("configuration", "%v: %v", config.FlagNameMQTTTopicTemplate, err))
+		}
+	}
+	if len(results) > 0 {
+		return results
+	}
+	if file == "" {
+		return []doctor.Result{doctor.Pass("configuration", "no configuration file, using defaults and flags")}
+	}
+	return []doctor.Result{doctor.Pass("configuration", "%v", file)}
+}
+
+// checkCertificates checks that the client certificate and the CA root
+// certificates are valid at now and that the private key is not readable by
+// any user.
+func checkCertificates(conf *config.Config, now time.Time) []doctor.Result {
+	results := []doctor.Result{}
+	if conf.CertFile == "" {
+		results = append(results, doctor.Skip(config.FlagNameCertFile, "no client certificate is configured"))
+	} else {
+		results = append(results, doctor.Certificate(config.FlagNameCertFile, conf.CertFile, now))
+	}
+	if conf.KeyFile != "" {
+		results = append(results, doctor.PrivateKey(config.FlagNameKeyFile, conf.KeyFile))
+	}
+	for _, file := range conf.CARoot {
+		results = append(results, doctor.Certificate(config.FlagNameCaRoot, file, now))
+	}
+	return results
+}
+
+// checkServers connects to every configured server, completing a TLS
+// handshake and checking the server pins where the connection uses TLS.
+// Connections to HTTP servers go through the proxy set in the environment, if
+// any, which is checked first.
+func checkServers(conf *config.Config) []doctor.Result {
+	switch conf.Protocol {
+	case "", "none":
+		return []doctor.Result{doctor.Skip("server", "no transport protocol is configured")}
+	case "mqtt", "http":
+	default:
+		return []doctor.Result{doctor.Skip("server", "unsupported transport protocol: %v", conf.Protocol)}
+	}
+
+	tlsConfig, err := conf.CreateTLSConfig()
+	if err == nil {
+		tlsConfig, err = conf.ServerTLSConfig(tlsConfig)
+	}
+	if err != nil {
+		return []doctor.Result{doctor.Fail("server", "cannot create TLS config: %v", err)}
+	}
+
+	results := []doctor.Result{}
+	for _, server := range conf.Server {
+		addr, useTLS, err := doctor.ServerAddress(conf.Protocol, server)
+		if err != nil {
+			results = append(results, doctor.Fail("server", "%v: %v", server, err))
+			continue
+		}
+
+		var proxy *url.URL
+		if conf.Protocol == "http" {
+			proxy, err = http.ProxyFromEnvironment(&http.Request{URL: &url.URL{Scheme: "https", Host: addr}})
+			if err != nil {
+				results = append(results, doctor.Fail("proxy", "%v", err))
+				continue
+			}
+			if proxy == nil {
+				results = append(results, doctor.Skip("proxy", "no proxy is configured for %v", server))
+			} else if err := doctor.Connect(addr, proxy, nil, doctorTimeout); err != nil {
+				results = append(results, doctor.Fail("proxy", "%v: %v", proxy.Redacted(), err))
+				continue
+			} else {
+				results = append(results, doctor.Pass("proxy", "%v connects to %v", proxy.Redacted(), addr))
+			}
+		}
+
+		var handshake *tls.Config
+		if useTLS {
+			handshake = tlsConfig
+		}
+		if err := doctor.Connect(addr, proxy, handshake, doctorTimeout); err != nil {
+			results = append(results, doctor.Fail("server", "%v: %v", server, err))
+			continue
+		}
+		if useTLS {
+			results = append(results, doctor.Pass("server", "%v: TLS handshake succeeded", server))
+		} else {
+			results = append(results, doctor.Warn("server", "%v: reachable, but the connection is not encrypted", server))
+		}
+	}
+	return results
+}
+
+// checkWorkers checks the settings that apply to workers and that the workers
+// they name are installed.
+func checkWorkers(conf *config.Config) []doctor.Result {
+	results := []doctor.Result{}
+	if conf.DispatchOverflow != "" {
+		if err := work.ValidateOverflowPolicy(conf.DispatchOverflow); err != nil {
+			results = append(results, doctor.Fail("workers", "%v: %v", config.FlagNameDispatchOverflow, err))
+		}
+	}
+	aliases, err := work.ParseDirectiveAliases(conf.DirectiveAliases)
+	if err != nil {
+		results = append(results, doctor.Fail("workers", "%v: %v", config.FlagNameDirectiveAlias, err))
+	}
+	if _, err := work.ParseRateLimits(conf.RateLimits, conf.ByteQuotas); err != nil {
+		results = append(results, doctor.Fail("workers", "%v", err))
+	}
+	if _, err := work.ParseConcurrencyLimits(conf.MaxConcurrency); err != nil {
+		results = append(results, doctor.Fail("workers", "%v: %v", config.FlagNameMaxConcurrency, err))
+	}
+	onDemand, err := work.ParseOnDemandWorkers(conf.OnDemandWorkers, conf.WorkerIdleTimeout)
+	if err != nil {
+		results = append(results, doctor.Fail("workers", "%v: %v", config.FlagNameOnDemandWorkers, err))
+	}
+
+	matches, err := filepath.Glob(filepath.Join(constants.DBusSystemServicesDir, "com.redhat.Yggdrasil1.Worker1.*.service"))
+	if err != nil {
+		return append(results, doctor.Fail("workers", "cannot list workers: %v", err))
+	}
+	installed := make(map[string]bool, len(matches))
+	for _, match := range matches {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), "com.redhat.Yggdrasil1.Worker1."), ".service")
+		installed[name] = true
+	}
+
+	for _, worker := range conf.ExcludeWorkers {
+		if !installed[worker] {
+			results = append(results, doctor.Warn("workers", "%v: worker %v is not installed", config.FlagNameExcludeWorkers, worker))
+		}
+	}
+	onDemandWorkers := make([]string, 0, len(onDemand))
+	for worker := range onDemand {
+		onDemandWorkers = append(onDemandWorkers, worker)
+	}
+	sort.Strings(onDemandWorkers)
+	for _, worker := range onDemandWorkers {
+		if !installed[worker] {
+			results = append(results, doctor.Warn("workers", "%v: worker %v is not installed", config.FlagNameOnDemandWorkers, worker))
+		}
+	}
+	directives := make([]string, 0, len(aliases))
+	for directive := range aliases {
+		directives = append(directives, directive)
+	}
+	sort.Strings(directives)
+	for _, directive := range directives {
+		if worker := aliases[directive]; !installed[worker] {
+			results = append(results, doctor.Warn("workers", "%v: %v is routed to worker %v, which is not installed", config.FlagNameDirectiveAlias, directive, worker))
+		}
+	}
+
+	if len(installed) == 0 {
+		return append(results, doctor.Warn("workers", "no workers are installed in %v", constants.DBusSystemServicesDir))
+	}
+	if len(results) == 0 {
+		results = append(results, doctor.Pass("workers", "%v workers are installed in %v", len(installed), constants.DBusSystemServicesDir))
+	}
+	return results
+}
+
+// checkDirectories checks that the directories yggd writes to can be written
+// to, or created.
+func checkDirectories(conf *config.Config) []doctor.Result {
+	stateDir, runtimeDir := constants.StateDir, constants.RuntimeDir
+	if conf.StateDir != "" {
+		stateDir = conf.StateDir
+	}
+	if conf.RuntimeDir != "" {
+		runtimeDir = conf.RuntimeDir
+	}
+	return []doctor.Result{
+		doctor.Directory(config.FlagNameStateDir, stateDir),
+		doctor.Directory(config.FlagNameRuntimeDir, runtimeDir),
+		doctor.Directory("cache-dir", constants.CacheDir),
+		doctor.Directory("log-dir", constants.LogDir),
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/redhatinsights/yggdrasil/internal/config"
+)
+
+// healthReport is the JSON body returned by the health endpoints.
+type healthReport struct {
+	Status     string                       `json:"status"`
+	Connected  bool                         `json:"connected"`
+	Dispatcher string                       `json:"dispatcher"`
+	Workers    map[string]map[string]string `json:"workers"`
+}
+
+// listenLocal creates a listener on addr, either a "unix:PATH" socket or a
+// TCP "HOST:PORT" address on a loopback interface.
+func listenLocal(addr string) (net.Listener, error) {
+	if err := config.ValidateLocalListen(addr); err != nil {
+		return nil, err
+	}
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("cannot remove stale socket: %w", err)
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// serveHealth serves the health endpoints on l. "/healthz" reports whether
+// the dispatcher is responsive; "/readyz" additionally requires the transport
+// to be connected, unless no network protocol is configured. Both respond with
+// a JSON health report, and with status 503 if the check fails.
+func serveHealth(l net.Listener, client *Client) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		report := client.healthReport()
+		writeHealthReport(w, report, report.Dispatcher == "responsive")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		report := client.healthReport()
+		ready := report.Dispatcher == "responsive" &&
+			(report.Connected || config.DefaultConfig.Protocol == "none")
+		writeHealthReport(w, report, ready)
+	})
+
+	server := http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	log.Infof("serving health endpoints on %v", l.Addr())
+	if err := server.Serve(l); err != nil {
+		log.Errorf("cannot serve health endpoints: %v", err)
+	}
+}
+
+// healthReport collects the broker connectivity, dispatcher responsiveness and
+// worker status of c.
+func (c *Client) healthReport() healthReport {
+	report := healthReport{
+		Connected:  c.connected.Load(),
+		Dispatcher: "responsive",
+		Workers:    c.dispatcher.WorkerStatus(),
+	}
+	if !c.dispatcher.Responsive(time.Second) {
+		report.Dispatcher = "unresponsive"
+	}
+	return report
+}
+
+// writeHealthReport writes report as JSON, with status "ok" and HTTP status
+// 200 if ok is true, or "unavailable" and 503 otherwise.
+func writeHealthReport(w http.ResponseWriter, report healthReport, ok bool) {
+	code := http.StatusOK
+	report.Status = "ok"
+	if !ok {
+		code = http.StatusServiceUnavailable
+		report.Status = "unavailable"
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/redhatinsights/yggdrasil/internal/config"
+)
+
+// debugDuration is how long SIGUSR1 raises the log level to debug.
+const debugDuration = 30 * time.Minute
+
+// logLevelOverride is a log level set at runtime in place of the configured
+// log level.
+var logLevelOverride struct {
+	sync.Mutex
+	active bool
+	until  time.Time
+	timer  *time.Timer
+	// generation is incremented by every change, so that a timer that fires
+	// after being replaced does not restore the configured level.
+	generation uint64
+}
+
+// overrideLogLevel sets the log level to level in place of the configured log
+// level. If duration is non-zero, the configured level is restored once it
+// elapses; otherwise level stays in effect until restoreLogLevel is called.
+func overrideLogLevel(level log.Level, duration time.Duration) {
+	logLevelOverride.Lock()
+	defer logLevelOverride.Unlock()
+
+	stopOverrideTimer()
+	logLevelOverride.active = true
+	logLevelOverride.until = time.Time{}
+	if duration > 0 {
+		generation := logLevelOverride.generation
+		logLevelOverride.until = time.Now().Add(duration)
+		logLevelOverride.timer = time.AfterFunc(duration, func() {
+			logLevelOverride.Lock()
+			defer logLevelOverride.Unlock()
+			if logLevelOverride.generation != generation {
+				return
+			}
+			logLevelOverride.active = false
+			logLevelOverride.until = time.Time{}
+			logLevelOverride.timer = nil
+			setConfiguredLogLevel()
+			log.Infof("log level override expired; log level restored to %v", log.CurrentLevel())
+		})
+	}
+
+	setLogLevel(level)
+	if duration > 0 {
+		log.Infof("log level set to %v for %v", level, duration)
+	} else {
+		log.Infof("log level set to %v", level)
+	}
+}
+
+// restoreLogLevel removes any log level override, restoring the configured
+// log level.
+func restoreLogLevel() {
+	logLevelOverride.Lock()
+	defer logLevelOverride.Unlock()
+
+	stopOverrideTimer()
+	logLevelOverride.active = false
+	logLevelOverride.until = time.Time{}
+	setConfiguredLogLevel()
+	log.Infof("log level restored to %v", log.CurrentLevel())
+}
+
+// stopOverrideTimer stops the timer of the current override, if any. The
+// caller must hold the logLevelOverride lock.
+func stopOverrideTimer() {
+	logLevelOverride.generation++
+	if logLevelOverride.timer != nil {
+		logLevelOverride.timer.Stop()
+		logLevelOverride.timer = nil
+	}
+}
+
+// setConfiguredLogLevel sets the log level to the configured log level. The
+// caller must hold the logLevelOverride lock.
+func setConfiguredLogLevel() {
+	level, err := log.ParseLevel(config.DefaultConfig.LogLevel)
+	if err != nil {
+		log.Errorf("cannot parse log level: %v", err)
+		return
+	}
+	setLogLevel(level)
+}
+
+// applyConfiguredLogLevel sets the log level to the configured log level,
+// unless it is overridden, in which case the configured level takes effect
+// once the override expires or is removed.
+func applyConfiguredLogLevel() {
+	logLevelOverride.Lock()
+	defer logLevelOverride.Unlock()
+
+	if logLevelOverride.active {
+		log.Infof("configured log level set to %v; keeping log level %v until the override is removed", config.DefaultConfig.LogLevel, log.CurrentLevel())
+		return
+	}
+	setConfiguredLogLevel()
+	log.Infof("log level set to %v", log.CurrentLevel())
+}
+
+// logLevelStatus returns the current log level and, if it overrides the
+// configured level until a set time, that time.
+func logLevelStatus() (level string, until time.Time) {
+	logLevelOverride.Lock()
+	defer logLevelOverride.Unlock()
+
+	return log.CurrentLevel().String(), logLevelOverride.until
+}
+
+// monitorLogLevelSignals changes the log level on receipt of SIGUSR1, which
+// sets it to debug for debugDuration, and SIGUSR2, which restores the
+// configured log level.
+func monitorLogLevelSignals() {
+	usr := make(chan os.Signal, 1)
+	signal.Notify(usr, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	for sig := range usr {
+		switch sig {
+		case syscall.SIGUSR1:
+			overrideLogLevel(log.LevelDebug, debugDuration)
+		case syscall.SIGUSR2:
+			restoreLogLevel()
+		}
+	}
+}
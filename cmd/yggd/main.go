@@ -4,23 +4,38 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/coreos/go-systemd/v22/daemon"
+	"github.com/redhatinsights/yggdrasil/internal/audit"
+	"github.com/redhatinsights/yggdrasil/internal/clientid"
 	"github.com/redhatinsights/yggdrasil/internal/config"
 	"github.com/redhatinsights/yggdrasil/internal/constants"
+	"github.com/redhatinsights/yggdrasil/internal/facts"
+	"github.com/redhatinsights/yggdrasil/internal/history"
+	"github.com/redhatinsights/yggdrasil/internal/hook"
 	"github.com/redhatinsights/yggdrasil/internal/http"
+	"github.com/redhatinsights/yggdrasil/internal/kerberos"
+	"github.com/redhatinsights/yggdrasil/internal/logging"
 	"github.com/redhatinsights/yggdrasil/internal/messagejournal"
+	"github.com/redhatinsights/yggdrasil/internal/oauth"
+	"github.com/redhatinsights/yggdrasil/internal/signature"
+	"github.com/redhatinsights/yggdrasil/internal/spool"
+	"github.com/redhatinsights/yggdrasil/internal/tags"
 	"github.com/redhatinsights/yggdrasil/internal/transport"
+	"github.com/redhatinsights/yggdrasil/internal/watch"
 	"github.com/redhatinsights/yggdrasil/internal/work"
+	"github.com/redhatinsights/yggdrasil/ipc"
 
 	"git.sr.ht/~spc/go-log"
 	"github.com/redhatinsights/yggdrasil"
-	"github.com/rjeczalik/notify"
 	"github.com/urfave/cli/v2"
 	"github.com/urfave/cli/v2/altsrc"
 )
@@ -53,72 +68,198 @@ func generateDocumentation(c *cli.Context) error {
 func setupDefaultConfig(c *cli.Context) {
 	config.DefaultConfig = config.Config{
 		LogLevel:                 c.String(config.FlagNameLogLevel),
+		LogFormat:                c.String(config.FlagNameLogFormat),
 		ClientID:                 c.String(config.FlagNameClientID),
+		ClientIDSources:          c.StringSlice(config.FlagNameClientIDSources),
 		Server:                   c.StringSlice(config.FlagNameServer),
 		CertFile:                 c.String(config.FlagNameCertFile),
 		KeyFile:                  c.String(config.FlagNameKeyFile),
 		CARoot:                   c.StringSlice(config.FlagNameCaRoot),
+		ServerPins:               c.StringSlice(config.FlagNameServerPin),
+		TLSMinVersion:            c.String(config.FlagNameTLSMinVersion),
+		TLSMaxVersion:            c.String(config.FlagNameTLSMaxVersion),
+		TLSCipherSuites:          c.StringSlice(config.FlagNameTLSCipherSuites),
 		PathPrefix:               c.String(config.FlagNamePathPrefix),
 		Protocol:                 c.String(config.FlagNameProtocol),
 		DataHost:                 c.String(config.FlagNameDataHost),
 		FactsFile:                c.String(config.FlagNameFactsFile),
+		DisableFactsCollectors:   c.StringSlice(config.FlagNameDisableFactsCollectors),
+		FactsInterval:            c.Duration(config.FlagNameFactsInterval),
 		HTTPRetries:              c.Int(config.FlagNameHTTPRetries),
 		HTTPTimeout:              c.Duration(config.FlagNameHTTPTimeout),
+		HTTPHeaders:              c.StringSlice(config.FlagNameHTTPHeader),
+		HTTPUserAgent:            c.String(config.FlagNameHTTPUserAgent),
+		HTTPReceiveMode:          c.String(config.FlagNameHTTPReceiveMode),
+		OAuthFlow:                c.String(config.FlagNameOAuthFlow),
+		OAuthTokenURL:            c.String(config.FlagNameOAuthTokenURL),
+		OAuthDeviceURL:           c.String(config.FlagNameOAuthDeviceURL),
+		OAuthClientID:            c.String(config.FlagNameOAuthClientID),
+		OAuthClientSecret:        c.String(config.FlagNameOAuthClientSecret),
+		OAuthScopes:              c.StringSlice(config.FlagNameOAuthScope),
+		KerberosKeytab:           c.String(config.FlagNameKerberosKeytab),
+		KerberosPrincipal:        c.String(config.FlagNameKerberosPrincipal),
+		KerberosConfig:           c.String(config.FlagNameKerberosConfig),
 		MQTTConnectRetry:         c.Bool(config.FlagNameMQTTConnectRetry),
 		MQTTConnectRetryInterval: c.Duration(config.FlagNameMQTTConnectRetryInterval),
 		MQTTAutoReconnect:        c.Bool(config.FlagNameMQTTAutoReconnect),
 		MQTTReconnectDelay:       c.Duration(config.FlagNameMQTTReconnectDelay),
 		MQTTConnectTimeout:       c.Duration(config.FlagNameMQTTConnectTimeout),
 		MQTTPublishTimeout:       c.Duration(config.FlagNameMQTTPublishTimeout),
+		MQTTTopicTemplate:        c.String(config.FlagNameMQTTTopicTemplate),
+		MQTTUsername:             c.String(config.FlagNameMQTTUsername),
+		MQTTPassword:             c.String(config.FlagNameMQTTPassword),
+		MQTTPasswordFile:         c.String(config.FlagNameMQTTPasswordFile),
 		MessageJournal:           c.String(config.FlagNameMessageJournal),
+		HealthCheckInterval:      c.Duration(config.FlagNameHealthCheckInterval),
+		HealthCheckFailures:      c.Int(config.FlagNameHealthCheckFailures),
+		FilePollInterval:         c.Duration(config.FlagNameFilePollInterval),
+		WorkerStartConcurrency:   c.Int(config.FlagNameWorkerStartConcurrency),
+		OnDemandWorkers:          c.StringSlice(config.FlagNameOnDemandWorkers),
+		WorkerIdleTimeout:        c.Duration(config.FlagNameWorkerIdleTimeout),
+		ShutdownTimeout:          c.Duration(config.FlagNameShutdownTimeout),
+		RestartDelay:             c.Duration(config.FlagNameRestartDelay),
+		RestartMaxDelay:          c.Duration(config.FlagNameRestartMaxDelay),
+		ExcludeWorkers:           c.StringSlice(config.FlagNameExcludeWorkers),
+		RemoteWorkerControl:      c.StringSlice(config.FlagNameRemoteWorkerControl),
+		TrustedKeys:              c.StringSlice(config.FlagNameTrustedKeys),
+		RequireSignatures:        c.Bool(config.FlagNameRequireSignatures),
+		SpoolKey:                 c.String(config.FlagNameSpoolKey),
+		RedactPatterns:           c.StringSlice(config.FlagNameRedactPattern),
+		RedactPaths:              c.StringSlice(config.FlagNameRedactPath),
+		RateLimits:               c.StringSlice(config.FlagNameRateLimit),
+		ByteQuotas:               c.StringSlice(config.FlagNameByteQuota),
+		DispatchRetries:          c.Int(config.FlagNameDispatchRetries),
+		DispatchRetryDelay:       c.Duration(config.FlagNameDispatchRetryDelay),
+		DedupCacheSize:           c.Int(config.FlagNameDedupCacheSize),
+		MessageMaxAge:            c.Duration(config.FlagNameMessageMaxAge),
+		MaxConcurrency:           c.StringSlice(config.FlagNameMaxConcurrency),
+		DispatchQueueDepth:       c.Int(config.FlagNameDispatchQueueDepth),
+		DispatchOverflow:         c.String(config.FlagNameDispatchOverflow),
+		ResponseTimeout:          c.Duration(config.FlagNameResponseTimeout),
+		DirectiveAliases:         c.StringSlice(config.FlagNameDirectiveAlias),
+		MessageHook:              c.String(config.FlagNameMessageHook),
+		OnConnectHook:            c.String(config.FlagNameOnConnectHook),
+		OnDisconnectHook:         c.String(config.FlagNameOnDisconnectHook),
+		MessageHistorySize:       c.Int(config.FlagNameMessageHistorySize),
+		HealthListen:             c.String(config.FlagNameHealthListen),
+		DebugListen:              c.String(config.FlagNameDebugListen),
+		ConfigDir:                instancePathFlag(c, config.FlagNameConfigDir, constants.ConfigDir),
+		StateDir:                 instancePathFlag(c, config.FlagNameStateDir, constants.StateDir),
+		RuntimeDir:               instancePathFlag(c, config.FlagNameRuntimeDir, constants.RuntimeDir),
+		PIDFile:                  c.Path(config.FlagNamePIDFile),
+	}
+
+	// In a container, logs are structured for the container runtime to
+	// collect, and the runtime tracks yggd by its process, not a PID file.
+	if c.Bool("container") {
+		if !c.IsSet(config.FlagNameLogFormat) {
+			config.DefaultConfig.LogFormat = "json"
+		}
+		config.DefaultConfig.PIDFile = ""
 	}
 }
 
+// setupDirectories replaces the compile-time configuration, state and runtime
+// directories with the configured ones, creating the state and runtime
+// directories if they do not exist. In a container, directories that cannot
+// be written to are replaced with temporary ones.
+func setupDirectories(container bool) error {
+	if config.DefaultConfig.ConfigDir != "" {
+		constants.ConfigDir = config.DefaultConfig.ConfigDir
+	}
+	if config.DefaultConfig.StateDir != "" {
+		constants.StateDir = config.DefaultConfig.StateDir
+	}
+	if config.DefaultConfig.RuntimeDir != "" {
+		constants.RuntimeDir = config.DefaultConfig.RuntimeDir
+	}
+
+	if container {
+		var err error
+		if constants.StateDir, err = fallbackDir(constants.StateDir, "state"); err != nil {
+			return err
+		}
+		if constants.RuntimeDir, err = fallbackDir(constants.RuntimeDir, "run"); err != nil {
+			return err
+		}
+	}
+
+	for _, dir := range []string{constants.StateDir, constants.RuntimeDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("cannot create directory '%v': %w", dir, err)
+		}
+	}
+	log.Debugf("using directories config=%v state=%v runtime=%v", constants.ConfigDir, constants.StateDir, constants.RuntimeDir)
+	return nil
+}
+
 // setupLogging sets up logging for yggd
 func setupLogging(c *cli.Context) error {
 	level, err := log.ParseLevel(config.DefaultConfig.LogLevel)
 	if err != nil {
 		return cli.Exit(err, 1)
 	}
-	log.SetLevel(level)
-	log.SetPrefix(fmt.Sprintf("[%v] ", c.App.Name))
-	if log.CurrentLevel() >= log.LevelDebug {
-		log.SetFlags(log.LstdFlags | log.Llongfile)
+	// In a container, logs are written to standard output for the container
+	// runtime to collect.
+	out := os.Stderr
+	if c.Bool("container") {
+		out = os.Stdout
+	}
+	switch config.DefaultConfig.LogFormat {
+	case "text":
+		log.SetPrefix(fmt.Sprintf("[%v] ", c.App.Name))
+		log.SetOutput(out)
+	case "json":
+		log.SetPrefix("")
+		log.SetOutput(logging.NewJSONWriter(out, c.App.Name))
+	default:
+		return cli.Exit(fmt.Errorf("unsupported log format: %v", config.DefaultConfig.LogFormat), 1)
 	}
+	setLogLevel(level)
+
+	redactor, err := logging.NewRedactor(config.DefaultConfig.RedactPatterns, config.DefaultConfig.RedactPaths)
+	if err != nil {
+		return cli.Exit(err, 1)
+	}
+	logging.SetRedactor(redactor)
 	return nil
 }
 
-// setupClientID tries to create client ID for yggd. It tries to load
-// client ID from certificate CN, if certificate is provided. If not, it
-// tries to load client ID from file. If client ID is not found in file,
-// it generates new client ID and saves it to file.
-func setupClientID() error {
-	clientIDFile := filepath.Join(constants.StateDir, "client-id")
-	if config.DefaultConfig.CertFile != "" {
-		CN, err := parseCertCN(config.DefaultConfig.CertFile)
-		if err != nil {
-			return cli.Exit(fmt.Errorf("cannot parse certificate: %w", err), 1)
-		}
-		if err := setClientID([]byte(CN), clientIDFile); err != nil {
-			return cli.Exit(fmt.Errorf("cannot set client-id to CN: %w", err), 1)
-		}
+// setLogLevel sets the log level, including source file names in text log
+// lines at debug level and above.
+func setLogLevel(level log.Level) {
+	log.SetLevel(level)
+	if config.DefaultConfig.LogFormat == "json" {
+		log.SetFlags(0)
+	} else if log.CurrentLevel() >= log.LevelDebug {
+		log.SetFlags(log.LstdFlags | log.Llongfile)
+	} else {
+		log.SetFlags(log.LstdFlags)
 	}
+}
 
-	if config.DefaultConfig.ClientID == "" {
-		clientID, err := getClientID(clientIDFile)
-		if err != nil {
-			return cli.Exit(fmt.Errorf("cannot get client-id: %w", err), 1)
-		}
-		if len(clientID) == 0 {
-			data, err := createClientID(clientIDFile)
-			if err != nil {
-				return cli.Exit(fmt.Errorf("cannot create client-id: %w", err), 1)
-			}
-			clientID = data
-		}
-		config.DefaultConfig.ClientID = string(clientID)
+// setupClientID derives the client ID from the sources configured with
+// client-id-sources, trying each in order, and returns the name of the source
+// it was derived from. An instance name distinguishes the client IDs of
+// instances derived from the machine ID.
+func setupClientID(instance string) (string, error) {
+	sources := config.DefaultConfig.ClientIDSources
+	if len(sources) == 0 {
+		sources = clientid.DefaultSources
 	}
-	return nil
+	id, source, err := clientid.Derive(sources, clientid.Inputs{
+		ClientID:      config.DefaultConfig.ClientID,
+		CertFile:      config.DefaultConfig.CertFile,
+		MachineIDFile: "/etc/machine-id",
+		File:          filepath.Join(constants.StateDir, "client-id"),
+		Instance:      instance,
+	})
+	if err != nil {
+		return "", cli.Exit(fmt.Errorf("cannot set up client ID: %w", err), 1)
+	}
+	config.DefaultConfig.ClientID = id
+	log.Infof("using client ID %v from %v", id, source)
+	return source, nil
 }
 
 // setupFactsFile creates a canonical facts file if it doesn’t exist
@@ -150,11 +291,53 @@ func setupFactsFile() error {
 	return nil
 }
 
+// setupWorkerEnvironment writes an environment file that worker service units
+// load with EnvironmentFile=. It exposes the client ID, connection settings,
+// top-level canonical facts and tags to workers as YGG_* variables, which
+// systemd expands in the worker's ExecStart= line when the worker is started.
+func setupWorkerEnvironment() error {
+	env := map[string]string{
+		"YGG_CLIENT_ID":   config.DefaultConfig.ClientID,
+		"YGG_PROTOCOL":    config.DefaultConfig.Protocol,
+		"YGG_SERVER":      strings.Join(config.DefaultConfig.Server, " "),
+		"YGG_DATA_HOST":   config.DefaultConfig.DataHost,
+		"YGG_PATH_PREFIX": config.DefaultConfig.PathPrefix,
+	}
+
+	for k, v := range facts.DefaultRegistry.Collect() {
+		switch v := v.(type) {
+		case string, float64, bool:
+			env["YGG_FACT_"+environmentName(k)] = fmt.Sprintf("%v", v)
+		}
+	}
+
+	for k, v := range hostTags() {
+		env["YGG_TAG_"+environmentName(k)] = v
+	}
+
+	file := filepath.Join(constants.RuntimeDir, "worker.env")
+	if err := writeFileAtomic(file, formatEnvironmentFile(env), 0644); err != nil {
+		return fmt.Errorf("cannot write worker environment file '%v': %w", file, err)
+	}
+	log.Debugf("wrote worker environment file '%v'", file)
+	return nil
+}
+
 // setupClient tries to set up new client and transporter
 func setupClient(
 	dispatcher *work.Dispatcher,
 	tlsConfig *tls.Config,
 ) (*Client, transport.Transporter, error) {
+	tokens, err := newTokenSource(tlsConfig)
+	if err != nil {
+		return nil, nil, cli.Exit(err, 1)
+	}
+
+	tlsConfig, err = config.DefaultConfig.ServerTLSConfig(tlsConfig)
+	if err != nil {
+		return nil, nil, cli.Exit(fmt.Errorf("cannot create TLS config: %w", err), 1)
+	}
+
 	var transporter transport.Transporter
 	switch config.DefaultConfig.Protocol {
 	case "mqtt":
@@ -163,16 +346,28 @@ func setupClient(
 			config.DefaultConfig.ClientID,
 			config.DefaultConfig.Server,
 			tlsConfig,
+			tokens,
 		)
 		if err != nil {
 			return nil, nil, cli.Exit(fmt.Errorf("cannot create MQTT transport: %w", err), 1)
 		}
 	case "http":
 		var err error
+		headers, err := http.ParseHeaders(config.DefaultConfig.HTTPHeaders)
+		if err != nil {
+			return nil, nil, cli.Exit(err, 1)
+		}
+		negotiator, err := newNegotiator()
+		if err != nil {
+			return nil, nil, cli.Exit(err, 1)
+		}
 		transporter, err = transport.NewHTTPTransport(
 			config.DefaultConfig.ClientID, config.DefaultConfig.Server[0],
 			tlsConfig,
-			UserAgent,
+			config.DefaultConfig.HTTPUserAgent,
+			headers,
+			tokens,
+			negotiator,
 			time.Second*5,
 		)
 		if err != nil {
@@ -196,13 +391,37 @@ func setupClient(
 			1,
 		)
 	}
+	verifier, err := loadVerifier(config.DefaultConfig.TrustedKeys, config.DefaultConfig.RequireSignatures)
+	if err != nil {
+		return nil, nil, cli.Exit(err, 1)
+	}
 	client := NewClient(dispatcher, transporter)
+	client.verifier.Store(verifier)
+	client.hooks = hook.NewConnectionHooks(
+		config.DefaultConfig.OnConnectHook,
+		config.DefaultConfig.OnDisconnectHook,
+		30*time.Second,
+	)
 	if err := client.Connect(); err != nil {
 		return nil, nil, cli.Exit(fmt.Errorf("cannot connect client: %w", err), 1)
 	}
 	return client, transporter, nil
 }
 
+// loadVerifier loads the trusted keys message signatures are verified
+// against. Requiring signatures without any trusted key is an error, since
+// every message would be rejected.
+func loadVerifier(trustedKeys []string, require bool) (*signature.Verifier, error) {
+	verifier, err := signature.Load(trustedKeys)
+	if err != nil {
+		return nil, err
+	}
+	if require && verifier.Len() == 0 {
+		return nil, fmt.Errorf("%v is set but no %v are set", config.FlagNameRequireSignatures, config.FlagNameTrustedKeys)
+	}
+	return verifier, nil
+}
+
 // setupMessageJournal tries to set up a message journal database to track
 // worker emitted events at the provided path.
 func setupMessageJournal(client *Client) error {
@@ -226,6 +445,39 @@ func setupMessageJournal(client *Client) error {
 	return nil
 }
 
+// newTokenSource creates the source of the OAuth 2.0 access tokens the
+// transport authenticates with, or returns nil if no OAuth 2.0 flow is
+// configured. Requests to the authorization server are verified with
+// tlsConfig.
+func newTokenSource(tlsConfig *tls.Config) (http.TokenSource, error) {
+	if config.DefaultConfig.OAuthFlow == "" {
+		return nil, nil
+	}
+	client := http.NewHTTPClient(tlsConfig, config.DefaultConfig.HTTPUserAgent)
+	client.Timeout = config.DefaultConfig.HTTPTimeout
+	source, err := oauth.NewSource(
+		config.DefaultConfig.OAuthConfig(filepath.Join(constants.StateDir, "oauth-refresh-token")),
+		&client.Client,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("cannot configure OAuth 2.0: %w", err)
+	}
+	return source, nil
+}
+
+// newNegotiator creates the negotiator authenticating HTTP requests with
+// Kerberos, or returns nil if no keytab is configured.
+func newNegotiator() (http.Negotiator, error) {
+	if config.DefaultConfig.KerberosKeytab == "" {
+		return nil, nil
+	}
+	negotiator, err := kerberos.NewNegotiator(config.DefaultConfig.KerberosAuthConfig())
+	if err != nil {
+		return nil, fmt.Errorf("cannot configure Kerberos authentication: %w", err)
+	}
+	return negotiator, nil
+}
+
 // setupTLS tries to set up new TLS config and HTTP client
 func setupTLS() (*http.Client, *tls.Config, error) {
 	tlsConfig, err := config.DefaultConfig.CreateTLSConfig()
@@ -233,13 +485,32 @@ func setupTLS() (*http.Client, *tls.Config, error) {
 		return nil, nil, cli.Exit(fmt.Errorf("cannot create TLS config: %w", err), 1)
 	}
 
-	httpClient := http.NewHTTPClient(tlsConfig, UserAgent)
-	httpClient.Retries = config.DefaultConfig.HTTPRetries
-	httpClient.Timeout = config.DefaultConfig.HTTPTimeout
+	httpClient, err := newHTTPClient(tlsConfig)
+	if err != nil {
+		return nil, nil, cli.Exit(err, 1)
+	}
 
 	return httpClient, tlsConfig, nil
 }
 
+// newHTTPClient creates the HTTP client the dispatcher fetches and uploads
+// data for workers with.
+func newHTTPClient(tlsConfig *tls.Config) (*http.Client, error) {
+	headers, err := http.ParseHeaders(config.DefaultConfig.HTTPHeaders)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := http.NewHTTPClient(tlsConfig, config.DefaultConfig.HTTPUserAgent)
+	httpClient.Retries = config.DefaultConfig.HTTPRetries
+	httpClient.Timeout = config.DefaultConfig.HTTPTimeout
+	httpClient.Headers = headers
+	httpClient.Negotiator, err = newNegotiator()
+	if err != nil {
+		return nil, err
+	}
+	return httpClient, nil
+}
+
 // publishConnectionStatus tries to publish connection status to server
 func publishConnectionStatus(client *Client) {
 	msg, err := client.ConnectionStatus()
@@ -256,56 +527,360 @@ func monitorFactsFile(client *Client) {
 	if config.DefaultConfig.FactsFile == "" {
 		return
 	}
-	c := make(chan notify.EventInfo, 1)
-	if err := notify.Watch(config.DefaultConfig.FactsFile, c, notify.InCloseWrite); err != nil {
-		log.Infof("cannot start watching '%v': %v", config.DefaultConfig.FactsFile, err)
+	w := watch.New([]string{config.DefaultConfig.FactsFile}, config.DefaultConfig.FilePollInterval)
+	defer w.Stop()
+
+	for range w.C {
+		if err := setupWorkerEnvironment(); err != nil {
+			log.Errorf("cannot update worker environment: %v", err)
+		}
+		go func() {
+			msg, err := client.ConnectionStatus()
+			if err != nil {
+				log.Fatalf("cannot get connection status: %v", err)
+			}
+			if _, _, _, err := client.SendConnectionStatusMessage(msg); err != nil {
+				log.Errorf("cannot send connection status message: %v", err)
+			}
+		}()
+	}
+}
+
+// monitorFacts collects canonical facts every config.DefaultConfig.FactsInterval
+// and, if they differ from the facts last sent to the server, updates the
+// worker environment and publishes a new connection-status message.
+func monitorFacts(client *Client) {
+	if config.DefaultConfig.FactsInterval <= 0 {
 		return
 	}
-	defer notify.Stop(c)
+	ticker := time.NewTicker(config.DefaultConfig.FactsInterval)
+	defer ticker.Stop()
 
-	for e := range c {
-		switch e.Event() {
-		case notify.InCloseWrite:
-			go func() {
-				msg, err := client.ConnectionStatus()
-				if err != nil {
-					log.Fatalf("cannot get connection status: %v", err)
-				}
-				if _, _, _, err := client.SendConnectionStatusMessage(msg); err != nil {
-					log.Errorf("cannot send connection status message: %v", err)
-				}
-			}()
+	for range ticker.C {
+		announced := client.announcedFactsHash.Load()
+		if announced == nil {
+			// Nothing was sent yet; facts are sent once connected.
+			continue
 		}
+		if factsHash(facts.DefaultRegistry.Collect()) == announced.(string) {
+			continue
+		}
+		log.Info("canonical facts changed; publishing connection status")
+		if err := setupWorkerEnvironment(); err != nil {
+			log.Errorf("cannot update worker environment: %v", err)
+		}
+		publishConnectionStatus(client)
 	}
 }
 
 // monitorTags tries to monitor tags file for changes
 func monitorTags(client *Client) {
-	c := make(chan notify.EventInfo, 1)
-
 	fp := filepath.Join(constants.ConfigDir, "tags.toml")
 
-	if err := notify.Watch(fp, c, notify.InCloseWrite, notify.InDelete); err != nil {
-		log.Infof("cannot start watching '%v': %v", fp, err)
-		return
+	w := watch.New([]string{fp}, config.DefaultConfig.FilePollInterval)
+	defer w.Stop()
+
+	for range w.C {
+		go updateTags(client)
 	}
-	defer notify.Stop(c)
+}
 
-	for e := range c {
-		log.Debugf("received inotify event %v", e.Event())
-		switch e.Event() {
-		case notify.InCloseWrite, notify.InDelete:
-			go func() {
-				msg, err := client.ConnectionStatus()
-				if err != nil {
-					log.Fatalf("cannot get connection status: %v", err)
-				}
-				if _, _, _, err = client.SendConnectionStatusMessage(msg); err != nil {
-					log.Errorf("cannot send connection status: %v", err)
-				}
-			}()
+// updateTags shares the current tags with workers, through the dispatcher and
+// the worker environment, and publishes a new connection-status message
+// including them.
+func updateTags(client *Client) {
+	client.dispatcher.SetTags(hostTags())
+	if err := setupWorkerEnvironment(); err != nil {
+		log.Errorf("cannot update worker environment: %v", err)
+	}
+	publishConnectionStatus(client)
+}
+
+// readConfigTags reads the "tags" table of the configuration file at filePath
+// and the fragments in its drop-in directory.
+func readConfigTags(filePath string) (map[string]string, error) {
+	if filePath == "" {
+		return nil, nil
+	}
+	tree, err := config.LoadTree(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return config.TreeTags(tree)
+}
+
+// hostTags returns the tags set in the configuration file merged with those
+// read from the tags file, which take precedence.
+func hostTags() map[string]string {
+	merged := make(map[string]string)
+	for k, v := range config.DefaultConfig.Tags {
+		merged[k] = v
+	}
+
+	tagsFilePath := filepath.Join(constants.ConfigDir, "tags.toml")
+	if _, err := os.Stat(tagsFilePath); !os.IsNotExist(err) {
+		fileTags, err := tags.ReadTagsFile(tagsFilePath)
+		if err != nil {
+			log.Errorf("cannot load tags: %v", err)
+		}
+		for k, v := range fileTags {
+			merged[k] = v
 		}
 	}
+	return merged
+}
+
+// monitorConfigFile reloads runtime-adjustable settings from the
+// configuration file at filePath and its drop-in directory whenever a file is
+// written or removed, or yggd receives SIGHUP.
+func monitorConfigFile(filePath string, client *Client) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var changed <-chan string
+	if filePath != "" {
+		w := watch.New([]string{filePath, config.DropInDir(filePath)}, config.DefaultConfig.FilePollInterval)
+		defer w.Stop()
+		changed = w.C
+	}
+
+	for {
+		select {
+		case <-hup:
+			log.Info("received SIGHUP; reloading configuration")
+		case path := <-changed:
+			log.Debugf("'%v' changed; reloading configuration", path)
+		}
+		if err := reloadConfigAndNotify(filePath, client); err != nil {
+			log.Errorf("cannot reload configuration: %v", err)
+		}
+	}
+}
+
+// reloadConfigAndNotify reloads the configuration file at filePath and, if it
+// was applied, informs workers that the configuration changed.
+func reloadConfigAndNotify(filePath string, client *Client) error {
+	if err := reloadConfig(filePath, client); err != nil {
+		return err
+	}
+	if err := client.dispatcher.EmitEvent(ipc.DispatcherEventConfigReloaded); err != nil {
+		log.Errorf("cannot emit event: %v", err)
+	}
+	return nil
+}
+
+// reloadConfig reads the configuration file at filePath and applies the
+// settings that can change without restarting yggd. All values are read and
+// checked before any is applied, so an invalid file leaves the running
+// configuration untouched. The transport is only reconnected if the TLS
+// certificate, key or CA root files changed.
+func reloadConfig(filePath string, client *Client) error {
+	if filePath == "" {
+		return nil
+	}
+	inputSource, err := newConfigSource(filePath)
+	if err != nil {
+		return err
+	}
+
+	logLevel, err := inputSource.String(config.FlagNameLogLevel)
+	if err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameLogLevel, err)
+	}
+	if logLevel != "" {
+		if _, err := log.ParseLevel(logLevel); err != nil {
+			return fmt.Errorf("cannot parse %v: %w", config.FlagNameLogLevel, err)
+		}
+	}
+
+	disableFactsCollectors, err := inputSource.StringSlice(config.FlagNameDisableFactsCollectors)
+	if err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameDisableFactsCollectors, err)
+	}
+
+	excludeWorkers, err := inputSource.StringSlice(config.FlagNameExcludeWorkers)
+	if err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameExcludeWorkers, err)
+	}
+
+	directiveAliases, err := inputSource.StringSlice(config.FlagNameDirectiveAlias)
+	if err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameDirectiveAlias, err)
+	}
+	aliases, err := work.ParseDirectiveAliases(directiveAliases)
+	if err != nil {
+		return err
+	}
+
+	rateLimitEntries, err := inputSource.StringSlice(config.FlagNameRateLimit)
+	if err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameRateLimit, err)
+	}
+	byteQuotas, err := inputSource.StringSlice(config.FlagNameByteQuota)
+	if err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameByteQuota, err)
+	}
+	rateLimits, err := work.ParseRateLimits(rateLimitEntries, byteQuotas)
+	if err != nil {
+		return err
+	}
+
+	concurrencyEntries, err := inputSource.StringSlice(config.FlagNameMaxConcurrency)
+	if err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameMaxConcurrency, err)
+	}
+	concurrencyLimits, err := work.ParseConcurrencyLimits(concurrencyEntries)
+	if err != nil {
+		return err
+	}
+
+	onDemandWorkers, err := inputSource.StringSlice(config.FlagNameOnDemandWorkers)
+	if err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameOnDemandWorkers, err)
+	}
+	onDemandTimeouts, err := work.ParseOnDemandWorkers(onDemandWorkers, config.DefaultConfig.WorkerIdleTimeout)
+	if err != nil {
+		return err
+	}
+
+	dataHost, err := inputSource.String(config.FlagNameDataHost)
+	if err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameDataHost, err)
+	}
+
+	configTags, err := readConfigTags(filePath)
+	if err != nil {
+		return err
+	}
+
+	trustedKeys, err := inputSource.StringSlice(config.FlagNameTrustedKeys)
+	if err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameTrustedKeys, err)
+	}
+	requireSignatures, err := inputSource.Bool(config.FlagNameRequireSignatures)
+	if err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameRequireSignatures, err)
+	}
+	verifier, err := loadVerifier(trustedKeys, requireSignatures)
+	if err != nil {
+		return err
+	}
+
+	redactPatterns, err := inputSource.StringSlice(config.FlagNameRedactPattern)
+	if err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameRedactPattern, err)
+	}
+	redactPaths, err := inputSource.StringSlice(config.FlagNameRedactPath)
+	if err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameRedactPath, err)
+	}
+	redactor, err := logging.NewRedactor(redactPatterns, redactPaths)
+	if err != nil {
+		return err
+	}
+
+	tlsConf := config.DefaultConfig
+	if tlsConf.CertFile, err = inputSource.String(config.FlagNameCertFile); err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameCertFile, err)
+	}
+	if tlsConf.KeyFile, err = inputSource.String(config.FlagNameKeyFile); err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameKeyFile, err)
+	}
+	if tlsConf.CARoot, err = inputSource.StringSlice(config.FlagNameCaRoot); err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameCaRoot, err)
+	}
+	if tlsConf.ServerPins, err = inputSource.StringSlice(config.FlagNameServerPin); err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameServerPin, err)
+	}
+	if tlsConf.TLSMinVersion, err = inputSource.String(config.FlagNameTLSMinVersion); err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameTLSMinVersion, err)
+	}
+	if tlsConf.TLSMaxVersion, err = inputSource.String(config.FlagNameTLSMaxVersion); err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameTLSMaxVersion, err)
+	}
+	if tlsConf.TLSCipherSuites, err = inputSource.StringSlice(config.FlagNameTLSCipherSuites); err != nil {
+		return fmt.Errorf("cannot read %v: %w", config.FlagNameTLSCipherSuites, err)
+	}
+	tlsChanged := tlsConf.CertFile != config.DefaultConfig.CertFile ||
+		tlsConf.KeyFile != config.DefaultConfig.KeyFile ||
+		!slices.Equal(tlsConf.CARoot, config.DefaultConfig.CARoot) ||
+		!slices.Equal(tlsConf.ServerPins, config.DefaultConfig.ServerPins) ||
+		tlsConf.TLSMinVersion != config.DefaultConfig.TLSMinVersion ||
+		tlsConf.TLSMaxVersion != config.DefaultConfig.TLSMaxVersion ||
+		!slices.Equal(tlsConf.TLSCipherSuites, config.DefaultConfig.TLSCipherSuites)
+	var tlsConfig *tls.Config
+	if tlsChanged {
+		tlsConfig, err = tlsConf.CreateTLSConfig()
+		if err != nil {
+			return fmt.Errorf("cannot create TLS config: %w", err)
+		}
+		if _, err := tlsConf.ServerTLSConfig(tlsConfig); err != nil {
+			return fmt.Errorf("cannot create TLS config: %w", err)
+		}
+	}
+
+	if logLevel != "" && logLevel != config.DefaultConfig.LogLevel {
+		config.DefaultConfig.LogLevel = logLevel
+		applyConfiguredLogLevel()
+	}
+
+	config.DefaultConfig.DisableFactsCollectors = disableFactsCollectors
+	if err := facts.DefaultRegistry.SetDisabled(disableFactsCollectors); err != nil {
+		log.Warnf("cannot disable facts collectors: %v", err)
+	}
+
+	config.DefaultConfig.ExcludeWorkers = excludeWorkers
+	client.dispatcher.SetExcludedWorkers(excludeWorkers)
+
+	config.DefaultConfig.DirectiveAliases = directiveAliases
+	client.dispatcher.SetDirectiveAliases(aliases)
+
+	config.DefaultConfig.RateLimits = rateLimitEntries
+	config.DefaultConfig.ByteQuotas = byteQuotas
+	client.dispatcher.SetRateLimits(rateLimits)
+
+	config.DefaultConfig.MaxConcurrency = concurrencyEntries
+	client.dispatcher.SetConcurrencyLimits(concurrencyLimits)
+
+	config.DefaultConfig.OnDemandWorkers = onDemandWorkers
+	client.dispatcher.SetOnDemandWorkers(onDemandTimeouts)
+
+	if dataHost != config.DefaultConfig.DataHost {
+		config.DefaultConfig.DataHost = dataHost
+		log.Infof("data host set to '%v'", dataHost)
+		if err := setupWorkerEnvironment(); err != nil {
+			log.Warnf("cannot setup worker environment: %v", err)
+		}
+	}
+
+	config.DefaultConfig.TrustedKeys = trustedKeys
+	config.DefaultConfig.RequireSignatures = requireSignatures
+	client.verifier.Store(verifier)
+
+	config.DefaultConfig.RedactPatterns = redactPatterns
+	config.DefaultConfig.RedactPaths = redactPaths
+	logging.SetRedactor(redactor)
+
+	if !maps.Equal(configTags, config.DefaultConfig.Tags) {
+		config.DefaultConfig.Tags = configTags
+		log.Info("tags changed")
+		go updateTags(client)
+	}
+
+	if tlsChanged {
+		config.DefaultConfig.CertFile = tlsConf.CertFile
+		config.DefaultConfig.KeyFile = tlsConf.KeyFile
+		config.DefaultConfig.CARoot = tlsConf.CARoot
+		config.DefaultConfig.ServerPins = tlsConf.ServerPins
+		config.DefaultConfig.TLSMinVersion = tlsConf.TLSMinVersion
+		config.DefaultConfig.TLSMaxVersion = tlsConf.TLSMaxVersion
+		config.DefaultConfig.TLSCipherSuites = tlsConf.TLSCipherSuites
+		if err := reloadTLSConfig(tlsConfig, client.transporter, client.dispatcher); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // monitorCertificate tries to monitor certificate file for changes
@@ -320,88 +895,298 @@ func monitorCertificate(
 		return
 	}
 
-	for cfg := range TlSEvents {
-		log.Debug("reloading transport TLS configuration")
-		err := transporter.ReloadTLSConfig(cfg)
+	for cfg := range TlSEvents {
+		if err := reloadTLSConfig(cfg, transporter, dispatcher); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// reloadTLSConfig replaces the TLS configuration of the transporter and the
+// dispatcher's HTTP client with cfg.
+func reloadTLSConfig(
+	cfg *tls.Config,
+	transporter transport.Transporter,
+	dispatcher *work.Dispatcher,
+) error {
+	log.Debug("reloading transport TLS configuration")
+	serverTLSConfig, err := config.DefaultConfig.ServerTLSConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("cannot update transporter TLS configuration: %w", err)
+	}
+	if err := transporter.ReloadTLSConfig(serverTLSConfig); err != nil {
+		return fmt.Errorf("cannot update transporter TLS configuration: %w", err)
+	}
+	log.Info("transport TLS configuration reloaded")
+
+	log.Debug("setting dispatcher HTTP client")
+	httpClient, err := newHTTPClient(cfg)
+	if err != nil {
+		return fmt.Errorf("cannot create dispatcher HTTP client: %w", err)
+	}
+	dispatcher.HTTPClient = httpClient
+	log.Info("dispatcher HTTP client updated")
+	return nil
+}
+
+// systemdWatchDog tries to send sd_notify to systemd.
+// More details about sd_notify can be found here:
+// https://www.freedesktop.org/software/systemd/man/sd_notify.html
+//
+// A watchdog notification is only sent if the dispatcher is responsive, so a
+// hung dispatcher causes systemd to restart yggd.
+func systemdWatchDog(dispatcher *work.Dispatcher) {
+	watchdogDuration, err := daemon.SdWatchdogEnabled(false)
+	if err != nil {
+		log.Errorf("cannot get watchdog duration: %v", err)
+	}
+	if watchdogDuration > 0 {
+		log.Debug("starting systemd watchdog notification")
+		for {
+			if dispatcher.Responsive(watchdogDuration / 4) {
+				if _, err := daemon.SdNotify(false, daemon.SdNotifyWatchdog); err != nil {
+					log.Errorf("cannot call sd_notify(%v): %v", daemon.SdNotifyWatchdog, err)
+				}
+			} else {
+				log.Error("dispatcher is unresponsive; skipping watchdog notification")
+			}
+			time.Sleep(watchdogDuration / 2)
+		}
+	}
+}
+
+// systemdStatus sends a free-form status string to systemd, displayed by
+// "systemctl status".
+func systemdStatus(status string) {
+	if _, err := daemon.SdNotify(false, "STATUS="+status); err != nil {
+		log.Errorf("cannot call sd_notify(STATUS=%v): %v", status, err)
+	}
+}
+
+// mainAction is main action of yggd
+func mainAction(c *cli.Context) error {
+
+	// First of all set up a channel to receive the TERM or INT signal
+	// over and clean up before quitting.
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
+
+	// Generate documentation, when hidden flag is set
+	if c.Bool("generate-man-page") || c.Bool("generate-markdown") {
+		err := generateDocumentation(c)
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+
+	// Check the configuration and host instead of starting, when requested
+	if c.Bool("doctor") {
+		setupDefaultConfig(c)
+		return runDoctor(c)
+	}
+
+	// Detach into the background when not supervised by systemd
+	if c.Bool("daemonize") && c.Bool("container") {
+		return cli.Exit(fmt.Errorf("cannot daemonize in container mode"), 1)
+	}
+	if c.Bool("daemonize") && !daemonized() {
+		pid, err := daemonize()
+		if err != nil {
+			return cli.Exit(fmt.Errorf("cannot daemonize: %w", err), 1)
+		}
+		fmt.Printf("%v started with PID %v\n", c.App.Name, pid)
+		return nil
+	}
+
+	// Setup configuration according to CLI flags and options
+	setupDefaultConfig(c)
+
+	// Setup logging according to configuration
+	err := setupLogging(c)
+	if err != nil {
+		return err
+	}
+
+	// Read tags, which can only be set in the configuration file
+	config.DefaultConfig.Tags, err = readConfigTags(c.String("config"))
+	if err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	if err := work.ValidateOverflowPolicy(config.DefaultConfig.DispatchOverflow); err != nil {
+		return cli.Exit(err, 1)
+	}
+	if len(config.DefaultConfig.ClientIDSources) > 0 {
+		if err := clientid.ValidateSources(config.DefaultConfig.ClientIDSources); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+	log.Infof("starting %v version %v", c.App.Name, c.App.Version)
+
+	if err := setupDirectories(c.Bool("container")); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	// In a container, yggd and its workers share a bus listening in the
+	// runtime directory, unless another bus is given
+	if _, ok := os.LookupEnv("DBUS_SESSION_BUS_ADDRESS"); c.Bool("container") && !ok {
+		if err := os.Setenv("DBUS_SESSION_BUS_ADDRESS", containerBusAddress()); err != nil {
+			return cli.Exit(fmt.Errorf("cannot set bus address: %w", err), 1)
+		}
+	}
+
+	// Record the process ID for supervisors other than systemd, defaulting to
+	// the runtime directory when running in the background
+	pidFile := config.DefaultConfig.PIDFile
+	if pidFile == "" && daemonized() {
+		pidFile = filepath.Join(constants.RuntimeDir, "yggd.pid")
+	}
+	if pidFile != "" {
+		if err := writePIDFile(pidFile); err != nil {
+			return cli.Exit(fmt.Errorf("cannot write PID file: %w", err), 1)
+		}
+		defer func() {
+			if err := removePIDFile(pidFile); err != nil {
+				log.Errorf("cannot remove PID file: %v", err)
+			}
+		}()
+	}
+
+	// Derive the client ID from the configured sources
+	clientIDSource, err := setupClientID(c.String("instance"))
+	if err != nil {
+		return err
+	}
+
+	err = setupFactsFile()
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot setup facts file: %v", err), 1)
+	}
+
+	// Gather canonical facts from the facts file and the facts.d directory
+	// after the built-in collectors, so they take precedence
+	if config.DefaultConfig.FactsFile != "" {
+		facts.Register(facts.FileCollector(config.DefaultConfig.FactsFile))
+	}
+	facts.Register(facts.DirCollector(filepath.Join(constants.ConfigDir, "facts.d")))
+	if err := facts.DefaultRegistry.SetDisabled(config.DefaultConfig.DisableFactsCollectors); err != nil {
+		log.Warnf("cannot disable facts collectors: %v", err)
+	}
+
+	// Share the client ID, connection settings and facts with workers
+	err = setupWorkerEnvironment()
+	if err != nil {
+		log.Warnf("cannot setup worker environment: %v", err)
+	}
+
+	// Create HTTP client and TLS configuration. HTTP client is used for
+	// getting data, when MQTT could not transport too big messages.
+	httpClient, tlsConfig, err := setupTLS()
+	if err != nil {
+		return err
+	}
+
+	// Create Dispatcher service
+	dispatcher := work.NewDispatcher(httpClient)
+
+	// Record unsuccessful worker exits
+	dispatcher.CrashReportDir = filepath.Join(constants.StateDir, "crash")
+
+	// Keep messages that cannot be delivered to a worker
+	dispatcher.DeadLetterDir = filepath.Join(constants.StateDir, "dead-letter")
+
+	// Validate message content against per-directive JSON Schemas
+	dispatcher.SchemaDir = filepath.Join(constants.ConfigDir, "schemas")
+
+	// Journal messages until a worker acknowledges them
+	dispatcher.PendingDir = filepath.Join(constants.StateDir, "pending")
+
+	// Encrypt journaled messages and dead letters
+	dispatcher.SpoolCipher, err = spool.Load(config.DefaultConfig.SpoolKey)
+	if err != nil {
+		return cli.Exit(fmt.Errorf("cannot load %v: %w", config.FlagNameSpoolKey, err), 1)
+	}
+
+	// Drop messages that have already been received
+	err = dispatcher.LoadSeenMessages(
+		filepath.Join(constants.StateDir, "seen-messages.json"),
+		config.DefaultConfig.DedupCacheSize,
+	)
+	if err != nil {
+		log.Warnf("cannot load seen messages: %v", err)
+	}
+
+	// Record the outcome of each message
+	if config.DefaultConfig.MessageHistorySize > 0 {
+		h, err := history.Open(
+			filepath.Join(constants.StateDir, "history.db"),
+			config.DefaultConfig.MessageHistorySize,
+		)
 		if err != nil {
-			log.Errorf("cannot update transporter TLS configuration: %v", err)
-			continue
+			log.Warnf("cannot open message history: %v", err)
+		} else {
+			dispatcher.History = h
 		}
-		log.Info("transport TLS configuration reloaded")
-
-		log.Debug("setting dispatcher HTTP client")
-		httpClient := http.NewHTTPClient(cfg, UserAgent)
-		dispatcher.HTTPClient = httpClient
-		log.Info("dispatcher HTTP client updated")
 	}
-}
 
-// systemdWatchDog tries to send sd_notify to systemd.
-// More details about sd_notify can be found here:
-// https://www.freedesktop.org/software/systemd/man/sd_notify.html
-func systemdWatchDog() {
-	watchdogDuration, err := daemon.SdWatchdogEnabled(false)
+	// Record control messages and the actions taken on their behalf
+	a, err := audit.Open(filepath.Join(constants.StateDir, audit.FileName))
 	if err != nil {
-		log.Errorf("cannot get watchdog duration: %v", err)
-	}
-	if watchdogDuration > 0 {
-		log.Debug("starting systemd watchdog notification")
-		for {
-			if _, err := daemon.SdNotify(false, daemon.SdNotifyWatchdog); err != nil {
-				log.Errorf("cannot call sd_notify(%v): %v", daemon.SdNotifyWatchdog, err)
-			}
-			time.Sleep(watchdogDuration / 2)
-		}
+		return cli.Exit(fmt.Errorf("cannot open audit log: %w", err), 1)
 	}
-}
+	dispatcher.Audit = a
 
-// mainAction is main action of yggd
-func mainAction(c *cli.Context) error {
+	// Ignore workers excluded by configuration
+	dispatcher.SetExcludedWorkers(config.DefaultConfig.ExcludeWorkers)
 
-	// First of all set up a channel to receive the TERM or INT signal
-	// over and clean up before quitting.
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
+	// Let workers read the tags describing the host
+	dispatcher.SetTags(hostTags())
 
-	// Generate documentation, when hidden flag is set
-	if c.Bool("generate-man-page") || c.Bool("generate-markdown") {
-		err := generateDocumentation(c)
-		if err != nil {
-			return err
-		}
-		return nil
-	}
+	// Propagate trace context from the server to workers and back
+	dispatcher.Use(&work.TraceContextMiddleware{MaxAge: 24 * time.Hour})
 
-	// Setup configuration according to CLI flags and options
-	setupDefaultConfig(c)
+	// Pass messages through an external hook program
+	if config.DefaultConfig.MessageHook != "" {
+		dispatcher.Use(work.HookMiddleware{
+			Path:    config.DefaultConfig.MessageHook,
+			Timeout: 30 * time.Second,
+		})
+	}
 
-	// Setup logging according to configuration
-	err := setupLogging(c)
+	// Route directives to workers according to configuration
+	aliases, err := work.ParseDirectiveAliases(config.DefaultConfig.DirectiveAliases)
 	if err != nil {
-		return err
+		return cli.Exit(err, 1)
 	}
-	log.Infof("starting %v version %v", c.App.Name, c.App.Version)
+	dispatcher.SetDirectiveAliases(aliases)
 
-	// Tries to create file containing client ID
-	err = setupClientID()
+	// Limit the messages dispatched to and data transmitted by workers
+	rateLimits, err := work.ParseRateLimits(config.DefaultConfig.RateLimits, config.DefaultConfig.ByteQuotas)
 	if err != nil {
-		return err
+		return cli.Exit(err, 1)
 	}
+	dispatcher.SetRateLimits(rateLimits)
 
-	err = setupFactsFile()
+	// Limit the messages each worker handles at once
+	concurrencyLimits, err := work.ParseConcurrencyLimits(config.DefaultConfig.MaxConcurrency)
 	if err != nil {
-		return cli.Exit(fmt.Errorf("cannot setup facts file: %v", err), 1)
+		return cli.Exit(err, 1)
 	}
+	dispatcher.SetConcurrencyLimits(concurrencyLimits)
 
-	// Create HTTP client and TLS configuration. HTTP client is used for
-	// getting data, when MQTT could not transport too big messages.
-	httpClient, tlsConfig, err := setupTLS()
+	// Start workers on their first message and stop them when idle
+	onDemandTimeouts, err := work.ParseOnDemandWorkers(config.DefaultConfig.OnDemandWorkers, config.DefaultConfig.WorkerIdleTimeout)
 	if err != nil {
-		return err
+		return cli.Exit(err, 1)
 	}
+	dispatcher.SetOnDemandWorkers(onDemandTimeouts)
 
-	// Create Dispatcher service
-	dispatcher := work.NewDispatcher(httpClient)
+	// Restore the set of workers disabled at runtime
+	err = dispatcher.LoadDisabledWorkers(filepath.Join(constants.StateDir, "disabled-workers.json"))
+	if err != nil {
+		log.Warnf("cannot load disabled workers: %v", err)
+	}
 
 	// Create Transporter service (it could be HTTP or MQTT according to configuration)
 	// This also starts probably the most important goroutine waiting for messages
@@ -410,6 +1195,8 @@ func mainAction(c *cli.Context) error {
 	if err != nil {
 		return cli.Exit(fmt.Errorf("cannot setup client: %w", err), 1)
 	}
+	client.configFile = c.String("config")
+	client.clientIDSource = clientIDSource
 
 	// Create a message journal if a journal path is provided
 	// or if it is enabled in the config.
@@ -439,12 +1226,43 @@ func mainAction(c *cli.Context) error {
 	// new connection-status message if the file changes.
 	go monitorFactsFile(client)
 
+	// Start a goroutine that periodically collects canonical facts and
+	// publishes a new connection-status message if they changed.
+	go monitorFacts(client)
+
 	// Start a goroutine that watches the tags file for write events and
 	// publishes connection status messages when the file changes.
 	go monitorTags(client)
 
-	// Start a goroutine that sends notifications to systemd
-	go systemdWatchDog()
+	// Start a goroutine that reloads the configuration file when it changes
+	// or when SIGHUP is received.
+	go monitorConfigFile(c.String("config"), client)
+
+	// Start a goroutine that changes the log level when SIGUSR1 or SIGUSR2
+	// is received.
+	go monitorLogLevelSignals()
+
+	// Serve health endpoints for liveness probes and local monitoring
+	if config.DefaultConfig.HealthListen != "" {
+		l, err := listenLocal(config.DefaultConfig.HealthListen)
+		if err != nil {
+			return cli.Exit(fmt.Errorf("cannot listen for health checks: %w", err), 1)
+		}
+		go serveHealth(l, client)
+	}
+
+	// Serve profiles and internal state for diagnosing yggd in production
+	if config.DefaultConfig.DebugListen != "" {
+		l, err := listenLocal(config.DefaultConfig.DebugListen)
+		if err != nil {
+			return cli.Exit(fmt.Errorf("cannot listen for debug requests: %w", err), 1)
+		}
+		go serveDebug(l, client)
+	}
+
+	// Start a goroutine that sends notifications to systemd. Without
+	// systemd, no watchdog is configured and nothing is sent.
+	go systemdWatchDog(dispatcher)
 
 	// Notify systemd that yggd is ready
 	var sdState = daemon.SdNotifyReady
@@ -456,21 +1274,108 @@ func mainAction(c *cli.Context) error {
 	<-quit
 
 	// Notify systemd that yggd is stopping
+	systemdStatus("stopping")
 	sdState = daemon.SdNotifyStopping
 	if _, err := daemon.SdNotify(false, sdState); err != nil {
 		log.Errorf("cannot call sd_notify(%v): %v", sdState, err)
 	}
 
+	// Finish the messages in progress and go offline
+	client.Shutdown(config.DefaultConfig.ShutdownTimeout)
+
+	// Let workers know that yggd is stopping
+	if err := dispatcher.EmitEvent(ipc.DispatcherEventShuttingDown); err != nil {
+		log.Errorf("cannot emit event: %v", err)
+	}
+
+	// Stop the workers, which have no messages left to handle
+	if err := dispatcher.StopWorkers(); err != nil {
+		log.Errorf("cannot stop workers: %v", err)
+	}
+
 	return nil
 }
 
+// instancePathFlag returns the value of the path flag name. When running as a
+// named instance and the flag is not set, instancePath is returned instead of
+// the flag default, which does not account for the instance.
+func instancePathFlag(c *cli.Context, name string, instancePath string) string {
+	if c.String("instance") != "" && !c.IsSet(name) {
+		return instancePath
+	}
+	return c.Path(name)
+}
+
 // beforeAction loads flag values from a config file only if the
 // "config" flag value is non-zero.
 func beforeAction(c *cli.Context) error {
+	// Namespace the directories and bus of a named instance, reading its
+	// configuration file unless another one is given.
+	if instance := c.String("instance"); instance != "" {
+		if err := config.ApplyInstance(instance); err != nil {
+			return cli.Exit(err, 1)
+		}
+		if !c.IsSet("config") {
+			filePath, err := yggdrasil.ConfigPath()
+			if err != nil {
+				return cli.Exit(err, 1)
+			}
+			if err := c.Set("config", filePath); err != nil {
+				return err
+			}
+		}
+	}
+
+	// In a container, the configuration is read from the environment rather
+	// than a configuration file, unless one is given.
+	if c.Bool("container") {
+		if err := applyContainerEnv(c); err != nil {
+			return cli.Exit(err, 1)
+		}
+		if !c.IsSet("config") {
+			if err := c.Set("config", ""); err != nil {
+				return err
+			}
+		}
+	}
+
 	filePath := c.String("config")
+
+	// Fetch the initial configuration before reading it, if requested.
+	if url := c.String("bootstrap-url"); url != "" {
+		if err := validateBootstrapURL(url); err != nil {
+			return cli.Exit(err, 1)
+		}
+		if filePath == "" {
+			filePath = filepath.Join(instancePathFlag(c, config.FlagNameConfigDir, constants.ConfigDir), "config.toml")
+		}
+		tokenFile := instancePathFlag(c, "bootstrap-token-file", filepath.Join(constants.ConfigDir, "bootstrap-token"))
+		err := bootstrap(newBootstrapClient(), url, tokenFile, filePath, instancePathFlag(c, config.FlagNameConfigDir, constants.ConfigDir))
+		if err != nil {
+			return cli.Exit(fmt.Errorf("cannot bootstrap: %w", err), 1)
+		}
+		if err := c.Set("config", filePath); err != nil {
+			return err
+		}
+	}
+
+	if filePath != "" && c.Bool("strict-config") && !c.Bool("doctor") {
+		problems, err := config.CheckFiles(filePath)
+		if err != nil {
+			return cli.Exit(err, 1)
+		}
+		if len(problems) > 0 {
+			return cli.Exit(fmt.Errorf("invalid configuration:\n%v", strings.Join(problems, "\n")), 1)
+		}
+	}
+
 	if filePath != "" {
-		inputSource, err := altsrc.NewTomlSourceFromFile(filePath)
+		inputSource, err := newConfigSource(filePath)
 		if err != nil {
+			// The doctor reports the problem along with the other checks.
+			if c.Bool("doctor") {
+				return nil
+			}
 			return err
 		}
 		return altsrc.ApplyInputSourceValues(c, inputSource, c.App.Flags)
@@ -478,6 +1383,34 @@ func beforeAction(c *cli.Context) error {
 	return nil
 }
 
+// newConfigSource creates an input source from the configuration file at
+// filePath, merged with the fragments in its drop-in directory, with secret
+// references replaced by the secrets.
+func newConfigSource(filePath string) (altsrc.InputSourceContext, error) {
+	tree, err := config.LoadTree(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// MapInputSource expects integers as int, while go-toml decodes them as
+	// int64. String values may reference secrets, which are resolved here so
+	// they never need to be stored in the configuration file.
+	values := make(map[interface{}]interface{})
+	for k, v := range tree.ToMap() {
+		switch t := v.(type) {
+		case int64:
+			v = int(t)
+		case string:
+			v, err = config.ResolveSecret(t)
+			if err != nil {
+				return nil, fmt.Errorf("cannot resolve %v: %w", k, err)
+			}
+		}
+		values[k] = v
+	}
+	return altsrc.NewMapInputSource(filePath, values), nil
+}
+
 // main is entry point for yggd daemon
 func main() {
 	app := cli.NewApp()
@@ -497,11 +1430,47 @@ func main() {
 			TakesFile: true,
 			Usage:     "Read config values from `FILE`",
 		},
+		&cli.StringFlag{
+			Name:  "instance",
+			Usage: "Run as the isolated instance `NAME`, with its own directories, bus and client ID",
+		},
+		&cli.BoolFlag{
+			Name:  "strict-config",
+			Usage: "Refuse to start if the configuration has unknown options or invalid values",
+		},
+		&cli.BoolFlag{
+			Name:    "container",
+			EnvVars: []string{"YGGD_CONTAINER"},
+			Usage:   "Run as a container sidecar, configured from YGGD_* environment variables and logging to standard output",
+		},
+		&cli.BoolFlag{
+			Name:  "daemonize",
+			Usage: "Detach from the terminal and run in the background, without systemd",
+		},
+		&cli.BoolFlag{
+			Name:  "doctor",
+			Usage: "Check the configuration, certificates, servers and directories, print a report and exit",
+		},
+		&cli.StringFlag{
+			Name:  "bootstrap-url",
+			Usage: "Fetch the initial configuration from the https `URL` before starting",
+		},
+		&cli.PathFlag{
+			Name:      "bootstrap-token-file",
+			Value:     filepath.Join(constants.ConfigDir, "bootstrap-token"),
+			TakesFile: true,
+			Usage:     "Authenticate to the bootstrap URL with the one-time token in `FILE`",
+		},
 		altsrc.NewStringFlag(&cli.StringFlag{
 			Name:  config.FlagNameLogLevel,
 			Value: "info",
 			Usage: "Set the logging output level to `LEVEL`",
 		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameLogFormat,
+			Value: "text",
+			Usage: "Write log output in `FORMAT` (text or json)",
+		}),
 		altsrc.NewStringFlag(&cli.StringFlag{
 			Name:  config.FlagNameCertFile,
 			Usage: "Use `FILE` as the client certificate",
@@ -515,6 +1484,22 @@ func main() {
 			Hidden: true,
 			Usage:  "Use `FILE` as the root CA",
 		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:  config.FlagNameServerPin,
+			Usage: "Accept only servers whose certificate matches `PIN` ('sha256//BASE64' public key hash or certificate file) (can be specified multiple times)",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameTLSMinVersion,
+			Usage: "Require at least TLS `VERSION` ('1.2' or '1.3', default '1.3')",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameTLSMaxVersion,
+			Usage: "Allow at most TLS `VERSION` ('1.2' or '1.3')",
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:  config.FlagNameTLSCipherSuites,
+			Usage: "Enable only the TLS 1.2 cipher suite `NAME` (can be specified multiple times)",
+		}),
 		altsrc.NewStringFlag(&cli.StringFlag{
 			Name:   config.FlagNamePathPrefix,
 			Value:  constants.DefaultPathPrefix,
@@ -547,11 +1532,24 @@ func main() {
 			Name:  config.FlagNameClientID,
 			Usage: "Use `VALUE` as the client ID when connecting",
 		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:  config.FlagNameClientIDSources,
+			Usage: "Derive the client ID from the first of `SOURCE` (config, certificate, machine-id or file) that yields one (can be specified multiple times; default config, certificate, file)",
+		}),
 		altsrc.NewPathFlag(&cli.PathFlag{
 			Name:      config.FlagNameFactsFile,
 			Usage:     "Read facts from `FILE`",
 			TakesFile: true,
 		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:  config.FlagNameDisableFactsCollectors,
+			Usage: "Do not gather canonical facts with the collector `NAME` (can be specified multiple times)",
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:  config.FlagNameFactsInterval,
+			Usage: "Collect canonical facts every `DURATION` and publish them if they changed (0 disables)",
+			Value: 15 * time.Minute,
+		}),
 		altsrc.NewIntFlag(&cli.IntFlag{
 			Name:   config.FlagNameHTTPRetries,
 			Usage:  "Retry HTTP requests `N` times",
@@ -562,6 +1560,57 @@ func main() {
 			Usage:  "Wait for `DURATION` before cancelling an HTTP request",
 			Hidden: true,
 		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:  config.FlagNameHTTPHeader,
+			Usage: "Add the header `NAME: VALUE` to every HTTP request (can be specified multiple times)",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameHTTPUserAgent,
+			Usage: "Send `VALUE` as the User-Agent of HTTP requests",
+			Value: UserAgent,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameHTTPReceiveMode,
+			Usage: "Receive HTTP control messages by `MODE` ('poll' or 'sse')",
+			Value: "poll",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameOAuthFlow,
+			Usage: "Authenticate with OAuth 2.0 access tokens obtained by `FLOW` ('client-credentials' or 'device')",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameOAuthTokenURL,
+			Usage: "Request OAuth 2.0 access tokens from `URL`",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameOAuthDeviceURL,
+			Usage: "Request OAuth 2.0 device authorization from `URL`",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameOAuthClientID,
+			Usage: "Request OAuth 2.0 access tokens as client `ID`",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameOAuthClientSecret,
+			Usage: "Authenticate the OAuth 2.0 client with `SECRET`",
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:  config.FlagNameOAuthScope,
+			Usage: "Request OAuth 2.0 access tokens for `SCOPE` (can be specified multiple times)",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameKerberosKeytab,
+			Usage: "Authenticate HTTP requests with Kerberos using the keys in `FILE`",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameKerberosPrincipal,
+			Usage: "Obtain Kerberos tickets as `PRINCIPAL`",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameKerberosConfig,
+			Usage: "Read the Kerberos configuration from `FILE`",
+			Value: "/etc/krb5.conf",
+		}),
 		altsrc.NewBoolFlag(&cli.BoolFlag{
 			Name:   config.FlagNameMQTTConnectRetry,
 			Usage:  "Enable automatic reconnection logic when the client initially connects",
@@ -598,10 +1647,198 @@ func main() {
 			Value:  30 * time.Second,
 			Hidden: true,
 		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameMQTTTopicTemplate,
+			Usage: "Build MQTT topics from the Go template `TEMPLATE`",
+			Value: transport.DefaultTopicTemplate,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameMQTTUsername,
+			Usage: "Authenticate to the MQTT broker as `USERNAME`",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameMQTTPassword,
+			Usage: "Authenticate to the MQTT broker with `PASSWORD` (use 'secret:NAME' to read it from a credential)",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameMQTTPasswordFile,
+			Usage: "Authenticate to the MQTT broker with the password read from `FILE`",
+		}),
 		altsrc.NewStringFlag(&cli.StringFlag{
 			Name:  config.FlagNameMessageJournal,
 			Usage: "Record worker events and messages in the database `FILE`",
 		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:   config.FlagNameHealthCheckInterval,
+			Usage:  "Check running workers every `DURATION`, restarting those that do not answer pings or make no progress on a message within their response timeout (0 disables health checks)",
+			Hidden: true,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:   config.FlagNameHealthCheckFailures,
+			Usage:  "Restart a worker after it fails `N` consecutive health checks",
+			Value:  3,
+			Hidden: true,
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:  config.FlagNameFilePollInterval,
+			Usage: "Check files on file systems without inotify support for changes every `DURATION`",
+			Value: watch.DefaultPollInterval,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:  config.FlagNameWorkerStartConcurrency,
+			Usage: "Start all workers when yggd starts, `N` at a time (0 to start each on its first message)",
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:  config.FlagNameOnDemandWorkers,
+			Usage: "Start a worker on its first message and stop it when idle, given as `WORKER[=DURATION]` (can be specified multiple times)",
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:  config.FlagNameWorkerIdleTimeout,
+			Usage: "Stop an on-demand worker after it has been idle for `DURATION`",
+			Value: 5 * time.Minute,
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:  config.FlagNameShutdownTimeout,
+			Usage: "Wait up to `DURATION` for workers to handle received messages when stopping",
+			Value: 30 * time.Second,
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:   config.FlagNameRestartDelay,
+			Usage:  "Wait at least `DURATION` between restarts of a worker that fails health checks",
+			Value:  5 * time.Second,
+			Hidden: true,
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:   config.FlagNameRestartMaxDelay,
+			Usage:  "Wait at most `DURATION` between restarts of a worker that fails health checks (0 for no limit)",
+			Value:  5 * time.Minute,
+			Hidden: true,
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:  config.FlagNameExcludeWorkers,
+			Usage: "Ignore the worker `NAME` (can be specified multiple times)",
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:  config.FlagNameRemoteWorkerControl,
+			Usage: "Allow the server to start, stop or restart the worker `NAME` (can be specified multiple times)",
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:  config.FlagNameTrustedKeys,
+			Usage: "Verify message signatures against the public keys in `FILE` (can be specified multiple times)",
+		}),
+		altsrc.NewBoolFlag(&cli.BoolFlag{
+			Name:  config.FlagNameRequireSignatures,
+			Usage: "Reject messages from the server without a signature made by a trusted key",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameSpoolKey,
+			Usage: "Encrypt messages kept on disk with the base64-encoded `KEY` (or keyring:NAME)",
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:  config.FlagNameRedactPattern,
+			Usage: "Mask matches of the regular expression `REGEXP` in logged messages (can be specified multiple times)",
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:  config.FlagNameRedactPath,
+			Usage: "Mask the value at `PATH`, such as content.password, in logged messages (can be specified multiple times)",
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:  config.FlagNameRateLimit,
+			Usage: "Dispatch at most N messages per minute to a worker, given as `DIRECTIVE=N` (can be specified multiple times)",
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:  config.FlagNameByteQuota,
+			Usage: "Let a worker transmit at most SIZE bytes per hour, given as `DIRECTIVE=SIZE` (can be specified multiple times)",
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:   config.FlagNameDispatchRetries,
+			Usage:  "Retry delivering a message to a worker `N` times before reporting failure",
+			Value:  3,
+			Hidden: true,
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:   config.FlagNameDispatchRetryDelay,
+			Usage:  "Wait at least `DURATION` before retrying delivery of a message to a worker",
+			Value:  1 * time.Second,
+			Hidden: true,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:   config.FlagNameDedupCacheSize,
+			Usage:  "Remember the IDs of the last `N` messages to drop duplicates",
+			Value:  1000,
+			Hidden: true,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameHealthListen,
+			Usage: "Serve /healthz and /readyz on `ADDRESS` (loopback HOST:PORT or unix:PATH)",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameDebugListen,
+			Usage: "Serve pprof profiles and internal state on `ADDRESS` (loopback HOST:PORT or unix:PATH)",
+		}),
+		altsrc.NewPathFlag(&cli.PathFlag{
+			Name:  config.FlagNameConfigDir,
+			Value: constants.ConfigDir,
+			Usage: "Read configuration data, such as tags and message schemas, from `DIR`",
+		}),
+		altsrc.NewPathFlag(&cli.PathFlag{
+			Name:  config.FlagNameStateDir,
+			Value: constants.StateDir,
+			Usage: "Store local state in `DIR`",
+		}),
+		altsrc.NewPathFlag(&cli.PathFlag{
+			Name:  config.FlagNameRuntimeDir,
+			Value: constants.RuntimeDir,
+			Usage: "Store runtime data, such as the worker environment file, in `DIR`",
+		}),
+		altsrc.NewPathFlag(&cli.PathFlag{
+			Name:  config.FlagNamePIDFile,
+			Usage: "Write the process ID to `FILE` while running",
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:   config.FlagNameMessageHistorySize,
+			Usage:  "Keep the outcomes of the last `N` messages in the message history",
+			Value:  1000,
+			Hidden: true,
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:  config.FlagNameMessageMaxAge,
+			Usage: "Discard messages sent more than `DURATION` ago (0 to disable)",
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:  config.FlagNameMaxConcurrency,
+			Usage: "Dispatch at most N messages at once to a worker, given as `DIRECTIVE=N`, overriding its max_concurrency feature (can be specified multiple times)",
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:  config.FlagNameDispatchQueueDepth,
+			Usage: "Queue up to `N` messages for each busy worker",
+			Value: 100,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  config.FlagNameDispatchOverflow,
+			Usage: "Apply `POLICY` to messages for a worker with a full queue (reject, drop-oldest, or block to hold them until that queue has room)",
+			Value: work.OverflowReject,
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:  config.FlagNameResponseTimeout,
+			Usage: "Report messages a worker has not responded to within `DURATION` as timed out (0 to disable)",
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:  config.FlagNameDirectiveAlias,
+			Usage: "Route messages for a directive to a worker, given as `DIRECTIVE=WORKER` (can be specified multiple times)",
+		}),
+		altsrc.NewPathFlag(&cli.PathFlag{
+			Name:  config.FlagNameMessageHook,
+			Usage: "Run `FILE` to inspect, modify or reject each message",
+		}),
+		altsrc.NewPathFlag(&cli.PathFlag{
+			Name:  config.FlagNameOnConnectHook,
+			Usage: "Run `FILE` each time the transport connects",
+		}),
+		altsrc.NewPathFlag(&cli.PathFlag{
+			Name:  config.FlagNameOnDisconnectHook,
+			Usage: "Run `FILE` each time the transport disconnects",
+		}),
 	}
 
 	app.EnableBashCompletion = true
@@ -1,93 +1,52 @@
 package main
 
 import (
-	"crypto/x509"
-	"encoding/pem"
+	"bytes"
 	"fmt"
-	"math/rand"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 )
 
-func randomString(n int) string {
-	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
-	data := make([]byte, n)
-	for i := range data {
-		data[i] = letters[rand.Intn(len(letters))]
-	}
-	return string(data)
+// environmentName converts a key into a name suitable for use as an
+// environment variable by upper-casing it and replacing any character that is
+// not a letter, digit or underscore with an underscore.
+func environmentName(key string) string {
+	return regexp.MustCompile("[^A-Z0-9_]").ReplaceAllString(strings.ToUpper(key), "_")
 }
 
-// parseCertCN parses the contents of filename as an x509 certificate and
-// returns the Subject CommonName.
-func parseCertCN(filename string) (string, error) {
-	var asn1Data []byte
-	switch filepath.Ext(filename) {
-	case ".pem":
-		data, err := os.ReadFile(filename)
-		if err != nil {
-			return "", err
-		}
-
-		block, _ := pem.Decode(data)
-		if block == nil {
-			return "", fmt.Errorf("failed to decode PEM data: %v", filename)
-		}
-		asn1Data = append(asn1Data, block.Bytes...)
-	default:
-		var err error
-		asn1Data, err = os.ReadFile(filename)
-		if err != nil {
-			return "", err
-		}
+// formatEnvironmentFile formats env as the contents of a systemd
+// EnvironmentFile, sorted by key, with each value quoted.
+func formatEnvironmentFile(env map[string]string) []byte {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 
-	cert, err := x509.ParseCertificate(asn1Data)
-	if err != nil {
-		return "", err
-	}
-	return cert.Subject.CommonName, nil
-}
-
-// createClientID will generate a semi-random string to be used as the MQTT
-// client ID and save the value to the client-id file.
-func createClientID(file string) ([]byte, error) {
-	if _, err := os.Stat(file); os.IsExist(err) {
-		return nil, fmt.Errorf("cannot create client-id: %w", err)
+	var buf bytes.Buffer
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "%v=%v\n", k, strconv.Quote(env[k]))
 	}
-
-	data := []byte(randomString(64))
-
-	if err := setClientID(data, file); err != nil {
-		return nil, fmt.Errorf("cannot set client-id: %w", err)
-	}
-
-	return data, nil
+	return buf.Bytes()
 }
 
-// setClientID writes data to the client ID file.
-func setClientID(data []byte, file string) error {
-	if err := os.MkdirAll(filepath.Dir(file), 0750); err != nil {
+// writeFileAtomic writes data to a temporary file in the same directory as
+// file and renames it into place, so readers never observe a partial write.
+func writeFileAtomic(file string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
 		return fmt.Errorf("cannot create directory: %w", err)
 	}
 
-	if err := os.WriteFile(file, data, 0600); err != nil {
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
 		return fmt.Errorf("cannot write file: %w", err)
 	}
-
-	return nil
-
-}
-
-// getClientID reads data from the client ID file.
-func getClientID(file string) ([]byte, error) {
-	if _, err := os.Stat(file); os.IsNotExist(err) {
-		return nil, nil
-	}
-	data, err := os.ReadFile(file)
-	if err != nil {
-		return nil, fmt.Errorf("cannot read file: %w", err)
+	if err := os.Rename(tmp, file); err != nil {
+		return fmt.Errorf("cannot rename file: %w", err)
 	}
-
-	return data, nil
+	return nil
 }
@@ -0,0 +1,204 @@
+// Package audit implements an append-only, tamper-evident log of the actions
+// yggd takes on behalf of the server.
+//
+// Each record is a JSON object on its own line. A record holds the SHA-256
+// hash of the record before it, and its own hash covers every other field, so
+// modifying, removing or reordering records breaks the chain, which Verify
+// detects.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+)
+
+// FileName is the name of the audit log file in the yggd state directory.
+const FileName = "audit.log"
+
+// Names of audited actions.
+const (
+	ActionControlReceived = "control-received"
+	ActionDispatched      = "dispatched"
+	ActionWorkerStarted   = "worker-started"
+	ActionWorkerStopped   = "worker-stopped"
+	ActionWorkerControl   = "worker-control"
+	ActionRejected        = "rejected"
+)
+
+// zeroHash is the previous hash of the first record.
+var zeroHash = hex.EncodeToString(make([]byte, sha256.Size))
+
+// Record is an entry in the audit log.
+type Record struct {
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Worker    string    `json:"worker,omitempty"`
+	MessageID string    `json:"message_id,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `json:"hash"`
+}
+
+// sum returns the hash of r, computed over every field but Hash.
+func (r Record) sum() (string, error) {
+	r.Hash = ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// Log is an open audit log. A nil *Log discards records.
+type Log struct {
+	mu       sync.Mutex
+	file     *os.File
+	seq      uint64
+	lastHash string
+}
+
+// Open opens the audit log at path for appending, creating it if needed. The
+// chain is continued from the last record in the file. A partial record at the
+// end of the file, left by a write interrupted by a crash, is truncated with a
+// warning; a broken chain is left for Verify to report.
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("cannot create directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open audit log: %w", err)
+	}
+
+	l := &Log{file: file, lastHash: zeroHash}
+	reader := bufio.NewReader(file)
+	var size int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				log.Warnf("truncating partial record at the end of audit log %v", path)
+				if err := file.Truncate(size); err != nil {
+					file.Close()
+					return nil, fmt.Errorf("cannot truncate audit log: %w", err)
+				}
+			}
+			break
+		}
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("cannot read audit log: %w", err)
+		}
+		size += int64(len(line))
+
+		var r Record
+		if err := json.Unmarshal(line, &r); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("cannot parse audit log record: %w", err)
+		}
+		l.seq = r.Seq
+		l.lastHash = r.Hash
+	}
+	return l, nil
+}
+
+// Append adds a record of action to the log and syncs it to disk.
+func (l *Log) Append(action, worker, messageID, detail string) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r := Record{
+		Seq:       l.seq + 1,
+		Time:      time.Now().UTC(),
+		Action:    action,
+		Worker:    worker,
+		MessageID: messageID,
+		Detail:    detail,
+		PrevHash:  l.lastHash,
+	}
+	hash, err := r.sum()
+	if err != nil {
+		return fmt.Errorf("cannot hash audit record: %w", err)
+	}
+	r.Hash = hash
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("cannot marshal audit record: %w", err)
+	}
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("cannot write audit record: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("cannot sync audit log: %w", err)
+	}
+	l.seq = r.Seq
+	l.lastHash = r.Hash
+	return nil
+}
+
+// Close closes the log.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// Verify reads an audit log from r and checks its hash chain, returning the
+// number of records read. The error names the first record that breaks the
+// chain.
+func Verify(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	n := 0
+	prev := Record{Hash: zeroHash}
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		n++
+
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return n, fmt.Errorf("line %v: cannot parse record: %w", n, err)
+		}
+		if rec.Seq != prev.Seq+1 {
+			return n, fmt.Errorf("line %v: sequence number %v does not follow %v", n, rec.Seq, prev.Seq)
+		}
+		if rec.PrevHash != prev.Hash {
+			return n, fmt.Errorf("line %v: record %v does not follow the hash of record %v", n, rec.Seq, prev.Seq)
+		}
+		hash, err := rec.sum()
+		if err != nil {
+			return n, fmt.Errorf("line %v: cannot hash record: %w", n, err)
+		}
+		if rec.Hash != hash {
+			return n, fmt.Errorf("line %v: record %v has been modified", n, rec.Seq)
+		}
+		prev = rec
+	}
+	if err := scanner.Err(); err != nil {
+		return n, fmt.Errorf("cannot read audit log: %w", err)
+	}
+	return n, nil
+}
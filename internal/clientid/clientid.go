@@ -0,0 +1,177 @@
+// Package clientid derives the client ID yggd uses to identify itself to the
+// server.
+//
+// The client ID is taken from the first of an ordered list of sources that
+// yields one:
+//
+//   - "config": the client-id configuration option.
+//   - "certificate": the subject common name of the client certificate or, if
+//     it is empty, its first DNS subject alternative name.
+//   - "machine-id": the systemd machine ID, suffixed with the instance name
+//     when yggd runs as a named instance.
+//   - "file": the ID persisted in the client ID file, which is created with a
+//     random UUID if it does not exist. This source always yields an ID.
+package clientid
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Names of client ID sources.
+const (
+	SourceConfig      = "config"
+	SourceCertificate = "certificate"
+	SourceMachineID   = "machine-id"
+	SourceFile        = "file"
+)
+
+// DefaultSources is the default order in which sources are tried.
+var DefaultSources = []string{SourceConfig, SourceCertificate, SourceFile}
+
+// Inputs holds the values the sources derive a client ID from.
+type Inputs struct {
+	// ClientID is the value of the client-id configuration option.
+	ClientID string
+
+	// CertFile is the path to the client certificate, in PEM or DER format.
+	CertFile string
+
+	// MachineIDFile is the path to the systemd machine ID file.
+	MachineIDFile string
+
+	// File is the path to the persisted client ID file.
+	File string
+
+	// Instance is the name of the yggd instance, if any. Instances on one
+	// host share the machine ID, so it is suffixed with the instance name.
+	Instance string
+}
+
+// ValidateSources returns an error if sources is empty or names an unknown
+// source.
+func ValidateSources(sources []string) error {
+	if len(sources) == 0 {
+		return fmt.Errorf("no client ID source is set")
+	}
+	for _, source := range sources {
+		switch source {
+		case SourceConfig, SourceCertificate, SourceMachineID, SourceFile:
+		default:
+			return fmt.Errorf("unknown client ID source '%v'", source)
+		}
+	}
+	return nil
+}
+
+// Derive returns the client ID yielded by the first source in sources that
+// yields one, along with the name of that source. An ID derived from a source
+// other than the file is written to the client ID file, so the file always
+// holds the ID in use.
+func Derive(sources []string, in Inputs) (id string, source string, err error) {
+	if err := ValidateSources(sources); err != nil {
+		return "", "", err
+	}
+	for _, source := range sources {
+		var id string
+		var err error
+		switch source {
+		case SourceConfig:
+			id = in.ClientID
+		case SourceCertificate:
+			if in.CertFile != "" {
+				id, err = certificateID(in.CertFile)
+			}
+		case SourceMachineID:
+			id, err = readID(in.MachineIDFile)
+			if id != "" && in.Instance != "" {
+				id += "-" + in.Instance
+			}
+		case SourceFile:
+			id, err = readID(in.File)
+			if err == nil && id == "" {
+				id, err = Generate(in.File)
+			}
+			if err != nil {
+				return "", "", fmt.Errorf("cannot derive client ID from %v: %w", source, err)
+			}
+			return id, source, nil
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("cannot derive client ID from %v: %w", source, err)
+		}
+		if id == "" {
+			continue
+		}
+		if err := write(in.File, id); err != nil {
+			return "", "", err
+		}
+		return id, source, nil
+	}
+	return "", "", fmt.Errorf("no client ID source yielded an ID: %v", strings.Join(sources, ", "))
+}
+
+// Generate creates a new random client ID and writes it to file, replacing
+// any previous ID.
+func Generate(file string) (string, error) {
+	id := uuid.New().String()
+	if err := write(file, id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// readID reads an ID from file, returning an empty string if the file does not
+// exist.
+func readID(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("cannot read file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// write writes id to file.
+func write(file, id string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0750); err != nil {
+		return fmt.Errorf("cannot create directory: %w", err)
+	}
+	if err := os.WriteFile(file, []byte(id), 0600); err != nil {
+		return fmt.Errorf("cannot write client ID file: %w", err)
+	}
+	return nil
+}
+
+// certificateID returns the subject common name of the certificate in file
+// or, if it is empty, its first DNS subject alternative name.
+func certificateID(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("cannot read certificate: %w", err)
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	} else if filepath.Ext(file) == ".pem" {
+		return "", fmt.Errorf("cannot decode PEM data: %v", file)
+	}
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse certificate: %w", err)
+	}
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName, nil
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0], nil
+	}
+	return "", nil
+}
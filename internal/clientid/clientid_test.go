@@ -0,0 +1,174 @@
+package clientid
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// writeCertificate writes a self-signed certificate with the given common
+// name and DNS names to a file in dir and returns its path.
+func writeCertificate(t *testing.T, dir, commonName string, dnsNames []string) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "cert.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDerive(t *testing.T) {
+	tests := []struct {
+		description string
+		sources     []string
+		clientID    string
+		commonName  string
+		dnsNames    []string
+		machineID   string
+		file        string
+		instance    string
+		wantID      string
+		wantSource  string
+		wantError   bool
+	}{
+		{
+			description: "config",
+			sources:     DefaultSources,
+			clientID:    "configured",
+			commonName:  "certificate",
+			wantID:      "configured",
+			wantSource:  SourceConfig,
+		},
+		{
+			description: "certificate common name",
+			sources:     DefaultSources,
+			commonName:  "certificate",
+			file:        "persisted",
+			wantID:      "certificate",
+			wantSource:  SourceCertificate,
+		},
+		{
+			description: "certificate DNS name",
+			sources:     DefaultSources,
+			dnsNames:    []string{"host.example.com"},
+			wantID:      "host.example.com",
+			wantSource:  SourceCertificate,
+		},
+		{
+			description: "machine ID",
+			sources:     []string{SourceMachineID, SourceFile},
+			machineID:   "4c4c4544004c4c4544004c4c45440000\n",
+			file:        "persisted",
+			wantID:      "4c4c4544004c4c4544004c4c45440000",
+			wantSource:  SourceMachineID,
+		},
+		{
+			description: "machine ID of instance",
+			sources:     []string{SourceMachineID, SourceFile},
+			machineID:   "4c4c4544004c4c4544004c4c45440000\n",
+			instance:    "staging",
+			wantID:      "4c4c4544004c4c4544004c4c45440000-staging",
+			wantSource:  SourceMachineID,
+		},
+		{
+			description: "persisted",
+			sources:     DefaultSources,
+			file:        "persisted",
+			wantID:      "persisted",
+			wantSource:  SourceFile,
+		},
+		{
+			description: "generated",
+			sources:     DefaultSources,
+			wantSource:  SourceFile,
+		},
+		{
+			description: "no source yields",
+			sources:     []string{SourceConfig, SourceMachineID},
+			wantError:   true,
+		},
+		{
+			description: "unknown source",
+			sources:     []string{"hostname"},
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			dir := t.TempDir()
+			in := Inputs{
+				ClientID:      test.clientID,
+				MachineIDFile: filepath.Join(dir, "machine-id"),
+				File:          filepath.Join(dir, "client-id"),
+				Instance:      test.instance,
+			}
+			if test.commonName != "" || len(test.dnsNames) > 0 {
+				in.CertFile = writeCertificate(t, dir, test.commonName, test.dnsNames)
+			}
+			if test.machineID != "" {
+				if err := os.WriteFile(in.MachineIDFile, []byte(test.machineID), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if test.file != "" {
+				if err := os.WriteFile(in.File, []byte(test.file), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			gotID, gotSource, err := Derive(test.sources, in)
+
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.wantID == "" {
+				if _, err := uuid.Parse(gotID); err != nil {
+					t.Errorf("expected a generated UUID, got %q", gotID)
+				}
+			} else if gotID != test.wantID {
+				t.Errorf("%q != %q", gotID, test.wantID)
+			}
+			if gotSource != test.wantSource {
+				t.Errorf("%q != %q", gotSource, test.wantSource)
+			}
+			persisted, err := os.ReadFile(in.File)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(persisted) != gotID {
+				t.Errorf("persisted %q != %q", persisted, gotID)
+			}
+		})
+	}
+}
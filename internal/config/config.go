@@ -8,29 +8,93 @@ import (
 
 	"git.sr.ht/~spc/go-log"
 	"github.com/redhatinsights/yggdrasil/internal/constants"
-	"github.com/rjeczalik/notify"
+	"github.com/redhatinsights/yggdrasil/internal/kerberos"
+	"github.com/redhatinsights/yggdrasil/internal/oauth"
+	"github.com/redhatinsights/yggdrasil/internal/watch"
 )
 
 const (
 	FlagNameLogLevel                 = "log-level"
+	FlagNameLogFormat                = "log-format"
 	FlagNameCertFile                 = "cert-file"
 	FlagNameKeyFile                  = "key-file"
 	FlagNameCaRoot                   = "ca-root"
+	FlagNameServerPin                = "server-pin"
+	FlagNameTLSMinVersion            = "tls-min-version"
+	FlagNameTLSMaxVersion            = "tls-max-version"
+	FlagNameTLSCipherSuites          = "tls-cipher-suites"
 	FlagNameServer                   = "server"
 	FlagNameClientID                 = "client-id"
+	FlagNameClientIDSources          = "client-id-sources"
 	FlagNamePathPrefix               = "path-prefix"
 	FlagNameProtocol                 = "protocol"
 	FlagNameDataHost                 = "data-host"
 	FlagNameFactsFile                = "facts-file"
+	FlagNameDisableFactsCollectors   = "disable-facts-collectors"
+	FlagNameFactsInterval            = "facts-interval"
 	FlagNameHTTPRetries              = "http-retries"
 	FlagNameHTTPTimeout              = "http-timeout"
+	FlagNameHTTPHeader               = "http-header"
+	FlagNameHTTPUserAgent            = "http-user-agent"
+	FlagNameHTTPReceiveMode          = "http-receive-mode"
+	FlagNameOAuthFlow                = "oauth-flow"
+	FlagNameOAuthTokenURL            = "oauth-token-url"
+	FlagNameOAuthDeviceURL           = "oauth-device-authorization-url"
+	FlagNameOAuthClientID            = "oauth-client-id"
+	FlagNameOAuthClientSecret        = "oauth-client-secret"
+	FlagNameOAuthScope               = "oauth-scope"
+	FlagNameKerberosKeytab           = "kerberos-keytab"
+	FlagNameKerberosPrincipal        = "kerberos-principal"
+	FlagNameKerberosConfig           = "kerberos-config"
 	FlagNameMQTTConnectRetry         = "mqtt-connect-retry"
 	FlagNameMQTTConnectRetryInterval = "mqtt-connect-retry-interval"
 	FlagNameMQTTAutoReconnect        = "mqtt-auto-reconnect"
 	FlagNameMQTTReconnectDelay       = "mqtt-reconnect-delay"
 	FlagNameMQTTConnectTimeout       = "mqtt-connect-timeout"
 	FlagNameMQTTPublishTimeout       = "mqtt-publish-timeout"
+	FlagNameMQTTTopicTemplate        = "mqtt-topic-template"
+	FlagNameMQTTUsername             = "mqtt-username"
+	FlagNameMQTTPassword             = "mqtt-password"
+	FlagNameMQTTPasswordFile         = "mqtt-password-file"
 	FlagNameMessageJournal           = "message-journal"
+	FlagNameHealthCheckInterval      = "health-check-interval"
+	FlagNameHealthCheckFailures      = "health-check-failures"
+	FlagNameWorkerStartConcurrency   = "worker-start-concurrency"
+	FlagNameOnDemandWorkers          = "on-demand-workers"
+	FlagNameWorkerIdleTimeout        = "worker-idle-timeout"
+	FlagNameFilePollInterval         = "file-poll-interval"
+	FlagNameShutdownTimeout          = "shutdown-timeout"
+	FlagNameRestartDelay             = "restart-delay"
+	FlagNameRestartMaxDelay          = "restart-max-delay"
+	FlagNameExcludeWorkers           = "exclude-workers"
+	FlagNameRemoteWorkerControl      = "remote-worker-control"
+	FlagNameTrustedKeys              = "trusted-keys"
+	FlagNameRequireSignatures        = "require-signatures"
+	FlagNameSpoolKey                 = "spool-key"
+	FlagNameRedactPattern            = "redact-pattern"
+	FlagNameRedactPath               = "redact-path"
+	FlagNameRateLimit                = "rate-limit"
+	FlagNameByteQuota                = "byte-quota"
+	FlagNameDispatchRetries          = "dispatch-retries"
+	FlagNameDispatchRetryDelay       = "dispatch-retry-delay"
+	FlagNameDedupCacheSize           = "dedup-cache-size"
+	FlagNameMessageMaxAge            = "message-max-age"
+	FlagNameDispatchQueueDepth       = "dispatch-queue-depth"
+	FlagNameMaxConcurrency           = "max-concurrency"
+	FlagNameDispatchOverflow         = "dispatch-overflow"
+	FlagNameResponseTimeout          = "response-timeout"
+	FlagNameDirectiveAlias           = "directive-alias"
+	FlagNameMessageHook              = "message-hook"
+	FlagNameOnConnectHook            = "on-connect-hook"
+	FlagNameOnDisconnectHook         = "on-disconnect-hook"
+	FlagNameMessageHistorySize       = "message-history-size"
+	FlagNameConfigDir                = "config-dir"
+	FlagNameHealthListen             = "health-listen"
+	FlagNameDebugListen              = "debug-listen"
+	FlagNameTags                     = "tags"
+	FlagNameStateDir                 = "state-dir"
+	FlagNameRuntimeDir               = "runtime-dir"
+	FlagNamePIDFile                  = "pid-file"
 )
 
 var DefaultConfig = Config{
@@ -40,77 +104,378 @@ var DefaultConfig = Config{
 // Config contains current configuration state for yggdrasil.
 type Config struct {
 	// LogLevel is the level value used for logging.
-	LogLevel string
+	LogLevel string `toml:"log-level"`
+
+	// LogFormat is the format of log output, either "text" or "json".
+	LogFormat string `toml:"log-format"`
 
 	// ClientID is a unique identification value for the client over connection
 	// transports.
-	ClientID string
+	ClientID string `toml:"client-id"`
+
+	// ClientIDSources is the ordered list of sources the client ID is derived
+	// from: "config" (ClientID), "certificate" (the common name or first DNS
+	// name of CertFile), "machine-id" (/etc/machine-id) and "file" (a random
+	// UUID persisted in the state directory). An empty list uses "config",
+	// "certificate" and "file".
+	ClientIDSources []string `toml:"client-id-sources"`
 
 	// Server is a URI to which yggd connects in order to send and receive data.
-	Server []string
+	Server []string `toml:"server"`
 
 	// CertFile is a path to a public certificate, optionally used along with
 	// KeyFile to authenticate connections.
-	CertFile string
+	CertFile string `toml:"cert-file"`
 
 	// KeyFile is a path to a private certificate, optionally used along with
 	// CertFile to authenticate connections.
-	KeyFile string
+	KeyFile string `toml:"key-file"`
 
 	// CARoot is the list of paths with chain certificate file to optionally
 	// include in the TLS configration's CA root list.
-	CARoot []string
+	CARoot []string `toml:"ca-root"`
+
+	// ServerPins is a list of pins the certificate presented by the server
+	// must match, in addition to being signed by a CA root: "sha256//BASE64"
+	// hashes of a public key in the certificate chain, or paths to files of
+	// PEM encoded certificates the server certificate must be one of.
+	ServerPins []string `toml:"server-pin"`
+
+	// TLSMinVersion is the minimum TLS version of connections, "1.2" or
+	// "1.3". If empty, TLS 1.3 is required.
+	TLSMinVersion string `toml:"tls-min-version"`
+
+	// TLSMaxVersion is the maximum TLS version of connections. If empty, the
+	// latest version supported is the maximum.
+	TLSMaxVersion string `toml:"tls-max-version"`
+
+	// TLSCipherSuites is the list of cipher suites, by IANA name, enabled for
+	// TLS 1.2 connections. If empty, a secure default list is used. Setting it
+	// requires TLSMinVersion to be "1.2". The cipher suites of TLS 1.3
+	// connections are not configurable.
+	TLSCipherSuites []string `toml:"tls-cipher-suites"`
 
 	// PathPrefix is a value prepended to all path names at the transport layer.
-	PathPrefix string
+	PathPrefix string `toml:"path-prefix"`
 
 	// Protocol is the protocol used by yggd when connecting to Server. Can be
 	// either MQTT, HTTP or none.
-	Protocol string
+	Protocol string `toml:"protocol"`
 
 	// DataHost is a hostname value to interject into all HTTP requests when
 	// handling data retrieval for "detachedContent" workers.
-	DataHost string
+	DataHost string `toml:"data-host"`
 
 	// FactsFile is a path to a file containing a JSON object consisting of
 	// key/value pairs that can be used for system identification.
-	FactsFile string
+	FactsFile string `toml:"facts-file"`
+
+	// DisableFactsCollectors is a list of names of facts collectors that are
+	// not run when gathering canonical facts. Facts from the facts file are
+	// gathered by the "facts-file" collector. The list is reloaded when the
+	// configuration file changes.
+	DisableFactsCollectors []string `toml:"disable-facts-collectors"`
+
+	// FactsInterval is how often canonical facts are collected to detect
+	// changes, such as a new IP address or host name. When the facts differ
+	// from those last sent to the server, a new connection-status message is
+	// published. A value of 0 disables periodic collection.
+	FactsInterval time.Duration `toml:"facts-interval"`
 
 	// HTTPRetries is the number of times the client will attempt to resend
 	// failed HTTP requests before giving up.
-	HTTPRetries int
+	HTTPRetries int `toml:"http-retries"`
 
 	// HTTPTimeout is the duration the client will wait before cancelling an
 	// HTTP request.
-	HTTPTimeout time.Duration
+	HTTPTimeout time.Duration `toml:"http-timeout"`
+
+	// HTTPHeaders is a list of "NAME: VALUE" headers added to every HTTP
+	// request, sent by the HTTP transport and when fetching and uploading
+	// data for workers. Values may reference secrets.
+	HTTPHeaders []string `toml:"http-header"`
+
+	// HTTPUserAgent is the User-Agent header of every HTTP request. If empty,
+	// "yggd/VERSION" is sent.
+	HTTPUserAgent string `toml:"http-user-agent"`
+
+	// HTTPReceiveMode is how the HTTP transport receives control messages:
+	// "poll" sends a request every polling interval, "sse" holds a request
+	// open and receives messages as Server-Sent Events.
+	HTTPReceiveMode string `toml:"http-receive-mode"`
+
+	// OAuthFlow is the OAuth 2.0 flow access tokens are obtained with,
+	// "client-credentials" or "device". Tokens are sent as bearer tokens by
+	// the HTTP transport and as the password of MQTT connections. If empty,
+	// no tokens are obtained.
+	OAuthFlow string `toml:"oauth-flow"`
+
+	// OAuthTokenURL is the token endpoint of the authorization server.
+	OAuthTokenURL string `toml:"oauth-token-url"`
+
+	// OAuthDeviceURL is the device authorization endpoint of the
+	// authorization server, used by the "device" flow.
+	OAuthDeviceURL string `toml:"oauth-device-authorization-url"`
+
+	// OAuthClientID is the client ID registered with the authorization
+	// server.
+	OAuthClientID string `toml:"oauth-client-id"`
+
+	// OAuthClientSecret is the client secret registered with the
+	// authorization server. It may reference a secret.
+	OAuthClientSecret string `toml:"oauth-client-secret"`
+
+	// OAuthScopes are the scopes requested for access tokens.
+	OAuthScopes []string `toml:"oauth-scope"`
+
+	// KerberosKeytab is the keytab holding the keys HTTP requests are
+	// authenticated with using Kerberos (Negotiate). If empty, requests are
+	// not authenticated with Kerberos.
+	KerberosKeytab string `toml:"kerberos-keytab"`
+
+	// KerberosPrincipal is the principal tickets are obtained for. If empty,
+	// the first principal in KerberosKeytab is used.
+	KerberosPrincipal string `toml:"kerberos-principal"`
+
+	// KerberosConfig is the Kerberos configuration file.
+	KerberosConfig string `toml:"kerberos-config"`
 
 	// MQTTConnectRetry is the MQTT client option to enable connection retry
 	// logic when performing the initial connection.
-	MQTTConnectRetry bool
+	MQTTConnectRetry bool `toml:"mqtt-connect-retry"`
 
 	// MQTTConnectRetryInterval is the MQTT client option that specifies the
 	// duration to wait between connection retry attempts.
-	MQTTConnectRetryInterval time.Duration
+	MQTTConnectRetryInterval time.Duration `toml:"mqtt-connect-retry-interval"`
 
 	// MQTTAutoReconnect is the MQTT client option that enables automatic
 	// reconnection logic when the client unexpectedly disconnects.
-	MQTTAutoReconnect bool
+	MQTTAutoReconnect bool `toml:"mqtt-auto-reconnect"`
 
 	// MQTTReconnectDelay is the duration the client with wait before attempting
 	// to reconnect to the MQTT broker.
-	MQTTReconnectDelay time.Duration
+	MQTTReconnectDelay time.Duration `toml:"mqtt-reconnect-delay"`
 
 	// MQTTConnectTimeout is the duration the client will wait for an MQTT
 	// connection to be established before giving up.
-	MQTTConnectTimeout time.Duration
+	MQTTConnectTimeout time.Duration `toml:"mqtt-connect-timeout"`
 
 	// MQTTPublishTimeout is the duration the client will wait for an MQTT
 	// connection to publish a message before giving up.
-	MQTTPublishTimeout time.Duration
+	MQTTPublishTimeout time.Duration `toml:"mqtt-publish-timeout"`
+
+	// MQTTTopicTemplate is the Go template the MQTT topics are built from,
+	// given the path prefix, client ID, channel ("data" or "control"),
+	// direction ("in" or "out") and, for data messages, directive.
+	MQTTTopicTemplate string `toml:"mqtt-topic-template"`
+
+	// MQTTUsername is the username yggd authenticates to the MQTT broker
+	// with.
+	MQTTUsername string `toml:"mqtt-username"`
+
+	// MQTTPassword is the password yggd authenticates to the MQTT broker
+	// with. It should reference a secret rather than hold the password.
+	MQTTPassword string `toml:"mqtt-password"`
+
+	// MQTTPasswordFile is a file only its owner can read holding the password
+	// yggd authenticates to the MQTT broker with. It is read on every
+	// connection attempt.
+	MQTTPasswordFile string `toml:"mqtt-password-file"`
 
 	// MessageJournal is used to enable the storage of worker events
 	// and message data in a SQLite file at the specified file path.
-	MessageJournal string
+	MessageJournal string `toml:"message-journal"`
+
+	// HealthCheckInterval is the duration the dispatcher waits between health
+	// checks of each running worker. A worker fails a check if its bus
+	// connection does not answer a ping, or if it has neither finished nor
+	// reported working on a message within its response timeout. A zero
+	// value, the default, disables health checks.
+	HealthCheckInterval time.Duration `toml:"health-check-interval"`
+
+	// HealthCheckFailures is the number of consecutive health-check pings a
+	// worker may fail before it is considered unresponsive and restarted.
+	HealthCheckFailures int `toml:"health-check-failures"`
+
+	// WorkerStartConcurrency is the number of workers activated at the same
+	// time when yggd starts. If zero, workers are not started until a message
+	// is dispatched to them.
+	WorkerStartConcurrency int `toml:"worker-start-concurrency"`
+
+	// OnDemandWorkers is a list of "WORKER" or "WORKER=DURATION" entries
+	// naming workers that are not started until their first message arrives
+	// and are stopped once they have been idle for DURATION, or for
+	// WorkerIdleTimeout if no duration is given. The list is reloaded when the
+	// configuration file changes.
+	OnDemandWorkers []string `toml:"on-demand-workers"`
+
+	// WorkerIdleTimeout is the default duration an on-demand worker may stay
+	// idle before it is stopped.
+	WorkerIdleTimeout time.Duration `toml:"worker-idle-timeout"`
+
+	// FilePollInterval is the interval at which watched files, such as the
+	// configuration and certificate files, are checked for changes when they
+	// are on a file system that does not support inotify.
+	FilePollInterval time.Duration `toml:"file-poll-interval"`
+
+	// ShutdownTimeout is the duration yggd waits when stopping for messages
+	// already received to be handled by workers before it disconnects and
+	// stops the workers. Messages not yet dispatched are redelivered when yggd
+	// starts again.
+	ShutdownTimeout time.Duration `toml:"shutdown-timeout"`
+
+	// RestartDelay is the initial duration the dispatcher waits between
+	// successive restarts of a worker that fails health checks. The delay
+	// doubles with each restart and is randomly jittered. It does not affect
+	// workers that exit on their own; systemd restarts those.
+	RestartDelay time.Duration `toml:"restart-delay"`
+
+	// RestartMaxDelay is the upper bound on the delay between successive
+	// restarts of a worker that fails health checks. Zero means no limit.
+	RestartMaxDelay time.Duration `toml:"restart-max-delay"`
+
+	// ExcludeWorkers is a list of worker names the dispatcher ignores. An
+	// excluded worker is not sent any messages and is not reported to the
+	// server. The list is reloaded when the configuration file changes.
+	ExcludeWorkers []string `toml:"exclude-workers"`
+
+	// RemoteWorkerControl is a list of worker names the server may start, stop
+	// or restart with a "worker" command. An empty list disables the command.
+	RemoteWorkerControl []string `toml:"remote-worker-control"`
+
+	// TrustedKeys is a list of paths to PEM-encoded public keys. A message
+	// received from the server that carries a signature is rejected unless
+	// the signature was made by one of these keys.
+	TrustedKeys []string `toml:"trusted-keys"`
+
+	// RequireSignatures rejects messages received from the server that do not
+	// carry a signature made by one of TrustedKeys.
+	RequireSignatures bool `toml:"require-signatures"`
+
+	// SpoolKey is the base64-encoded 256-bit key messages kept on disk are
+	// encrypted with, or "keyring:NAME" to read it from the kernel keyring.
+	// Messages are stored unencrypted if it is empty.
+	SpoolKey string `toml:"spool-key"`
+
+	// RedactPatterns is a list of regular expressions whose matches are
+	// masked in message payloads and metadata written to the log.
+	RedactPatterns []string `toml:"redact-pattern"`
+
+	// RedactPaths is a list of dot-separated paths, such as
+	// "content.credentials.password" or "metadata.Authorization", whose values
+	// are masked in message payloads and metadata written to the log.
+	RedactPaths []string `toml:"redact-path"`
+
+	// RateLimits is a list of "DIRECTIVE=N" entries capping the number of
+	// messages dispatched to a worker per minute. Messages over the limit are
+	// deferred until it allows them.
+	RateLimits []string `toml:"rate-limit"`
+
+	// ByteQuotas is a list of "DIRECTIVE=SIZE" entries capping the number of
+	// bytes a worker may transmit per hour. A size may end in K, M or G.
+	ByteQuotas []string `toml:"byte-quota"`
+
+	// DispatchRetries is the number of times the dispatcher retries delivering
+	// a message from the server to a worker that did not accept it before
+	// reporting the failure to the server. It also caps the number of times a
+	// message is dispatched again after its worker exited without emitting
+	// the END event for it.
+	DispatchRetries int `toml:"dispatch-retries"`
+
+	// DispatchRetryDelay is the initial duration the dispatcher waits before
+	// retrying delivery of a message. The delay doubles with each attempt and
+	// is randomly jittered.
+	DispatchRetryDelay time.Duration `toml:"dispatch-retry-delay"`
+
+	// DedupCacheSize is the number of recently received message IDs
+	// remembered to detect and drop duplicate messages. A value of 0 disables
+	// duplicate detection.
+	DedupCacheSize int `toml:"dedup-cache-size"`
+
+	// MessageMaxAge is the age after which a message received from the server
+	// is discarded rather than processed. A value of 0 disables the check;
+	// messages carrying an "Expires" metadata value are discarded once it has
+	// passed regardless.
+	MessageMaxAge time.Duration `toml:"message-max-age"`
+
+	// MaxConcurrency is a list of "DIRECTIVE=N" entries capping the number of
+	// messages a worker handles at once. An entry takes precedence over the
+	// worker's own "max_concurrency" feature. The list is reloaded when the
+	// configuration file changes.
+	MaxConcurrency []string `toml:"max-concurrency"`
+
+	// DispatchQueueDepth is the number of messages queued for a worker that is
+	// handling as many messages as its concurrency limit allows.
+	DispatchQueueDepth int `toml:"dispatch-queue-depth"`
+
+	// DispatchOverflow is the policy applied when a message arrives for a
+	// worker whose queue is full: "reject", "drop-oldest" or "block". Under
+	// "block", the message is held back until the worker's queue has room;
+	// messages for other workers are not delayed until 100 messages are held
+	// back for the worker, when receiving stops until its queue has room.
+	DispatchOverflow string `toml:"dispatch-overflow"`
+
+	// ResponseTimeout is the duration after which a message dispatched to a
+	// worker that has not responded is reported to the server as timed out,
+	// unless the worker sets its own "response_timeout" feature. A value of 0
+	// disables the timeout.
+	ResponseTimeout time.Duration `toml:"response-timeout"`
+
+	// DirectiveAliases is a list of "DIRECTIVE=WORKER" entries routing
+	// messages for a directive to a differently named worker. The list is
+	// reloaded when the configuration file changes.
+	DirectiveAliases []string `toml:"directive-alias"`
+
+	// MessageHook is the path to a program run for every message received
+	// from the server and every message sent by a worker. The program may
+	// modify or reject the message.
+	MessageHook string `toml:"message-hook"`
+
+	// OnConnectHook is the path to a program run each time the transport
+	// connects to the server.
+	OnConnectHook string `toml:"on-connect-hook"`
+
+	// OnDisconnectHook is the path to a program run each time the transport
+	// disconnects from the server.
+	OnDisconnectHook string `toml:"on-disconnect-hook"`
+
+	// MessageHistorySize is the number of message outcomes kept in the
+	// message history. A value of 0 disables the history.
+	MessageHistorySize int `toml:"message-history-size"`
+
+	// HealthListen is the address on which yggd serves its /healthz and
+	// /readyz endpoints: a loopback "HOST:PORT" or "unix:PATH". An empty value
+	// disables the endpoints.
+	HealthListen string `toml:"health-listen"`
+
+	// DebugListen is the address on which yggd serves pprof profiles and a
+	// dump of its internal state: a loopback "HOST:PORT" or "unix:PATH". An
+	// empty value disables the listener.
+	DebugListen string `toml:"debug-listen"`
+
+	// Tags are key/value pairs describing the host, set as a table in the
+	// configuration file. They are merged with the tags read from the tags
+	// file, which take precedence, included in connection-status messages and
+	// exposed to workers. Tags have no command-line flag.
+	Tags map[string]string `toml:"tags"`
+
+	// ConfigDir is the directory holding configuration data, such as tags and
+	// message schemas, overriding the compile-time default.
+	ConfigDir string `toml:"config-dir"`
+
+	// StateDir is the directory holding local state, such as the client ID,
+	// crash reports and the message history, overriding the compile-time
+	// default.
+	StateDir string `toml:"state-dir"`
+
+	// RuntimeDir is the directory holding runtime data, such as the
+	// environment file shared with workers, overriding the compile-time
+	// default.
+	RuntimeDir string `toml:"runtime-dir"`
+
+	// PIDFile is the file the process ID of yggd is written to while it runs,
+	// for process supervisors other than systemd.
+	PIDFile string `toml:"pid-file"`
 }
 
 // CreateTLSConfig creates a tls.Config object from the current configuration.
@@ -139,7 +504,12 @@ func (conf *Config) CreateTLSConfig() (*tls.Config, error) {
 		rootCAs = append(rootCAs, data)
 	}
 
-	tlsConfig, err := newTLSConfig(certData, keyData, rootCAs)
+	settings, err := conf.parseTLSSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := newTLSConfig(certData, keyData, rootCAs, settings)
 	if err != nil {
 		return nil, err
 	}
@@ -147,15 +517,38 @@ func (conf *Config) CreateTLSConfig() (*tls.Config, error) {
 	return tlsConfig, nil
 }
 
-// WatcherUpdate creates an Inotify watcher on all TLS related information
-// (Cert-file, key-file and CA-root) if any of those files are updated, it'll
-// send over the returned channel a new TLS.Config that consumers can use to
-// renew their connections.
+// OAuthConfig returns the OAuth 2.0 configuration, persisting refresh tokens
+// to refreshTokenFile.
+func (conf *Config) OAuthConfig(refreshTokenFile string) oauth.Config {
+	return oauth.Config{
+		Flow:                   conf.OAuthFlow,
+		TokenURL:               conf.OAuthTokenURL,
+		DeviceAuthorizationURL: conf.OAuthDeviceURL,
+		ClientID:               conf.OAuthClientID,
+		ClientSecret:           conf.OAuthClientSecret,
+		Scopes:                 conf.OAuthScopes,
+		RefreshTokenFile:       refreshTokenFile,
+	}
+}
+
+// KerberosAuthConfig returns the configuration of Kerberos authentication.
+func (conf *Config) KerberosAuthConfig() kerberos.Config {
+	return kerberos.Config{
+		Keytab:    conf.KerberosKeytab,
+		Principal: conf.KerberosPrincipal,
+		Krb5Conf:  conf.KerberosConfig,
+	}
+}
+
+// WatcherUpdate creates a watcher on all TLS related information (Cert-file,
+// key-file and CA-root) if any of those files are updated, it'll send over the
+// returned channel a new TLS.Config that consumers can use to renew their
+// connections.
 // The main use case if when on short-lived certificates, where a connection
 // need to be reloaded to create a new TLSHandshake
-// It will return an error if cannot set the inotify on any file
+// Files on file systems without inotify support are polled every
+// FilePollInterval.
 func (conf *Config) WatcherUpdate() (chan *tls.Config, error) {
-	c := make(chan notify.EventInfo, 1)
 	files := []string{}
 
 	if len(conf.CARoot) > 0 {
@@ -174,31 +567,17 @@ func (conf *Config) WatcherUpdate() (chan *tls.Config, error) {
 		return nil, nil
 	}
 
-	for _, fp := range files {
-		if err := notify.Watch(fp, c, notify.InCloseWrite, notify.InDelete); err != nil {
-			return nil, fmt.Errorf("cannot start watching file '%v': %w", fp, err)
-		}
-		log.Debugf("added watchpoint for file: %v", fp)
-	}
+	w := watch.New(files, conf.FilePollInterval)
 
 	events := make(chan *tls.Config, 1)
 	go func() {
-		for e := range c {
-			log.Debugf("received inotify event %v", e.Event())
-			switch e.Event() {
-			case notify.InCloseWrite, notify.InDelete:
-				cfg, err := conf.CreateTLSConfig()
-				if err != nil {
-					log.Errorf(
-						"cannot create TLS config from file '%v' on event %v: %v",
-						e.Path(),
-						e.Event(),
-						err,
-					)
-				}
-				if cfg != nil {
-					events <- cfg
-				}
+		for path := range w.C {
+			cfg, err := conf.CreateTLSConfig()
+			if err != nil {
+				log.Errorf("cannot create TLS config after '%v' changed: %v", path, err)
+			}
+			if cfg != nil {
+				events <- cfg
 			}
 		}
 	}()
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pelletier/go-toml"
+)
+
+// DropInDir returns the directory holding configuration fragments for the
+// configuration file at path.
+func DropInDir(path string) string {
+	return path + ".d"
+}
+
+// Files returns path followed by the "*.toml" fragments in its drop-in
+// directory, in lexical order. A missing drop-in directory is not an error.
+func Files(path string) ([]string, error) {
+	entries, err := os.ReadDir(DropInDir(path))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("cannot read drop-in directory: %w", err)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".toml" {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	files := []string{path}
+	for _, name := range names {
+		files = append(files, filepath.Join(DropInDir(path), name))
+	}
+	return files, nil
+}
+
+// LoadTree reads the configuration file at path and merges the fragments in
+// its drop-in directory over it, in lexical order. A key set in a fragment
+// replaces the value set by the configuration file or an earlier fragment.
+func LoadTree(path string) (*toml.Tree, error) {
+	files, err := Files(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree *toml.Tree
+	for _, file := range files {
+		t, err := toml.LoadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse '%v': %w", file, err)
+		}
+		if tree == nil {
+			tree = t
+			continue
+		}
+		for _, key := range t.Keys() {
+			tree.SetPath([]string{key}, t.GetPath([]string{key}))
+		}
+	}
+	return tree, nil
+}
+
+// LoadFile parses the configuration file at path merged with the fragments in
+// its drop-in directory. Keys that do not name a configuration option are
+// rejected.
+func LoadFile(path string) (*Config, error) {
+	tree, err := LoadTree(path)
+	if err != nil {
+		return nil, err
+	}
+	return loadTree(tree)
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		fragments   map[string]string
+		want        *Config
+		wantError   bool
+	}{
+		{
+			description: "no drop-in directory",
+			input:       "protocol = \"mqtt\"\nserver = [\"tcp://a:1883\"]\n",
+			want: &Config{
+				Protocol: "mqtt",
+				Server:   []string{"tcp://a:1883"},
+			},
+		},
+		{
+			description: "fragments in lexical order",
+			input:       "protocol = \"mqtt\"\nserver = [\"tcp://a:1883\"]\nlog-level = \"info\"\n",
+			fragments: map[string]string{
+				"20-debug.toml":  "log-level = \"debug\"\n",
+				"10-broker.toml": "server = [\"tcp://b:1883\"]\nlog-level = \"warn\"\n",
+				"30-ignored.txt": "log-level = \"trace\"\n",
+			},
+			want: &Config{
+				Protocol: "mqtt",
+				Server:   []string{"tcp://b:1883"},
+				LogLevel: "debug",
+			},
+		},
+		{
+			description: "unknown key in fragment",
+			input:       "protocol = \"mqtt\"\n",
+			fragments: map[string]string{
+				"10-proxy.toml": "proxy = \"http://proxy:3128\"\n",
+			},
+			wantError: true,
+		},
+		{
+			description: "syntax error in fragment",
+			input:       "protocol = \"mqtt\"\n",
+			fragments: map[string]string{
+				"10-broker.toml": "server = tcp://b:1883\n",
+			},
+			wantError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(path, []byte(test.input), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if len(test.fragments) > 0 {
+				if err := os.Mkdir(DropInDir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for name, data := range test.fragments {
+				if err := os.WriteFile(filepath.Join(DropInDir(path), name), []byte(data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := LoadFile(path)
+
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !cmp.Equal(got, test.want) {
+					t.Errorf("%v", cmp.Diff(got, test.want))
+				}
+			}
+		})
+	}
+}
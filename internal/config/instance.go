@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/redhatinsights/yggdrasil/internal/constants"
+)
+
+var instanceNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// ValidateInstance returns an error if name is not a valid instance name.
+// Instance names become part of directory names and of the abstract socket
+// name of the instance bus.
+func ValidateInstance(name string) error {
+	if !instanceNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid instance name '%v'", name)
+	}
+	return nil
+}
+
+// InstanceDir returns dir namespaced for the named instance, matching the
+// directories systemd creates for the yggdrasil@NAME.service unit.
+func InstanceDir(dir, instance string) string {
+	return dir + "-" + instance
+}
+
+// InstanceBusAddress returns the address of the private bus of the named
+// instance, listened on by the yggdrasil-bus@NAME.socket unit.
+func InstanceBusAddress(instance string) string {
+	return "unix:abstract=yggd_" + instance
+}
+
+// ApplyInstance namespaces the configuration, state, cache, runtime and log
+// directories in constants for the named instance. Unless a session bus is
+// already set in the environment, the private bus of the instance is used.
+func ApplyInstance(instance string) error {
+	if err := ValidateInstance(instance); err != nil {
+		return err
+	}
+
+	constants.ConfigDir = InstanceDir(constants.ConfigDir, instance)
+	constants.StateDir = InstanceDir(constants.StateDir, instance)
+	constants.CacheDir = InstanceDir(constants.CacheDir, instance)
+	constants.RuntimeDir = InstanceDir(constants.RuntimeDir, instance)
+	constants.LogDir = InstanceDir(constants.LogDir, instance)
+
+	if _, ok := os.LookupEnv("DBUS_SESSION_BUS_ADDRESS"); !ok {
+		if err := os.Setenv("DBUS_SESSION_BUS_ADDRESS", InstanceBusAddress(instance)); err != nil {
+			return fmt.Errorf("cannot set bus address: %w", err)
+		}
+	}
+	return nil
+}
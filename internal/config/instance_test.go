@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/redhatinsights/yggdrasil/internal/constants"
+)
+
+func TestValidateInstance(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		wantError   bool
+	}{
+		{description: "valid", input: "staging"},
+		{description: "digits and separators", input: "prod-2_eu"},
+		{description: "empty", input: "", wantError: true},
+		{description: "path", input: "../etc", wantError: true},
+		{description: "leading dash", input: "-staging", wantError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			err := ValidateInstance(test.input)
+			if (err != nil) != test.wantError {
+				t.Errorf("got error %v, want error %v", err, test.wantError)
+			}
+		})
+	}
+}
+
+func TestApplyInstance(t *testing.T) {
+	configDir, stateDir := constants.ConfigDir, constants.StateDir
+	cacheDir, runtimeDir, logDir := constants.CacheDir, constants.RuntimeDir, constants.LogDir
+	t.Cleanup(func() {
+		constants.ConfigDir, constants.StateDir = configDir, stateDir
+		constants.CacheDir, constants.RuntimeDir, constants.LogDir = cacheDir, runtimeDir, logDir
+	})
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "")
+	os.Unsetenv("DBUS_SESSION_BUS_ADDRESS")
+
+	if err := ApplyInstance("staging"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := constants.StateDir, stateDir+"-staging"; got != want {
+		t.Errorf("%v != %v", got, want)
+	}
+	if got, want := constants.ConfigDir, configDir+"-staging"; got != want {
+		t.Errorf("%v != %v", got, want)
+	}
+	if got, want := os.Getenv("DBUS_SESSION_BUS_ADDRESS"), "unix:abstract=yggd_staging"; got != want {
+		t.Errorf("%v != %v", got, want)
+	}
+}
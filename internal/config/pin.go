@@ -0,0 +1,101 @@
+package config
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// spkiPinPrefix is the prefix of a pin given as the SHA-256 hash of a
+// certificate's Subject Public Key Info, in the format used by curl and
+// HTTP Public Key Pinning.
+const spkiPinPrefix = "sha256//"
+
+// serverPins holds the hashes a certificate presented by the server is
+// matched against.
+type serverPins struct {
+	// publicKeys are SHA-256 hashes of pinned Subject Public Key Infos.
+	publicKeys map[[sha256.Size]byte]bool
+
+	// certificates are SHA-256 hashes of pinned DER encoded certificates.
+	certificates map[[sha256.Size]byte]bool
+}
+
+// parseServerPins parses a list of pins. An entry starting with "sha256//"
+// is the base64 encoded SHA-256 hash of a public key. Any other entry is the
+// path to a file of PEM encoded certificates, each of which is pinned.
+func parseServerPins(entries []string) (*serverPins, error) {
+	pins := serverPins{
+		publicKeys:   make(map[[sha256.Size]byte]bool),
+		certificates: make(map[[sha256.Size]byte]bool),
+	}
+	for _, entry := range entries {
+		if encoded, ok := strings.CutPrefix(entry, spkiPinPrefix); ok {
+			hash, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil || len(hash) != sha256.Size {
+				return nil, fmt.Errorf("invalid public key pin '%v': expected %vBASE64 of a SHA-256 hash", entry, spkiPinPrefix)
+			}
+			pins.publicKeys[[sha256.Size]byte(hash)] = true
+			continue
+		}
+
+		data, err := os.ReadFile(entry)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read pinned certificate: %w", err)
+		}
+		var found bool
+		for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+				return nil, fmt.Errorf("cannot parse pinned certificate in '%v': %w", entry, err)
+			}
+			pins.certificates[sha256.Sum256(block.Bytes)] = true
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("'%v' does not contain a PEM encoded certificate", entry)
+		}
+	}
+	return &pins, nil
+}
+
+// verify returns an error unless the leaf certificate of state is a pinned
+// certificate or a certificate of one of its verified chains has a pinned
+// public key.
+func (pins *serverPins) verify(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) > 0 && pins.certificates[sha256.Sum256(state.PeerCertificates[0].Raw)] {
+		return nil
+	}
+	for _, chain := range state.VerifiedChains {
+		for _, cert := range chain {
+			if pins.publicKeys[sha256.Sum256(cert.RawSubjectPublicKeyInfo)] {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("certificate presented by '%v' does not match a pin in %v", state.ServerName, FlagNameServerPin)
+}
+
+// ServerTLSConfig returns a copy of tlsConfig that, in addition to verifying
+// the server certificate against the CA roots, rejects servers whose
+// certificate does not match one of the pins in ServerPins. If no pins are
+// configured, tlsConfig is returned unchanged.
+func (conf *Config) ServerTLSConfig(tlsConfig *tls.Config) (*tls.Config, error) {
+	if len(conf.ServerPins) == 0 || tlsConfig == nil {
+		return tlsConfig, nil
+	}
+	pins, err := parseServerPins(conf.ServerPins)
+	if err != nil {
+		return nil, err
+	}
+	pinned := tlsConfig.Clone()
+	pinned.VerifyConnection = pins.verify
+	return pinned, nil
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServerTLSConfig(t *testing.T) {
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+	cert := srv.Certificate()
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "server.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(emptyFile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	spki := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	other := sha256.Sum256([]byte("other"))
+
+	tests := []struct {
+		description        string
+		input              []string
+		wantError          bool
+		wantHandshakeError bool
+	}{
+		{
+			description: "no pins",
+			input:       nil,
+		},
+		{
+			description: "public key",
+			input:       []string{"sha256//" + base64.StdEncoding.EncodeToString(other[:]), "sha256//" + base64.StdEncoding.EncodeToString(spki[:])},
+		},
+		{
+			description: "certificate file",
+			input:       []string{certFile},
+		},
+		{
+			description:        "mismatch",
+			input:              []string{"sha256//" + base64.StdEncoding.EncodeToString(other[:])},
+			wantHandshakeError: true,
+		},
+		{
+			description: "invalid hash",
+			input:       []string{"sha256//AAAA"},
+			wantError:   true,
+		},
+		{
+			description: "missing file",
+			input:       []string{filepath.Join(dir, "missing.pem")},
+			wantError:   true,
+		},
+		{
+			description: "file without certificate",
+			input:       []string{emptyFile},
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			roots := x509.NewCertPool()
+			roots.AddCert(cert)
+			conf := Config{ServerPins: test.input}
+			got, err := conf.ServerTLSConfig(&tls.Config{RootCAs: roots})
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			conn, err := tls.Dial("tcp", strings.TrimPrefix(srv.URL, "https://"), got)
+			if test.wantHandshakeError {
+				if err == nil {
+					conn.Close()
+					t.Errorf("expected handshake error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn.Close()
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"github.com/redhatinsights/yggdrasil/internal/constants"
+)
+
+// SecretPrefix marks a configuration value as a reference to a secret. The
+// value "secret:NAME" is replaced by the contents of the credential
+// "yggdrasil.NAME".
+const SecretPrefix = "secret:"
+
+var secretNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// CredentialName returns the name of the systemd credential holding the
+// secret name, or an error if name is not a valid secret name.
+func CredentialName(name string) (string, error) {
+	if !secretNameRegexp.MatchString(name) {
+		return "", fmt.Errorf("invalid secret name '%v'", name)
+	}
+	return "yggdrasil." + name, nil
+}
+
+// ResolveSecret returns value unchanged unless it begins with SecretPrefix, in
+// which case the referenced secret is read and returned. When yggd runs as a
+// systemd service, secrets are read from $CREDENTIALS_DIRECTORY, where systemd
+// places credentials, decrypting them if needed. Otherwise they are read from
+// constants.CredstoreDir.
+func ResolveSecret(value string) (string, error) {
+	name, ok := strings.CutPrefix(value, SecretPrefix)
+	if !ok {
+		return value, nil
+	}
+	credential, err := CredentialName(name)
+	if err != nil {
+		return "", err
+	}
+
+	dir, ok := os.LookupEnv("CREDENTIALS_DIRECTORY")
+	if !ok {
+		dir = constants.CredstoreDir
+	}
+	data, err := os.ReadFile(filepath.Join(dir, credential))
+	if err != nil {
+		return "", fmt.Errorf("cannot read secret '%v': %w", name, err)
+	}
+	return strings.TrimRight(string(data), "\n"), nil
+}
+
+// ReadSecretFile returns the contents of file, which must be a regular file
+// owned by the current user and not accessible by other users, without a
+// trailing newline.
+func ReadSecretFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", fmt.Errorf("cannot open secret file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("cannot stat secret file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("secret file '%v' is not a regular file", file)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("secret file '%v' is accessible by other users: mode %v", file, info.Mode().Perm())
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Geteuid() {
+		return "", fmt.Errorf("secret file '%v' is owned by another user", file)
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", fmt.Errorf("cannot read secret file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\n"), nil
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "yggdrasil.mqtt-password"), []byte("hunter2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+
+	tests := []struct {
+		description string
+		input       string
+		want        string
+		wantError   bool
+	}{
+		{
+			description: "plain value",
+			input:       "tcp://localhost:1883",
+			want:        "tcp://localhost:1883",
+		},
+		{
+			description: "secret",
+			input:       "secret:mqtt-password",
+			want:        "hunter2",
+		},
+		{
+			description: "missing secret",
+			input:       "secret:proxy-password",
+			wantError:   true,
+		},
+		{
+			description: "invalid name",
+			input:       "secret:../passwd",
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got, err := ResolveSecret(test.input)
+
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != test.want {
+					t.Errorf("%v != %v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadSecretFile(t *testing.T) {
+	dir := t.TempDir()
+	for name, mode := range map[string]os.FileMode{"private": 0600, "group-readable": 0640} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("hunter2\n"), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(filepath.Join(dir, name), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		description string
+		input       string
+		want        string
+		wantError   bool
+	}{
+		{
+			description: "private",
+			input:       filepath.Join(dir, "private"),
+			want:        "hunter2",
+		},
+		{
+			description: "group readable",
+			input:       filepath.Join(dir, "group-readable"),
+			wantError:   true,
+		},
+		{
+			description: "directory",
+			input:       dir,
+			wantError:   true,
+		},
+		{
+			description: "missing",
+			input:       filepath.Join(dir, "missing"),
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got, err := ReadSecretFile(test.input)
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
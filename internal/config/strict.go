@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml"
+)
+
+// CheckFiles strictly checks the configuration file at path and the fragments
+// in its drop-in directory. Each problem is prefixed with the file, line and
+// column where the offending value is set. Unknown keys and values of the
+// wrong type are reported for every file; the merged configuration is then
+// checked with Validate, and each problem is located at the file that last set
+// the option, or at path if the problem is not about a single option. An error
+// is returned if a file cannot be read or parsed.
+func CheckFiles(path string) ([]string, error) {
+	files, err := Files(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]reflect.Type)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		fields[t.Field(i).Tag.Get("toml")] = t.Field(i).Type
+	}
+
+	problems := []string{}
+	locations := make(map[string]string)
+	for _, file := range files {
+		tree, err := toml.LoadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse '%v': %w", file, err)
+		}
+		keys := tree.Keys()
+		sort.Slice(keys, func(i, j int) bool {
+			a, b := tree.GetPosition(keys[i]), tree.GetPosition(keys[j])
+			return a.Line < b.Line || (a.Line == b.Line && a.Col < b.Col)
+		})
+		for _, key := range keys {
+			pos := tree.GetPosition(key)
+			location := fmt.Sprintf("%v:%v:%v", file, pos.Line, pos.Col)
+			locations[key] = location
+
+			typ, ok := fields[key]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%v: unknown configuration option '%v'", location, key))
+				continue
+			}
+			if err := checkType(typ, tree.Get(key)); err != nil {
+				problems = append(problems, fmt.Sprintf("%v: %v: %v", location, key, err))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return problems, nil
+	}
+
+	conf, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, problem := range conf.Validate() {
+		key, _, _ := strings.Cut(problem, ":")
+		location, ok := locations[key]
+		if !ok {
+			location = path
+		}
+		problems = append(problems, location+": "+problem)
+	}
+	return problems, nil
+}
+
+// checkType returns an error if the TOML value v cannot be decoded into a
+// value of type typ.
+func checkType(typ reflect.Type, v interface{}) error {
+	if typ == reflect.TypeOf(time.Duration(0)) {
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("must be a duration string, got %v", tomlType(v))
+		}
+		if _, err := time.ParseDuration(s); err != nil {
+			return fmt.Errorf("invalid duration '%v'", s)
+		}
+		return nil
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("must be a string, got %v", tomlType(v))
+		}
+	case reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("must be a boolean, got %v", tomlType(v))
+		}
+	case reflect.Int:
+		if _, ok := v.(int64); !ok {
+			return fmt.Errorf("must be an integer, got %v", tomlType(v))
+		}
+	case reflect.Map:
+		tree, ok := v.(*toml.Tree)
+		if !ok {
+			return fmt.Errorf("must be a table of strings, got %v", tomlType(v))
+		}
+		for _, key := range tree.Keys() {
+			if e := tree.Get(key); tomlType(e) != "string" {
+				return fmt.Errorf("value of '%v' must be a string, got %v", key, tomlType(e))
+			}
+		}
+	case reflect.Slice:
+		a, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("must be an array of strings, got %v", tomlType(v))
+		}
+		for i, e := range a {
+			if _, ok := e.(string); !ok {
+				return fmt.Errorf("element %v must be a string, got %v", i, tomlType(e))
+			}
+		}
+	}
+	return nil
+}
+
+// tomlType returns the TOML name of the type of the decoded value v.
+func tomlType(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int64:
+		return "integer"
+	case float64:
+		return "float"
+	case []interface{}:
+		return "array"
+	case *toml.Tree, []*toml.Tree:
+		return "table"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
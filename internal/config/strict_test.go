@@ -0,0 +1,103 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheckFiles(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		fragments   map[string]string
+		want        []string
+		wantError   bool
+	}{
+		{
+			description: "valid",
+			input:       "protocol = \"mqtt\"\nserver = [\"tcp://localhost:1883\"]\nhttp-retries = 3\nhttp-timeout = \"30s\"\n",
+			want:        []string{},
+		},
+		{
+			description: "unknown key and type mismatches",
+			input:       "protocol = \"mqtt\"\nservers = [\"tcp://localhost:1883\"]\nhttp-retries = \"3\"\nhttp-timeout = 30\nexclude-workers = [1]\n",
+			want: []string{
+				"config.toml:2:1: unknown configuration option 'servers'",
+				"config.toml:3:1: http-retries: must be an integer, got string",
+				"config.toml:4:1: http-timeout: must be a duration string, got integer",
+				"config.toml:5:1: exclude-workers: element 0 must be a string, got integer",
+			},
+		},
+		{
+			description: "tags",
+			input:       "protocol = \"mqtt\"\n\n[tags]\nenvironment = \"prod\"\ncost-center = 1234\n",
+			want: []string{
+				"config.toml:3:1: tags: value of 'cost-center' must be a string, got integer",
+			},
+		},
+		{
+			description: "invalid value in fragment",
+			input:       "protocol = \"mqtt\"\nserver = [\"tcp://localhost:1883\"]\nlog-level = \"info\"\n",
+			fragments: map[string]string{
+				"10-debug.toml": "\nlog-level = \"loud\"\n",
+			},
+			want: []string{
+				"config.toml.d/10-debug.toml:2:1: log-level: invalid level value: loud",
+			},
+		},
+		{
+			description: "problem not about a single option",
+			input:       "cert-file = \"/nonexistent/cert.pem\"\n",
+			want: []string{
+				"config.toml: cert-file and key-file must be set together",
+			},
+		},
+		{
+			description: "syntax error",
+			input:       "protocol = mqtt\n",
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "config.toml")
+			if err := os.WriteFile(path, []byte(test.input), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if len(test.fragments) > 0 {
+				if err := os.Mkdir(DropInDir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for name, data := range test.fragments {
+				if err := os.WriteFile(filepath.Join(DropInDir(path), name), []byte(data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := CheckFiles(path)
+
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i := range got {
+					got[i] = strings.TrimPrefix(got[i], dir+string(filepath.Separator))
+				}
+				if !cmp.Equal(got, test.want) {
+					t.Errorf("%v", cmp.Diff(got, test.want))
+				}
+			}
+		})
+	}
+}
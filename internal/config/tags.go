@@ -0,0 +1,28 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/pelletier/go-toml"
+)
+
+// TreeTags returns the tags set in the "tags" table of a parsed configuration,
+// or nil if the table is not set.
+func TreeTags(tree *toml.Tree) (map[string]string, error) {
+	if tree == nil || !tree.Has(FlagNameTags) {
+		return nil, nil
+	}
+	table, ok := tree.Get(FlagNameTags).(*toml.Tree)
+	if !ok {
+		return nil, fmt.Errorf("%v: must be a table", FlagNameTags)
+	}
+	tags := make(map[string]string)
+	for _, key := range table.Keys() {
+		v, ok := table.Get(key).(string)
+		if !ok {
+			return nil, fmt.Errorf("%v: value of '%v' must be a string", FlagNameTags, key)
+		}
+		tags[key] = v
+	}
+	return tags, nil
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pelletier/go-toml"
+)
+
+func TestTreeTags(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		want        map[string]string
+		wantError   bool
+	}{
+		{
+			description: "unset",
+			input:       "protocol = \"mqtt\"\n",
+		},
+		{
+			description: "table",
+			input:       "[tags]\nenvironment = \"prod\"\ncost-center = \"1234\"\n",
+			want:        map[string]string{"environment": "prod", "cost-center": "1234"},
+		},
+		{
+			description: "not a table",
+			input:       "tags = \"prod\"\n",
+			wantError:   true,
+		},
+		{
+			description: "not a string",
+			input:       "[tags]\ncost-center = 1234\n",
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			tree, err := toml.Load(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := TreeTags(tree)
+
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !cmp.Equal(got, test.want) {
+					t.Errorf("%v", cmp.Diff(got, test.want))
+				}
+			}
+		})
+	}
+}
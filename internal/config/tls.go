@@ -4,15 +4,100 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"slices"
+	"strings"
 )
 
+// tlsVersions maps the TLS versions that can be configured to their values.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseTLSVersion returns the TLS version named by s, such as "1.2". An empty
+// s returns 0, leaving the version to the crypto/tls default.
+func parseTLSVersion(s string) (uint16, error) {
+	if s == "" {
+		return 0, nil
+	}
+	version, ok := tlsVersions[s]
+	if !ok {
+		return 0, fmt.Errorf("unsupported TLS version '%v': must be one of '1.2' or '1.3'", s)
+	}
+	return version, nil
+}
+
+// parseCipherSuites returns the IDs of the TLS 1.2 cipher suites named, using
+// the IANA names listed by crypto/tls. Suites with known security issues are
+// rejected, as are TLS 1.3 suites, which crypto/tls does not allow to be
+// configured.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := make(map[string]*tls.CipherSuite)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		suite, ok := known[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported cipher suite '%v'", name)
+		}
+		if !slices.Contains(suite.SupportedVersions, tls.VersionTLS12) {
+			return nil, fmt.Errorf("cipher suite '%v' is a TLS 1.3 suite, which cannot be configured", name)
+		}
+		ids = append(ids, suite.ID)
+	}
+	return ids, nil
+}
+
+// tlsSettings holds the parsed TLS version range and cipher suites.
+type tlsSettings struct {
+	minVersion   uint16
+	maxVersion   uint16
+	cipherSuites []uint16
+}
+
+// parseTLSSettings parses the TLS version range and cipher suites of conf.
+func (conf *Config) parseTLSSettings() (*tlsSettings, error) {
+	minVersion, err := parseTLSVersion(conf.TLSMinVersion)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", FlagNameTLSMinVersion, err)
+	}
+	maxVersion, err := parseTLSVersion(conf.TLSMaxVersion)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", FlagNameTLSMaxVersion, err)
+	}
+	if minVersion != 0 && maxVersion != 0 && minVersion > maxVersion {
+		return nil, fmt.Errorf("%v must not be greater than %v", FlagNameTLSMinVersion, FlagNameTLSMaxVersion)
+	}
+	cipherSuites, err := parseCipherSuites(conf.TLSCipherSuites)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", FlagNameTLSCipherSuites, err)
+	}
+	// Cipher suites only apply to TLS 1.2 connections, which are not made
+	// unless the minimum version is 1.2; the default minimum is 1.3.
+	if len(cipherSuites) > 0 && minVersion != tls.VersionTLS12 {
+		return nil, fmt.Errorf("%v only apply to TLS 1.2: %v must be '1.2'", FlagNameTLSCipherSuites, FlagNameTLSMinVersion)
+	}
+	return &tlsSettings{minVersion: minVersion, maxVersion: maxVersion, cipherSuites: cipherSuites}, nil
+}
+
 func newTLSConfig(
 	certPEMBlock []byte,
 	keyPEMBlock []byte,
 	CARootPEMBlocks [][]byte,
+	settings *tlsSettings,
 ) (*tls.Config, error) {
 	config := &tls.Config{
-		MinVersion: tls.VersionTLS13,
+		MinVersion:   tls.VersionTLS13,
+		MaxVersion:   settings.maxVersion,
+		CipherSuites: settings.cipherSuites,
+	}
+	if settings.minVersion != 0 {
+		config.MinVersion = settings.minVersion
 	}
 
 	if len(certPEMBlock) > 0 && len(keyPEMBlock) > 0 {
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCreateTLSConfigSettings(t *testing.T) {
+	tests := []struct {
+		description      string
+		input            Config
+		wantMinVersion   uint16
+		wantMaxVersion   uint16
+		wantCipherSuites []uint16
+		wantError        bool
+	}{
+		{
+			description:    "default",
+			input:          Config{},
+			wantMinVersion: tls.VersionTLS13,
+		},
+		{
+			description: "tls 1.2",
+			input: Config{
+				TLSMinVersion:   "1.2",
+				TLSMaxVersion:   "1.2",
+				TLSCipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+			},
+			wantMinVersion:   tls.VersionTLS12,
+			wantMaxVersion:   tls.VersionTLS12,
+			wantCipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384},
+		},
+		{
+			description: "unsupported version",
+			input:       Config{TLSMaxVersion: "1.0"},
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got, err := test.input.CreateTLSConfig()
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.MinVersion != test.wantMinVersion {
+				t.Errorf("got MinVersion %x, want %x", got.MinVersion, test.wantMinVersion)
+			}
+			if got.MaxVersion != test.wantMaxVersion {
+				t.Errorf("got MaxVersion %x, want %x", got.MaxVersion, test.wantMaxVersion)
+			}
+			if !cmp.Equal(got.CipherSuites, test.wantCipherSuites) {
+				t.Errorf("%v", cmp.Diff(got.CipherSuites, test.wantCipherSuites))
+			}
+		})
+	}
+}
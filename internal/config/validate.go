@@ -0,0 +1,252 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/pelletier/go-toml"
+)
+
+// Load parses a configuration file in TOML format. Keys that do not name a
+// configuration option are rejected.
+func Load(r io.Reader) (*Config, error) {
+	tree, err := toml.LoadReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse configuration: %w", err)
+	}
+	return loadTree(tree)
+}
+
+// loadTree decodes a parsed configuration, rejecting keys that do not name a
+// configuration option.
+func loadTree(tree *toml.Tree) (*Config, error) {
+	known := make(map[string]bool)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		known[t.Field(i).Tag.Get("toml")] = true
+	}
+	unknown := []string{}
+	for _, key := range tree.Keys() {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown configuration option '%v'", strings.Join(unknown, "', '"))
+	}
+
+	var conf Config
+	if err := tree.Unmarshal(&conf); err != nil {
+		return nil, fmt.Errorf("cannot parse configuration: %w", err)
+	}
+	return &conf, nil
+}
+
+// Validate checks the configuration values, including that the certificate,
+// key and CA root files can be read and that the server addresses are valid
+// for the protocol. It returns a list of problems found; an empty list means
+// the configuration is valid.
+func (conf *Config) Validate() []string {
+	problems := []string{}
+
+	if conf.LogLevel != "" {
+		if _, err := log.ParseLevel(conf.LogLevel); err != nil {
+			problems = append(problems, fmt.Sprintf("%v: %v", FlagNameLogLevel, err))
+		}
+	}
+
+	switch conf.LogFormat {
+	case "", "text", "json":
+	default:
+		problems = append(problems, fmt.Sprintf("%v: must be one of 'text' or 'json', got '%v'", FlagNameLogFormat, conf.LogFormat))
+	}
+
+	switch conf.Protocol {
+	case "", "none":
+	case "mqtt", "http":
+		if len(conf.Server) == 0 {
+			problems = append(problems, fmt.Sprintf("%v: must be set when %v is '%v'", FlagNameServer, FlagNameProtocol, conf.Protocol))
+		}
+		for _, server := range conf.Server {
+			if err := validateServer(conf.Protocol, server); err != nil {
+				problems = append(problems, fmt.Sprintf("%v: %v", FlagNameServer, err))
+			}
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("%v: must be one of 'mqtt', 'http' or 'none', got '%v'", FlagNameProtocol, conf.Protocol))
+	}
+
+	switch conf.HTTPReceiveMode {
+	case "", "poll", "sse":
+	default:
+		problems = append(problems, fmt.Sprintf("%v: must be one of 'poll' or 'sse', got '%v'", FlagNameHTTPReceiveMode, conf.HTTPReceiveMode))
+	}
+
+	if (conf.CertFile == "") != (conf.KeyFile == "") {
+		problems = append(problems, fmt.Sprintf("%v and %v must be set together", FlagNameCertFile, FlagNameKeyFile))
+	} else if conf.CertFile != "" {
+		problems = append(problems, validateKeyPair(conf.CertFile, conf.KeyFile)...)
+	}
+
+	for _, file := range conf.CARoot {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%v: cannot read '%v': %v", FlagNameCaRoot, file, err))
+			continue
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(data) {
+			problems = append(problems, fmt.Sprintf("%v: '%v' does not contain a PEM encoded certificate", FlagNameCaRoot, file))
+		}
+	}
+
+	if conf.MQTTPassword != "" && conf.MQTTPasswordFile != "" {
+		problems = append(problems, fmt.Sprintf("%v and %v cannot be set together", FlagNameMQTTPassword, FlagNameMQTTPasswordFile))
+	} else if conf.MQTTPasswordFile != "" {
+		if _, err := ReadSecretFile(conf.MQTTPasswordFile); err != nil {
+			problems = append(problems, fmt.Sprintf("%v: %v", FlagNameMQTTPasswordFile, err))
+		}
+	}
+
+	if conf.OAuthFlow != "" {
+		oauthConfig := conf.OAuthConfig("")
+		if err := oauthConfig.Validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("%v: %v", FlagNameOAuthFlow, err))
+		}
+	}
+
+	if conf.KerberosKeytab != "" {
+		if conf.OAuthFlow != "" {
+			problems = append(problems, fmt.Sprintf("%v and %v cannot be set together", FlagNameKerberosKeytab, FlagNameOAuthFlow))
+		}
+		kerberosConfig := conf.KerberosAuthConfig()
+		if err := kerberosConfig.Validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("%v: %v", FlagNameKerberosKeytab, err))
+		}
+	}
+
+	if _, err := conf.parseTLSSettings(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if _, err := parseServerPins(conf.ServerPins); err != nil {
+		problems = append(problems, fmt.Sprintf("%v: %v", FlagNameServerPin, err))
+	}
+
+	for _, hook := range []struct{ name, path string }{
+		{FlagNameMessageHook, conf.MessageHook},
+		{FlagNameOnConnectHook, conf.OnConnectHook},
+		{FlagNameOnDisconnectHook, conf.OnDisconnectHook},
+	} {
+		if hook.path == "" {
+			continue
+		}
+		info, err := os.Stat(hook.path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%v: %v", hook.name, err))
+		} else if info.IsDir() || info.Mode().Perm()&0111 == 0 {
+			problems = append(problems, fmt.Sprintf("%v: '%v' is not executable", hook.name, hook.path))
+		}
+	}
+
+	if conf.HealthListen != "" {
+		if err := ValidateLocalListen(conf.HealthListen); err != nil {
+			problems = append(problems, fmt.Sprintf("%v: %v", FlagNameHealthListen, err))
+		}
+	}
+
+	if conf.DebugListen != "" {
+		if err := ValidateLocalListen(conf.DebugListen); err != nil {
+			problems = append(problems, fmt.Sprintf("%v: %v", FlagNameDebugListen, err))
+		}
+	}
+
+	v := reflect.ValueOf(*conf)
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Field(i); f.CanInt() && f.Int() < 0 {
+			problems = append(problems, fmt.Sprintf("%v: must not be negative", v.Type().Field(i).Tag.Get("toml")))
+		}
+	}
+
+	return problems
+}
+
+// validateServer checks that server is a valid address for protocol. MQTT
+// brokers are URLs; HTTP servers are a host name with an optional port.
+func validateServer(protocol, server string) error {
+	switch protocol {
+	case "mqtt":
+		u, err := url.Parse(server)
+		if err != nil {
+			return fmt.Errorf("invalid broker URL '%v': %w", server, err)
+		}
+		switch u.Scheme {
+		case "tcp", "ssl", "tls", "mqtt", "mqtts", "ws", "wss":
+		default:
+			return fmt.Errorf("invalid broker URL '%v': scheme must be one of tcp, ssl, tls, mqtt, mqtts, ws or wss", server)
+		}
+		if u.Host == "" {
+			return fmt.Errorf("invalid broker URL '%v': missing host", server)
+		}
+	case "http":
+		u, err := url.Parse("https://" + server)
+		if err != nil || u.Host != server {
+			return fmt.Errorf("invalid server '%v': must be a host name with an optional port", server)
+		}
+	}
+	return nil
+}
+
+// ValidateLocalListen checks that addr is a unix socket path or a TCP address
+// on a loopback interface, so that what is served on it is only reachable from
+// the host.
+func ValidateLocalListen(addr string) error {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("socket path '%v' must be absolute", path)
+		}
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address '%v': %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("address '%v' must be on a loopback interface", addr)
+	}
+	return nil
+}
+
+// validateKeyPair checks that certFile and keyFile can be read and hold a
+// matching certificate and private key.
+func validateKeyPair(certFile, keyFile string) []string {
+	problems := []string{}
+	certData, err := os.ReadFile(certFile)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("%v: cannot read '%v': %v", FlagNameCertFile, certFile, err))
+	}
+	keyData, err := os.ReadFile(keyFile)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("%v: cannot read '%v': %v", FlagNameKeyFile, keyFile, err))
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+	if _, err := tls.X509KeyPair(certData, keyData); err != nil {
+		problems = append(problems, fmt.Sprintf("%v and %v do not form a valid key pair: %v", FlagNameCertFile, FlagNameKeyFile, err))
+	}
+	return problems
+}
@@ -0,0 +1,250 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		want        *Config
+		wantError   bool
+	}{
+		{
+			description: "valid",
+			input:       "protocol = \"mqtt\"\nserver = [\"tcp://localhost:1883\"]\nmessage-max-age = \"1h\"\n",
+			want: &Config{
+				Protocol:      "mqtt",
+				Server:        []string{"tcp://localhost:1883"},
+				MessageMaxAge: time.Hour,
+			},
+		},
+		{
+			description: "unknown key",
+			input:       "protocol = \"mqtt\"\nservers = [\"tcp://localhost:1883\"]\n",
+			wantError:   true,
+		},
+		{
+			description: "syntax error",
+			input:       "protocol = mqtt\n",
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got, err := Load(strings.NewReader(test.input))
+
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !cmp.Equal(got, test.want) {
+					t.Errorf("%#v != %#v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	hook := filepath.Join(dir, "hook")
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	notExecutable := filepath.Join(dir, "not-executable")
+	if err := os.WriteFile(notExecutable, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		description string
+		input       Config
+		want        []string
+	}{
+		{
+			description: "empty",
+			input:       Config{},
+			want:        []string{},
+		},
+		{
+			description: "valid mqtt",
+			input: Config{
+				LogLevel:    "debug",
+				Protocol:    "mqtt",
+				Server:      []string{"tcp://localhost:1883", "wss://localhost"},
+				MessageHook: hook,
+			},
+			want: []string{},
+		},
+		{
+			description: "valid http",
+			input: Config{
+				Protocol: "http",
+				Server:   []string{"localhost:8080"},
+			},
+			want: []string{},
+		},
+		{
+			description: "invalid log level",
+			input:       Config{LogLevel: "loud"},
+			want:        []string{"log-level: invalid level value: loud"},
+		},
+		{
+			description: "health listen on loopback",
+			input:       Config{HealthListen: "127.0.0.1:8090"},
+			want:        []string{},
+		},
+		{
+			description: "health listen on unix socket",
+			input:       Config{HealthListen: "unix:/run/yggdrasil/health.sock"},
+			want:        []string{},
+		},
+		{
+			description: "health listen on all interfaces",
+			input:       Config{HealthListen: ":8090"},
+			want:        []string{"health-listen: address ':8090' must be on a loopback interface"},
+		},
+		{
+			description: "debug listen on another host",
+			input:       Config{DebugListen: "192.0.2.1:6060"},
+			want:        []string{"debug-listen: address '192.0.2.1:6060' must be on a loopback interface"},
+		},
+		{
+			description: "invalid log format",
+			input:       Config{LogFormat: "xml"},
+			want:        []string{"log-format: must be one of 'text' or 'json', got 'xml'"},
+		},
+		{
+			description: "invalid protocol",
+			input:       Config{Protocol: "amqp"},
+			want:        []string{"protocol: must be one of 'mqtt', 'http' or 'none', got 'amqp'"},
+		},
+		{
+			description: "invalid http receive mode",
+			input:       Config{HTTPReceiveMode: "push"},
+			want:        []string{"http-receive-mode: must be one of 'poll' or 'sse', got 'push'"},
+		},
+		{
+			description: "valid tls settings",
+			input:       Config{TLSMinVersion: "1.2", TLSMaxVersion: "1.3", TLSCipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}},
+			want:        []string{},
+		},
+		{
+			description: "invalid tls version",
+			input:       Config{TLSMinVersion: "1.1"},
+			want:        []string{"tls-min-version: unsupported TLS version '1.1': must be one of '1.2' or '1.3'"},
+		},
+		{
+			description: "tls version range",
+			input:       Config{TLSMinVersion: "1.3", TLSMaxVersion: "1.2"},
+			want:        []string{"tls-min-version must not be greater than tls-max-version"},
+		},
+		{
+			description: "insecure cipher suite",
+			input:       Config{TLSCipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+			want:        []string{"tls-cipher-suites: unsupported cipher suite 'TLS_RSA_WITH_RC4_128_SHA'"},
+		},
+		{
+			description: "cipher suites without tls 1.2",
+			input:       Config{TLSCipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}},
+			want:        []string{"tls-cipher-suites only apply to TLS 1.2: tls-min-version must be '1.2'"},
+		},
+		{
+			description: "tls 1.3 cipher suite",
+			input:       Config{TLSMinVersion: "1.2", TLSCipherSuites: []string{"TLS_AES_128_GCM_SHA256"}},
+			want:        []string{"tls-cipher-suites: cipher suite 'TLS_AES_128_GCM_SHA256' is a TLS 1.3 suite, which cannot be configured"},
+		},
+		{
+			description: "valid oauth",
+			input: Config{
+				OAuthFlow:         "client-credentials",
+				OAuthTokenURL:     "https://sso.example.com/token",
+				OAuthClientID:     "yggd",
+				OAuthClientSecret: "s3cret",
+			},
+			want: []string{},
+		},
+		{
+			description: "oauth without token url",
+			input:       Config{OAuthFlow: "client-credentials", OAuthClientID: "yggd", OAuthClientSecret: "s3cret"},
+			want:        []string{"oauth-flow: invalid token URL: '' must be an https URL"},
+		},
+		{
+			description: "missing server",
+			input:       Config{Protocol: "mqtt"},
+			want:        []string{"server: must be set when protocol is 'mqtt'"},
+		},
+		{
+			description: "invalid broker scheme",
+			input:       Config{Protocol: "mqtt", Server: []string{"localhost:1883"}},
+			want:        []string{"server: invalid broker URL 'localhost:1883': scheme must be one of tcp, ssl, tls, mqtt, mqtts, ws or wss"},
+		},
+		{
+			description: "http server with scheme",
+			input:       Config{Protocol: "http", Server: []string{"https://localhost"}},
+			want:        []string{"server: invalid server 'https://localhost': must be a host name with an optional port"},
+		},
+		{
+			description: "cert without key",
+			input:       Config{CertFile: "/etc/pki/consumer/cert.pem"},
+			want:        []string{"cert-file and key-file must be set together"},
+		},
+		{
+			description: "unreadable key pair",
+			input: Config{
+				CertFile: filepath.Join(dir, "cert.pem"),
+				KeyFile:  filepath.Join(dir, "key.pem"),
+			},
+			want: []string{
+				"cert-file: cannot read '" + filepath.Join(dir, "cert.pem") + "': open " + filepath.Join(dir, "cert.pem") + ": no such file or directory",
+				"key-file: cannot read '" + filepath.Join(dir, "key.pem") + "': open " + filepath.Join(dir, "key.pem") + ": no such file or directory",
+			},
+		},
+		{
+			description: "ca root without certificate",
+			input:       Config{CARoot: []string{hook}},
+			want:        []string{"ca-root: '" + hook + "' does not contain a PEM encoded certificate"},
+		},
+		{
+			description: "hook not executable",
+			input:       Config{MessageHook: notExecutable},
+			want:        []string{"message-hook: '" + notExecutable + "' is not executable"},
+		},
+		{
+			description: "connection hooks",
+			input:       Config{OnConnectHook: hook, OnDisconnectHook: notExecutable},
+			want:        []string{"on-disconnect-hook: '" + notExecutable + "' is not executable"},
+		},
+		{
+			description: "negative values",
+			input:       Config{HTTPRetries: -1, ResponseTimeout: -1},
+			want: []string{
+				"http-retries: must not be negative",
+				"response-timeout: must not be negative",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got := test.input.Validate()
+
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("%#v != %#v", got, test.want)
+			}
+		})
+	}
+}
@@ -28,6 +28,7 @@ var (
 	LocalstateDir string = filepath.Join(PrefixDir, "var")
 	DataDir       string = filepath.Join(PrefixDir, "share")
 	LibDir        string = filepath.Join(PrefixDir, "lib")
+	LibexecDir    string = filepath.Join(PrefixDir, "libexec")
 
 	// ConfigDir is a path to a location where configuration data is assumed to
 	// be stored. For non-root users, this is set to $CONFIGURATION_DIRECTORY or
@@ -45,17 +46,50 @@ var (
 	// /var/cache/yggdrasil.
 	CacheDir string = filepath.Join(LocalstateDir, "cache", "yggdrasil")
 
+	// RuntimeDir is a path to a location where runtime data, such as the
+	// environment file shared with workers, can be stored. For non-root users,
+	// this is set to $RUNTIME_DIRECTORY or $XDG_RUNTIME_DIR/yggdrasil.
+	// Otherwise, it gets set to /run/yggdrasil.
+	RuntimeDir string = filepath.Join(LocalstateDir, "run", "yggdrasil")
+
+	// LogDir is a path to a location where log files can be stored. For
+	// non-root users, this is set to $LOGS_DIRECTORY or
+	// $XDG_STATE_HOME/yggdrasil/log. Otherwise, it gets set to
+	// /var/log/yggdrasil.
+	LogDir string = filepath.Join(LocalstateDir, "log", "yggdrasil")
+
 	// DBusSystemServicesDir is a path to a location where D-Bus bus-activable
-	// system service definition files are stored.
+	// system service definition files are stored. For non-root users, this is
+	// set to $XDG_DATA_HOME/dbus-1/services, read by the session bus.
 	DBusSystemServicesDir string = filepath.Join(DataDir, "dbus-1", "system-services")
 
 	// DBusPolicyConfigDir is a path to a location where D-Bus policy
-	// configuration definition files are stored.
+	// configuration definition files are stored. For non-root users, this is
+	// empty, since the session bus lets the user own any name.
 	DBusPolicyConfigDir string = filepath.Join(DataDir, "dbus-1", "system.d")
 
 	// SystemdSystemServicesDir is a path to a location where systemd system
-	// service unit files are stored.
+	// service unit files are stored. For non-root users, this is set to
+	// $XDG_CONFIG_HOME/systemd/user, read by the user service manager.
 	SystemdSystemServicesDir string = filepath.Join(LibDir, "systemd", "system")
+
+	// WorkerExecDir is a path to a location where worker programs installed
+	// from worker bundles are stored. For non-root users, this is set to
+	// $XDG_DATA_HOME/yggdrasil/workers.
+	WorkerExecDir string = filepath.Join(LibexecDir, "yggdrasil")
+
+	// LogrotateConfigDir is a path to a location where logrotate configuration
+	// files are stored. For non-root users, this is empty, since logrotate
+	// only reads system configuration.
+	LogrotateConfigDir string = filepath.Join(SysconfDir, "logrotate.d")
+
+	// CredstoreDir is a path to a location where systemd looks up plaintext
+	// credentials imported by services.
+	CredstoreDir string = filepath.Join(SysconfDir, "credstore")
+
+	// CredstoreEncryptedDir is a path to a location where systemd looks up
+	// encrypted credentials imported by services.
+	CredstoreEncryptedDir string = filepath.Join(SysconfDir, "credstore.encrypted")
 )
 
 func init() {
@@ -63,6 +97,16 @@ func init() {
 		ConfigDir = lookupEnv("CONFIGURATION_DIRECTORY", filepath.Join(xdg.ConfigHome, "yggdrasil"))
 		StateDir = lookupEnv("STATE_DIRECTORY", filepath.Join(xdg.StateHome, "yggdrasil"))
 		CacheDir = lookupEnv("CACHE_DIRECTORY", filepath.Join(xdg.CacheHome, "yggdrasil"))
+		RuntimeDir = lookupEnv("RUNTIME_DIRECTORY", filepath.Join(xdg.RuntimeDir, "yggdrasil"))
+		LogDir = lookupEnv("LOGS_DIRECTORY", filepath.Join(xdg.StateHome, "yggdrasil", "log"))
+
+		// Workers of a non-root user run in the user's session, activated by
+		// the session bus and supervised by the user service manager.
+		DBusSystemServicesDir = filepath.Join(xdg.DataHome, "dbus-1", "services")
+		DBusPolicyConfigDir = ""
+		SystemdSystemServicesDir = filepath.Join(xdg.ConfigHome, "systemd", "user")
+		WorkerExecDir = filepath.Join(xdg.DataHome, "yggdrasil", "workers")
+		LogrotateConfigDir = ""
 	}
 }
 
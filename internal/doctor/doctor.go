@@ -0,0 +1,305 @@
+// Package doctor checks that the host is set up for yggd to run: that its
+// certificates are valid, its servers can be reached and its directories can
+// be written to. Each check yields a Result, and the results are printed as a
+// report with Report.
+package doctor
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	// StatusPass means the check found no problem.
+	StatusPass Status = "PASS"
+
+	// StatusWarn means the check found a problem that does not prevent yggd
+	// from running yet.
+	StatusWarn Status = "WARN"
+
+	// StatusFail means the check found a problem that prevents yggd from
+	// running as configured.
+	StatusFail Status = "FAIL"
+
+	// StatusSkip means the check does not apply to the configuration.
+	StatusSkip Status = "SKIP"
+)
+
+// ExpiryWarning is how long before a certificate expires Certificate starts
+// warning about it.
+const ExpiryWarning = 30 * 24 * time.Hour
+
+// Result is the outcome of a check of one subject, such as a file or a
+// server.
+type Result struct {
+	Check  string
+	Status Status
+	Detail string
+}
+
+// Pass returns a passing result of check.
+func Pass(check, format string, a ...interface{}) Result {
+	return Result{Check: check, Status: StatusPass, Detail: fmt.Sprintf(format, a...)}
+}
+
+// Warn returns a warning result of check.
+func Warn(check, format string, a ...interface{}) Result {
+	return Result{Check: check, Status: StatusWarn, Detail: fmt.Sprintf(format, a...)}
+}
+
+// Fail returns a failing result of check.
+func Fail(check, format string, a ...interface{}) Result {
+	return Result{Check: check, Status: StatusFail, Detail: fmt.Sprintf(format, a...)}
+}
+
+// Skip returns a skipped result of check.
+func Skip(check, format string, a ...interface{}) Result {
+	return Result{Check: check, Status: StatusSkip, Detail: fmt.Sprintf(format, a...)}
+}
+
+// Report writes results to w as a table and returns the number of failed
+// checks.
+func Report(w io.Writer, results []Result) int {
+	failed := 0
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, r := range results {
+		fmt.Fprintf(tw, "%v\t%v\t%v\n", r.Status, r.Check, r.Detail)
+		if r.Status == StatusFail {
+			failed++
+		}
+	}
+	_ = tw.Flush()
+	return failed
+}
+
+// Certificate checks that every certificate in the PEM encoded file is valid
+// at now, warning about certificates that expire within ExpiryWarning.
+func Certificate(check, file string, now time.Time) Result {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Fail(check, "cannot read '%v': %v", file, err)
+	}
+
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return Fail(check, "cannot parse certificate in '%v': %v", file, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return Fail(check, "'%v' does not contain a PEM encoded certificate", file)
+	}
+
+	first := certs[0]
+	for _, cert := range certs {
+		if now.Before(cert.NotBefore) {
+			return Fail(check, "'%v' is not valid before %v", cert.Subject, cert.NotBefore.Format(time.RFC3339))
+		}
+		if now.After(cert.NotAfter) {
+			return Fail(check, "'%v' expired on %v", cert.Subject, cert.NotAfter.Format(time.RFC3339))
+		}
+		if cert.NotAfter.Before(first.NotAfter) {
+			first = cert
+		}
+	}
+	if first.NotAfter.Sub(now) < ExpiryWarning {
+		return Warn(check, "'%v' expires on %v", first.Subject, first.NotAfter.Format(time.RFC3339))
+	}
+	return Pass(check, "%v valid until %v", file, first.NotAfter.Format(time.RFC3339))
+}
+
+// PrivateKey checks that the private key file cannot be read by users other
+// than its owner and group.
+func PrivateKey(check, file string) Result {
+	info, err := os.Stat(file)
+	if err != nil {
+		return Fail(check, "%v", err)
+	}
+	if info.Mode().Perm()&0004 != 0 {
+		return Warn(check, "'%v' can be read by any user (mode %v)", file, info.Mode().Perm())
+	}
+	return Pass(check, "%v (mode %v)", file, info.Mode().Perm())
+}
+
+// Directory checks that yggd can create files in dir or, if dir does not
+// exist, that it can create dir.
+func Directory(check, dir string) Result {
+	path := dir
+	for {
+		_, err := os.Stat(path)
+		if err == nil {
+			break
+		}
+		parent := filepath.Dir(path)
+		if !errors.Is(err, os.ErrNotExist) || parent == path {
+			return Fail(check, "%v", err)
+		}
+		path = parent
+	}
+	if err := unix.Access(path, unix.W_OK|unix.X_OK); err != nil {
+		return Fail(check, "'%v' is not writable: %v", path, err)
+	}
+	if path != dir {
+		return Pass(check, "%v does not exist and can be created", dir)
+	}
+	return Pass(check, "%v is writable", dir)
+}
+
+// ServerAddress returns the network address of server and whether
+// connections to it use TLS. MQTT brokers are URLs; HTTP servers are a host
+// name with an optional port and are always connected to over HTTPS.
+func ServerAddress(protocol, server string) (string, bool, error) {
+	var u *url.URL
+	var err error
+	switch protocol {
+	case "mqtt":
+		u, err = url.Parse(server)
+	case "http":
+		u, err = url.Parse("https://" + server)
+	default:
+		return "", false, fmt.Errorf("unsupported protocol: %v", protocol)
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if u.Hostname() == "" {
+		return "", false, fmt.Errorf("missing host in '%v'", server)
+	}
+
+	var port string
+	var useTLS bool
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		port = "1883"
+	case "ssl", "tls", "mqtts":
+		port, useTLS = "8883", true
+	case "ws":
+		port = "80"
+	case "wss", "https":
+		port, useTLS = "443", true
+	default:
+		return "", false, fmt.Errorf("unsupported scheme: %v", u.Scheme)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	return net.JoinHostPort(u.Hostname(), port), useTLS, nil
+}
+
+// Connect opens a TCP connection to addr, through proxy if it is not nil, and
+// performs a TLS handshake if tlsConfig is not nil. Each step waits at most
+// timeout. The connection is closed before Connect returns.
+func Connect(addr string, proxy *url.URL, tlsConfig *tls.Config, timeout time.Duration) error {
+	var conn net.Conn
+	var err error
+	if proxy != nil {
+		conn, err = dialProxy(proxy, addr, timeout)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, timeout)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if tlsConfig == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	config := tlsConfig.Clone()
+	config.ServerName = host
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	if err := tls.Client(conn, config).Handshake(); err != nil {
+		return fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	return nil
+}
+
+// dialProxy connects to addr through the HTTP proxy, which must accept the
+// CONNECT method.
+func dialProxy(proxy *url.URL, addr string, timeout time.Duration) (net.Conn, error) {
+	proxyAddr := proxy.Host
+	if proxy.Port() == "" {
+		port := "80"
+		if proxy.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxy.Hostname(), port)
+	}
+
+	conn, err := net.DialTimeout("tcp", proxyAddr, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to proxy: %w", err)
+	}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if proxy.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxy.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("TLS handshake with proxy failed: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot send request to proxy: %w", err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot read response from proxy: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused connection to %v: %v", addr, resp.Status)
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
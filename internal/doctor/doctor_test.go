@@ -0,0 +1,287 @@
+package doctor
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func writeCertificate(t *testing.T, dir string, notBefore, notAfter time.Time) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "cert.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCertificate(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		description string
+		notBefore   time.Time
+		notAfter    time.Time
+		want        Status
+	}{
+		{
+			description: "valid",
+			notBefore:   now.Add(-time.Hour),
+			notAfter:    now.Add(365 * 24 * time.Hour),
+			want:        StatusPass,
+		},
+		{
+			description: "expiring soon",
+			notBefore:   now.Add(-time.Hour),
+			notAfter:    now.Add(24 * time.Hour),
+			want:        StatusWarn,
+		},
+		{
+			description: "expired",
+			notBefore:   now.Add(-2 * time.Hour),
+			notAfter:    now.Add(-time.Hour),
+			want:        StatusFail,
+		},
+		{
+			description: "not yet valid",
+			notBefore:   now.Add(time.Hour),
+			notAfter:    now.Add(365 * 24 * time.Hour),
+			want:        StatusFail,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			file := writeCertificate(t, t.TempDir(), test.notBefore, test.notAfter)
+			got := Certificate("cert-file", file, now)
+			if got.Status != test.want {
+				t.Errorf("got %v (%v), want %v", got.Status, got.Detail, test.want)
+			}
+		})
+	}
+}
+
+func TestCertificateInvalidFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cert.pem")
+	if got := Certificate("cert-file", file, time.Now()); got.Status != StatusFail {
+		t.Errorf("missing file: got %v, want %v", got.Status, StatusFail)
+	}
+	if err := os.WriteFile(file, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got := Certificate("cert-file", file, time.Now()); got.Status != StatusFail {
+		t.Errorf("invalid file: got %v, want %v", got.Status, StatusFail)
+	}
+}
+
+func TestPrivateKey(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		description string
+		mode        os.FileMode
+		want        Status
+	}{
+		{description: "owner only", mode: 0600, want: StatusPass},
+		{description: "group readable", mode: 0640, want: StatusPass},
+		{description: "world readable", mode: 0644, want: StatusWarn},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			file := filepath.Join(dir, "key.pem")
+			if err := os.WriteFile(file, []byte("key"), test.mode); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(file, test.mode); err != nil {
+				t.Fatal(err)
+			}
+			if got := PrivateKey("key-file", file); got.Status != test.want {
+				t.Errorf("got %v (%v), want %v", got.Status, got.Detail, test.want)
+			}
+		})
+	}
+}
+
+func TestDirectory(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte{}, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		description string
+		input       string
+		want        Status
+	}{
+		{description: "existing", input: dir, want: StatusPass},
+		{description: "missing", input: filepath.Join(dir, "a", "b"), want: StatusPass},
+		{description: "below a file", input: filepath.Join(file, "a"), want: StatusFail},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if got := Directory("state-dir", test.input); got.Status != test.want {
+				t.Errorf("got %v (%v), want %v", got.Status, got.Detail, test.want)
+			}
+		})
+	}
+}
+
+func TestServerAddress(t *testing.T) {
+	tests := []struct {
+		description string
+		protocol    string
+		server      string
+		wantAddr    string
+		wantTLS     bool
+		wantError   bool
+	}{
+		{description: "mqtt", protocol: "mqtt", server: "tcp://broker", wantAddr: "broker:1883"},
+		{description: "mqtts", protocol: "mqtt", server: "mqtts://broker", wantAddr: "broker:8883", wantTLS: true},
+		{description: "mqtt with port", protocol: "mqtt", server: "ssl://broker:1234", wantAddr: "broker:1234", wantTLS: true},
+		{description: "websocket", protocol: "mqtt", server: "wss://broker/mqtt", wantAddr: "broker:443", wantTLS: true},
+		{description: "http", protocol: "http", server: "example.com", wantAddr: "example.com:443", wantTLS: true},
+		{description: "http with port", protocol: "http", server: "example.com:8443", wantAddr: "example.com:8443", wantTLS: true},
+		{description: "unknown scheme", protocol: "mqtt", server: "ftp://broker", wantError: true},
+		{description: "missing host", protocol: "mqtt", server: "tcp://", wantError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			addr, useTLS, err := ServerAddress(test.protocol, test.server)
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got %v", addr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if addr != test.wantAddr || useTLS != test.wantTLS {
+				t.Errorf("got %v %v, want %v %v", addr, useTLS, test.wantAddr, test.wantTLS)
+			}
+		})
+	}
+}
+
+// connectProxy is an HTTP proxy handler that tunnels CONNECT requests.
+func connectProxy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	upstream, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 OK\r\n\r\n"); err != nil {
+		upstream.Close()
+		conn.Close()
+		return
+	}
+	go func() {
+		_, _ = io.Copy(upstream, conn)
+		upstream.Close()
+	}()
+	_, _ = io.Copy(conn, upstream)
+	conn.Close()
+}
+
+func TestConnect(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	addr := server.Listener.Addr().String()
+
+	trusted := x509.NewCertPool()
+	trusted.AddCert(server.Certificate())
+
+	proxy := httptest.NewServer(http.HandlerFunc(connectProxy))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		description string
+		proxy       *url.URL
+		tlsConfig   *tls.Config
+		wantError   bool
+	}{
+		{description: "tcp"},
+		{description: "tls", tlsConfig: &tls.Config{RootCAs: trusted}},
+		{description: "untrusted", tlsConfig: &tls.Config{RootCAs: x509.NewCertPool()}, wantError: true},
+		{description: "proxy", proxy: proxyURL, tlsConfig: &tls.Config{RootCAs: trusted}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			err := Connect(addr, test.proxy, test.tlsConfig, 5*time.Second)
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	var buf bytes.Buffer
+	failed := Report(&buf, []Result{
+		Pass("configuration", "config.toml"),
+		Fail("server", "connection refused"),
+		Skip("proxy", "no proxy is configured"),
+	})
+	if failed != 1 {
+		t.Errorf("got %v failures, want 1", failed)
+	}
+
+	want := "PASS  configuration  config.toml\n" +
+		"FAIL  server         connection refused\n" +
+		"SKIP  proxy          no proxy is configured\n"
+	if got := buf.String(); !cmp.Equal(got, want) {
+		t.Errorf("%v", cmp.Diff(got, want))
+	}
+}
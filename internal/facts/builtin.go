@@ -0,0 +1,172 @@
+package facts
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Names of the built-in collectors.
+const (
+	CollectorMachineID    = "machine-id"
+	CollectorBIOSUUID     = "bios-uuid"
+	CollectorIPAddresses  = "ip-addresses"
+	CollectorMACAddresses = "mac-addresses"
+	CollectorFQDN         = "fqdn"
+	CollectorSubscription = "subscription"
+)
+
+// Files read by the built-in collectors.
+var (
+	machineIDFile    = "/etc/machine-id"
+	biosUUIDFile     = "/sys/class/dmi/id/product_uuid"
+	consumerCertFile = "/etc/pki/consumer/cert.pem"
+)
+
+// Builtins returns the built-in collectors.
+func Builtins() []Collector {
+	return []Collector{
+		CollectorFunc(CollectorMachineID, collectMachineID),
+		CollectorFunc(CollectorBIOSUUID, collectBIOSUUID),
+		CollectorFunc(CollectorIPAddresses, collectIPAddresses),
+		CollectorFunc(CollectorMACAddresses, collectMACAddresses),
+		CollectorFunc(CollectorFQDN, collectFQDN),
+		CollectorFunc(CollectorSubscription, collectSubscription),
+	}
+}
+
+// FileCollector returns a collector named "facts-file" that reads facts from
+// the JSON object in the file at path.
+func FileCollector(path string) Collector {
+	return CollectorFunc("facts-file", func() (map[string]interface{}, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read facts file: %w", err)
+		}
+		var facts map[string]interface{}
+		if err := json.Unmarshal(data, &facts); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal facts: %w", err)
+		}
+		return facts, nil
+	})
+}
+
+// collectMachineID reads the systemd machine ID as "machine_id".
+func collectMachineID() (map[string]interface{}, error) {
+	data, err := os.ReadFile(machineIDFile)
+	if err != nil {
+		return nil, err
+	}
+	id := strings.TrimSpace(string(data))
+	if id == "" {
+		return nil, fmt.Errorf("%v is empty", machineIDFile)
+	}
+	return map[string]interface{}{"machine_id": id}, nil
+}
+
+// collectBIOSUUID reads the SMBIOS system UUID as "bios_uuid".
+func collectBIOSUUID() (map[string]interface{}, error) {
+	data, err := os.ReadFile(biosUUIDFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	id := strings.ToLower(strings.TrimSpace(string(data)))
+	if id == "" {
+		return nil, nil
+	}
+	return map[string]interface{}{"bios_uuid": id}, nil
+}
+
+// collectIPAddresses lists the addresses of the network interfaces that are
+// up, other than loopback interfaces, as "ip_addresses".
+func collectIPAddresses() (map[string]interface{}, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	addresses := []string{}
+	for _, i := range interfaces {
+		if i.Flags&net.FlagUp == 0 || i.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := i.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("cannot get addresses of %v: %w", i.Name, err)
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLinkLocalUnicast() {
+				addresses = append(addresses, ipnet.IP.String())
+			}
+		}
+	}
+	return map[string]interface{}{"ip_addresses": addresses}, nil
+}
+
+// collectMACAddresses lists the hardware addresses of the network interfaces
+// other than loopback interfaces as "mac_addresses".
+func collectMACAddresses() (map[string]interface{}, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	addresses := []string{}
+	for _, i := range interfaces {
+		if i.Flags&net.FlagLoopback != 0 || len(i.HardwareAddr) == 0 {
+			continue
+		}
+		addresses = append(addresses, i.HardwareAddr.String())
+	}
+	return map[string]interface{}{"mac_addresses": addresses}, nil
+}
+
+// collectFQDN resolves the fully qualified domain name of the host as "fqdn",
+// falling back to the host name if it cannot be resolved.
+func collectFQDN() (map[string]interface{}, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	fqdn := hostname
+	if !strings.Contains(hostname, ".") {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if cname, err := net.DefaultResolver.LookupCNAME(ctx, hostname); err == nil && cname != "" {
+			fqdn = strings.TrimSuffix(cname, ".")
+		}
+	}
+	return map[string]interface{}{"fqdn": fqdn}, nil
+}
+
+// collectSubscription reads the subscription-manager consumer ID from the
+// common name of the consumer certificate as "subscription_manager_id". The
+// system is not required to be registered.
+func collectSubscription() (map[string]interface{}, error) {
+	data, err := os.ReadFile(consumerCertFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("cannot decode %v", consumerCertFile)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %v: %w", consumerCertFile, err)
+	}
+	if cert.Subject.CommonName == "" {
+		return nil, nil
+	}
+	return map[string]interface{}{"subscription_manager_id": cert.Subject.CommonName}, nil
+}
@@ -0,0 +1,124 @@
+package facts
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileCollectors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "3a5bd4bf-0a3c-4a5f-9a3e-6a8a2b1c0d9e"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		description string
+		file        *string
+		content     []byte
+		collect     func() (map[string]interface{}, error)
+		want        map[string]interface{}
+		wantError   bool
+	}{
+		{
+			description: "machine id",
+			file:        &machineIDFile,
+			content:     []byte("4c4c4544004c4c4544004c4c45440000\n"),
+			collect:     collectMachineID,
+			want:        map[string]interface{}{"machine_id": "4c4c4544004c4c4544004c4c45440000"},
+		},
+		{
+			description: "empty machine id",
+			file:        &machineIDFile,
+			content:     []byte("\n"),
+			collect:     collectMachineID,
+			wantError:   true,
+		},
+		{
+			description: "bios uuid",
+			file:        &biosUUIDFile,
+			content:     []byte("4C4C4544-0042-3010-8050-B4C04F4B3732\n"),
+			collect:     collectBIOSUUID,
+			want:        map[string]interface{}{"bios_uuid": "4c4c4544-0042-3010-8050-b4c04f4b3732"},
+		},
+		{
+			description: "missing bios uuid",
+			file:        &biosUUIDFile,
+			collect:     collectBIOSUUID,
+		},
+		{
+			description: "subscription",
+			file:        &consumerCertFile,
+			content:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			collect:     collectSubscription,
+			want:        map[string]interface{}{"subscription_manager_id": "3a5bd4bf-0a3c-4a5f-9a3e-6a8a2b1c0d9e"},
+		},
+		{
+			description: "unregistered",
+			file:        &consumerCertFile,
+			collect:     collectSubscription,
+		},
+		{
+			description: "invalid certificate",
+			file:        &consumerCertFile,
+			content:     []byte("not a certificate"),
+			collect:     collectSubscription,
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			saved := *test.file
+			defer func() { *test.file = saved }()
+			*test.file = filepath.Join(dir, "missing")
+			if test.content != nil {
+				*test.file = write(test.description, test.content)
+			}
+
+			got, err := test.collect()
+
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !cmp.Equal(got, test.want) {
+					t.Errorf("%v", cmp.Diff(got, test.want))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package facts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+)
+
+// dirTimeout is how long an executable in a facts directory may run.
+var dirTimeout = 10 * time.Second
+
+// DirCollector returns a collector named "facts-dir" that gathers facts from
+// the files in dir. A file ending in ".json" holds a JSON object of facts. An
+// executable file is run without arguments and writes a JSON object of facts
+// to its standard output. Other files are ignored, as are files writable by
+// users other than their owner. Files are read in lexical order, and facts
+// from a later file take precedence. A file that cannot be read is logged and
+// skipped. A missing directory holds no facts.
+func DirCollector(dir string) Collector {
+	return CollectorFunc("facts-dir", func() (map[string]interface{}, error) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("cannot read directory: %w", err)
+		}
+		names := []string{}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				names = append(names, entry.Name())
+			}
+		}
+		sort.Strings(names)
+
+		facts := make(map[string]interface{})
+		for _, name := range names {
+			f, err := readFactsFile(filepath.Join(dir, name))
+			if err != nil {
+				log.Warnf("cannot read facts from %v: %v", filepath.Join(dir, name), err)
+				continue
+			}
+			for k, v := range f {
+				facts[k] = v
+			}
+		}
+		return facts, nil
+	})
+}
+
+// readFactsFile reads facts from the JSON file or executable at path. It
+// returns nil if path is neither.
+func readFactsFile(path string) (map[string]interface{}, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	executable := info.Mode().Perm()&0111 != 0
+	if !executable && !strings.HasSuffix(path, ".json") {
+		return nil, nil
+	}
+	if info.Mode().Perm()&0022 != 0 {
+		return nil, fmt.Errorf("file is writable by group or others")
+	}
+
+	var data []byte
+	if executable {
+		data, err = runFactsProgram(path)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var facts map[string]interface{}
+	if err := json.Unmarshal(data, &facts); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal facts: %w", err)
+	}
+	return facts, nil
+}
+
+// runFactsProgram runs the program at path and returns its standard output.
+func runFactsProgram(path string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dirTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return nil, fmt.Errorf("%w: %v", err, strings.TrimSpace(stderr.String()))
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,96 @@
+package facts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDirCollector(t *testing.T) {
+	type file struct {
+		name    string
+		mode    os.FileMode
+		content string
+	}
+
+	tests := []struct {
+		description string
+		files       []file
+		want        map[string]interface{}
+	}{
+		{
+			description: "json",
+			files: []file{
+				{"10-site.json", 0644, `{"cost_center": "1234"}`},
+			},
+			want: map[string]interface{}{"cost_center": "1234"},
+		},
+		{
+			description: "executable",
+			files: []file{
+				{"env", 0755, "#!/bin/sh\necho '{\"environment\": \"prod\"}'\n"},
+			},
+			want: map[string]interface{}{"environment": "prod"},
+		},
+		{
+			description: "later takes precedence",
+			files: []file{
+				{"10-a.json", 0644, `{"environment": "dev", "owner": "a"}`},
+				{"20-b.json", 0644, `{"environment": "prod"}`},
+			},
+			want: map[string]interface{}{"environment": "prod", "owner": "a"},
+		},
+		{
+			description: "ignored",
+			files: []file{
+				{"README", 0644, "not facts"},
+				{"10-a.json", 0666, `{"owner": "a"}`},
+			},
+			want: map[string]interface{}{},
+		},
+		{
+			description: "failures skipped",
+			files: []file{
+				{"10-invalid.json", 0644, `{`},
+				{"20-fail", 0755, "#!/bin/sh\necho failed >&2\nexit 1\n"},
+				{"30-b.json", 0644, `{"owner": "b"}`},
+			},
+			want: map[string]interface{}{"owner": "b"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range test.files {
+				path := filepath.Join(dir, f.name)
+				if err := os.WriteFile(path, []byte(f.content), f.mode); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chmod(path, f.mode); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := DirCollector(dir).Collect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
+
+func TestDirCollectorMissing(t *testing.T) {
+	got, err := DirCollector(filepath.Join(t.TempDir(), "missing")).Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("%v != nil", got)
+	}
+}
@@ -0,0 +1,149 @@
+// Package facts gathers the canonical facts yggd sends to the server to
+// identify the system.
+//
+// Facts are gathered by collectors. The built-in collectors, listed by
+// Builtins, are registered with DefaultRegistry; products embedding yggd add
+// their own collectors with Register.
+package facts
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"git.sr.ht/~spc/go-log"
+)
+
+// Collector gathers a set of canonical facts.
+type Collector interface {
+	// Name identifies the collector, for example to disable it in the
+	// configuration.
+	Name() string
+
+	// Collect returns the facts gathered by the collector, keyed by fact
+	// name.
+	Collect() (map[string]interface{}, error)
+}
+
+// collectorFunc is a Collector calling an ordinary function.
+type collectorFunc struct {
+	name    string
+	collect func() (map[string]interface{}, error)
+}
+
+func (c collectorFunc) Name() string { return c.name }
+
+func (c collectorFunc) Collect() (map[string]interface{}, error) { return c.collect() }
+
+// CollectorFunc returns a Collector named name that gathers facts by calling
+// collect.
+func CollectorFunc(name string, collect func() (map[string]interface{}, error)) Collector {
+	return collectorFunc{name: name, collect: collect}
+}
+
+// Registry holds an ordered set of collectors.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors []Collector
+	disabled   map[string]bool
+}
+
+// NewRegistry creates a Registry holding collectors.
+func NewRegistry(collectors ...Collector) *Registry {
+	r := &Registry{}
+	for _, c := range collectors {
+		r.Register(c)
+	}
+	return r
+}
+
+// Register adds c to the registry, replacing any collector with the same name.
+// Collectors are run in the order they were first registered, and facts
+// gathered by a later collector take precedence over those of an earlier one.
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, existing := range r.collectors {
+		if existing.Name() == c.Name() {
+			r.collectors[i] = c
+			return
+		}
+	}
+	r.collectors = append(r.collectors, c)
+}
+
+// Names returns the names of the registered collectors, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		names = append(names, c.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetDisabled disables the collectors named in names, enabling all others. It
+// returns an error naming any collector that is not registered; the others
+// are disabled regardless.
+func (r *Registry) SetDisabled(names []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.disabled = make(map[string]bool)
+	unknown := []string{}
+	for _, name := range names {
+		r.disabled[name] = true
+		found := false
+		for _, c := range r.collectors {
+			if c.Name() == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown facts collectors: %v", unknown)
+	}
+	return nil
+}
+
+// Collect runs every enabled collector and merges the facts they gather. A
+// collector that fails is logged and skipped.
+func (r *Registry) Collect() map[string]interface{} {
+	r.mu.RLock()
+	collectors := make([]Collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		if !r.disabled[c.Name()] {
+			collectors = append(collectors, c)
+		}
+	}
+	r.mu.RUnlock()
+
+	facts := make(map[string]interface{})
+	for _, c := range collectors {
+		f, err := c.Collect()
+		if err != nil {
+			log.Warnf("cannot collect %v facts: %v", c.Name(), err)
+			continue
+		}
+		for k, v := range f {
+			facts[k] = v
+		}
+	}
+	return facts
+}
+
+// DefaultRegistry holds the built-in collectors and those added with Register.
+var DefaultRegistry = NewRegistry(Builtins()...)
+
+// Register adds c to DefaultRegistry.
+func Register(c Collector) {
+	DefaultRegistry.Register(c)
+}
@@ -0,0 +1,96 @@
+package facts
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRegistryCollect(t *testing.T) {
+	static := func(name string, facts map[string]interface{}) Collector {
+		return CollectorFunc(name, func() (map[string]interface{}, error) { return facts, nil })
+	}
+	failing := CollectorFunc("failing", func() (map[string]interface{}, error) {
+		return map[string]interface{}{"a": "failing"}, errors.New("failed")
+	})
+
+	tests := []struct {
+		description string
+		collectors  []Collector
+		disabled    []string
+		want        map[string]interface{}
+		wantError   bool
+	}{
+		{
+			description: "merged",
+			collectors: []Collector{
+				static("one", map[string]interface{}{"a": "one"}),
+				static("two", map[string]interface{}{"b": "two"}),
+			},
+			want: map[string]interface{}{"a": "one", "b": "two"},
+		},
+		{
+			description: "later takes precedence",
+			collectors: []Collector{
+				static("one", map[string]interface{}{"a": "one"}),
+				static("two", map[string]interface{}{"a": "two"}),
+			},
+			want: map[string]interface{}{"a": "two"},
+		},
+		{
+			description: "replaced",
+			collectors: []Collector{
+				static("one", map[string]interface{}{"a": "one"}),
+				static("two", map[string]interface{}{"a": "two"}),
+				static("one", map[string]interface{}{"a": "three"}),
+			},
+			want: map[string]interface{}{"a": "two"},
+		},
+		{
+			description: "disabled",
+			collectors: []Collector{
+				static("one", map[string]interface{}{"a": "one"}),
+				static("two", map[string]interface{}{"b": "two"}),
+			},
+			disabled: []string{"two"},
+			want:     map[string]interface{}{"a": "one"},
+		},
+		{
+			description: "disabled unknown",
+			collectors: []Collector{
+				static("one", map[string]interface{}{"a": "one"}),
+			},
+			disabled:  []string{"three"},
+			want:      map[string]interface{}{"a": "one"},
+			wantError: true,
+		},
+		{
+			description: "failed",
+			collectors: []Collector{
+				static("one", map[string]interface{}{"a": "one"}),
+				failing,
+			},
+			want: map[string]interface{}{"a": "one"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			r := NewRegistry(test.collectors...)
+			err := r.SetDisabled(test.disabled)
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			got := r.Collect()
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
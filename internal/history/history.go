@@ -0,0 +1,164 @@
+// Package history keeps a bounded record of the messages handled by the
+// dispatcher and their outcomes in a SQLite database.
+package history
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+//go:embed migrations/*.sql
+var embeddedMigrationData embed.FS
+
+// Entry records the outcome of handling a message.
+type Entry struct {
+	Time      time.Time
+	MessageID string
+	Directive string
+	Outcome   string
+	Detail    string
+	Size      int
+}
+
+// Map returns e as a string map, suitable for sending over D-Bus.
+func (e Entry) Map() map[string]string {
+	return map[string]string{
+		"time":       e.Time.Format(time.RFC3339Nano),
+		"message_id": e.MessageID,
+		"directive":  e.Directive,
+		"outcome":    e.Outcome,
+		"detail":     e.Detail,
+		"size":       fmt.Sprint(e.Size),
+	}
+}
+
+// Filter selects history entries. Zero-valued fields match every entry.
+type Filter struct {
+	MessageID string
+	Directive string
+	Since     time.Time
+	Until     time.Time
+}
+
+// History is a bounded record of message outcomes. Once it holds more than
+// its size, the oldest entries are removed.
+type History struct {
+	database *sql.DB
+	size     int
+}
+
+// Open opens the history database at databaseFilePath, creating it if
+// necessary, keeping at most size entries.
+func Open(databaseFilePath string, size int) (*History, error) {
+	db, err := sql.Open("sqlite3", databaseFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("database object not created: %w", err)
+	}
+	if err := migrateHistoryDB(db, databaseFilePath); err != nil {
+		return nil, fmt.Errorf("database migration error: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("history database not connected: %w", err)
+	}
+	return &History{database: db, size: size}, nil
+}
+
+// migrateHistoryDB ensures the history database schema is up to date.
+func migrateHistoryDB(db *sql.DB, databaseFilePath string) error {
+	databaseDriver, err := sqlite3.WithInstance(db, &sqlite3.Config{})
+	if err != nil {
+		return fmt.Errorf("database driver not initialized: %w", err)
+	}
+	migrationDriver, err := iofs.New(embeddedMigrationData, "migrations")
+	if err != nil {
+		return fmt.Errorf("embedded migration data not found: %w", err)
+	}
+	migration, err := migrate.NewWithInstance(
+		"iofs",
+		migrationDriver,
+		databaseFilePath,
+		databaseDriver,
+	)
+	if err != nil {
+		return fmt.Errorf("database migration not initialized: %w", err)
+	}
+	if err := migration.Up(); err != nil && err != migrate.ErrNoChange {
+		return fmt.Errorf("database migration failed: %w", err)
+	}
+	return nil
+}
+
+// Add records e, removing the oldest entries beyond the history size.
+func (h *History) Add(e Entry) error {
+	result, err := h.database.Exec(
+		`INSERT INTO history (time, message_id, directive, outcome, detail, size) VALUES (?,?,?,?,?,?)`,
+		e.Time.UTC(),
+		e.MessageID,
+		e.Directive,
+		e.Outcome,
+		e.Detail,
+		e.Size,
+	)
+	if err != nil {
+		return fmt.Errorf("cannot insert history entry: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("cannot select last insert ID: %w", err)
+	}
+	if _, err := h.database.Exec(`DELETE FROM history WHERE id <= ?`, id-int64(h.size)); err != nil {
+		return fmt.Errorf("cannot remove old history entries: %w", err)
+	}
+	return nil
+}
+
+// Entries returns the entries matching filter, oldest first.
+func (h *History) Entries(filter Filter) ([]Entry, error) {
+	query := `SELECT time, message_id, directive, outcome, detail, size FROM history WHERE 1=1`
+	args := []interface{}{}
+	if filter.MessageID != "" {
+		query += ` AND message_id = ?`
+		args = append(args, filter.MessageID)
+	}
+	if filter.Directive != "" {
+		query += ` AND directive = ?`
+		args = append(args, filter.Directive)
+	}
+	if !filter.Since.IsZero() {
+		query += ` AND time >= ?`
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		query += ` AND time <= ?`
+		args = append(args, filter.Until.UTC())
+	}
+	query += ` ORDER BY id`
+
+	rows, err := h.database.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query history entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		var detail sql.NullString
+		if err := rows.Scan(&e.Time, &e.MessageID, &e.Directive, &e.Outcome, &detail, &e.Size); err != nil {
+			return nil, fmt.Errorf("cannot scan history entry: %w", err)
+		}
+		e.Detail = detail.String
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("cannot iterate history entries: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,79 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEntries(t *testing.T) {
+	start := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: start, MessageID: "1", Directive: "echo", Outcome: "dispatched", Size: 4},
+		{Time: start.Add(time.Minute), MessageID: "1", Directive: "echo", Outcome: "response", Detail: "2", Size: 4},
+		{Time: start.Add(2 * time.Minute), MessageID: "3", Directive: "test", Outcome: "failed", Detail: "no such worker", Size: 0},
+	}
+
+	tests := []struct {
+		description string
+		size        int
+		input       Filter
+		want        []Entry
+	}{
+		{
+			description: "unfiltered",
+			size:        10,
+			input:       Filter{},
+			want:        entries,
+		},
+		{
+			description: "directive",
+			size:        10,
+			input:       Filter{Directive: "echo"},
+			want:        entries[:2],
+		},
+		{
+			description: "message id",
+			size:        10,
+			input:       Filter{MessageID: "3"},
+			want:        entries[2:],
+		},
+		{
+			description: "time range",
+			size:        10,
+			input:       Filter{Since: start.Add(time.Minute), Until: start.Add(time.Minute)},
+			want:        entries[1:2],
+		},
+		{
+			description: "bounded",
+			size:        2,
+			input:       Filter{},
+			want:        entries[1:],
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			h, err := Open(filepath.Join(t.TempDir(), "history.db"), test.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range entries {
+				if err := h.Add(e); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := h.Entries(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS history;
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS history (
+    id INTEGER NOT NULL PRIMARY KEY,
+    time DATETIME NOT NULL,
+    message_id TEXT NOT NULL,
+    directive TEXT NOT NULL,
+    outcome TEXT NOT NULL,
+    detail TEXT,
+    size INTEGER NOT NULL
+);
@@ -0,0 +1,176 @@
+// Package hook runs programs configured by the administrator when the
+// transport connects to or disconnects from the server, so that local
+// actions, such as changing firewall rules or alerting, can follow the
+// connection state.
+//
+// A hook program is invoked with "connect" or "disconnect" as its only
+// argument. The details of the event are passed in the environment:
+//
+//	YGG_EVENT      "connect" or "disconnect"
+//	YGG_REASON     why the state changed: "connected", "lost", "requested"
+//	               or "shutdown"
+//	YGG_TIME       when the state changed, in RFC 3339 format
+//	YGG_PROTOCOL   the transport protocol
+//	YGG_SERVER     the configured servers, separated by commas
+//	YGG_CLIENT_ID  the client ID
+package hook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+)
+
+// queueSize is the number of events that can wait for a hook program to
+// finish before further events are dropped.
+const queueSize = 16
+
+// Reasons the connection state changed.
+const (
+	ReasonConnected = "connected"
+	ReasonLost      = "lost"
+	ReasonRequested = "requested"
+	ReasonShutdown  = "shutdown"
+)
+
+// Event is a change of the transport's connection state.
+type Event struct {
+	Connected bool
+	Reason    string
+	Time      time.Time
+	Protocol  string
+	Servers   []string
+	ClientID  string
+}
+
+// name returns "connect" or "disconnect".
+func (e Event) name() string {
+	if e.Connected {
+		return "connect"
+	}
+	return "disconnect"
+}
+
+// environment returns the environment of a hook program run for e.
+func (e Event) environment() []string {
+	return append(os.Environ(),
+		"YGG_EVENT="+e.name(),
+		"YGG_REASON="+e.Reason,
+		"YGG_TIME="+e.Time.Format(time.RFC3339),
+		"YGG_PROTOCOL="+e.Protocol,
+		"YGG_SERVER="+strings.Join(e.Servers, ","),
+		"YGG_CLIENT_ID="+e.ClientID,
+	)
+}
+
+// ConnectionHooks runs the programs configured for connection state changes.
+// Programs run one at a time, in the order the events occurred, without
+// delaying the caller.
+type ConnectionHooks struct {
+	onConnect    string
+	onDisconnect string
+	timeout      time.Duration
+	queue        chan Event
+	pending      sync.WaitGroup
+}
+
+// NewConnectionHooks creates ConnectionHooks running onConnect when the
+// transport connects and onDisconnect when it disconnects, killing a
+// program that runs longer than timeout. Either path may be empty.
+func NewConnectionHooks(onConnect, onDisconnect string, timeout time.Duration) *ConnectionHooks {
+	h := ConnectionHooks{
+		onConnect:    onConnect,
+		onDisconnect: onDisconnect,
+		timeout:      timeout,
+		queue:        make(chan Event, queueSize),
+	}
+	go func() {
+		for e := range h.queue {
+			if err := h.run(e); err != nil {
+				log.Warnf("cannot run %v hook: %v", e.name(), err)
+			}
+			h.pending.Done()
+		}
+	}()
+	return &h
+}
+
+// Notify queues the program configured for e to run. If no program is
+// configured for e, Notify does nothing.
+func (h *ConnectionHooks) Notify(e Event) {
+	if h.path(e) == "" {
+		return
+	}
+	h.pending.Add(1)
+	select {
+	case h.queue <- e:
+	default:
+		h.pending.Done()
+		log.Warnf("dropping %v hook: too many hooks waiting to run", e.name())
+	}
+}
+
+// Wait waits up to timeout for the queued programs to finish. It returns
+// false if they did not finish in time.
+func (h *ConnectionHooks) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		h.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// path returns the program configured for e.
+func (h *ConnectionHooks) path(e Event) string {
+	if e.Connected {
+		return h.onConnect
+	}
+	return h.onDisconnect
+}
+
+// run runs the program configured for e and waits for it to finish.
+func (h *ConnectionHooks) run(e Event) error {
+	ctx := context.Background()
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, h.path(e), e.name())
+	cmd.Env = e.environment()
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// Run the program in its own process group, so that processes it starts
+	// are killed with it when it times out.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+
+	log.Debugf("running %v hook %v: %v", e.name(), cmd.Path, e.Reason)
+	err := cmd.Run()
+	if output.Len() > 0 {
+		log.Infof("%v hook: %v", e.name(), strings.TrimSpace(output.String()))
+	}
+	if err != nil {
+		return fmt.Errorf("%v: %w", cmd.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package hook
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// writeScript writes an executable shell script with body to dir.
+func writeScript(t *testing.T, dir, name, body string) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	script := writeScript(t, dir, "hook",
+		`echo "$1 $YGG_EVENT $YGG_REASON $YGG_TIME $YGG_PROTOCOL $YGG_SERVER $YGG_CLIENT_ID" >> `+out+"\n")
+
+	tests := []struct {
+		description string
+		hooks       *ConnectionHooks
+		input       Event
+		want        string
+		wantError   bool
+	}{
+		{
+			description: "connect",
+			hooks:       &ConnectionHooks{onConnect: script},
+			input: Event{
+				Connected: true,
+				Reason:    ReasonConnected,
+				Time:      time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				Protocol:  "mqtt",
+				Servers:   []string{"mqtts://a", "mqtts://b"},
+				ClientID:  "abc",
+			},
+			want: "connect connect connected 2024-01-02T03:04:05Z mqtt mqtts://a,mqtts://b abc\n",
+		},
+		{
+			description: "disconnect",
+			hooks:       &ConnectionHooks{onDisconnect: script},
+			input: Event{
+				Reason:   ReasonLost,
+				Time:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				Protocol: "http",
+				Servers:  []string{"example.com"},
+				ClientID: "abc",
+			},
+			want: "disconnect disconnect lost 2024-01-02T03:04:05Z http example.com abc\n",
+		},
+		{
+			description: "failure",
+			hooks:       &ConnectionHooks{onConnect: writeScript(t, dir, "fail", "exit 1\n")},
+			input:       Event{Connected: true},
+			wantError:   true,
+		},
+		{
+			description: "timeout",
+			hooks:       &ConnectionHooks{onConnect: writeScript(t, dir, "slow", "sleep 10\n"), timeout: 100 * time.Millisecond},
+			input:       Event{Connected: true},
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			_ = os.Remove(out)
+			err := test.hooks.run(test.input)
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(string(got), test.want) {
+				t.Errorf("%v", cmp.Diff(string(got), test.want))
+			}
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	script := writeScript(t, dir, "hook", `echo "$YGG_EVENT $YGG_REASON" >> `+out+"\n")
+
+	hooks := NewConnectionHooks(script, script, time.Minute)
+	hooks.Notify(Event{Connected: true, Reason: ReasonConnected})
+	hooks.Notify(Event{Reason: ReasonLost})
+	hooks.Notify(Event{Connected: true, Reason: ReasonConnected})
+	hooks.Notify(Event{Reason: ReasonShutdown})
+
+	if !hooks.Wait(5 * time.Second) {
+		t.Fatal("timed out waiting for hooks")
+	}
+
+	want := "connect connected\ndisconnect lost\nconnect connected\ndisconnect shutdown\n"
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(string(got), want) {
+		t.Errorf("%v", cmp.Diff(string(got), want))
+	}
+}
@@ -21,6 +21,29 @@ type Client struct {
 	// Retries is the number of times the client will attempt to resend failed
 	// HTTP requests before giving up.
 	Retries int
+
+	// Headers are added to every request, replacing any header of the same
+	// name given for the request.
+	Headers http.Header
+
+	// Tokens, if set, provides a bearer token sent in the Authorization
+	// header of every request.
+	Tokens TokenSource
+
+	// Negotiator, if set, sets the Authorization header of every request for
+	// Negotiate authentication.
+	Negotiator Negotiator
+}
+
+// TokenSource provides bearer tokens.
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// Negotiator sets the Authorization header of requests for Negotiate
+// (SPNEGO) authentication.
+type Negotiator interface {
+	Negotiate(req *http.Request) error
 }
 
 // NewHTTPClient creates a client with the given TLS configuration and
@@ -39,14 +62,25 @@ func NewHTTPClient(config *tls.Config, ua string) *Client {
 }
 
 func (c *Client) Get(url string) (*http.Response, error) {
+	return c.GetWithHeaders(url, nil)
+}
+
+// GetWithHeaders sends a GET request to url with the given headers in
+// addition to the configured ones.
+func (c *Client) GetWithHeaders(url string, headers map[string]string) (*http.Response, error) {
 	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create HTTP request: %w", err)
 	}
-	req.Header.Add("User-Agent", c.userAgent)
+
+	for k, v := range headers {
+		req.Header.Add(k, strings.TrimSpace(v))
+	}
+	if err := c.setHeaders(req); err != nil {
+		return nil, err
+	}
 
 	log.Debugf("sending HTTP request: %v %v", req.Method, req.URL)
-	log.Tracef("request: %v", req)
 
 	return c.Do(req)
 }
@@ -60,14 +94,37 @@ func (c *Client) Post(url string, headers map[string]string, body []byte) (*http
 	for k, v := range headers {
 		req.Header.Add(k, strings.TrimSpace(v))
 	}
-	req.Header.Add("User-Agent", c.userAgent)
+	if err := c.setHeaders(req); err != nil {
+		return nil, err
+	}
 
 	log.Debugf("sending HTTP request: %v %v", req.Method, req.URL)
-	log.Tracef("request: %v", req)
 
 	return c.Do(req)
 }
 
+// setHeaders sets the User-Agent header, the configured headers and, if
+// there is a token source or a negotiator, the Authorization header of req.
+func (c *Client) setHeaders(req *http.Request) error {
+	req.Header.Set("User-Agent", c.userAgent)
+	for k, v := range c.Headers {
+		req.Header[k] = v
+	}
+	if c.Tokens != nil {
+		token, err := c.Tokens.Token()
+		if err != nil {
+			return fmt.Errorf("cannot get access token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if c.Negotiator != nil {
+		if err := c.Negotiator.Negotiate(req); err != nil {
+			return fmt.Errorf("cannot negotiate authentication: %w", err)
+		}
+	}
+	return nil
+}
+
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
 	var resp *http.Response
 	var err error
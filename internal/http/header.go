@@ -0,0 +1,59 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/redhatinsights/yggdrasil/internal/config"
+)
+
+// reservedHeaders are the headers that cannot be configured, because they
+// are set by the client or describe the request body.
+var reservedHeaders = map[string]bool{
+	"Host":              true,
+	"User-Agent":        true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+}
+
+// ParseHeaders parses a list of "NAME: VALUE" entries into headers added to
+// every request. Values may reference secrets, as configuration values do.
+func ParseHeaders(entries []string) (http.Header, error) {
+	headers := make(http.Header, len(entries))
+	for _, entry := range entries {
+		name, value, ok := strings.Cut(entry, ":")
+		name = strings.TrimSpace(name)
+		if !ok || !validHeaderName(name) {
+			return nil, fmt.Errorf("invalid HTTP header '%v': expected NAME: VALUE", entry)
+		}
+		name = textproto.CanonicalMIMEHeaderKey(name)
+		if reservedHeaders[name] {
+			return nil, fmt.Errorf("HTTP header '%v' cannot be set", name)
+		}
+		value, err := config.ResolveSecret(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve HTTP header '%v': %w", name, err)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("invalid value for HTTP header '%v'", name)
+		}
+		headers.Add(name, value)
+	}
+	return headers, nil
+}
+
+// validHeaderName returns true if name is a non-empty HTTP token.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r > 0x7e || r <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return true
+}
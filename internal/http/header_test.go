@@ -0,0 +1,147 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseHeaders(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "yggdrasil.api-key"), []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+
+	tests := []struct {
+		description string
+		input       []string
+		want        http.Header
+		wantError   bool
+	}{
+		{
+			description: "empty",
+			input:       nil,
+			want:        http.Header{},
+		},
+		{
+			description: "headers",
+			input:       []string{"x-tenant-id: 1234", "X-Route: a", "X-Route:b"},
+			want:        http.Header{"X-Tenant-Id": {"1234"}, "X-Route": {"a", "b"}},
+		},
+		{
+			description: "secret",
+			input:       []string{"X-Api-Key: secret:api-key"},
+			want:        http.Header{"X-Api-Key": {"s3cret"}},
+		},
+		{
+			description: "missing secret",
+			input:       []string{"X-Api-Key: secret:missing"},
+			wantError:   true,
+		},
+		{
+			description: "missing separator",
+			input:       []string{"X-Tenant-Id 1234"},
+			wantError:   true,
+		},
+		{
+			description: "invalid name",
+			input:       []string{"X Tenant: 1234"},
+			wantError:   true,
+		},
+		{
+			description: "reserved",
+			input:       []string{"user-agent: curl"},
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got, err := ParseHeaders(test.input)
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
+
+// staticToken is a TokenSource always providing the same token.
+type staticToken string
+
+func (t staticToken) Token() (string, error) {
+	return string(t), nil
+}
+
+func TestClientHeaders(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(nil, "test/1.0")
+	client.Headers = http.Header{"X-Tenant-Id": {"1234"}}
+	client.Tokens = staticToken("t0k3n")
+	resp, err := client.Post(srv.URL, map[string]string{"X-Tenant-Id": "5678", "X-Other": "a"}, []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	for name, want := range map[string]string{"User-Agent": "test/1.0", "X-Tenant-Id": "1234", "X-Other": "a", "Authorization": "Bearer t0k3n"} {
+		if v := got.Get(name); v != want {
+			t.Errorf("%v: got %v, want %v", name, v, want)
+		}
+	}
+}
+
+// negotiatorFunc is a Negotiator calling itself.
+type negotiatorFunc func(req *http.Request) error
+
+func (f negotiatorFunc) Negotiate(req *http.Request) error {
+	return f(req)
+}
+
+func TestClientNegotiator(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	client := NewHTTPClient(nil, "test/1.0")
+	client.Negotiator = negotiatorFunc(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Negotiate "+req.URL.Hostname())
+		return nil
+	})
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want := "Negotiate 127.0.0.1"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	client.Negotiator = negotiatorFunc(func(req *http.Request) error {
+		return errors.New("no ticket")
+	})
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,246 @@
+// Package jsonschema validates JSON values against a subset of JSON Schema
+// (draft 2020-12). The supported keywords are "type", "enum", "const",
+// "properties", "required", "additionalProperties", "items", "minItems",
+// "maxItems", "minLength", "maxLength", "pattern", "minimum" and "maximum".
+// Other keywords are ignored.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a parsed JSON Schema.
+type Schema struct {
+	Type                 typeList           `json:"type"`
+	Enum                 []interface{}      `json:"enum"`
+	Const                *interface{}       `json:"const"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Pattern              string             `json:"pattern"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+
+	pattern *regexp.Regexp
+}
+
+// typeList holds the value of the "type" keyword, which may be a single type
+// name or a list of type names.
+type typeList []string
+
+func (t *typeList) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = typeList{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return fmt.Errorf("cannot unmarshal type: %w", err)
+	}
+	*t = names
+	return nil
+}
+
+// Parse parses data as a JSON Schema.
+func Parse(data []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal schema: %w", err)
+	}
+	if err := s.compile(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Load reads and parses the JSON Schema in file.
+func Load(file string) (*Schema, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read schema: %w", err)
+	}
+	return Parse(data)
+}
+
+// compile prepares s and its subschemas for validation.
+func (s *Schema) compile() error {
+	if s.Pattern != "" {
+		r, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("cannot compile pattern: %w", err)
+		}
+		s.pattern = r
+	}
+	for _, p := range s.Properties {
+		if err := p.compile(); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.compile()
+	}
+	return nil
+}
+
+// ValidationError lists the ways a value fails to match a schema.
+type ValidationError struct {
+	Errors []string
+}
+
+func (e *ValidationError) Error() string {
+	return strings.Join(e.Errors, "; ")
+}
+
+// Validate checks that the JSON document data matches s. If it does not, the
+// returned error is a *ValidationError.
+func (s *Schema) Validate(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return &ValidationError{Errors: []string{fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+	var errs []string
+	s.validate("", v, &errs)
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// validate appends to errs a description of each way v, found at the JSON
+// pointer path, fails to match s.
+func (s *Schema) validate(path string, v interface{}, errs *[]string) {
+	fail := func(format string, args ...interface{}) {
+		location := path
+		if location == "" {
+			location = "/"
+		}
+		*errs = append(*errs, location+": "+fmt.Sprintf(format, args...))
+	}
+
+	if len(s.Type) > 0 && !matchesType(s.Type, v) {
+		fail("expected %v, got %v", strings.Join(s.Type, " or "), typeName(v))
+		return
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("value is not one of the allowed values")
+		}
+	}
+	if s.Const != nil && !reflect.DeepEqual(*s.Const, v) {
+		fail("value does not equal the constant value")
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, has := v[name]; !has {
+				fail("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if p, has := s.Properties[name]; has {
+				p.validate(path+"/"+escapePointer(name), v[name], errs)
+			} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				fail("unexpected property %q", name)
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			fail("expected at least %v items, got %v", *s.MinItems, len(v))
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			fail("expected at most %v items, got %v", *s.MaxItems, len(v))
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%v/%v", path, i), item, errs)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.MinLength != nil && length < *s.MinLength {
+			fail("expected at least %v characters, got %v", *s.MinLength, length)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			fail("expected at most %v characters, got %v", *s.MaxLength, length)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("value does not match pattern %q", s.Pattern)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			fail("expected a value of at least %v, got %v", *s.Minimum, v)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			fail("expected a value of at most %v, got %v", *s.Maximum, v)
+		}
+	}
+}
+
+// matchesType returns true if v is of one of the JSON Schema types.
+func matchesType(types []string, v interface{}) bool {
+	for _, t := range types {
+		switch name := typeName(v); {
+		case t == name:
+			return true
+		case t == "number" && name == "integer":
+			return true
+		}
+	}
+	return false
+}
+
+// typeName returns the JSON Schema type name of v, reporting numbers without
+// a fractional part as "integer".
+func typeName(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// escapePointer escapes name for use as a JSON pointer reference token.
+func escapePointer(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
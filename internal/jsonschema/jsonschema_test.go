@@ -0,0 +1,129 @@
+package jsonschema
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidate(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"required": ["command", "timeout"],
+		"additionalProperties": false,
+		"properties": {
+			"command": {"type": "string", "enum": ["install", "remove"]},
+			"timeout": {"type": "integer", "minimum": 1, "maximum": 3600},
+			"packages": {
+				"type": "array",
+				"minItems": 1,
+				"items": {"type": "string", "pattern": "^[a-z0-9-]+$"}
+			}
+		}
+	}`
+
+	tests := []struct {
+		description string
+		input       string
+		want        []string
+	}{
+		{
+			description: "valid",
+			input:       `{"command": "install", "timeout": 60, "packages": ["vim"]}`,
+		},
+		{
+			description: "missing required",
+			input:       `{"command": "install"}`,
+			want:        []string{`/: missing required property "timeout"`},
+		},
+		{
+			description: "wrong types",
+			input:       `{"command": 1, "timeout": 1.5}`,
+			want: []string{
+				"/command: expected string, got integer",
+				"/timeout: expected integer, got number",
+			},
+		},
+		{
+			description: "constraints",
+			input:       `{"command": "upgrade", "timeout": 0, "packages": ["Vim"], "force": true}`,
+			want: []string{
+				"/command: value is not one of the allowed values",
+				`/: unexpected property "force"`,
+				`/packages/0: value does not match pattern "^[a-z0-9-]+$"`,
+				"/timeout: expected a value of at least 1, got 0",
+			},
+		},
+		{
+			description: "not an object",
+			input:       `"install"`,
+			want:        []string{"/: expected object, got string"},
+		},
+		{
+			description: "invalid JSON",
+			input:       `{`,
+			want:        []string{"invalid JSON: unexpected end of JSON input"},
+		},
+	}
+
+	s, err := Parse([]byte(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			err := s.Validate([]byte(test.input))
+
+			var got []string
+			if err != nil {
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) {
+					t.Fatalf("unexpected error type %T", err)
+				}
+				got = validationErr.Errors
+			}
+
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		wantError   bool
+	}{
+		{
+			description: "type list",
+			input:       `{"type": ["string", "null"]}`,
+		},
+		{
+			description: "invalid pattern",
+			input:       `{"properties": {"a": {"pattern": "("}}}`,
+			wantError:   true,
+		},
+		{
+			description: "invalid type",
+			input:       `{"type": 1}`,
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			_, err := Parse([]byte(test.input))
+
+			if test.wantError && err == nil {
+				t.Error("expected error, got nil")
+			}
+			if !test.wantError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
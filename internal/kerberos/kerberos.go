@@ -0,0 +1,155 @@
+// Package kerberos authenticates HTTP requests with Kerberos, sending SPNEGO
+// tokens in the Authorization header ("Negotiate" authentication, RFC 4559).
+//
+// Tickets are obtained with the keys of a keytab, usually the host keytab
+// maintained when the host is joined to a realm or an Active Directory domain.
+package kerberos
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/jcmturner/gokrb5/v8/client"
+	krb5config "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+)
+
+// Config describes the keytab and principal tickets are obtained with.
+type Config struct {
+	// Keytab is the keytab file holding the keys of Principal.
+	Keytab string
+
+	// Principal is the client principal, as "name@REALM" or as "name" in the
+	// default realm. If empty, the first principal in Keytab is used.
+	Principal string
+
+	// Krb5Conf is the Kerberos configuration file locating the KDCs.
+	Krb5Conf string
+}
+
+// Validate checks that the Kerberos configuration can be loaded and that the
+// keytab holds keys for the principal.
+func (conf *Config) Validate() error {
+	_, err := conf.newClient()
+	return err
+}
+
+// newClient creates a Kerberos client logging in with the keys in the keytab.
+func (conf *Config) newClient() (*client.Client, error) {
+	krb5conf, err := krb5config.Load(conf.Krb5Conf)
+	if err != nil && !errors.As(err, &krb5config.UnsupportedDirective{}) {
+		return nil, fmt.Errorf("cannot load Kerberos configuration: %w", err)
+	}
+	kt, err := keytab.Load(conf.Keytab)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load keytab '%v': %w", conf.Keytab, err)
+	}
+	name, realm, err := conf.principal(kt, krb5conf.LibDefaults.DefaultRealm)
+	if err != nil {
+		return nil, err
+	}
+	// Active Directory does not support FAST, which gokrb5 does not
+	// implement either.
+	return client.NewWithKeytab(name, realm, kt, krb5conf, client.DisablePAFXFAST(true)), nil
+}
+
+// principal returns the name and realm of the configured principal, checking
+// that kt holds keys for it.
+func (conf *Config) principal(kt *keytab.Keytab, defaultRealm string) (string, string, error) {
+	if conf.Principal == "" {
+		if len(kt.Entries) == 0 {
+			return "", "", fmt.Errorf("keytab '%v' is empty", conf.Keytab)
+		}
+		p := kt.Entries[0].Principal
+		return strings.Join(p.Components, "/"), p.Realm, nil
+	}
+
+	name, realm := conf.Principal, defaultRealm
+	if i := strings.LastIndex(conf.Principal, "@"); i >= 0 {
+		name, realm = conf.Principal[:i], conf.Principal[i+1:]
+	}
+	for _, entry := range kt.Entries {
+		if strings.Join(entry.Principal.Components, "/") == name && entry.Principal.Realm == realm {
+			return name, realm, nil
+		}
+	}
+	return "", "", fmt.Errorf("keytab '%v' holds no keys for '%v@%v'", conf.Keytab, name, realm)
+}
+
+// Negotiator sets SPNEGO tokens in the Authorization header of HTTP
+// requests.
+type Negotiator struct {
+	conf Config
+
+	lock    sync.Mutex
+	client  *client.Client
+	modTime time.Time
+}
+
+// NewNegotiator creates a Negotiator obtaining tickets as described by conf.
+func NewNegotiator(conf Config) (*Negotiator, error) {
+	n := &Negotiator{conf: conf}
+	if _, err := n.currentClient(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Negotiate sets the Authorization header of req to a SPNEGO token for the
+// service principal "HTTP/host", host being the host req is sent to.
+func (n *Negotiator) Negotiate(req *http.Request) error {
+	cl, err := n.currentClient()
+	if err != nil {
+		return err
+	}
+	s := spnego.SPNEGOClient(cl, "HTTP/"+req.URL.Hostname())
+	if err := s.AcquireCred(); err != nil {
+		return fmt.Errorf("cannot log in: %w", err)
+	}
+	token, err := s.InitSecContext()
+	if err != nil {
+		return fmt.Errorf("cannot get service ticket: %w", err)
+	}
+	data, err := token.Marshal()
+	if err != nil {
+		return fmt.Errorf("cannot marshal SPNEGO token: %w", err)
+	}
+	req.Header.Set("Authorization", "Negotiate "+base64.StdEncoding.EncodeToString(data))
+	return nil
+}
+
+// currentClient returns the Kerberos client, replacing it if the keytab has
+// been modified since it was created. Keys in a host keytab change whenever
+// the machine account password is rotated.
+func (n *Negotiator) currentClient() (*client.Client, error) {
+	info, err := os.Stat(n.conf.Keytab)
+	if err != nil {
+		return nil, fmt.Errorf("cannot stat keytab: %w", err)
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.client != nil && info.ModTime().Equal(n.modTime) {
+		return n.client, nil
+	}
+	cl, err := n.conf.newClient()
+	if err != nil {
+		return nil, err
+	}
+	if n.client != nil {
+		log.Infof("keytab '%v' modified, logging in again", n.conf.Keytab)
+		n.client.Destroy()
+	}
+	n.client = cl
+	n.modTime = info.ModTime()
+	return cl, nil
+}
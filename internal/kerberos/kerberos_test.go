@@ -0,0 +1,114 @@
+package kerberos
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/keytab"
+)
+
+const krb5Conf = `[libdefaults]
+  default_realm = EXAMPLE.COM
+
+[realms]
+  EXAMPLE.COM = {
+    kdc = kdc.example.com
+  }
+`
+
+// writeKeytab writes a keytab holding keys for principals in EXAMPLE.COM.
+func writeKeytab(t *testing.T, principals ...string) string {
+	kt := keytab.New()
+	for _, p := range principals {
+		if err := kt.AddEntry(p, "EXAMPLE.COM", "s3cret", time.Now(), 1, 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := kt.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "krb5.keytab")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigPrincipal(t *testing.T) {
+	krb5ConfPath := filepath.Join(t.TempDir(), "krb5.conf")
+	if err := os.WriteFile(krb5ConfPath, []byte(krb5Conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+	keytabPath := writeKeytab(t, "NODE$", "host/node.example.com")
+
+	tests := []struct {
+		description string
+		input       Config
+		wantName    string
+		wantRealm   string
+		wantError   bool
+	}{
+		{
+			description: "first principal",
+			input:       Config{Keytab: keytabPath, Krb5Conf: krb5ConfPath},
+			wantName:    "NODE$",
+			wantRealm:   "EXAMPLE.COM",
+		},
+		{
+			description: "default realm",
+			input:       Config{Keytab: keytabPath, Principal: "host/node.example.com", Krb5Conf: krb5ConfPath},
+			wantName:    "host/node.example.com",
+			wantRealm:   "EXAMPLE.COM",
+		},
+		{
+			description: "explicit realm",
+			input:       Config{Keytab: keytabPath, Principal: "NODE$@EXAMPLE.COM", Krb5Conf: krb5ConfPath},
+			wantName:    "NODE$",
+			wantRealm:   "EXAMPLE.COM",
+		},
+		{
+			description: "no keys",
+			input:       Config{Keytab: keytabPath, Principal: "NODE$@OTHER.COM", Krb5Conf: krb5ConfPath},
+			wantError:   true,
+		},
+		{
+			description: "empty keytab",
+			input:       Config{Keytab: writeKeytab(t), Krb5Conf: krb5ConfPath},
+			wantError:   true,
+		},
+		{
+			description: "missing keytab",
+			input:       Config{Keytab: filepath.Join(t.TempDir(), "missing"), Krb5Conf: krb5ConfPath},
+			wantError:   true,
+		},
+		{
+			description: "missing configuration",
+			input:       Config{Keytab: keytabPath, Krb5Conf: filepath.Join(t.TempDir(), "missing")},
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			cl, err := test.input.newClient()
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if name := cl.Credentials.UserName(); name != test.wantName {
+				t.Errorf("name: %v != %v", name, test.wantName)
+			}
+			if realm := cl.Credentials.Realm(); realm != test.wantRealm {
+				t.Errorf("realm: %v != %v", realm, test.wantRealm)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"fmt"
+	stdlog "log"
+	"runtime"
+	"strings"
+
+	"git.sr.ht/~spc/go-log"
+)
+
+// Fields logs lines concerning a worker and, optionally, a message. When logs
+// are written as JSON, the worker and message ID are set as fields of each
+// line; otherwise lines are logged as by the go-log package functions.
+type Fields struct {
+	Worker    string
+	MessageID string
+}
+
+// With returns Fields logging lines concerning worker and the message
+// identified by messageID, which may be empty.
+func With(worker, messageID string) Fields {
+	return Fields{Worker: worker, MessageID: messageID}
+}
+
+// Errorf logs a line at the error level. Arguments are handled in the manner
+// of fmt.Printf.
+func (f Fields) Errorf(format string, v ...interface{}) {
+	f.output(log.LevelError, format, v...)
+}
+
+// Warnf logs a line at the warn level. Arguments are handled in the manner of
+// fmt.Printf.
+func (f Fields) Warnf(format string, v ...interface{}) {
+	f.output(log.LevelWarn, format, v...)
+}
+
+// Infof logs a line at the info level. Arguments are handled in the manner of
+// fmt.Printf.
+func (f Fields) Infof(format string, v ...interface{}) {
+	f.output(log.LevelInfo, format, v...)
+}
+
+// Debugf logs a line at the debug level. Arguments are handled in the manner
+// of fmt.Printf.
+func (f Fields) Debugf(format string, v ...interface{}) {
+	f.output(log.LevelDebug, format, v...)
+}
+
+// output logs a line at level if the current log level allows it. It must be
+// called directly by the exported methods, so that the caller of those
+// methods is reported as the source of the line.
+func (f Fields) output(level log.Level, format string, v ...interface{}) {
+	if log.CurrentLevel() < level {
+		return
+	}
+	message := fmt.Sprintf(format, v...)
+
+	if w, ok := log.Writer().(*JSONWriter); ok {
+		e := Entry{Level: strings.ToLower(log.FormatLevel(level)), Worker: f.Worker, MessageID: f.MessageID, Message: message}
+		if pc, file, line, ok := runtime.Caller(2); ok {
+			e.Component, e.Caller = w.source(runtime.FuncForPC(pc).Name(), file, line)
+		}
+		_ = w.write(e)
+		return
+	}
+	_ = stdlog.New(log.Writer(), log.Prefix(), log.Flags()).Output(3, message)
+}
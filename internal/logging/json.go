@@ -0,0 +1,136 @@
+// Package logging formats log output for consumption by log pipelines.
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logPackage is the import path of the logging library whose functions name
+// the level of each line.
+const logPackage = "git.sr.ht/~spc/go-log"
+
+// Entry is a single log line in JSON format.
+type Entry struct {
+	Time      string `json:"ts"`
+	Level     string `json:"level"`
+	Component string `json:"component"`
+	Worker    string `json:"worker,omitempty"`
+	MessageID string `json:"message_id,omitempty"`
+	Caller    string `json:"caller,omitempty"`
+	Message   string `json:"message"`
+}
+
+// JSONWriter is an io.Writer that reformats each line written by the standard
+// library log package as a JSON object. It is meant to be set as the output of
+// a logger with no prefix and no flags.
+//
+// The level of a line is the name of the logging function that wrote it, such
+// as "error" for Errorf, and its component is the package that called that
+// function; lines written by other loggers have the level "info". Only lines
+// logged through Fields carry a worker and message ID.
+type JSONWriter struct {
+	mu  sync.Mutex
+	out io.Writer
+	app string
+}
+
+// NewJSONWriter creates a JSONWriter writing to out. Lines logged by the main
+// package are attributed to the component app.
+func NewJSONWriter(out io.Writer, app string) *JSONWriter {
+	return &JSONWriter{out: out, app: app}
+}
+
+// Write writes p, a single log line, to the underlying writer as JSON.
+func (w *JSONWriter) Write(p []byte) (int, error) {
+	e := Entry{Message: strings.TrimSuffix(string(p), "\n")}
+	e.Level, e.Component, e.Caller = w.caller()
+	if err := w.write(e); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// write writes e, stamped with the current time, to the underlying writer.
+func (w *JSONWriter) write(e Entry) error {
+	e.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.out.Write(data)
+	return err
+}
+
+// source returns the component and source location of a line logged by the
+// function named function at file:line.
+func (w *JSONWriter) source(function, file string, line int) (component, caller string) {
+	component = w.app
+	if pkg, _ := splitFunction(function); pkg != "main" {
+		component = path.Base(pkg)
+	}
+	return component, fmt.Sprintf("%v:%v", path.Base(file), line)
+}
+
+// caller walks the stack to find the logging function that wrote the current
+// line and the function that called it, returning the level, component and
+// source location of the line.
+func (w *JSONWriter) caller() (level, component, caller string) {
+	level = "info"
+	component = w.app
+
+	pc := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pc[:runtime.Callers(3, pc)])
+	found := false
+	for {
+		frame, more := frames.Next()
+		pkg, fn := splitFunction(frame.Function)
+		if pkg == logPackage {
+			level = levelName(fn)
+			found = true
+		} else if found {
+			component, caller = w.source(frame.Function, frame.File, frame.Line)
+			break
+		}
+		if !more {
+			break
+		}
+	}
+	return level, component, caller
+}
+
+// splitFunction splits a fully qualified function name, such as
+// "example.com/pkg.(*T).Method", into its package path and function name.
+func splitFunction(name string) (pkg, fn string) {
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return name, ""
+	}
+	return name[:slash+1+dot], name[slash+1+dot+1:]
+}
+
+// levelName returns the level logged by the logging function fn, such as
+// "warn" for "Warnf" or "(*Logger).Warnln".
+func levelName(fn string) string {
+	if i := strings.LastIndex(fn, "."); i >= 0 {
+		fn = fn[i+1:]
+	}
+	fn = strings.TrimSuffix(strings.TrimSuffix(fn, "ln"), "f")
+	switch fn {
+	case "Error", "Warn", "Info", "Debug", "Trace", "Fatal", "Panic":
+		return strings.ToLower(fn)
+	default:
+		return "info"
+	}
+}
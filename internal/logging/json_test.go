@@ -0,0 +1,130 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestJSONWriter(t *testing.T) {
+	tests := []struct {
+		description string
+		input       func(l *log.Logger)
+		want        Entry
+	}{
+		{
+			description: "error",
+			input: func(l *log.Logger) {
+				l.Errorf("cannot dispatch data: %v", "timeout")
+			},
+			want: Entry{
+				Level:     "error",
+				Component: "logging",
+				Message:   "cannot dispatch data: timeout",
+			},
+		},
+		{
+			description: "worker not parsed from text",
+			input: func(l *log.Logger) {
+				l.Warnf("worker is stopping; discarding message %v", "2e1a3b0c-5b5e-4a4f-9a0e-3f6f3f0d9c11")
+			},
+			want: Entry{
+				Level:     "warn",
+				Component: "logging",
+				Message:   "worker is stopping; discarding message 2e1a3b0c-5b5e-4a4f-9a0e-3f6f3f0d9c11",
+			},
+		},
+		{
+			description: "debug line",
+			input: func(l *log.Logger) {
+				l.Debugln("send message to worker", "echo")
+			},
+			want: Entry{
+				Level:     "debug",
+				Component: "logging",
+				Message:   "send message to worker echo",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := log.New(NewJSONWriter(&buf, "yggd"), "", 0, log.LevelTrace)
+
+			test.input(l)
+
+			var got Entry
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("cannot unmarshal %q: %v", buf.String(), err)
+			}
+			if got.Time == "" || got.Caller == "" {
+				t.Errorf("missing time or caller: %v", got)
+			}
+			if !cmp.Equal(got, test.want, cmpopts.IgnoreFields(Entry{}, "Time", "Caller")) {
+				t.Errorf("%v", cmp.Diff(got, test.want, cmpopts.IgnoreFields(Entry{}, "Time", "Caller")))
+			}
+		})
+	}
+}
+
+func TestFields(t *testing.T) {
+	var buf bytes.Buffer
+	writer, prefix, flags, level := log.Writer(), log.Prefix(), log.Flags(), log.CurrentLevel()
+	t.Cleanup(func() {
+		log.SetOutput(writer)
+		log.SetPrefix(prefix)
+		log.SetFlags(flags)
+		log.SetLevel(level)
+	})
+	log.SetOutput(NewJSONWriter(&buf, "yggd"))
+	log.SetLevel(log.LevelInfo)
+
+	With("echo", "2e1a3b0c-5b5e-4a4f-9a0e-3f6f3f0d9c11").Warnf("discarding expired message")
+	With("echo", "").Debugf("not logged")
+
+	var got Entry
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("cannot unmarshal %q: %v", buf.String(), err)
+	}
+	want := Entry{
+		Level:     "warn",
+		Component: "logging",
+		Worker:    "echo",
+		MessageID: "2e1a3b0c-5b5e-4a4f-9a0e-3f6f3f0d9c11",
+		Message:   "discarding expired message",
+	}
+	if !cmp.Equal(got, want, cmpopts.IgnoreFields(Entry{}, "Time", "Caller")) {
+		t.Errorf("%v", cmp.Diff(got, want, cmpopts.IgnoreFields(Entry{}, "Time", "Caller")))
+	}
+	if !strings.HasPrefix(got.Caller, "json_test.go:") {
+		t.Errorf("got caller %v, want json_test.go", got.Caller)
+	}
+}
+
+func TestSplitFunction(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantPkg string
+		wantFn  string
+	}{
+		{input: "git.sr.ht/~spc/go-log.Errorf", wantPkg: "git.sr.ht/~spc/go-log", wantFn: "Errorf"},
+		{input: "git.sr.ht/~spc/go-log.(*Logger).Warnln", wantPkg: "git.sr.ht/~spc/go-log", wantFn: "(*Logger).Warnln"},
+		{input: "main.main", wantPkg: "main", wantFn: "main"},
+		{input: "github.com/redhatinsights/yggdrasil/internal/work.(*Dispatcher).Connect.func1", wantPkg: "github.com/redhatinsights/yggdrasil/internal/work", wantFn: "(*Dispatcher).Connect.func1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			pkg, fn := splitFunction(test.input)
+			if pkg != test.wantPkg || fn != test.wantFn {
+				t.Errorf("%v, %v != %v, %v", pkg, fn, test.wantPkg, test.wantFn)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Redacted replaces redacted values in logged payloads.
+const Redacted = "[REDACTED]"
+
+// Redactor masks secrets in message payloads and metadata before they are
+// logged.
+//
+// Paths are dot-separated keys into an object holding a message's "content",
+// parsed as JSON, and "metadata", such as "content.credentials.password" or
+// "metadata.Authorization"; a "*" matches every key or array element at its
+// level. Values at a path are replaced whole. Patterns are regular
+// expressions matched against the logged text; a pattern with capture groups
+// replaces only the text the groups match, otherwise the whole match is
+// replaced.
+type Redactor struct {
+	patterns []*regexp.Regexp
+	paths    [][]string
+}
+
+// NewRedactor creates a Redactor from the given regular expressions and
+// paths.
+func NewRedactor(patterns []string, paths []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("cannot compile redaction pattern '%v': %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	for _, path := range paths {
+		keys := strings.Split(strings.TrimPrefix(path, "$."), ".")
+		if keys[0] != "content" && keys[0] != "metadata" && keys[0] != "*" {
+			return nil, fmt.Errorf("invalid redaction path '%v': must start with content or metadata", path)
+		}
+		r.paths = append(r.paths, keys)
+	}
+	return r, nil
+}
+
+// Message renders content and metadata as a JSON object with redacted values
+// masked. Content that is not JSON is rendered as a string.
+func (r *Redactor) Message(content []byte, metadata map[string]string) string {
+	message := map[string]interface{}{}
+	var value interface{}
+	if err := json.Unmarshal(content, &value); err == nil {
+		message["content"] = value
+	} else {
+		message["content"] = string(content)
+	}
+	if len(metadata) > 0 {
+		m := make(map[string]interface{}, len(metadata))
+		for k, v := range metadata {
+			m[k] = v
+		}
+		message["metadata"] = m
+	}
+
+	if r != nil {
+		for _, path := range r.paths {
+			redactPath(message, path)
+		}
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Sprintf("%v", message)
+	}
+	return r.Text(string(data))
+}
+
+// Text returns s with the matches of the redaction patterns masked.
+func (r *Redactor) Text(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, re := range r.patterns {
+		if re.NumSubexp() == 0 {
+			s = re.ReplaceAllLiteralString(s, Redacted)
+			continue
+		}
+		s = re.ReplaceAllStringFunc(s, func(match string) string {
+			m := re.FindStringSubmatchIndex(match)
+			var b strings.Builder
+			last := 0
+			for i := 2; i < len(m); i += 2 {
+				if m[i] < 0 || m[i] < last {
+					continue
+				}
+				b.WriteString(match[last:m[i]])
+				b.WriteString(Redacted)
+				last = m[i+1]
+			}
+			b.WriteString(match[last:])
+			return b.String()
+		})
+	}
+	return s
+}
+
+// redactPath replaces the values at path in v.
+func redactPath(v interface{}, path []string) {
+	if len(path) == 0 {
+		return
+	}
+	key, rest := path[0], path[1:]
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			if key != "*" && !strings.EqualFold(k, key) {
+				continue
+			}
+			if len(rest) == 0 {
+				t[k] = Redacted
+			} else {
+				redactPath(child, rest)
+			}
+		}
+	case []interface{}:
+		if key != "*" {
+			return
+		}
+		for i, child := range t {
+			if len(rest) == 0 {
+				t[i] = Redacted
+			} else {
+				redactPath(child, rest)
+			}
+		}
+	}
+}
+
+// redactor is the Redactor applied by Payload.
+var redactor atomic.Pointer[Redactor]
+
+// SetRedactor sets the Redactor applied to payloads logged with Payload.
+func SetRedactor(r *Redactor) {
+	redactor.Store(r)
+}
+
+// payload is a message payload formatted for logging.
+type payload struct {
+	content  []byte
+	metadata map[string]string
+}
+
+// String renders the payload with the current Redactor applied.
+func (p payload) String() string {
+	return redactor.Load().Message(p.content, p.metadata)
+}
+
+// Payload returns a value that formats content and metadata for logging with
+// the redaction rules set by SetRedactor applied. Redaction only happens when
+// the value is formatted, so payloads are not processed unless they are
+// logged.
+func Payload(content []byte, metadata map[string]string) fmt.Stringer {
+	return payload{content: content, metadata: metadata}
+}
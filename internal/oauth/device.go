@@ -0,0 +1,79 @@
+package oauth
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+)
+
+// deviceGrantType is the grant type of a device access token request.
+const deviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// defaultDeviceInterval is the polling interval used if the authorization
+// server does not give one.
+const defaultDeviceInterval = 5 * time.Second
+
+// deviceResponse is a successful response of the device authorization
+// endpoint.
+type deviceResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// authorizeDevice runs the device authorization flow: it requests a user
+// code, logs where the user enters it, and polls the token endpoint until the
+// user has authorized the device, denied it, or the code expires.
+func (s *Source) authorizeDevice() (*tokenResponse, error) {
+	params := url.Values{}
+	if len(s.conf.Scopes) > 0 {
+		params.Set("scope", strings.Join(s.conf.Scopes, " "))
+	}
+	var device deviceResponse
+	if err := s.post(s.conf.DeviceAuthorizationURL, params, &device); err != nil {
+		return nil, fmt.Errorf("cannot request device authorization: %w", err)
+	}
+	if device.DeviceCode == "" || device.UserCode == "" || device.VerificationURI == "" {
+		return nil, fmt.Errorf("device authorization response is incomplete")
+	}
+
+	if device.VerificationURIComplete != "" {
+		log.Warnf("authorize this device by visiting %v", device.VerificationURIComplete)
+	} else {
+		log.Warnf("authorize this device by visiting %v and entering the code %v", device.VerificationURI, device.UserCode)
+	}
+
+	interval := defaultDeviceInterval
+	if device.Interval > 0 {
+		interval = time.Duration(device.Interval) * time.Second
+	}
+	deadline := s.now().Add(time.Duration(device.ExpiresIn) * time.Second)
+	for device.ExpiresIn <= 0 || s.now().Before(deadline) {
+		s.sleep(interval)
+
+		resp, err := s.requestToken(url.Values{
+			"grant_type":  {deviceGrantType},
+			"device_code": {device.DeviceCode},
+		})
+		switch errorCode(err) {
+		case "":
+			if err != nil {
+				return nil, err
+			}
+			log.Info("device authorized")
+			return resp, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			return nil, fmt.Errorf("device authorization failed: %w", err)
+		}
+	}
+	return nil, fmt.Errorf("device authorization failed: the user code expired")
+}
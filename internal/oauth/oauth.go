@@ -0,0 +1,332 @@
+// Package oauth obtains OAuth 2.0 access tokens with the client credentials
+// grant (RFC 6749) or the device authorization grant (RFC 8628), and
+// refreshes them before they expire.
+//
+// Access tokens are held in memory only. A refresh token issued by the
+// authorization server is persisted, so that a device authorized once does not
+// have to be authorized again when yggd restarts.
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+)
+
+const (
+	// FlowClientCredentials obtains tokens with the client ID and secret.
+	FlowClientCredentials = "client-credentials"
+
+	// FlowDevice obtains tokens once a user has authorized the device by
+	// visiting a URL and entering a code.
+	FlowDevice = "device"
+)
+
+// expiryMargin is how long before an access token expires it is refreshed.
+const expiryMargin = time.Minute
+
+// Config describes the authorization server and the client tokens are
+// obtained for.
+type Config struct {
+	// Flow is FlowClientCredentials or FlowDevice.
+	Flow string
+
+	// TokenURL is the token endpoint of the authorization server.
+	TokenURL string
+
+	// DeviceAuthorizationURL is the device authorization endpoint of the
+	// authorization server, used by FlowDevice.
+	DeviceAuthorizationURL string
+
+	// ClientID and ClientSecret identify the client. ClientSecret may be empty
+	// for public clients using FlowDevice.
+	ClientID     string
+	ClientSecret string
+
+	// Scopes are the scopes requested.
+	Scopes []string
+
+	// RefreshTokenFile is the file a refresh token is persisted to. If empty,
+	// refresh tokens are kept in memory only.
+	RefreshTokenFile string
+}
+
+// Validate checks that conf names a supported flow and the endpoints it
+// needs.
+func (conf *Config) Validate() error {
+	switch conf.Flow {
+	case FlowClientCredentials:
+		if conf.ClientSecret == "" {
+			return fmt.Errorf("a client secret is required for the %v flow", conf.Flow)
+		}
+	case FlowDevice:
+		if err := validateURL(conf.DeviceAuthorizationURL); err != nil {
+			return fmt.Errorf("invalid device authorization URL: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported flow '%v': must be one of '%v' or '%v'", conf.Flow, FlowClientCredentials, FlowDevice)
+	}
+	if err := validateURL(conf.TokenURL); err != nil {
+		return fmt.Errorf("invalid token URL: %w", err)
+	}
+	if conf.ClientID == "" {
+		return fmt.Errorf("a client ID is required")
+	}
+	return nil
+}
+
+// validateURL checks that s is an absolute HTTPS URL.
+func validateURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("'%v' must be an https URL", s)
+	}
+	return nil
+}
+
+// Source provides access tokens, obtaining a new one when the current token
+// is about to expire. It is safe for concurrent use.
+type Source struct {
+	conf   Config
+	client *http.Client
+	now    func() time.Time
+	sleep  func(time.Duration)
+
+	// fetch serializes obtaining new tokens. lock guards the token fields
+	// and is never held while talking to the authorization server, so that a
+	// valid token is returned while a new one is being obtained.
+	fetch        sync.Mutex
+	lock         sync.Mutex
+	accessToken  string
+	expiry       time.Time
+	refreshToken string
+}
+
+// NewSource creates a Source that sends requests to the authorization server
+// with client, reading any refresh token persisted by an earlier Source.
+func NewSource(conf Config, client *http.Client) (*Source, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	s := Source{
+		conf:   conf,
+		client: client,
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+	if conf.RefreshTokenFile != "" {
+		data, err := os.ReadFile(conf.RefreshTokenFile)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("cannot read refresh token: %w", err)
+		}
+		s.refreshToken = strings.TrimSpace(string(data))
+	}
+	return &s, nil
+}
+
+// Token returns a valid access token. If the current token expires within
+// a minute, a new one is obtained, using the refresh token if there is one
+// and running the configured flow otherwise. With FlowDevice, Token blocks
+// until the device is authorized; meanwhile, other callers are given the
+// current token until it expires.
+func (s *Source) Token() (string, error) {
+	if token, ok := s.cachedToken(expiryMargin); ok {
+		return token, nil
+	}
+
+	// Only one caller obtains a new token; the others wait for it once the
+	// current token has expired.
+	if !s.fetch.TryLock() {
+		if token, ok := s.cachedToken(0); ok {
+			return token, nil
+		}
+		s.fetch.Lock()
+	}
+	defer s.fetch.Unlock()
+	if token, ok := s.cachedToken(expiryMargin); ok {
+		return token, nil
+	}
+
+	s.lock.Lock()
+	refreshToken := s.refreshToken
+	s.lock.Unlock()
+	if refreshToken != "" {
+		resp, err := s.requestToken(url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {refreshToken},
+		})
+		if err == nil {
+			return s.setToken(resp), nil
+		}
+		log.Warnf("cannot refresh access token: %v", err)
+		s.lock.Lock()
+		s.refreshToken = ""
+		s.lock.Unlock()
+	}
+
+	var resp *tokenResponse
+	var err error
+	switch s.conf.Flow {
+	case FlowDevice:
+		resp, err = s.authorizeDevice()
+	default:
+		params := url.Values{"grant_type": {"client_credentials"}}
+		if len(s.conf.Scopes) > 0 {
+			params.Set("scope", strings.Join(s.conf.Scopes, " "))
+		}
+		resp, err = s.requestToken(params)
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot obtain access token: %w", err)
+	}
+	return s.setToken(resp), nil
+}
+
+// cachedToken returns the current access token and true if it does not
+// expire within margin.
+func (s *Source) cachedToken(margin time.Duration) (string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.accessToken != "" && s.now().Add(margin).Before(s.expiry) {
+		return s.accessToken, true
+	}
+	return "", false
+}
+
+// setToken records the token in resp, persisting its refresh token, and
+// returns the access token.
+func (s *Source) setToken(resp *tokenResponse) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.accessToken = resp.AccessToken
+	if resp.ExpiresIn > 0 {
+		s.expiry = s.now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	} else {
+		// The authorization server did not say when the token expires; use
+		// it for an hour before obtaining a new one.
+		s.expiry = s.now().Add(time.Hour)
+	}
+	if resp.RefreshToken != "" && resp.RefreshToken != s.refreshToken {
+		s.refreshToken = resp.RefreshToken
+		if err := s.writeRefreshToken(); err != nil {
+			log.Warnf("cannot persist refresh token: %v", err)
+		}
+	}
+	log.Debugf("obtained access token expiring at %v", s.expiry)
+	return s.accessToken
+}
+
+// writeRefreshToken persists the refresh token, readable by the owner only.
+func (s *Source) writeRefreshToken() error {
+	if s.conf.RefreshTokenFile == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.conf.RefreshTokenFile), 0750); err != nil {
+		return fmt.Errorf("cannot create directory: %w", err)
+	}
+	if err := os.WriteFile(s.conf.RefreshTokenFile, []byte(s.refreshToken), 0600); err != nil {
+		return fmt.Errorf("cannot write refresh token file: %w", err)
+	}
+	return nil
+}
+
+// tokenResponse is a successful response of the token endpoint.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// errorResponse is an error response of the authorization server.
+type errorResponse struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *errorResponse) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("%v: %v", e.Code, e.Description)
+	}
+	return e.Code
+}
+
+// requestToken sends a token request with params and the client
+// credentials to the token endpoint.
+func (s *Source) requestToken(params url.Values) (*tokenResponse, error) {
+	var resp tokenResponse
+	if err := s.post(s.conf.TokenURL, params, &resp); err != nil {
+		return nil, err
+	}
+	if resp.AccessToken == "" {
+		return nil, fmt.Errorf("token response has no access token")
+	}
+	if resp.TokenType != "" && !strings.EqualFold(resp.TokenType, "bearer") {
+		return nil, fmt.Errorf("unsupported token type '%v'", resp.TokenType)
+	}
+	return &resp, nil
+}
+
+// post sends params as a form to endpoint, authenticating the client, and
+// decodes the JSON response into v. An error response of the authorization
+// server is returned as an *errorResponse.
+func (s *Source) post(endpoint string, params url.Values, v interface{}) error {
+	if s.conf.ClientSecret == "" {
+		params.Set("client_id", s.conf.ClientID)
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return fmt.Errorf("cannot create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.conf.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(s.conf.ClientID), url.QueryEscape(s.conf.ClientSecret))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot send request to '%v': %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("cannot read response from '%v': %w", endpoint, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e errorResponse
+		if json.Unmarshal(body, &e) == nil && e.Code != "" {
+			return &e
+		}
+		return fmt.Errorf("unexpected response from '%v': %v", endpoint, resp.Status)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("cannot parse response from '%v': %w", endpoint, err)
+	}
+	return nil
+}
+
+// errorCode returns the OAuth error code of err, if it is an error response.
+func errorCode(err error) string {
+	var e *errorResponse
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ""
+}
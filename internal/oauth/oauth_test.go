@@ -0,0 +1,314 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// authServer is a fake authorization server. Each token request is recorded
+// and answered with the next response in responses.
+type authServer struct {
+	*httptest.Server
+	requests  []http.Request
+	forms     []map[string]string
+	responses []response
+}
+
+type response struct {
+	status int
+	body   map[string]interface{}
+}
+
+func newAuthServer(t *testing.T, responses ...response) *authServer {
+	s := &authServer{responses: responses}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		form := make(map[string]string)
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		s.requests = append(s.requests, *r)
+		s.forms = append(s.forms, form)
+		if len(s.responses) == 0 {
+			t.Errorf("unexpected request: %v", form)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		resp := s.responses[0]
+		s.responses = s.responses[1:]
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(resp.status)
+		_ = json.NewEncoder(w).Encode(resp.body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestClientCredentials(t *testing.T) {
+	srv := newAuthServer(t,
+		response{http.StatusOK, map[string]interface{}{"access_token": "a1", "token_type": "Bearer", "expires_in": 300}},
+		response{http.StatusOK, map[string]interface{}{"access_token": "a2", "token_type": "Bearer", "expires_in": 300}},
+	)
+	source, err := NewSource(Config{
+		Flow:         FlowClientCredentials,
+		TokenURL:     srv.URL + "/token",
+		ClientID:     "client",
+		ClientSecret: "s3cret",
+		Scopes:       []string{"read", "write"},
+	}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	source.now = func() time.Time { return now }
+
+	for _, want := range []string{"a1", "a1"} {
+		got, err := source.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+
+	now = now.Add(4*time.Minute + 30*time.Second)
+	got, err := source.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "a2" {
+		t.Errorf("got %v, want a2", got)
+	}
+
+	wantForm := map[string]string{"grant_type": "client_credentials", "scope": "read write"}
+	if !cmp.Equal(srv.forms[0], wantForm) {
+		t.Errorf("%v", cmp.Diff(srv.forms[0], wantForm))
+	}
+	if user, password, _ := srv.requests[0].BasicAuth(); user != "client" || password != "s3cret" {
+		t.Errorf("got client credentials %v:%v", user, password)
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	srv := newAuthServer(t,
+		response{http.StatusBadRequest, map[string]interface{}{"error": "invalid_grant"}},
+		response{http.StatusOK, map[string]interface{}{"access_token": "a1", "expires_in": 60, "refresh_token": "r2"}},
+		response{http.StatusOK, map[string]interface{}{"access_token": "a2", "expires_in": 300}},
+	)
+	file := filepath.Join(t.TempDir(), "state", "refresh-token")
+	if err := os.MkdirAll(filepath.Dir(file), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("r1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	source, err := NewSource(Config{
+		Flow:             FlowClientCredentials,
+		TokenURL:         srv.URL + "/token",
+		ClientID:         "client",
+		ClientSecret:     "s3cret",
+		RefreshTokenFile: file,
+	}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"a1", "a2"} {
+		got, err := source.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+
+	wantForms := []map[string]string{
+		{"grant_type": "refresh_token", "refresh_token": "r1"},
+		{"grant_type": "client_credentials"},
+		{"grant_type": "refresh_token", "refresh_token": "r2"},
+	}
+	if !cmp.Equal(srv.forms, wantForms) {
+		t.Errorf("%v", cmp.Diff(srv.forms, wantForms))
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "r2" {
+		t.Errorf("got persisted refresh token %v, want r2", string(data))
+	}
+}
+
+func TestDeviceFlow(t *testing.T) {
+	tests := []struct {
+		description string
+		responses   []response
+		want        string
+		wantSleeps  []time.Duration
+		wantError   bool
+	}{
+		{
+			description: "authorized",
+			responses: []response{
+				{http.StatusOK, map[string]interface{}{"device_code": "d1", "user_code": "ABCD", "verification_uri": "https://example.com/device", "expires_in": 600, "interval": 2}},
+				{http.StatusBadRequest, map[string]interface{}{"error": "authorization_pending"}},
+				{http.StatusBadRequest, map[string]interface{}{"error": "slow_down"}},
+				{http.StatusOK, map[string]interface{}{"access_token": "a1", "token_type": "bearer", "expires_in": 300}},
+			},
+			want:       "a1",
+			wantSleeps: []time.Duration{2 * time.Second, 2 * time.Second, 7 * time.Second},
+		},
+		{
+			description: "denied",
+			responses: []response{
+				{http.StatusOK, map[string]interface{}{"device_code": "d1", "user_code": "ABCD", "verification_uri": "https://example.com/device"}},
+				{http.StatusBadRequest, map[string]interface{}{"error": "access_denied"}},
+			},
+			wantSleeps: []time.Duration{defaultDeviceInterval},
+			wantError:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			srv := newAuthServer(t, test.responses...)
+			source, err := NewSource(Config{
+				Flow:                   FlowDevice,
+				TokenURL:               srv.URL + "/token",
+				DeviceAuthorizationURL: srv.URL + "/device",
+				ClientID:               "client",
+			}, srv.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sleeps []time.Duration
+			source.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+			got, err := source.Token()
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got %v", got)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != test.want {
+					t.Errorf("got %v, want %v", got, test.want)
+				}
+			}
+			if !cmp.Equal(sleeps, test.wantSleeps) {
+				t.Errorf("%v", cmp.Diff(sleeps, test.wantSleeps))
+			}
+			if form := srv.forms[len(srv.forms)-1]; form["device_code"] != "d1" || form["client_id"] != "client" {
+				t.Errorf("unexpected token request %v", form)
+			}
+		})
+	}
+}
+
+func TestTokenDuringDeviceFlow(t *testing.T) {
+	srv := newAuthServer(t,
+		response{http.StatusOK, map[string]interface{}{"device_code": "d1", "user_code": "ABCD", "verification_uri": "https://example.com/device", "interval": 1}},
+		response{http.StatusOK, map[string]interface{}{"access_token": "a2", "token_type": "bearer", "expires_in": 300}},
+	)
+	source, err := NewSource(Config{
+		Flow:                   FlowDevice,
+		TokenURL:               srv.URL + "/token",
+		DeviceAuthorizationURL: srv.URL + "/device",
+		ClientID:               "client",
+	}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The current token expires within the margin, but has not expired yet.
+	source.accessToken = "a1"
+	source.expiry = time.Now().Add(expiryMargin / 2)
+
+	polling := make(chan struct{})
+	authorized := make(chan struct{})
+	source.sleep = func(time.Duration) {
+		close(polling)
+		<-authorized
+	}
+	done := make(chan string)
+	go func() {
+		token, err := source.Token()
+		if err != nil {
+			t.Error(err)
+		}
+		done <- token
+	}()
+
+	<-polling
+	if got, err := source.Token(); err != nil || got != "a1" {
+		t.Errorf("got %v, %v during device flow, want a1", got, err)
+	}
+	close(authorized)
+	if got := <-done; got != "a2" {
+		t.Errorf("got %v, want a2", got)
+	}
+	if got, err := source.Token(); err != nil || got != "a2" {
+		t.Errorf("got %v, %v, want a2", got, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		description string
+		input       Config
+		wantError   bool
+	}{
+		{
+			description: "client credentials",
+			input:       Config{Flow: FlowClientCredentials, TokenURL: "https://example.com/token", ClientID: "c", ClientSecret: "s"},
+		},
+		{
+			description: "device",
+			input:       Config{Flow: FlowDevice, TokenURL: "https://example.com/token", DeviceAuthorizationURL: "https://example.com/device", ClientID: "c"},
+		},
+		{
+			description: "missing secret",
+			input:       Config{Flow: FlowClientCredentials, TokenURL: "https://example.com/token", ClientID: "c"},
+			wantError:   true,
+		},
+		{
+			description: "missing device authorization URL",
+			input:       Config{Flow: FlowDevice, TokenURL: "https://example.com/token", ClientID: "c"},
+			wantError:   true,
+		},
+		{
+			description: "plain http",
+			input:       Config{Flow: FlowClientCredentials, TokenURL: "http://example.com/token", ClientID: "c", ClientSecret: "s"},
+			wantError:   true,
+		},
+		{
+			description: "unknown flow",
+			input:       Config{Flow: "password", TokenURL: "https://example.com/token", ClientID: "c"},
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			err := test.input.Validate()
+			if test.wantError && err == nil {
+				t.Errorf("expected error")
+			}
+			if !test.wantError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+// Package signature verifies detached signatures on messages received from
+// the server, so that a compromised broker cannot inject messages.
+//
+// A signed message carries a base64-encoded signature in its "signature"
+// metadata. The signature covers, each followed by a newline:
+//
+//   - the message type
+//   - the message ID
+//   - the ID of the message it responds to
+//   - the directive, which is empty for a control message
+//   - the time the message was sent, in RFC 3339 format in UTC with
+//     fractional seconds as in time.RFC3339Nano
+//   - the metadata other than the signature, encoded as a JSON object with its
+//     keys sorted
+//
+// followed by the content, included verbatim. A signed message is accepted
+// only within MaxAge of the time it was sent, so that it cannot be replayed
+// once the dispatcher has forgotten its message ID.
+//
+// Ed25519 signatures are made over these bytes directly. ECDSA and RSA
+// (PKCS #1 v1.5) signatures are made over their SHA-256 digest.
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redhatinsights/yggdrasil"
+)
+
+// MetadataKey is the metadata key carrying a message's signature.
+const MetadataKey = "signature"
+
+// MaxAge is how long after it was sent a signed message is accepted.
+const MaxAge = time.Hour
+
+// MaxClockSkew is how far in the future the sent time of a signed message may
+// be, allowing for the clocks of the server and the host to differ.
+const MaxClockSkew = 5 * time.Minute
+
+// ErrUnsigned is returned by Verify for a message without a signature.
+var ErrUnsigned = errors.New("message is not signed")
+
+// Verifier checks signatures against a set of trusted public keys.
+type Verifier struct {
+	keys []crypto.PublicKey
+	now  func() time.Time
+}
+
+// Load creates a Verifier trusting the PEM-encoded public keys in the files
+// at paths. A file may hold several keys, each in a "PUBLIC KEY" block.
+func Load(paths []string) (*Verifier, error) {
+	v := &Verifier{now: time.Now}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read trusted key: %w", err)
+		}
+		keys, err := parseKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse trusted key %v: %w", path, err)
+		}
+		v.keys = append(v.keys, keys...)
+	}
+	return v, nil
+}
+
+// parseKeys parses the "PUBLIC KEY" blocks in data.
+func parseKeys(data []byte) ([]crypto.PublicKey, error) {
+	keys := []crypto.PublicKey{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch key.(type) {
+		case ed25519.PublicKey, *ecdsa.PublicKey, *rsa.PublicKey:
+		default:
+			return nil, fmt.Errorf("unsupported key type %T", key)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public key found")
+	}
+	return keys, nil
+}
+
+// Len returns the number of trusted keys.
+func (v *Verifier) Len() int {
+	if v == nil {
+		return 0
+	}
+	return len(v.keys)
+}
+
+// Verify checks the signature in metadata over payload, of a message sent at
+// sent. It returns ErrUnsigned if metadata holds no signature, and an error if
+// the signature was not made by any trusted key or the message was not sent
+// within MaxAge.
+func (v *Verifier) Verify(payload []byte, metadata map[string]string, sent time.Time) error {
+	encoded, ok := metadata[MetadataKey]
+	if !ok || encoded == "" {
+		return ErrUnsigned
+	}
+	sig, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("cannot decode signature: %w", err)
+	}
+	digest := sha256.Sum256(payload)
+	if v == nil {
+		return fmt.Errorf("no trusted key is set")
+	}
+	if !v.matches(payload, digest[:], sig) {
+		return fmt.Errorf("signature does not match any trusted key")
+	}
+
+	now := v.now()
+	if sent.IsZero() {
+		return fmt.Errorf("signed message has no sent time")
+	}
+	if age := now.Sub(sent); age > MaxAge {
+		return fmt.Errorf("signed message was sent %v ago, more than %v", age.Round(time.Second), MaxAge)
+	}
+	if sent.Sub(now) > MaxClockSkew {
+		return fmt.Errorf("signed message was sent in the future, at %v", sent.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// matches returns true if sig is a signature over payload, whose SHA-256
+// digest is digest, made by a trusted key.
+func (v *Verifier) matches(payload, digest, sig []byte) bool {
+	for _, key := range v.keys {
+		switch k := key.(type) {
+		case ed25519.PublicKey:
+			if ed25519.Verify(k, payload, sig) {
+				return true
+			}
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(k, digest, sig) {
+				return true
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, sig) == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DataPayload returns the bytes the signature of msg covers.
+func DataPayload(msg *yggdrasil.Data) []byte {
+	return payload(msg.Type, msg.MessageID, msg.ResponseTo, msg.Directive, msg.Sent, msg.Metadata, msg.Content)
+}
+
+// ControlPayload returns the bytes the signature of msg covers.
+func ControlPayload(msg *yggdrasil.Control) []byte {
+	return payload(msg.Type, msg.MessageID, msg.ResponseTo, "", msg.Sent, msg.Metadata, msg.Content)
+}
+
+// payload joins the signed fields of a message.
+func payload(
+	messageType yggdrasil.MessageType,
+	messageID, responseTo, directive string,
+	sent time.Time,
+	metadata map[string]string,
+	content []byte,
+) []byte {
+	signed := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		if k != MetadataKey {
+			signed[k] = v
+		}
+	}
+	// Maps are marshaled with their keys sorted, and strings cannot hold an
+	// unescaped newline, so the encoding is canonical and fits on one line.
+	encodedMetadata, _ := json.Marshal(signed)
+
+	var buf bytes.Buffer
+	for _, field := range []string{
+		string(messageType),
+		messageID,
+		responseTo,
+		directive,
+		sent.UTC().Format(time.RFC3339Nano),
+	} {
+		buf.WriteString(field)
+		buf.WriteByte('\n')
+	}
+	buf.Write(encodedMetadata)
+	buf.WriteByte('\n')
+	buf.Write(content)
+	return buf.Bytes()
+}
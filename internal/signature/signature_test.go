@@ -0,0 +1,249 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redhatinsights/yggdrasil"
+)
+
+// writeKey writes the PEM encoding of pub to a file in dir and returns its
+// path.
+func writeKey(t *testing.T, dir, name string, pub crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// sign signs payload with key and returns the encoded signature.
+func sign(t *testing.T, key crypto.Signer, payload []byte) string {
+	var sig []byte
+	var err error
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		sig, err = key.Sign(rand.Reader, payload, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(payload)
+		sig, err = key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func TestVerify(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, untrustedKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	verifier, err := Load([]string{
+		writeKey(t, dir, "ed25519.pem", edKey.Public()),
+		writeKey(t, dir, "ecdsa.pem", ecKey.Public()),
+		writeKey(t, dir, "rsa.pem", rsaKey.Public()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	verifier.now = func() time.Time { return now }
+
+	msg := yggdrasil.Data{
+		Type:       yggdrasil.MessageTypeData,
+		MessageID:  "1234",
+		ResponseTo: "1233",
+		Sent:       now.Add(-time.Minute),
+		Directive:  "echo",
+		Metadata:   map[string]string{"return_url": "https://example.com/return", "Priority": "high"},
+		Content:    json.RawMessage(`{"hello":"world"}`),
+	}
+	tampered := msg
+	tampered.Directive = "package-manager"
+	redirected := msg
+	redirected.Metadata = map[string]string{"return_url": "https://attacker.example.com", "Priority": "high"}
+	reparented := msg
+	reparented.ResponseTo = "9999"
+	stale := msg
+	stale.Sent = now.Add(-MaxAge - time.Minute)
+	future := msg
+	future.Sent = now.Add(MaxClockSkew + time.Minute)
+
+	tests := []struct {
+		description string
+		msg         yggdrasil.Data
+		metadata    map[string]string
+		wantError   error
+		wantAny     bool
+	}{
+		{
+			description: "ed25519",
+			metadata:    map[string]string{MetadataKey: sign(t, edKey, DataPayload(&msg))},
+		},
+		{
+			description: "ecdsa",
+			metadata:    map[string]string{MetadataKey: sign(t, ecKey, DataPayload(&msg))},
+		},
+		{
+			description: "rsa",
+			metadata:    map[string]string{MetadataKey: sign(t, rsaKey, DataPayload(&msg))},
+		},
+		{
+			description: "unsigned",
+			metadata:    map[string]string{},
+			wantError:   ErrUnsigned,
+		},
+		{
+			description: "untrusted key",
+			metadata:    map[string]string{MetadataKey: sign(t, untrustedKey, DataPayload(&msg))},
+			wantAny:     true,
+		},
+		{
+			description: "tampered directive",
+			metadata:    map[string]string{MetadataKey: sign(t, edKey, DataPayload(&tampered))},
+			wantAny:     true,
+		},
+		{
+			description: "invalid encoding",
+			metadata:    map[string]string{MetadataKey: "not base64!"},
+			wantAny:     true,
+		},
+		{
+			description: "tampered metadata",
+			msg:         redirected,
+			metadata:    map[string]string{MetadataKey: sign(t, edKey, DataPayload(&msg))},
+			wantAny:     true,
+		},
+		{
+			description: "tampered response to",
+			msg:         reparented,
+			metadata:    map[string]string{MetadataKey: sign(t, edKey, DataPayload(&msg))},
+			wantAny:     true,
+		},
+		{
+			description: "stale",
+			msg:         stale,
+			metadata:    map[string]string{MetadataKey: sign(t, edKey, DataPayload(&stale))},
+			wantAny:     true,
+		},
+		{
+			description: "sent in the future",
+			msg:         future,
+			metadata:    map[string]string{MetadataKey: sign(t, edKey, DataPayload(&future))},
+			wantAny:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			received := msg
+			if test.msg.MessageID != "" {
+				received = test.msg
+			}
+			metadata := make(map[string]string)
+			for k, v := range received.Metadata {
+				metadata[k] = v
+			}
+			for k, v := range test.metadata {
+				metadata[k] = v
+			}
+			received.Metadata = metadata
+			err := verifier.Verify(DataPayload(&received), received.Metadata, received.Sent)
+			switch {
+			case test.wantError != nil:
+				if !errors.Is(err, test.wantError) {
+					t.Errorf("got %v, want %v", err, test.wantError)
+				}
+			case test.wantAny:
+				if err == nil {
+					t.Error("expected error")
+				}
+			default:
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	notKey := filepath.Join(dir, "cert.pem")
+	if err := os.WriteFile(notKey, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{0}}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		description string
+		paths       []string
+		want        int
+		wantError   bool
+	}{
+		{
+			description: "none",
+			want:        0,
+		},
+		{
+			description: "missing file",
+			paths:       []string{filepath.Join(dir, "missing.pem")},
+			wantError:   true,
+		},
+		{
+			description: "no public key",
+			paths:       []string{notKey},
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got, err := Load(test.paths)
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got %v keys", got.Len())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Len() != test.want {
+				t.Errorf("got %v keys, want %v", got.Len(), test.want)
+			}
+		})
+	}
+}
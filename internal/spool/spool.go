@@ -0,0 +1,132 @@
+// Package spool encrypts messages yggd keeps on disk, such as messages
+// awaiting delivery to a worker and dead letters, since their payloads may
+// hold sensitive system data.
+//
+// Files are encrypted with AES-256-GCM. An encrypted file starts with a magic
+// header, followed by the nonce and the sealed data, so files written before
+// encryption was enabled can still be read.
+package spool
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// KeyringPrefix marks a key reference naming a key in the kernel keyring.
+const KeyringPrefix = "keyring:"
+
+// KeySize is the size of a key in bytes.
+const KeySize = 32
+
+// header marks an encrypted file.
+var header = []byte("YGGSPOOL1\n")
+
+// Cipher encrypts and decrypts spooled files. A nil *Cipher stores files
+// unencrypted.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a Cipher encrypting with key, which must be KeySize bytes
+// long.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key is %v bytes long, want %v", len(key), KeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Load creates a Cipher from the key referenced by ref. A reference of the
+// form "keyring:NAME" names a "user" key in the session or user kernel
+// keyring; any other reference is the key itself. Either way, the key is
+// base64 encoded. An empty reference returns a nil Cipher.
+func Load(ref string) (*Cipher, error) {
+	if ref == "" {
+		return nil, nil
+	}
+	encoded := ref
+	if name, ok := strings.CutPrefix(ref, KeyringPrefix); ok {
+		data, err := readKeyring(name)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read key '%v' from kernel keyring: %w", name, err)
+		}
+		encoded = string(data)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode key: %w", err)
+	}
+	return NewCipher(key)
+}
+
+// readKeyring reads the "user" key named name, searching the session keyring
+// and then the user keyring.
+func readKeyring(name string) ([]byte, error) {
+	id, err := unix.KeyctlSearch(unix.KEY_SPEC_SESSION_KEYRING, "user", name, 0)
+	if err != nil {
+		id, err = unix.KeyctlSearch(unix.KEY_SPEC_USER_KEYRING, "user", name, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	size, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	n, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, buf, 0)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:min(n, size)], nil
+}
+
+// Seal returns data encrypted for storage, or data unchanged if c is nil.
+func (c *Cipher) Seal(data []byte) ([]byte, error) {
+	if c == nil {
+		return data, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("cannot generate nonce: %w", err)
+	}
+	sealed := append(bytes.Clone(header), nonce...)
+	return c.aead.Seal(sealed, nonce, data, header), nil
+}
+
+// Open returns the data stored in a file written by Seal. Files without the
+// encryption header are returned unchanged, so files written before
+// encryption was enabled can be read.
+func (c *Cipher) Open(data []byte) ([]byte, error) {
+	sealed, ok := bytes.CutPrefix(data, header)
+	if !ok {
+		return data, nil
+	}
+	if c == nil {
+		return nil, fmt.Errorf("file is encrypted but no key is set")
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted file is truncated")
+	}
+	nonce, sealed := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, sealed, header)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt file: %w", err)
+	}
+	return plain, nil
+}
@@ -0,0 +1,141 @@
+package spool
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, KeySize)
+	c, err := NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewCipher(bytes.Repeat([]byte{2}, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := []byte(`{"data":{"message_id":"1234"}}`)
+
+	sealed, err := c.Seal(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, plain) {
+		t.Fatal("sealed data contains plaintext")
+	}
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		description string
+		cipher      *Cipher
+		input       []byte
+		want        []byte
+		wantError   bool
+	}{
+		{
+			description: "encrypted",
+			cipher:      c,
+			input:       sealed,
+			want:        plain,
+		},
+		{
+			description: "unencrypted file",
+			cipher:      c,
+			input:       plain,
+			want:        plain,
+		},
+		{
+			description: "no key",
+			cipher:      nil,
+			input:       sealed,
+			wantError:   true,
+		},
+		{
+			description: "wrong key",
+			cipher:      other,
+			input:       sealed,
+			wantError:   true,
+		},
+		{
+			description: "tampered",
+			cipher:      c,
+			input:       tampered,
+			wantError:   true,
+		},
+		{
+			description: "truncated",
+			cipher:      c,
+			input:       header,
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got, err := test.cipher.Open(test.input)
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		description string
+		ref         string
+		wantNil     bool
+		wantError   bool
+	}{
+		{
+			description: "empty",
+			ref:         "",
+			wantNil:     true,
+		},
+		{
+			description: "base64 key",
+			ref:         base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, KeySize)) + "\n",
+		},
+		{
+			description: "short key",
+			ref:         base64.StdEncoding.EncodeToString([]byte("short")),
+			wantError:   true,
+		},
+		{
+			description: "invalid encoding",
+			ref:         "not base64!",
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got, err := Load(test.ref)
+			if test.wantError {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got == nil) != test.wantNil {
+				t.Errorf("got %v, want nil %v", got, test.wantNil)
+			}
+		})
+	}
+}
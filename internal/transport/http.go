@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"path/filepath"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -34,9 +35,17 @@ type HTTP struct {
 	pollingInterval time.Duration
 	disconnected    atomic.Value
 	userAgent       string
+	headers         http.Header
+	tokens          internalhttp.TokenSource
+	negotiator      internalhttp.Negotiator
 	isTLS           atomic.Value
 	events          chan TransporterEvent
 	eventHandler    EventHandlerFunc
+
+	// streamLock guards stream, the body of the Server-Sent Events response
+	// control messages are currently received on, if any.
+	streamLock sync.Mutex
+	stream     io.Closer
 }
 
 func NewHTTPTransport(
@@ -44,19 +53,29 @@ func NewHTTPTransport(
 	server string,
 	tlsConfig *tls.Config,
 	userAgent string,
+	headers http.Header,
+	tokens internalhttp.TokenSource,
+	negotiator internalhttp.Negotiator,
 	pollingInterval time.Duration,
 ) (*HTTP, error) {
 	disconnected := atomic.Value{}
 	disconnected.Store(false)
 	isTls := atomic.Value{}
 	isTls.Store(tlsConfig != nil)
+	client := internalhttp.NewHTTPClient(tlsConfig.Clone(), userAgent)
+	client.Headers = headers
+	client.Tokens = tokens
+	client.Negotiator = negotiator
 	return &HTTP{
 		clientID:        clientID,
-		client:          internalhttp.NewHTTPClient(tlsConfig.Clone(), userAgent),
+		client:          client,
 		pollingInterval: pollingInterval,
 		disconnected:    disconnected,
 		server:          server,
 		userAgent:       userAgent,
+		headers:         headers,
+		tokens:          tokens,
+		negotiator:      negotiator,
 		isTLS:           isTls,
 		events:          make(chan TransporterEvent),
 	}, nil
@@ -74,15 +93,22 @@ func (t *HTTP) Connect() error {
 		}
 	}()
 
+	if config.DefaultConfig.HTTPReceiveMode == "sse" {
+		go t.streamControl()
+	} else {
+		go t.pollControl()
+	}
+
 	go func() {
 		for {
 			if t.disconnected.Load().(bool) {
 				return
 			}
-			resp, err := t.client.Get(t.getUrl("in", "control"))
+			resp, err := t.client.Get(t.getUrl("in", "data"))
 			if err != nil {
 				log.Tracef("cannot get HTTP request: %v", err)
 			}
+
 			if resp != nil {
 				data, err := io.ReadAll(resp.Body)
 				if err != nil {
@@ -94,7 +120,7 @@ func (t *HTTP) Connect() error {
 					for k, v := range resp.Header {
 						metadata[k] = v
 					}
-					_ = t.dataHandler("control", metadata, data)
+					_ = t.dataHandler("data", metadata, data)
 				}
 				resp.Body.Close()
 			}
@@ -102,43 +128,114 @@ func (t *HTTP) Connect() error {
 		}
 	}()
 
-	go func() {
-		for {
-			if t.disconnected.Load().(bool) {
-				return
-			}
-			resp, err := t.client.Get(t.getUrl("in", "data"))
+	t.events <- TransporterEventConnected
+
+	return nil
+}
+
+// pollControl receives control messages by sending a request every polling
+// interval until the transport is disconnected.
+func (t *HTTP) pollControl() {
+	for {
+		if t.disconnected.Load().(bool) {
+			return
+		}
+		resp, err := t.client.Get(t.getUrl("in", "control"))
+		if err != nil {
+			log.Tracef("cannot get HTTP request: %v", err)
+		}
+		if resp != nil {
+			data, err := io.ReadAll(resp.Body)
 			if err != nil {
-				log.Tracef("cannot get HTTP request: %v", err)
+				log.Errorf("cannot read response body: %v", err)
+				continue
 			}
+			if t.dataHandler != nil {
+				metadata := make(map[string]interface{})
+				for k, v := range resp.Header {
+					metadata[k] = v
+				}
+				_ = t.dataHandler("control", metadata, data)
+			}
+			resp.Body.Close()
+		}
+		time.Sleep(t.pollingInterval)
+	}
+}
 
-			if resp != nil {
-				data, err := io.ReadAll(resp.Body)
-				if err != nil {
-					log.Errorf("cannot read response body: %v", err)
-					continue
+// streamControl receives control messages as Server-Sent Events, holding a
+// request open until the server closes it and then sending another one,
+// until the transport is disconnected. Events of type "message" or
+// "control" are handled as control messages.
+func (t *HTTP) streamControl() {
+	var lastEventID string
+	retry := t.pollingInterval
+	for {
+		if t.disconnected.Load().(bool) {
+			return
+		}
+		headers := map[string]string{
+			"Accept":        "text/event-stream",
+			"Cache-Control": "no-cache",
+		}
+		if lastEventID != "" {
+			headers["Last-Event-ID"] = lastEventID
+		}
+		resp, err := t.client.GetWithHeaders(t.getUrl("in", "control"), headers)
+		if err != nil {
+			log.Tracef("cannot get HTTP request: %v", err)
+		}
+		if resp != nil {
+			if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+				log.Errorf("cannot receive control messages: unexpected response %v with content type '%v'", resp.Status, resp.Header.Get("Content-Type"))
+				resp.Body.Close()
+			} else {
+				t.setStream(resp.Body)
+				metadata := make(map[string]interface{})
+				for k, v := range resp.Header {
+					metadata[k] = v
 				}
-				if t.dataHandler != nil {
-					metadata := make(map[string]interface{})
-					for k, v := range resp.Header {
-						metadata[k] = v
+				err := readEvents(resp.Body, lastEventID, func(event sseEvent) {
+					lastEventID = event.ID
+					if event.Type != "message" && event.Type != "control" {
+						log.Debugf("ignoring event of type '%v'", event.Type)
+						return
 					}
-					_ = t.dataHandler("data", metadata, data)
-				}
+					if t.dataHandler != nil {
+						_ = t.dataHandler("control", metadata, event.Data)
+					}
+				}, func(d time.Duration) {
+					retry = d
+				})
+				t.setStream(nil)
 				resp.Body.Close()
+				if err != nil && !t.disconnected.Load().(bool) {
+					log.Debugf("control message stream closed: %v", err)
+				}
 			}
-			time.Sleep(t.pollingInterval)
 		}
-	}()
-
-	t.events <- TransporterEventConnected
+		time.Sleep(retry)
+	}
+}
 
-	return nil
+// setStream records body as the stream control messages are received on, so
+// that Disconnect can close it. If the transport is already disconnected,
+// body is closed right away.
+func (t *HTTP) setStream(body io.Closer) {
+	t.streamLock.Lock()
+	defer t.streamLock.Unlock()
+	t.stream = body
+	if body != nil && t.disconnected.Load().(bool) {
+		body.Close()
+	}
 }
 
 // ReloadTLSConfig creates a new HTTP client with the provided TLS config.
 func (t *HTTP) ReloadTLSConfig(tlsConfig *tls.Config) error {
 	*t.client = *internalhttp.NewHTTPClient(tlsConfig, t.userAgent)
+	t.client.Headers = t.headers
+	t.client.Tokens = t.tokens
+	t.client.Negotiator = t.negotiator
 	t.isTLS.Store(tlsConfig != nil)
 	return nil
 }
@@ -146,6 +243,11 @@ func (t *HTTP) ReloadTLSConfig(tlsConfig *tls.Config) error {
 func (t *HTTP) Disconnect(quiesce uint) {
 	time.Sleep(time.Millisecond * time.Duration(quiesce))
 	t.disconnected.Store(true)
+	t.streamLock.Lock()
+	if t.stream != nil {
+		t.stream.Close()
+	}
+	t.streamLock.Unlock()
 	t.events <- TransporterEventDisconnected
 }
 
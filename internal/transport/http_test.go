@@ -10,12 +10,15 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/redhatinsights/yggdrasil/internal/config"
 	"github.com/redhatinsights/yggdrasil/internal/transport"
 )
 
@@ -164,6 +167,9 @@ func TestTx(t *testing.T) {
 				test.serverAddr,
 				nil,
 				"testUA",
+				nil,
+				nil,
+				nil,
 				time.Second,
 			)
 			if err != nil {
@@ -196,3 +202,74 @@ func TestTx(t *testing.T) {
 		})
 	}
 }
+
+func TestStreamControl(t *testing.T) {
+	config.DefaultConfig.HTTPReceiveMode = "sse"
+	defer func() { config.DefaultConfig.HTTPReceiveMode = "" }()
+
+	lastEventIDs := make(chan string, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !strings.HasSuffix(req.URL.Path, "/control/test/in") {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if req.Header.Get("Accept") != "text/event-stream" {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		select {
+		case lastEventIDs <- req.Header.Get("Last-Event-ID"):
+		default:
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "retry: 10\nid: 1\ndata: {\"type\":\"ping\"}\n\nevent: other\ndata: x\n\n")
+	}))
+	defer srv.Close()
+
+	httpTransport, err := transport.NewHTTPTransport(
+		"test",
+		strings.TrimPrefix(srv.URL, "http://"),
+		nil,
+		"testUA",
+		nil,
+		nil,
+		nil,
+		time.Hour,
+	)
+	if err != nil {
+		t.Fatalf("cannot create new transport: %v", err)
+	}
+	received := make(chan string, 2)
+	_ = httpTransport.SetRxHandler(func(addr string, metadata map[string]interface{}, data []byte) error {
+		if addr == "control" {
+			select {
+			case received <- string(data):
+			default:
+			}
+		}
+		return nil
+	})
+	if err := httpTransport.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer httpTransport.Disconnect(0)
+
+	for i, want := range []string{"", "1"} {
+		select {
+		case got := <-lastEventIDs:
+			if got != want {
+				t.Errorf("request %v: got Last-Event-ID %q, want %q", i, got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("request %v: timed out waiting for request", i)
+		}
+		select {
+		case got := <-received:
+			if got != `{"type":"ping"}` {
+				t.Errorf("got %v", got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("request %v: timed out waiting for message", i)
+		}
+	}
+}
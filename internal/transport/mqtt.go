@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"text/template"
 	"time"
 
 	"git.sr.ht/~spc/go-log"
@@ -13,6 +14,7 @@ import (
 	"github.com/redhatinsights/yggdrasil"
 	"github.com/redhatinsights/yggdrasil/internal/config"
 	"github.com/redhatinsights/yggdrasil/internal/constants"
+	internalhttp "github.com/redhatinsights/yggdrasil/internal/http"
 )
 
 // MQTT is a Transporter that sends and receives data and control
@@ -23,15 +25,28 @@ type MQTT struct {
 	opts           *mqtt.ClientOptions
 	events         chan TransporterEvent
 	eventHandler   EventHandlerFunc
+	topics         *template.Template
 }
 
 // NewMQTTTransport creates a transport suitable for transmitting data over a
-// set of MQTT topics.
-func NewMQTTTransport(clientID string, brokers []string, tlsConfig *tls.Config) (*MQTT, error) {
+// set of MQTT topics. If tokens is not nil, each connection authenticates
+// with an access token as password; otherwise the configured password is
+// used, if any.
+func NewMQTTTransport(clientID string, brokers []string, tlsConfig *tls.Config, tokens internalhttp.TokenSource) (*MQTT, error) {
 	var t MQTT
 
 	t.events = make(chan TransporterEvent)
 
+	topicTemplate := config.DefaultConfig.MQTTTopicTemplate
+	if topicTemplate == "" {
+		topicTemplate = DefaultTopicTemplate
+	}
+	topics, err := ParseTopicTemplate(topicTemplate)
+	if err != nil {
+		return nil, err
+	}
+	t.topics = topics
+
 	if _, ok := os.LookupEnv("MQTT_DEBUG"); ok {
 		mqtt.DEBUG = log.New(os.Stderr, "[MQTT_DEBUG] ", log.LstdFlags, log.LevelDebug)
 	}
@@ -46,6 +61,16 @@ func NewMQTTTransport(clientID string, brokers []string, tlsConfig *tls.Config)
 	opts.SetConnectRetry(config.DefaultConfig.MQTTConnectRetry)
 	opts.SetConnectRetryInterval(config.DefaultConfig.MQTTConnectRetryInterval)
 	opts.SetAutoReconnect(config.DefaultConfig.MQTTAutoReconnect)
+	if tokens != nil || config.DefaultConfig.MQTTUsername != "" ||
+		config.DefaultConfig.MQTTPassword != "" || config.DefaultConfig.MQTTPasswordFile != "" {
+		opts.SetCredentialsProvider(func() (string, string) {
+			username, password, err := credentials(clientID, tokens)
+			if err != nil {
+				log.Errorf("cannot authenticate to the broker: %v", err)
+			}
+			return username, password
+		})
+	}
 	opts.SetOnConnectHandler(func(c mqtt.Client) {
 		t.events <- TransporterEventConnected
 
@@ -57,12 +82,19 @@ func NewMQTTTransport(clientID string, brokers []string, tlsConfig *tls.Config)
 		// Publish a throwaway message in case the topic does not exist;
 		// this is a workaround for the Akamai MQTT broker implementation.
 		go func() {
-			topic := fmt.Sprintf("%v/%v/data/out", config.DefaultConfig.PathPrefix, opts.ClientID())
+			topic, err := t.topic(opts.ClientID(), "data", "out", "")
+			if err != nil {
+				log.Errorf("cannot publish placeholder message: %v", err)
+				return
+			}
 			c.Publish(topic, 0, false, []byte{})
 		}()
 
-		var topic string
-		topic = fmt.Sprintf("%v/%v/data/in", config.DefaultConfig.PathPrefix, opts.ClientID())
+		topic, err := t.topic(opts.ClientID(), "data", "in", "+")
+		if err != nil {
+			log.Errorf("cannot subscribe to data messages: %v", err)
+			return
+		}
 		c.Subscribe(topic, 1, func(c mqtt.Client, m mqtt.Message) {
 			go func() {
 				if t.receiveHandler == nil {
@@ -75,7 +107,11 @@ func NewMQTTTransport(clientID string, brokers []string, tlsConfig *tls.Config)
 		})
 		log.Tracef("subscribed to topic: %v", topic)
 
-		topic = fmt.Sprintf("%v/%v/control/in", config.DefaultConfig.PathPrefix, opts.ClientID())
+		topic, err = t.topic(opts.ClientID(), "control", "in", "")
+		if err != nil {
+			log.Errorf("cannot subscribe to control messages: %v", err)
+			return
+		}
 		c.Subscribe(topic, 1, func(c mqtt.Client, m mqtt.Message) {
 			go func() {
 				if t.receiveHandler == nil {
@@ -130,12 +166,11 @@ func NewMQTTTransport(clientID string, brokers []string, tlsConfig *tls.Config)
 		return nil, fmt.Errorf("cannot marshal message to JSON: %w", err)
 	}
 
-	opts.SetBinaryWill(
-		fmt.Sprintf("%v/%v/control/out", config.DefaultConfig.PathPrefix, opts.ClientID),
-		data,
-		1,
-		false,
-	)
+	willTopic, err := t.topic(opts.ClientID, "control", "out", "")
+	if err != nil {
+		return nil, err
+	}
+	opts.SetBinaryWill(willTopic, data, 1, false)
 
 	t.opts = opts
 	t.client = mqtt.NewClient(opts)
@@ -182,6 +217,25 @@ func (t *MQTT) ReloadTLSConfig(tlsConfig *tls.Config) error {
 	return t.Connect()
 }
 
+// credentials returns the username and password a connection to the broker
+// authenticates with. The username defaults to clientID when authenticating
+// with an access token from tokens.
+func credentials(clientID string, tokens internalhttp.TokenSource) (string, string, error) {
+	username := config.DefaultConfig.MQTTUsername
+	if tokens != nil {
+		if username == "" {
+			username = clientID
+		}
+		token, err := tokens.Token()
+		return username, token, err
+	}
+	if file := config.DefaultConfig.MQTTPasswordFile; file != "" {
+		password, err := config.ReadSecretFile(file)
+		return username, password, err
+	}
+	return username, config.DefaultConfig.MQTTPassword, nil
+}
+
 // Disconnect closes the connection to the MQTT broker, waiting for the
 // specified number of milliseconds for work to complete.
 func (t *MQTT) Disconnect(quiesce uint) {
@@ -196,7 +250,19 @@ func (t *MQTT) Tx(
 	data []byte,
 ) (responseCode int, responseMetadata map[string]string, responseData []byte, err error) {
 	opts := t.client.OptionsReader()
-	topic := fmt.Sprintf("%v/%v/%v/out", config.DefaultConfig.PathPrefix, opts.ClientID(), addr)
+	var directive string
+	if addr == "data" {
+		var message struct {
+			Directive string `json:"directive"`
+		}
+		if err := json.Unmarshal(data, &message); err == nil {
+			directive = message.Directive
+		}
+	}
+	topic, err := t.topic(opts.ClientID(), addr, "out", directive)
+	if err != nil {
+		return TxResponseErr, nil, nil, err
+	}
 
 	token := t.client.Publish(topic, 1, false, data)
 	if !token.WaitTimeout(config.DefaultConfig.MQTTPublishTimeout) {
@@ -214,6 +280,18 @@ func (t *MQTT) Tx(
 	return TxResponseOK, map[string]string{}, []byte{}, nil
 }
 
+// topic returns the topic of messages on channel in direction, executing the
+// topic template.
+func (t *MQTT) topic(clientID, channel, direction, directive string) (string, error) {
+	return executeTopic(t.topics, TopicVars{
+		Prefix:    config.DefaultConfig.PathPrefix,
+		ClientID:  clientID,
+		Channel:   channel,
+		Direction: direction,
+		Directive: directive,
+	})
+}
+
 // SetRxHandler stores a reference to f, which is then called whenever data is
 // received over the inbound data topic.
 func (t *MQTT) SetRxHandler(f RxHandlerFunc) error {
@@ -0,0 +1,88 @@
+package transport
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/redhatinsights/yggdrasil/internal/config"
+	internalhttp "github.com/redhatinsights/yggdrasil/internal/http"
+)
+
+// staticToken is a token source always providing the same token.
+type staticToken string
+
+func (t staticToken) Token() (string, error) {
+	return string(t), nil
+}
+
+func TestCredentials(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(passwordFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		description  string
+		conf         config.Config
+		tokens       internalhttp.TokenSource
+		wantUsername string
+		wantPassword string
+		wantError    bool
+	}{
+		{
+			description:  "password",
+			conf:         config.Config{MQTTUsername: "user", MQTTPassword: "hunter2"},
+			wantUsername: "user",
+			wantPassword: "hunter2",
+		},
+		{
+			description:  "password file",
+			conf:         config.Config{MQTTUsername: "user", MQTTPasswordFile: passwordFile},
+			wantUsername: "user",
+			wantPassword: "from-file",
+		},
+		{
+			description: "missing password file",
+			conf:        config.Config{MQTTPasswordFile: filepath.Join(dir, "missing")},
+			wantError:   true,
+		},
+		{
+			description:  "token",
+			conf:         config.Config{MQTTPassword: "hunter2"},
+			tokens:       staticToken("t0k3n"),
+			wantUsername: "client",
+			wantPassword: "t0k3n",
+		},
+		{
+			description:  "token with username",
+			conf:         config.Config{MQTTUsername: "user"},
+			tokens:       staticToken("t0k3n"),
+			wantUsername: "user",
+			wantPassword: "t0k3n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			defaultConfig := config.DefaultConfig
+			config.DefaultConfig = test.conf
+			defer func() { config.DefaultConfig = defaultConfig }()
+
+			username, password, err := credentials("client", test.tokens)
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if username != test.wantUsername || password != test.wantPassword {
+				t.Errorf("got %v:%v, want %v:%v", username, password, test.wantUsername, test.wantPassword)
+			}
+		})
+	}
+}
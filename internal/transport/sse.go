@@ -0,0 +1,74 @@
+package transport
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sseEvent is an event received on a Server-Sent Events stream.
+type sseEvent struct {
+	// ID is the last event ID set on the stream when the event was
+	// dispatched.
+	ID string
+
+	// Type is the event type, or "message" if the event did not set one.
+	Type string
+
+	// Data is the event data, its data lines joined by newlines.
+	Data []byte
+}
+
+// readEvents reads a Server-Sent Events stream from r, calling dispatch for
+// each event and setRetry for each valid retry field, until r returns an
+// error or EOF. Events without data are not dispatched.
+func readEvents(r io.Reader, lastEventID string, dispatch func(sseEvent), setRetry func(time.Duration)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var eventType string
+	var data strings.Builder
+	var hasData bool
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			if hasData {
+				if eventType == "" {
+					eventType = "message"
+				}
+				dispatch(sseEvent{ID: lastEventID, Type: eventType, Data: []byte(data.String())})
+			}
+			eventType = ""
+			data.Reset()
+			hasData = false
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 32); err == nil {
+				setRetry(time.Duration(ms) * time.Millisecond)
+			}
+		}
+	}
+	return scanner.Err()
+}
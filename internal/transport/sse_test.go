@@ -0,0 +1,76 @@
+package transport
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadEvents(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		lastEventID string
+		want        []sseEvent
+		wantRetry   time.Duration
+	}{
+		{
+			description: "single event",
+			input:       "data: {\"type\":\"ping\"}\n\n",
+			want:        []sseEvent{{Type: "message", Data: []byte(`{"type":"ping"}`)}},
+		},
+		{
+			description: "multiple data lines",
+			input:       "event: control\ndata: a\ndata:b\r\n\r\n",
+			want:        []sseEvent{{Type: "control", Data: []byte("a\nb")}},
+		},
+		{
+			description: "ids",
+			input:       "id: 1\ndata: a\n\ndata: b\n\nid: 3\ndata: c\n\n",
+			lastEventID: "0",
+			want: []sseEvent{
+				{ID: "1", Type: "message", Data: []byte("a")},
+				{ID: "1", Type: "message", Data: []byte("b")},
+				{ID: "3", Type: "message", Data: []byte("c")},
+			},
+		},
+		{
+			description: "comments and events without data",
+			input:       ": keepalive\n\nevent: control\n\nunknown: x\ndata: a\n\n",
+			want:        []sseEvent{{Type: "message", Data: []byte("a")}},
+		},
+		{
+			description: "incomplete event",
+			input:       "data: a\n\ndata: b\n",
+			want:        []sseEvent{{Type: "message", Data: []byte("a")}},
+		},
+		{
+			description: "retry",
+			input:       "retry: 1500\nretry: soon\n\n",
+			wantRetry:   1500 * time.Millisecond,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			var got []sseEvent
+			var gotRetry time.Duration
+			err := readEvents(strings.NewReader(test.input), test.lastEventID, func(event sseEvent) {
+				got = append(got, event)
+			}, func(d time.Duration) {
+				gotRetry = d
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("%v", cmp.Diff(got, test.want))
+			}
+			if gotRetry != test.wantRetry {
+				t.Errorf("got retry %v, want %v", gotRetry, test.wantRetry)
+			}
+		})
+	}
+}
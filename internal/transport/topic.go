@@ -0,0 +1,76 @@
+package transport
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// DefaultTopicTemplate is the layout of the MQTT topics yggd publishes and
+// subscribes to unless another template is configured.
+const DefaultTopicTemplate = "{{.Prefix}}/{{.ClientID}}/{{.Channel}}/{{.Direction}}"
+
+// TopicVars holds the values an MQTT topic template is executed with.
+type TopicVars struct {
+	// Prefix is the configured path prefix.
+	Prefix string
+
+	// ClientID is the client ID yggd connects with.
+	ClientID string
+
+	// Channel is "data" or "control".
+	Channel string
+
+	// Direction is "in" for messages sent to yggd and "out" for messages
+	// sent by yggd.
+	Direction string
+
+	// Directive is the directive of an outgoing data message, or "+" when
+	// subscribing to incoming data messages, so that messages for any
+	// directive are received. It is empty for control messages.
+	Directive string
+}
+
+// ParseTopicTemplate parses text as an MQTT topic template. The template must
+// yield a distinct topic without wildcards for each channel and direction.
+func ParseTopicTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("topic").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse topic template: %w", err)
+	}
+
+	seen := make(map[string]string)
+	for _, channel := range []string{"data", "control"} {
+		for _, direction := range []string{"in", "out"} {
+			vars := TopicVars{Prefix: "prefix", ClientID: "client", Channel: channel, Direction: direction}
+			if channel == "data" {
+				vars.Directive = "directive"
+			}
+			topic, err := executeTopic(tmpl, vars)
+			if err != nil {
+				return nil, err
+			}
+			if strings.ContainsAny(topic, "+#") {
+				return nil, fmt.Errorf("topic template yields topic '%v' with a wildcard", topic)
+			}
+			name := channel + "/" + direction
+			if other, has := seen[topic]; has {
+				return nil, fmt.Errorf("topic template yields the same topic '%v' for %v and %v", topic, other, name)
+			}
+			seen[topic] = name
+		}
+	}
+	return tmpl, nil
+}
+
+// executeTopic returns the topic tmpl yields for vars.
+func executeTopic(tmpl *template.Template, vars TopicVars) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return "", fmt.Errorf("cannot execute topic template: %w", err)
+	}
+	if b.Len() == 0 {
+		return "", fmt.Errorf("topic template yields an empty topic")
+	}
+	return b.String(), nil
+}
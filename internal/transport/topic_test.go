@@ -0,0 +1,106 @@
+package transport
+
+import (
+	"testing"
+)
+
+func TestParseTopicTemplate(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		wantError   bool
+	}{
+		{
+			description: "default",
+			input:       DefaultTopicTemplate,
+		},
+		{
+			description: "directive",
+			input:       "{{.Prefix}}/{{.Channel}}/{{.Direction}}/{{.ClientID}}{{with .Directive}}/{{.}}{{end}}",
+		},
+		{
+			description: "invalid syntax",
+			input:       "{{.Prefix}/{{.ClientID}}",
+			wantError:   true,
+		},
+		{
+			description: "unknown variable",
+			input:       "{{.Prefix}}/{{.Host}}/{{.Channel}}/{{.Direction}}",
+			wantError:   true,
+		},
+		{
+			description: "same topic for each direction",
+			input:       "{{.Prefix}}/{{.ClientID}}/{{.Channel}}",
+			wantError:   true,
+		},
+		{
+			description: "wildcard",
+			input:       "{{.Prefix}}/#/{{.Channel}}/{{.Direction}}",
+			wantError:   true,
+		},
+		{
+			description: "empty",
+			input:       "",
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			_, err := ParseTopicTemplate(test.input)
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestExecuteTopic(t *testing.T) {
+	tests := []struct {
+		description string
+		template    string
+		input       TopicVars
+		want        string
+	}{
+		{
+			description: "default",
+			template:    DefaultTopicTemplate,
+			input:       TopicVars{Prefix: "yggdrasil", ClientID: "abc", Channel: "data", Direction: "out", Directive: "echo"},
+			want:        "yggdrasil/abc/data/out",
+		},
+		{
+			description: "directive",
+			template:    "{{.Prefix}}/{{.ClientID}}/{{.Channel}}/{{.Direction}}{{with .Directive}}/{{.}}{{end}}",
+			input:       TopicVars{Prefix: "yggdrasil", ClientID: "abc", Channel: "data", Direction: "in", Directive: "+"},
+			want:        "yggdrasil/abc/data/in/+",
+		},
+		{
+			description: "control without directive",
+			template:    "{{.Prefix}}/{{.ClientID}}/{{.Channel}}/{{.Direction}}{{with .Directive}}/{{.}}{{end}}",
+			input:       TopicVars{Prefix: "yggdrasil", ClientID: "abc", Channel: "control", Direction: "out"},
+			want:        "yggdrasil/abc/control/out",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			tmpl, err := ParseTopicTemplate(test.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := executeTopic(tmpl, test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+// Package watch notifies of changes to files and directories. Paths are
+// watched with inotify where the file system delivers inotify events. Paths on
+// network and user-space file systems, such as NFS, and paths inotify cannot
+// watch, such as files that do not exist yet, are polled instead, comparing
+// their modification times.
+package watch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/rjeczalik/notify"
+	"golang.org/x/sys/unix"
+)
+
+// DefaultPollInterval is the interval at which polled paths are checked when
+// no interval is given.
+const DefaultPollInterval = 5 * time.Second
+
+// pollingFileSystems are the types of file systems on which changes made by
+// other hosts or by a user-space server are not reported by inotify.
+var pollingFileSystems = map[int64]string{
+	unix.NFS_SUPER_MAGIC:  "nfs",
+	unix.CIFS_SUPER_MAGIC: "cifs",
+	unix.SMB_SUPER_MAGIC:  "smb",
+	unix.SMB2_SUPER_MAGIC: "smb2",
+	unix.FUSE_SUPER_MAGIC: "fuse",
+	unix.V9FS_MAGIC:       "9p",
+}
+
+// Watcher sends the path of each watched file or directory that changes on C.
+// A directory changes when an entry is added, removed or written.
+type Watcher struct {
+	// C receives the path of each changed file or directory.
+	C <-chan string
+
+	c      chan string
+	events chan notify.EventInfo
+	stop   chan struct{}
+	once   sync.Once
+}
+
+// New starts watching paths, polling those inotify cannot watch reliably
+// every interval.
+func New(paths []string, interval time.Duration) *Watcher {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	c := make(chan string, 1)
+	w := &Watcher{
+		C:      c,
+		c:      c,
+		events: make(chan notify.EventInfo, 1),
+		stop:   make(chan struct{}),
+	}
+
+	var polled []string
+	for _, path := range paths {
+		if fs, ok := pollingFileSystem(path); ok {
+			log.Debugf("polling '%v' every %v: %v does not support inotify", path, interval, fs)
+			polled = append(polled, path)
+			continue
+		}
+		if err := notify.Watch(path, w.events, notify.InCloseWrite, notify.InDelete, notify.InMovedTo); err != nil {
+			log.Debugf("polling '%v' every %v: cannot watch with inotify: %v", path, interval, err)
+			polled = append(polled, path)
+			continue
+		}
+		log.Debugf("added watchpoint for file: %v", path)
+	}
+
+	go w.forward(paths)
+	if len(polled) > 0 {
+		// Take the first fingerprints before returning, so changes made once
+		// New returns are not missed.
+		last := make(map[string]string, len(polled))
+		for _, path := range polled {
+			last[path] = fingerprint(path)
+		}
+		go w.poll(last, interval)
+	}
+	return w
+}
+
+// Stop stops watching. No more paths are sent on C after Stop returns.
+func (w *Watcher) Stop() {
+	w.once.Do(func() {
+		notify.Stop(w.events)
+		close(w.stop)
+	})
+}
+
+// forward sends the watched path of each inotify event on C. Events for
+// entries of a watched directory are reported as a change to the directory.
+func (w *Watcher) forward(paths []string) {
+	for {
+		select {
+		case <-w.stop:
+			return
+		case e := <-w.events:
+			log.Debugf("received inotify event %v", e.Event())
+			path := e.Path()
+			for _, p := range paths {
+				abs, err := filepath.Abs(p)
+				if err == nil && (abs == path || abs == filepath.Dir(path)) {
+					path = p
+					break
+				}
+			}
+			w.send(path)
+		}
+	}
+}
+
+// poll checks the paths in last every interval, sending each path whose
+// fingerprint changed from the one in last on C.
+func (w *Watcher) poll(last map[string]string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+		for path, previous := range last {
+			f := fingerprint(path)
+			if f == previous {
+				continue
+			}
+			last[path] = f
+			log.Debugf("detected change to '%v' by polling", path)
+			w.send(path)
+		}
+	}
+}
+
+// send sends path on C unless the watcher is stopped first.
+func (w *Watcher) send(path string) {
+	select {
+	case w.c <- path:
+	case <-w.stop:
+	}
+}
+
+// fingerprint summarizes the state of the file or directory at path, so that
+// a change to it yields a different fingerprint. A directory is summarized by
+// its entries.
+func fingerprint(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ""
+		}
+		return err.Error()
+	}
+	if !info.IsDir() {
+		return fmt.Sprintf("%v %v", info.ModTime().UnixNano(), info.Size())
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err.Error()
+	}
+	f := ""
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		f += fmt.Sprintf("%v %v %v\n", entry.Name(), info.ModTime().UnixNano(), info.Size())
+	}
+	return f
+}
+
+// pollingFileSystem returns the name of the file system path is on, or would
+// be created on, and true if changes to it must be polled.
+func pollingFileSystem(path string) (string, bool) {
+	var st unix.Statfs_t
+	for {
+		err := unix.Statfs(path, &st)
+		if err == nil {
+			break
+		}
+		parent := filepath.Dir(path)
+		if !errors.Is(err, unix.ENOENT) || parent == path {
+			return "", false
+		}
+		path = parent
+	}
+	fs, ok := pollingFileSystems[int64(st.Type)]
+	return fs, ok
+}
@@ -0,0 +1,75 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.toml")
+
+	tests := []struct {
+		description string
+		path        string
+		change      func() error
+	}{
+		{
+			description: "file created",
+			path:        file,
+			change:      func() error { return os.WriteFile(file, []byte("a"), 0644) },
+		},
+		{
+			description: "file written",
+			path:        file,
+			change:      func() error { return os.WriteFile(file, []byte("ab"), 0644) },
+		},
+		{
+			description: "directory entry added",
+			path:        dir,
+			change:      func() error { return os.WriteFile(filepath.Join(dir, "10-extra.toml"), nil, 0644) },
+		},
+		{
+			description: "file removed",
+			path:        file,
+			change:      func() error { return os.Remove(file) },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			before := fingerprint(test.path)
+			if err := test.change(); err != nil {
+				t.Fatal(err)
+			}
+			if after := fingerprint(test.path); after == before {
+				t.Errorf("fingerprint unchanged: %q", after)
+			}
+		})
+	}
+}
+
+func TestWatcherPolls(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "missing", "tags.toml")
+
+	w := New([]string{file}, 10*time.Millisecond)
+	defer w.Stop()
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(`key = "value"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-w.C:
+		if got != file {
+			t.Errorf("%v != %v", got, file)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no change detected")
+	}
+}
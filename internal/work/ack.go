@@ -0,0 +1,174 @@
+package work
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redhatinsights/yggdrasil"
+	"github.com/redhatinsights/yggdrasil/internal/config"
+	"github.com/redhatinsights/yggdrasil/internal/logging"
+)
+
+// ErrWorkerExited is the error a message is redelivered with when its worker
+// exited before emitting the END event for it.
+var ErrWorkerExited = errors.New("worker exited before finishing the message")
+
+// unackedMessage is a message dispatched to a worker and the last time the
+// worker was dispatched it or reported working on it.
+type unackedMessage struct {
+	data   yggdrasil.Data
+	active time.Time
+}
+
+// unackedMessages tracks the messages dispatched to each worker that the
+// worker has not yet acknowledged by emitting their END event, and how many
+// times each message has been redelivered after its worker exited.
+type unackedMessages struct {
+	mu          sync.Mutex
+	workers     map[string]map[string]*unackedMessage
+	redelivered map[string]int
+}
+
+// dispatched records that data was dispatched to worker at now.
+func (u *unackedMessages) dispatched(worker string, data yggdrasil.Data, now time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.workers == nil {
+		u.workers = make(map[string]map[string]*unackedMessage)
+	}
+	if u.workers[worker] == nil {
+		u.workers[worker] = make(map[string]*unackedMessage)
+	}
+	u.workers[worker][data.MessageID] = &unackedMessage{data: data, active: now}
+}
+
+// touch records that worker reported working on the message identified by
+// messageID at now.
+func (u *unackedMessages) touch(worker, messageID string, now time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if m, has := u.workers[worker][messageID]; has {
+		m.active = now
+	}
+}
+
+// stalled returns the IDs, sorted, of the messages dispatched to worker that
+// the worker has neither finished nor reported working on since before
+// now-timeout.
+func (u *unackedMessages) stalled(worker string, timeout time.Duration, now time.Time) []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var ids []string
+	for id, m := range u.workers[worker] {
+		if now.Sub(m.active) > timeout {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// ack records that worker finished handling the message identified by
+// messageID, returning the message and true if it was being tracked.
+func (u *unackedMessages) ack(worker, messageID string) (yggdrasil.Data, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	m, has := u.workers[worker][messageID]
+	if !has {
+		return yggdrasil.Data{}, false
+	}
+	delete(u.workers[worker], messageID)
+	delete(u.redelivered, messageID)
+	return m.data, true
+}
+
+// undo forgets that the message identified by messageID was dispatched to
+// worker, because dispatching it failed.
+func (u *unackedMessages) undo(worker, messageID string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.workers[worker], messageID)
+}
+
+// abandon forgets the messages dispatched to worker, for example because it
+// exited, and returns them along with the number of times each has now been
+// redelivered.
+func (u *unackedMessages) abandon(worker string) ([]yggdrasil.Data, []int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var messages []yggdrasil.Data
+	var counts []int
+	for id, m := range u.workers[worker] {
+		if u.redelivered == nil {
+			u.redelivered = make(map[string]int)
+		}
+		u.redelivered[id]++
+		messages = append(messages, m.data)
+		counts = append(counts, u.redelivered[id])
+	}
+	delete(u.workers, worker)
+	return messages, counts
+}
+
+// forget stops counting the redeliveries of the message identified by
+// messageID.
+func (u *unackedMessages) forget(messageID string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.redelivered, messageID)
+}
+
+// acknowledge removes the message identified by messageID from the pending
+// journal once worker has emitted its END event.
+func (d *Dispatcher) acknowledge(worker, messageID string) {
+	if data, ok := d.unacked.ack(worker, messageID); ok {
+		d.removePending(data)
+	}
+}
+
+// stalledMessages returns an error listing the messages dispatched to worker
+// that it has neither finished nor reported working on within its response
+// timeout, or nil if there are none or the worker has no response timeout.
+func (d *Dispatcher) stalledMessages(worker string) error {
+	timeout := d.responseTimeout(worker)
+	if timeout <= 0 {
+		return nil
+	}
+	ids := d.unacked.stalled(worker, timeout, time.Now())
+	if len(ids) == 0 {
+		return nil
+	}
+	return fmt.Errorf("no progress on messages %v within %v", ids, timeout)
+}
+
+// redeliverAbandoned dispatches the messages worker had not acknowledged when
+// it exited again, up to config.DefaultConfig.DispatchRetries times each. A
+// message that exhausts its redeliveries is reported as failed.
+func (d *Dispatcher) redeliverAbandoned(worker string) {
+	messages, counts := d.unacked.abandon(worker)
+	for i, data := range messages {
+		if counts[i] > config.DefaultConfig.DispatchRetries {
+			logging.With(worker, data.MessageID).Errorf("giving up dispatching message %v to worker %v: %v", data.MessageID, worker, ErrWorkerExited)
+			d.unacked.forget(data.MessageID)
+			d.trace(DispatchEventFailed, data, ErrWorkerExited.Error())
+			d.removePending(data)
+			go d.fail(data, fmt.Errorf("%w %v times", ErrWorkerExited, counts[i]))
+			continue
+		}
+		logging.With(worker, data.MessageID).Warnf("redelivering message %v: %v", data.MessageID, ErrWorkerExited)
+		go d.retryDispatch(
+			data,
+			ErrWorkerExited,
+			config.DefaultConfig.DispatchRetries,
+			config.DefaultConfig.DispatchRetryDelay,
+		)
+	}
+}
@@ -0,0 +1,202 @@
+package work
+
+import (
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/google/go-cmp/cmp"
+	"github.com/redhatinsights/yggdrasil"
+	"github.com/redhatinsights/yggdrasil/internal/config"
+	"github.com/redhatinsights/yggdrasil/ipc"
+)
+
+func TestUnackedMessages(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var u unackedMessages
+	u.dispatched("echo", yggdrasil.Data{MessageID: "a"}, now)
+	u.dispatched("echo", yggdrasil.Data{MessageID: "b"}, now)
+	u.dispatched("other", yggdrasil.Data{MessageID: "c"}, now)
+
+	if _, ok := u.ack("other", "a"); ok {
+		t.Error("acknowledged message of another worker")
+	}
+	if data, ok := u.ack("echo", "a"); !ok || data.MessageID != "a" {
+		t.Errorf("got %v, %v, want a, true", data.MessageID, ok)
+	}
+
+	for want := 1; want <= 2; want++ {
+		messages, counts := u.abandon("echo")
+		if len(messages) != 1 || messages[0].MessageID != "b" {
+			t.Fatalf("got %v, want [b]", messages)
+		}
+		if !cmp.Equal(counts, []int{want}) {
+			t.Errorf("got %v, want [%v]", counts, want)
+		}
+		u.dispatched("echo", messages[0], now)
+	}
+
+	u.ack("echo", "b")
+	u.dispatched("echo", yggdrasil.Data{MessageID: "b"}, now)
+	if _, counts := u.abandon("echo"); !cmp.Equal(counts, []int{1}) {
+		t.Errorf("redeliveries not reset by acknowledgment: %v", counts)
+	}
+	if messages, _ := u.abandon("echo"); len(messages) != 0 {
+		t.Errorf("unexpected messages: %v", messages)
+	}
+}
+
+func TestUnackedMessagesStalled(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var u unackedMessages
+	u.dispatched("echo", yggdrasil.Data{MessageID: "a"}, now)
+	u.dispatched("echo", yggdrasil.Data{MessageID: "b"}, now)
+
+	now = now.Add(time.Minute)
+	if got := u.stalled("echo", time.Minute, now); len(got) != 0 {
+		t.Errorf("got stalled %v within timeout", got)
+	}
+
+	u.touch("echo", "b", now)
+	now = now.Add(time.Second)
+	if got := u.stalled("echo", time.Minute, now); !cmp.Equal(got, []string{"a"}) {
+		t.Errorf("got stalled %v, want [a]", got)
+	}
+	if got := u.stalled("other", time.Minute, now); len(got) != 0 {
+		t.Errorf("got stalled %v for other worker", got)
+	}
+}
+
+// fakeWorker implements the com.redhat.Yggdrasil1.Worker1 interface,
+// emitting the BEGIN event for every message dispatched to it and, if finish
+// is set, the END event.
+type fakeWorker struct {
+	conn       *dbus.Conn
+	finish     bool
+	dispatched chan string
+}
+
+func (w *fakeWorker) emit(event ipc.WorkerEventName, messageID string) {
+	_ = w.conn.Emit(
+		"/com/redhat/Yggdrasil1/Worker1/echo",
+		"com.redhat.Yggdrasil1.Worker1.Event",
+		uint32(event), messageID, "", map[string]string{},
+	)
+}
+
+func (w *fakeWorker) Dispatch(addr, id, responseTo string, metadata map[string]string, data []byte) *dbus.Error {
+	w.emit(ipc.WorkerEventNameBegin, id)
+	w.dispatched <- id
+	if w.finish {
+		w.emit(ipc.WorkerEventNameEnd, id)
+	}
+	return nil
+}
+
+// startFakeWorker connects a fake "echo" worker to the bus at address.
+func startFakeWorker(t *testing.T, address string, finish bool) *fakeWorker {
+	t.Helper()
+	w := &fakeWorker{finish: finish, dispatched: make(chan string, 1)}
+	w.conn = connectTestBus(t, address)
+	if err := w.conn.Export(w, "/com/redhat/Yggdrasil1/Worker1/echo", "com.redhat.Yggdrasil1.Worker1"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := prop.Export(w.conn, "/com/redhat/Yggdrasil1/Worker1/echo", prop.Map{
+		"com.redhat.Yggdrasil1.Worker1": {
+			"RemoteContent": {Value: false},
+			"Features":      {Value: map[string]string{}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := w.conn.RequestName("com.redhat.Yggdrasil1.Worker1.echo", dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("cannot own worker name: %v", err)
+	}
+	return w
+}
+
+func TestRedeliverAfterWorkerExit(t *testing.T) {
+	address := startTestBus(t)
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", address)
+
+	defaults := config.DefaultConfig
+	t.Cleanup(func() { config.DefaultConfig = defaults })
+	config.DefaultConfig.DispatchRetries = 5
+	config.DefaultConfig.DispatchRetryDelay = 10 * time.Millisecond
+
+	d := NewDispatcher(nil)
+	d.PendingDir = t.TempDir()
+	failures := make(chan yggdrasil.Data, 1)
+	go func() {
+		for {
+			select {
+			case <-d.Dispatchers:
+			case <-d.WorkerEvents:
+			case <-d.WorkerLifecycle:
+			case <-d.DispatchEvents:
+			case data := <-d.Failures:
+				failures <- data
+			}
+		}
+	}()
+	if err := d.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first worker exits after the BEGIN event, before the END event.
+	first := startFakeWorker(t, address, false)
+	d.Inbound <- yggdrasil.Data{
+		Type:      yggdrasil.MessageTypeData,
+		MessageID: "m1",
+		Directive: "echo",
+		Sent:      time.Now(),
+		Content:   []byte(`"hello"`),
+	}
+	waitDispatched(t, first, "m1")
+	if n, err := d.PendingMessages(); err != nil || n != 1 {
+		t.Fatalf("got %v pending messages (%v), want 1 until the worker finishes", n, err)
+	}
+	first.conn.Close()
+
+	// The message is dispatched again to the restarted worker, which
+	// finishes it.
+	second := startFakeWorker(t, address, true)
+	waitDispatched(t, second, "m1")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		n, err := d.PendingMessages()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("message still pending after the worker finished it")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case data := <-failures:
+		t.Errorf("unexpected failure of message %v", data.MessageID)
+	default:
+	}
+}
+
+// waitDispatched waits for the message identified by messageID to be
+// dispatched to w.
+func waitDispatched(t *testing.T, w *fakeWorker, messageID string) {
+	t.Helper()
+	select {
+	case got := <-w.dispatched:
+		if got != messageID {
+			t.Fatalf("got message %v, want %v", got, messageID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("message %v was not dispatched", messageID)
+	}
+}
@@ -0,0 +1,55 @@
+package work
+
+import (
+	"fmt"
+	"strings"
+
+	"git.sr.ht/~spc/go-log"
+)
+
+// ParseDirectiveAliases parses a list of "DIRECTIVE=WORKER" entries into a
+// map from directive to worker name. Several directives may map to the same
+// worker.
+func ParseDirectiveAliases(entries []string) (map[string]string, error) {
+	aliases := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		directive, worker, ok := strings.Cut(entry, "=")
+		directive = strings.TrimSpace(directive)
+		worker = strings.TrimSpace(worker)
+		if !ok || directive == "" || worker == "" {
+			return nil, fmt.Errorf("invalid directive alias '%v': expected DIRECTIVE=WORKER", entry)
+		}
+		if _, has := aliases[directive]; has {
+			return nil, fmt.Errorf("duplicate directive alias '%v'", directive)
+		}
+		aliases[directive] = worker
+	}
+	return aliases, nil
+}
+
+// SetDirectiveAliases replaces the dispatcher's routing table, which maps
+// directives received from the server to local worker names.
+func (d *Dispatcher) SetDirectiveAliases(aliases map[string]string) {
+	var removed []string
+	d.aliases.Visit(func(k string, _ string) {
+		if _, has := aliases[k]; !has {
+			removed = append(removed, k)
+		}
+	})
+	for _, directive := range removed {
+		d.aliases.Del(directive)
+	}
+	for directive, worker := range aliases {
+		d.aliases.Set(directive, worker)
+	}
+}
+
+// resolveDirective returns the worker name directive is routed to by the
+// dispatcher's routing table, or directive itself if it has no alias.
+func (d *Dispatcher) resolveDirective(directive string) string {
+	if worker, has := d.aliases.Get(directive); has {
+		log.Debugf("routing directive %v to worker %v", directive, worker)
+		return worker
+	}
+	return directive
+}
@@ -0,0 +1,89 @@
+package work
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseDirectiveAliases(t *testing.T) {
+	tests := []struct {
+		description string
+		input       []string
+		want        map[string]string
+		wantError   bool
+	}{
+		{
+			description: "empty",
+			input:       []string{},
+			want:        map[string]string{},
+		},
+		{
+			description: "many to one",
+			input:       []string{"rhc-worker-playbook=playbook", "package-manager = playbook"},
+			want: map[string]string{
+				"rhc-worker-playbook": "playbook",
+				"package-manager":     "playbook",
+			},
+		},
+		{
+			description: "missing worker",
+			input:       []string{"echo="},
+			wantError:   true,
+		},
+		{
+			description: "missing separator",
+			input:       []string{"echo"},
+			wantError:   true,
+		},
+		{
+			description: "duplicate",
+			input:       []string{"echo=a", "echo=b"},
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got, err := ParseDirectiveAliases(test.input)
+
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got %v", got)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !cmp.Equal(got, test.want) {
+					t.Errorf("%v", cmp.Diff(got, test.want))
+				}
+			}
+		})
+	}
+}
+
+func TestResolveDirective(t *testing.T) {
+	d := NewDispatcher(nil)
+	d.SetDirectiveAliases(map[string]string{"old": "echo", "other": "test"})
+	d.SetDirectiveAliases(map[string]string{"old": "echo"})
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "old", want: "echo"},
+		{input: "other", want: "other"},
+		{input: "echo", want: "echo"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got := d.resolveDirective(test.input)
+
+			if got != test.want {
+				t.Errorf("%q != %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package work
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/redhatinsights/yggdrasil"
+)
+
+// BroadcastDirective is the directive of a message delivered to every
+// available worker.
+const BroadcastDirective = "*"
+
+// broadcastStatusOK is the delivery status of a worker that accepted a
+// broadcast message.
+const broadcastStatusOK = "ok"
+
+// broadcastTargets returns the sorted names of the workers a broadcast message
+// is delivered to: every known worker that is neither disabled nor excluded.
+func (d *Dispatcher) broadcastTargets() []string {
+	var workers []string
+	d.features.Visit(func(k string, _ map[string]string) {
+		if d.WorkerDisabled(k) || d.WorkerExcluded(k) {
+			return
+		}
+		workers = append(workers, k)
+	})
+	sort.Strings(workers)
+	return workers
+}
+
+// Broadcast dispatches a copy of data to every available worker. It returns
+// the delivery status of each worker: "ok" if the worker accepted the message,
+// or the error that prevented delivery.
+func (d *Dispatcher) Broadcast(data yggdrasil.Data) map[string]string {
+	statuses := make(map[string]string)
+	for _, worker := range d.broadcastTargets() {
+		msg := data
+		msg.Directive = worker
+		if err := d.Dispatch(msg); err != nil {
+			statuses[worker] = err.Error()
+			continue
+		}
+		statuses[worker] = broadcastStatusOK
+	}
+	return statuses
+}
+
+// broadcastError returns an error listing the workers that failed to accept a
+// broadcast message, or nil if every worker accepted it.
+func broadcastError(statuses map[string]string) error {
+	var errs []error
+	workers := make([]string, 0, len(statuses))
+	for worker := range statuses {
+		workers = append(workers, worker)
+	}
+	sort.Strings(workers)
+	for _, worker := range workers {
+		if statuses[worker] != broadcastStatusOK {
+			errs = append(errs, fmt.Errorf("%v: %v", worker, statuses[worker]))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// replyBroadcast broadcasts data received from the server and sends the
+// aggregated delivery status back to the server as a data message in reply to
+// data.
+func (d *Dispatcher) replyBroadcast(data yggdrasil.Data) {
+	statuses := d.Broadcast(data)
+
+	content, err := json.Marshal(statuses)
+	if err != nil {
+		log.Errorf("cannot marshal broadcast status: %v", err)
+		return
+	}
+
+	if err := d.reply(BroadcastDirective, data.MessageID, content); err != nil {
+		log.Errorf("cannot send broadcast status: %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package work
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBroadcastTargets(t *testing.T) {
+	d := NewDispatcher(nil)
+	d.features.Set("echo", map[string]string{})
+	d.features.Set("test", map[string]string{})
+	d.features.Set("other", map[string]string{})
+	d.disabled.Set("other", true)
+
+	got := d.broadcastTargets()
+	want := []string{"echo", "test"}
+
+	if !cmp.Equal(got, want) {
+		t.Errorf("%v", cmp.Diff(got, want))
+	}
+}
+
+func TestBroadcastError(t *testing.T) {
+	tests := []struct {
+		description string
+		input       map[string]string
+		want        string
+	}{
+		{
+			description: "all delivered",
+			input:       map[string]string{"echo": "ok", "test": "ok"},
+		},
+		{
+			description: "failures",
+			input: map[string]string{
+				"echo":  "ok",
+				"test":  "worker test is disabled",
+				"other": "cannot call method",
+			},
+			want: "other: cannot call method\ntest: worker test is disabled",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			err := broadcastError(test.input)
+
+			if test.want == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			} else {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				if err.Error() != test.want {
+					t.Errorf("%q != %q", err.Error(), test.want)
+				}
+			}
+		})
+	}
+}
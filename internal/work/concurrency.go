@@ -0,0 +1,332 @@
+package work
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/redhatinsights/yggdrasil"
+	"github.com/redhatinsights/yggdrasil/internal/config"
+	"github.com/redhatinsights/yggdrasil/internal/logging"
+	"github.com/redhatinsights/yggdrasil/ipc"
+)
+
+// Policies applied when a message arrives for a worker whose queue is full.
+const (
+	// OverflowReject reports the new message as failed.
+	OverflowReject = "reject"
+
+	// OverflowDropOldest reports the oldest queued message as failed and
+	// queues the new message.
+	OverflowDropOldest = "drop-oldest"
+
+	// OverflowBlock holds the new message back until the queue has room,
+	// without delaying messages for other workers until heldCapacity messages
+	// are held back.
+	OverflowBlock = "block"
+)
+
+// heldCapacity is the number of messages held back for a worker under
+// OverflowBlock before acquiring waits for the worker's queue to have room,
+// which stops further messages from being received.
+const heldCapacity = 100
+
+// ValidateOverflowPolicy returns an error if policy is not a known overflow
+// policy.
+func ValidateOverflowPolicy(policy string) error {
+	switch policy {
+	case OverflowReject, OverflowDropOldest, OverflowBlock:
+		return nil
+	default:
+		return fmt.Errorf("unknown overflow policy: %v", policy)
+	}
+}
+
+// workerQueue holds the messages being handled by a worker, those waiting
+// for the worker to have capacity and, under OverflowBlock, those held back
+// until the waiting queue has room.
+type workerQueue struct {
+	inFlight map[string]bool
+	waiting  []yggdrasil.Data
+	held     []yggdrasil.Data
+	depth    int
+}
+
+// dispatchQueues limits the number of messages each worker handles at once,
+// queueing the rest.
+type dispatchQueues struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	workers map[string]*workerQueue
+}
+
+// init prepares q for use. The caller must hold q.mu.
+func (q *dispatchQueues) init() {
+	if q.cond == nil {
+		q.cond = sync.NewCond(&q.mu)
+	}
+}
+
+// get returns the queue for worker, creating it if necessary. The caller must
+// hold q.mu.
+func (q *dispatchQueues) get(worker string) *workerQueue {
+	if q.workers == nil {
+		q.workers = make(map[string]*workerQueue)
+	}
+	wq, has := q.workers[worker]
+	if !has {
+		wq = &workerQueue{inFlight: make(map[string]bool)}
+		q.workers[worker] = wq
+	}
+	return wq
+}
+
+// acquire returns true if data may be dispatched now to a worker handling at
+// most limit messages at once. Otherwise data is queued, subject to depth and
+// policy; messages dropped from the queue are returned. Under OverflowBlock,
+// acquire blocks while heldCapacity messages are held back for the worker.
+func (q *dispatchQueues) acquire(data yggdrasil.Data, limit, depth int, policy string) (bool, []yggdrasil.Data) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.init()
+
+	wq := q.get(data.Directive)
+	wq.depth = depth
+	for policy == OverflowBlock && len(wq.held) >= heldCapacity {
+		q.cond.Wait()
+	}
+	if len(wq.inFlight) < limit && len(wq.waiting) == 0 && len(wq.held) == 0 {
+		wq.inFlight[data.MessageID] = true
+		return true, nil
+	}
+
+	if len(wq.waiting) >= depth || len(wq.held) > 0 {
+		switch policy {
+		case OverflowDropOldest:
+			if depth == 0 {
+				return false, []yggdrasil.Data{data}
+			}
+			dropped := wq.waiting[0]
+			wq.waiting = append(wq.waiting[1:], data)
+			return false, []yggdrasil.Data{dropped}
+		case OverflowBlock:
+			wq.held = append(wq.held, data)
+			return false, nil
+		default:
+			return false, []yggdrasil.Data{data}
+		}
+	}
+	wq.waiting = append(wq.waiting, data)
+	return false, nil
+}
+
+// release records that worker finished handling the message identified by
+// messageID and returns the next queued message, if any, which is then
+// considered in flight.
+func (q *dispatchQueues) release(worker, messageID string) (yggdrasil.Data, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	wq := q.get(worker)
+	if !wq.inFlight[messageID] {
+		return yggdrasil.Data{}, false
+	}
+	delete(wq.inFlight, messageID)
+	return q.next(wq)
+}
+
+// reset forgets the messages in flight to worker, for example because the
+// worker exited, and returns the next queued message, if any.
+func (q *dispatchQueues) reset(worker string) (yggdrasil.Data, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	wq := q.get(worker)
+	wq.inFlight = make(map[string]bool)
+	return q.next(wq)
+}
+
+// next moves the oldest waiting message of the highest priority of wq in
+// flight and returns it, then moves held messages into the waiting queue as
+// far as it has room, waking callers of acquire waiting for room. The caller
+// must hold q.mu.
+func (q *dispatchQueues) next(wq *workerQueue) (yggdrasil.Data, bool) {
+	defer func() {
+		for len(wq.held) > 0 && len(wq.waiting) < wq.depth {
+			wq.waiting = append(wq.waiting, wq.held[0])
+			wq.held = wq.held[1:]
+		}
+		q.init()
+		q.cond.Broadcast()
+	}()
+	if len(wq.waiting) == 0 {
+		if len(wq.held) == 0 {
+			return yggdrasil.Data{}, false
+		}
+		data := wq.held[0]
+		wq.held = wq.held[1:]
+		wq.inFlight[data.MessageID] = true
+		return data, true
+	}
+	n := 0
+	for i := range wq.waiting {
+		if messagePriority(wq.waiting[i].Metadata) < messagePriority(wq.waiting[n].Metadata) {
+			n = i
+		}
+	}
+	data := wq.waiting[n]
+	wq.waiting = append(wq.waiting[:n], wq.waiting[n+1:]...)
+	wq.inFlight[data.MessageID] = true
+	return data, true
+}
+
+// snapshot returns the IDs of the messages in flight to and waiting for each
+// worker with a non-empty queue.
+func (q *dispatchQueues) snapshot() map[string]QueueState {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	state := make(map[string]QueueState)
+	for worker, wq := range q.workers {
+		if len(wq.inFlight) == 0 && len(wq.waiting) == 0 && len(wq.held) == 0 {
+			continue
+		}
+		s := QueueState{InFlight: []string{}, Waiting: []string{}}
+		for id := range wq.inFlight {
+			s.InFlight = append(s.InFlight, id)
+		}
+		sort.Strings(s.InFlight)
+		for _, data := range wq.waiting {
+			s.Waiting = append(s.Waiting, data.MessageID)
+		}
+		for _, data := range wq.held {
+			s.Held = append(s.Held, data.MessageID)
+		}
+		state[worker] = s
+	}
+	return state
+}
+
+// QueueState describes the messages handled by a worker with a concurrency
+// limit, the messages waiting for it to have capacity and those held back
+// because the waiting queue is full.
+type QueueState struct {
+	InFlight []string `json:"in_flight"`
+	Waiting  []string `json:"waiting"`
+	Held     []string `json:"held,omitempty"`
+}
+
+// DispatchQueues returns the state of the queue of each worker that has
+// messages in flight or waiting.
+func (d *Dispatcher) DispatchQueues() map[string]QueueState {
+	return d.queues.snapshot()
+}
+
+// ParseConcurrencyLimits parses a list of "DIRECTIVE=N" entries into a map
+// from directive to the maximum number of messages its worker handles at once.
+func ParseConcurrencyLimits(entries []string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, entry := range entries {
+		directive, value, err := parseLimitEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid concurrency limit '%v': expected a positive number of messages", entry)
+		}
+		if _, has := limits[directive]; has {
+			return nil, fmt.Errorf("duplicate concurrency limit for '%v'", directive)
+		}
+		limits[directive] = n
+	}
+	return limits, nil
+}
+
+// SetConcurrencyLimits replaces the concurrency limits configured for each
+// worker. Messages already queued stay queued.
+func (d *Dispatcher) SetConcurrencyLimits(limits map[string]int) {
+	var removed []string
+	d.concurrency.Visit(func(k string, _ int) {
+		if _, has := limits[k]; !has {
+			removed = append(removed, k)
+		}
+	})
+	for _, worker := range removed {
+		d.concurrency.Del(worker)
+	}
+	for worker, limit := range limits {
+		d.concurrency.Set(worker, limit)
+	}
+}
+
+// maxConcurrency returns the maximum number of messages worker handles at
+// once, as configured by SetConcurrencyLimits or else declared by the
+// FeatureMaxConcurrency entry of its features, or 0 if the worker has no
+// limit.
+func (d *Dispatcher) maxConcurrency(worker string) int {
+	if limit, has := d.concurrency.Get(worker); has {
+		return limit
+	}
+	features, has := d.features.Get(worker)
+	if !has {
+		return 0
+	}
+	limit, err := strconv.Atoi(features[ipc.FeatureMaxConcurrency])
+	if err != nil || limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+// acquire returns true if data may be dispatched to its worker now. If the
+// worker is at its concurrency limit, data is queued and false is returned.
+func (d *Dispatcher) acquire(data yggdrasil.Data) bool {
+	limit := d.maxConcurrency(data.Directive)
+	if limit == 0 || data.MessageID == "" {
+		return true
+	}
+
+	ok, dropped := d.queues.acquire(
+		data,
+		limit,
+		config.DefaultConfig.DispatchQueueDepth,
+		config.DefaultConfig.DispatchOverflow,
+	)
+	if !ok && len(dropped) == 0 {
+		logging.With(data.Directive, data.MessageID).Debugf("queued message %v for busy worker %v", data.MessageID, data.Directive)
+		d.trace(DispatchEventQueued, data, "")
+	}
+	for _, data := range dropped {
+		logging.With(data.Directive, data.MessageID).Warnf("dropping message %v: queue for worker %v is full", data.MessageID, data.Directive)
+		d.trace(DispatchEventFailed, data, "queue is full")
+		d.removePending(data)
+		go d.fail(data, fmt.Errorf("queue for worker %v is full", data.Directive))
+	}
+	return ok
+}
+
+// release frees the capacity used by the message identified by messageID and
+// dispatches the next message queued for worker, if any.
+func (d *Dispatcher) release(worker, messageID string) {
+	if data, ok := d.queues.release(worker, messageID); ok {
+		go d.dispatchQueued(data)
+	}
+}
+
+// dispatchQueued dispatches data after it was taken off its worker's queue.
+func (d *Dispatcher) dispatchQueued(data yggdrasil.Data) {
+	if err := d.Dispatch(data); err != nil {
+		log.Errorf("cannot dispatch data: %v", err)
+		d.release(data.Directive, data.MessageID)
+		d.retryDispatch(
+			data,
+			err,
+			config.DefaultConfig.DispatchRetries,
+			config.DefaultConfig.DispatchRetryDelay,
+		)
+		return
+	}
+}
@@ -0,0 +1,291 @@
+package work
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/redhatinsights/yggdrasil"
+	"github.com/redhatinsights/yggdrasil/ipc"
+)
+
+func TestDispatchQueuesAcquire(t *testing.T) {
+	tests := []struct {
+		description string
+		policy      string
+		input       []string
+		wantOk      []bool
+		wantDropped []string
+		wantWaiting []string
+	}{
+		{
+			description: "within limit",
+			policy:      OverflowReject,
+			input:       []string{"a", "b"},
+			wantOk:      []bool{true, true},
+			wantWaiting: []string{},
+		},
+		{
+			description: "queued",
+			policy:      OverflowReject,
+			input:       []string{"a", "b", "c", "d"},
+			wantOk:      []bool{true, true, false, false},
+			wantWaiting: []string{"c", "d"},
+		},
+		{
+			description: "reject",
+			policy:      OverflowReject,
+			input:       []string{"a", "b", "c", "d", "e"},
+			wantOk:      []bool{true, true, false, false, false},
+			wantDropped: []string{"e"},
+			wantWaiting: []string{"c", "d"},
+		},
+		{
+			description: "drop oldest",
+			policy:      OverflowDropOldest,
+			input:       []string{"a", "b", "c", "d", "e"},
+			wantOk:      []bool{true, true, false, false, false},
+			wantDropped: []string{"c"},
+			wantWaiting: []string{"d", "e"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			q := dispatchQueues{}
+
+			gotOk := []bool{}
+			gotDropped := []string{}
+			for _, id := range test.input {
+				ok, dropped := q.acquire(yggdrasil.Data{MessageID: id, Directive: "echo"}, 2, 2, test.policy)
+				gotOk = append(gotOk, ok)
+				for _, data := range dropped {
+					gotDropped = append(gotDropped, data.MessageID)
+				}
+			}
+			gotWaiting := []string{}
+			for _, data := range q.get("echo").waiting {
+				gotWaiting = append(gotWaiting, data.MessageID)
+			}
+
+			if !cmp.Equal(gotOk, test.wantOk) {
+				t.Errorf("%v", cmp.Diff(gotOk, test.wantOk))
+			}
+			if len(test.wantDropped) == 0 {
+				test.wantDropped = []string{}
+			}
+			if !cmp.Equal(gotDropped, test.wantDropped) {
+				t.Errorf("%v", cmp.Diff(gotDropped, test.wantDropped))
+			}
+			if !cmp.Equal(gotWaiting, test.wantWaiting) {
+				t.Errorf("%v", cmp.Diff(gotWaiting, test.wantWaiting))
+			}
+		})
+	}
+}
+
+func TestDispatchQueuesRelease(t *testing.T) {
+	q := dispatchQueues{}
+	for _, id := range []string{"a", "b"} {
+		q.acquire(yggdrasil.Data{MessageID: id, Directive: "echo"}, 1, 1, OverflowReject)
+	}
+
+	if _, ok := q.release("echo", "unknown"); ok {
+		t.Error("unexpected next message for unknown message ID")
+	}
+	got, ok := q.release("echo", "a")
+	if !ok {
+		t.Fatal("expected next message")
+	}
+	if got.MessageID != "b" {
+		t.Errorf("%q != %q", got.MessageID, "b")
+	}
+	if _, ok := q.release("echo", "b"); ok {
+		t.Error("unexpected next message for empty queue")
+	}
+}
+
+func TestDispatchQueuesSnapshot(t *testing.T) {
+	q := dispatchQueues{}
+	for _, id := range []string{"b", "a", "c"} {
+		q.acquire(yggdrasil.Data{MessageID: id, Directive: "echo"}, 2, 2, OverflowReject)
+	}
+	q.acquire(yggdrasil.Data{MessageID: "d", Directive: "idle"}, 1, 1, OverflowReject)
+	q.release("idle", "d")
+
+	got := q.snapshot()
+	want := map[string]QueueState{
+		"echo": {InFlight: []string{"a", "b"}, Waiting: []string{"c"}},
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("%v", cmp.Diff(got, want))
+	}
+}
+
+func TestDispatchQueuesBlock(t *testing.T) {
+	q := dispatchQueues{}
+	for _, id := range []string{"a", "b", "c", "d"} {
+		ok, dropped := q.acquire(yggdrasil.Data{MessageID: id, Directive: "echo"}, 1, 1, OverflowBlock)
+		if ok != (id == "a") || len(dropped) != 0 {
+			t.Fatalf("acquire(%v) = %v, %v", id, ok, dropped)
+		}
+	}
+
+	// Another worker is not held back by the full queue.
+	if ok, _ := q.acquire(yggdrasil.Data{MessageID: "e", Directive: "other"}, 1, 1, OverflowBlock); !ok {
+		t.Error("expected message for other worker to be dispatched")
+	}
+
+	want := QueueState{InFlight: []string{"a"}, Waiting: []string{"b"}, Held: []string{"c", "d"}}
+	if got := q.snapshot()["echo"]; !cmp.Equal(got, want) {
+		t.Errorf("%v", cmp.Diff(got, want))
+	}
+
+	// Held messages are dispatched in order as the queue drains.
+	done := "a"
+	for _, id := range []string{"b", "c", "d"} {
+		got, ok := q.release("echo", done)
+		if !ok || got.MessageID != id {
+			t.Fatalf("got %q, %v, want %q", got.MessageID, ok, id)
+		}
+		done = id
+	}
+	if _, ok := q.release("echo", done); ok {
+		t.Error("unexpected next message for empty queue")
+	}
+}
+
+func TestDispatchQueuesBlockFlood(t *testing.T) {
+	q := dispatchQueues{}
+	q.acquire(yggdrasil.Data{MessageID: "0", Directive: "echo"}, 1, 1, OverflowBlock)
+
+	// A flood of messages for a worker that does not finish any is held back
+	// up to heldCapacity messages, then acquiring blocks.
+	const flood = 10 * heldCapacity
+	acquired := make(chan int, flood)
+	go func() {
+		for i := 1; i <= flood; i++ {
+			q.acquire(yggdrasil.Data{MessageID: strconv.Itoa(i), Directive: "echo"}, 1, 1, OverflowBlock)
+			acquired <- i
+		}
+	}()
+	want := 1 + heldCapacity
+	for i := 1; i <= want; i++ {
+		<-acquired
+	}
+	select {
+	case i := <-acquired:
+		t.Fatalf("acquired message %v beyond the held capacity", i)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if got := len(q.snapshot()["echo"].Held); got != heldCapacity {
+		t.Errorf("held %v messages, want %v", got, heldCapacity)
+	}
+
+	// Each message the worker finishes makes room for one more.
+	if _, ok := q.release("echo", "0"); !ok {
+		t.Fatal("expected next message")
+	}
+	select {
+	case i := <-acquired:
+		if i != want+1 {
+			t.Errorf("got message %v, want %v", i, want+1)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("acquire did not resume after release")
+	}
+	if got := len(q.snapshot()["echo"].Held); got != heldCapacity {
+		t.Errorf("held %v messages, want %v", got, heldCapacity)
+	}
+}
+
+func TestDispatchQueuesBlockNoDepth(t *testing.T) {
+	q := dispatchQueues{}
+	for _, id := range []string{"a", "b"} {
+		q.acquire(yggdrasil.Data{MessageID: id, Directive: "echo"}, 1, 0, OverflowBlock)
+	}
+
+	got, ok := q.release("echo", "a")
+	if !ok || got.MessageID != "b" {
+		t.Errorf("got %q, %v, want %q", got.MessageID, ok, "b")
+	}
+}
+
+func TestParseConcurrencyLimits(t *testing.T) {
+	tests := []struct {
+		description string
+		input       []string
+		want        map[string]int
+		wantError   bool
+	}{
+		{
+			description: "empty",
+			want:        map[string]int{},
+		},
+		{
+			description: "limits",
+			input:       []string{"echo=2", " package-manager = 1 "},
+			want:        map[string]int{"echo": 2, "package-manager": 1},
+		},
+		{
+			description: "zero",
+			input:       []string{"echo=0"},
+			wantError:   true,
+		},
+		{
+			description: "not a number",
+			input:       []string{"echo=many"},
+			wantError:   true,
+		},
+		{
+			description: "duplicate",
+			input:       []string{"echo=1", "echo=2"},
+			wantError:   true,
+		},
+		{
+			description: "missing directive",
+			input:       []string{"=1"},
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got, err := ParseConcurrencyLimits(test.input)
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
+
+func TestMaxConcurrency(t *testing.T) {
+	d := NewDispatcher(nil)
+	d.features.Set("echo", map[string]string{ipc.FeatureMaxConcurrency: "4"})
+	d.features.Set("plain", map[string]string{})
+
+	if got := d.maxConcurrency("echo"); got != 4 {
+		t.Errorf("got %v, want 4", got)
+	}
+	d.SetConcurrencyLimits(map[string]int{"echo": 1, "plain": 2})
+	if got := d.maxConcurrency("echo"); got != 1 {
+		t.Errorf("got %v, want 1", got)
+	}
+	if got := d.maxConcurrency("plain"); got != 2 {
+		t.Errorf("got %v, want 2", got)
+	}
+	d.SetConcurrencyLimits(nil)
+	if got := d.maxConcurrency("plain"); got != 0 {
+		t.Errorf("got %v, want 0", got)
+	}
+}
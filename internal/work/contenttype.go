@@ -0,0 +1,49 @@
+package work
+
+import (
+	"mime"
+	"sort"
+	"strings"
+
+	"github.com/redhatinsights/yggdrasil/ipc"
+)
+
+// routeByContentType returns the name of the worker that handles messages with
+// the given content type, according to the FeatureContentTypes entry of each
+// worker's features. If several workers handle the content type, the first
+// by name is chosen.
+func (d *Dispatcher) routeByContentType(contentType string) (string, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+
+	var workers []string
+	d.features.Visit(func(k string, v map[string]string) {
+		if d.WorkerDisabled(k) || d.WorkerExcluded(k) {
+			return
+		}
+		for _, t := range strings.Split(v[ipc.FeatureContentTypes], ",") {
+			if strings.EqualFold(strings.TrimSpace(t), mediaType) {
+				workers = append(workers, k)
+				return
+			}
+		}
+	})
+	if len(workers) == 0 {
+		return "", false
+	}
+	sort.Strings(workers)
+	return workers[0], true
+}
+
+// contentTypeMetadata returns the value of the "Content-Type" metadata key,
+// matched case-insensitively.
+func contentTypeMetadata(metadata map[string]string) string {
+	for k, v := range metadata {
+		if strings.EqualFold(k, "Content-Type") {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package work
+
+import (
+	"testing"
+
+	"github.com/redhatinsights/yggdrasil/ipc"
+)
+
+func TestRouteByContentType(t *testing.T) {
+	d := NewDispatcher(nil)
+	d.features.Set("echo", map[string]string{})
+	d.features.Set("json", map[string]string{ipc.FeatureContentTypes: "application/json, text/plain"})
+	d.features.Set("yaml", map[string]string{ipc.FeatureContentTypes: "application/yaml"})
+	d.features.Set("disabled", map[string]string{ipc.FeatureContentTypes: "application/xml"})
+	d.disabled.Set("disabled", true)
+
+	tests := []struct {
+		description string
+		input       string
+		want        string
+		wantOk      bool
+	}{
+		{
+			description: "match",
+			input:       "application/yaml",
+			want:        "yaml",
+			wantOk:      true,
+		},
+		{
+			description: "parameters and case",
+			input:       "Text/Plain; charset=utf-8",
+			want:        "json",
+			wantOk:      true,
+		},
+		{
+			description: "disabled worker",
+			input:       "application/xml",
+		},
+		{
+			description: "no match",
+			input:       "image/png",
+		},
+		{
+			description: "empty",
+			input:       "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got, ok := d.routeByContentType(test.input)
+
+			if ok != test.wantOk {
+				t.Fatalf("ok = %v, want %v", ok, test.wantOk)
+			}
+			if got != test.want {
+				t.Errorf("%q != %q", got, test.want)
+			}
+		})
+	}
+}
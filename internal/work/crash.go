@@ -0,0 +1,177 @@
+package work
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/godbus/dbus/v5"
+	"github.com/redhatinsights/yggdrasil/internal/logging"
+	"github.com/redhatinsights/yggdrasil/ipc"
+)
+
+// crashOutputSize is the maximum number of bytes of worker output included in
+// a crash report.
+const crashOutputSize = 16 * 1024
+
+// CrashReport describes how a worker process exited. A report is written to
+// disk only when the exit was unsuccessful.
+type CrashReport struct {
+	Worker string    `json:"worker"`
+	Unit   string    `json:"unit"`
+	Time   time.Time `json:"time"`
+
+	// Result is the systemd service result, such as "exit-code", "signal",
+	// "core-dump" or "watchdog".
+	Result string `json:"result"`
+
+	// ExitCode is the exit status of the worker process, if it exited.
+	ExitCode *int32 `json:"exit_code,omitempty"`
+
+	// Signal is the name of the signal that terminated the worker process, if
+	// it was killed.
+	Signal string `json:"signal,omitempty"`
+
+	// Output is the tail of the worker's journal output, if it could be read.
+	Output string `json:"output,omitempty"`
+}
+
+// exitStatus summarizes how the worker process exited: its exit code, the
+// name of the signal that killed it, or the systemd service result.
+func (r *CrashReport) exitStatus() string {
+	switch {
+	case r.ExitCode != nil:
+		return strconv.Itoa(int(*r.ExitCode))
+	case r.Signal != "":
+		return r.Signal
+	default:
+		return r.Result
+	}
+}
+
+// reportCrash writes a crash report for worker, run by unit, into
+// d.CrashReportDir and emits a CRASHED worker event, if d.CrashReportDir is
+// set. It returns the path to the report, if one was written.
+func (d *Dispatcher) reportCrash(worker, unit string, report *CrashReport) string {
+	if d.CrashReportDir == "" {
+		return ""
+	}
+
+	report.Output = journalOutput(unit)
+
+	file, err := writeCrashReport(d.CrashReportDir, report)
+	if err != nil {
+		log.Errorf("cannot write crash report: %v", err)
+		return ""
+	}
+	logging.With(worker, "").Warnf("worker %v crashed (%v); report written to %v", worker, report.Result, file)
+
+	d.WorkerEvents <- ipc.WorkerEvent{
+		Worker: worker,
+		Name:   ipc.WorkerEventNameCrashed,
+		Data: map[string]string{
+			"report": file,
+			"result": report.Result,
+		},
+	}
+	return file
+}
+
+// unitExitReport reads the exit state of unit from systemd.
+func (d *Dispatcher) unitExitReport(worker, unit string) (*CrashReport, error) {
+	if !systemdRunning() {
+		return nil, fmt.Errorf("systemd is not running")
+	}
+
+	path, err := callMethod[dbus.ObjectPath](
+		d.conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1"),
+		"org.freedesktop.systemd1.Manager.GetUnit",
+		unit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	obj := d.conn.Object("org.freedesktop.systemd1", *path)
+
+	// The bus name is released as the process exits, possibly before systemd
+	// has reaped it. Wait briefly for the unit to leave the active state.
+	for i := 0; i < 10; i++ {
+		state, err := obj.GetProperty("org.freedesktop.systemd1.Unit.ActiveState")
+		if err != nil {
+			return nil, err
+		}
+		if s, _ := state.Value().(string); s != "active" && s != "deactivating" {
+			break
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	result, err := obj.GetProperty("org.freedesktop.systemd1.Service.Result")
+	if err != nil {
+		return nil, err
+	}
+	report := CrashReport{
+		Worker: worker,
+		Unit:   unit,
+		Time:   time.Now().UTC(),
+	}
+	report.Result, _ = result.Value().(string)
+
+	code, err := obj.GetProperty("org.freedesktop.systemd1.Service.ExecMainCode")
+	if err != nil {
+		return nil, err
+	}
+	status, err := obj.GetProperty("org.freedesktop.systemd1.Service.ExecMainStatus")
+	if err != nil {
+		return nil, err
+	}
+	c, _ := code.Value().(int32)
+	s, _ := status.Value().(int32)
+	// ExecMainCode holds a siginfo si_code value: CLD_EXITED (1), CLD_KILLED
+	// (2) or CLD_DUMPED (3).
+	switch c {
+	case 1:
+		report.ExitCode = &s
+	case 2, 3:
+		report.Signal = syscall.Signal(s).String()
+	}
+
+	return &report, nil
+}
+
+// journalOutput returns the tail of the journal output for unit. Errors are
+// ignored, since yggd may not be permitted to read the journal.
+func journalOutput(unit string) string {
+	output, err := exec.Command("journalctl", "--unit", unit, "--lines", "200", "--output", "cat", "--no-pager").Output()
+	if err != nil {
+		log.Debugf("cannot read journal for unit %v: %v", unit, err)
+		return ""
+	}
+	if len(output) > crashOutputSize {
+		output = output[len(output)-crashOutputSize:]
+	}
+	return string(output)
+}
+
+// writeCrashReport writes report as a JSON file into dir, returning the path
+// to the file.
+func writeCrashReport(dir string, report *CrashReport) (string, error) {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal crash report: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return "", fmt.Errorf("cannot create directory: %w", err)
+	}
+	file := filepath.Join(dir, fmt.Sprintf("%v-%v.json", report.Worker, report.Time.Unix()))
+	if err := os.WriteFile(file, data, 0640); err != nil {
+		return "", fmt.Errorf("cannot write file: %w", err)
+	}
+	return file, nil
+}
@@ -0,0 +1,71 @@
+package work
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteCrashReport(t *testing.T) {
+	exitCode := int32(1)
+
+	tests := []struct {
+		description string
+		input       *CrashReport
+		wantFile    string
+	}{
+		{
+			description: "exit code",
+			input: &CrashReport{
+				Worker:   "echo",
+				Unit:     "com.redhat.Yggdrasil1.Worker1.echo.service",
+				Time:     time.Unix(1700000000, 0).UTC(),
+				Result:   "exit-code",
+				ExitCode: &exitCode,
+				Output:   "panic: oops\n",
+			},
+			wantFile: "echo-1700000000.json",
+		},
+		{
+			description: "signal",
+			input: &CrashReport{
+				Worker: "echo",
+				Unit:   "com.redhat.Yggdrasil1.Worker1.echo.service",
+				Time:   time.Unix(1700000001, 0).UTC(),
+				Result: "signal",
+				Signal: "killed",
+			},
+			wantFile: "echo-1700000001.json",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "crash")
+
+			file, err := writeCrashReport(dir, test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file != filepath.Join(dir, test.wantFile) {
+				t.Errorf("%v != %v", file, filepath.Join(dir, test.wantFile))
+			}
+
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got CrashReport
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(&got, test.input) {
+				t.Errorf("%v", cmp.Diff(&got, test.input))
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package work
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redhatinsights/yggdrasil"
+	"github.com/redhatinsights/yggdrasil/internal/spool"
+)
+
+// DeadLetter is a message the dispatcher could not deliver, along with the
+// reason delivery failed.
+type DeadLetter struct {
+	Data   yggdrasil.Data `json:"data"`
+	Reason string         `json:"reason"`
+	Time   time.Time      `json:"time"`
+}
+
+// deadLetterStore persists dead letters as JSON files in a directory, one file
+// per message, named after the message ID. Files are encrypted with cipher, if
+// it is set.
+type deadLetterStore struct {
+	dir    string
+	cipher *spool.Cipher
+}
+
+// path returns the file path of the dead letter for messageID.
+func (s deadLetterStore) path(messageID string) (string, error) {
+	return messageFile(s.dir, messageID)
+}
+
+// messageFile returns the path of the JSON file named after messageID in dir,
+// rejecting IDs that are not safe to use as file names.
+func messageFile(dir, messageID string) (string, error) {
+	if messageID == "" || filepath.Base(messageID) != messageID || strings.HasPrefix(messageID, ".") {
+		return "", fmt.Errorf("invalid message ID '%v'", messageID)
+	}
+	return filepath.Join(dir, messageID+".json"), nil
+}
+
+// save writes data to the store, recording reason as the delivery failure.
+func (s deadLetterStore) save(data yggdrasil.Data, reason string) error {
+	file, err := s.path(data.MessageID)
+	if err != nil {
+		return err
+	}
+	letter, err := json.Marshal(DeadLetter{
+		Data:   data,
+		Reason: reason,
+		Time:   time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot marshal dead letter: %w", err)
+	}
+	letter, err = s.cipher.Seal(letter)
+	if err != nil {
+		return fmt.Errorf("cannot encrypt dead letter: %w", err)
+	}
+	if err := os.MkdirAll(s.dir, 0750); err != nil {
+		return fmt.Errorf("cannot create directory: %w", err)
+	}
+	if err := os.WriteFile(file, letter, 0640); err != nil {
+		return fmt.Errorf("cannot write dead letter: %w", err)
+	}
+	return nil
+}
+
+// load reads the dead letter for messageID from the store.
+func (s deadLetterStore) load(messageID string) (*DeadLetter, error) {
+	file, err := s.path(messageID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read dead letter: %w", err)
+	}
+	data, err = s.cipher.Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read dead letter: %w", err)
+	}
+	var letter DeadLetter
+	if err := json.Unmarshal(data, &letter); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal dead letter: %w", err)
+	}
+	return &letter, nil
+}
+
+// remove deletes the dead letter for messageID from the store.
+func (s deadLetterStore) remove(messageID string) error {
+	file, err := s.path(messageID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil {
+		return fmt.Errorf("cannot remove dead letter: %w", err)
+	}
+	return nil
+}
+
+// list returns every dead letter in the store, oldest first.
+func (s deadLetterStore) list() ([]DeadLetter, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []DeadLetter{}, nil
+		}
+		return nil, fmt.Errorf("cannot read directory: %w", err)
+	}
+
+	letters := []DeadLetter{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		letter, err := s.load(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, *letter)
+	}
+	sort.SliceStable(letters, func(i, j int) bool {
+		return letters[i].Time.Before(letters[j].Time)
+	})
+	return letters, nil
+}
+
+// DeadLetters returns the messages the dispatcher failed to deliver.
+func (d *Dispatcher) DeadLetters() ([]DeadLetter, error) {
+	if d.DeadLetterDir == "" {
+		return nil, fmt.Errorf("dead-letter store is not enabled")
+	}
+	return deadLetterStore{dir: d.DeadLetterDir, cipher: d.SpoolCipher}.list()
+}
+
+// RedriveDeadLetter removes the dead letter for messageID from the store and
+// dispatches it again. If delivery fails, the message is returned to the
+// store.
+func (d *Dispatcher) RedriveDeadLetter(messageID string) error {
+	if d.DeadLetterDir == "" {
+		return fmt.Errorf("dead-letter store is not enabled")
+	}
+	store := deadLetterStore{dir: d.DeadLetterDir, cipher: d.SpoolCipher}
+
+	letter, err := store.load(messageID)
+	if err != nil {
+		return err
+	}
+	if err := store.remove(messageID); err != nil {
+		return err
+	}
+	if err := d.Dispatch(letter.Data); err != nil {
+		if err := store.save(letter.Data, err.Error()); err != nil {
+			return fmt.Errorf("cannot return message to dead-letter store: %w", err)
+		}
+		return fmt.Errorf("cannot dispatch message: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package work
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/redhatinsights/yggdrasil"
+)
+
+func TestDeadLetterStore(t *testing.T) {
+	store := deadLetterStore{dir: t.TempDir()}
+
+	got, err := store.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected empty store, got %v", got)
+	}
+
+	messages := []yggdrasil.Data{
+		{MessageID: "a", Directive: "echo", Content: json.RawMessage(`"hello"`)},
+		{MessageID: "b", Directive: "test", Content: json.RawMessage(`{}`)},
+	}
+	for _, msg := range messages {
+		if err := store.save(msg, "cannot call method"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err = store.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, letter := range got {
+		ids = append(ids, letter.Data.MessageID)
+		if letter.Reason != "cannot call method" {
+			t.Errorf("%v != %v", letter.Reason, "cannot call method")
+		}
+	}
+	if !cmp.Equal(ids, []string{"a", "b"}) {
+		t.Errorf("%v", cmp.Diff(ids, []string{"a", "b"}))
+	}
+
+	if err := store.remove("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.load("a"); err == nil {
+		t.Errorf("expected error loading removed dead letter")
+	}
+}
+
+func TestDeadLetterStorePath(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		wantError   bool
+	}{
+		{
+			description: "message ID",
+			input:       "c7f4b3c4-0d8e-4a53-9e38-0b8a9c5f3d21",
+		},
+		{
+			description: "empty",
+			input:       "",
+			wantError:   true,
+		},
+		{
+			description: "path traversal",
+			input:       "../client-id",
+			wantError:   true,
+		},
+		{
+			description: "hidden file",
+			input:       "..",
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			_, err := deadLetterStore{dir: "/var/lib/yggdrasil/dead-letter"}.path(test.input)
+
+			if test.wantError && err == nil {
+				t.Errorf("expected error, got nil")
+			}
+			if !test.wantError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package work
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/redhatinsights/yggdrasil"
+	"github.com/redhatinsights/yggdrasil/internal/config"
+	"github.com/redhatinsights/yggdrasil/internal/logging"
+	"github.com/redhatinsights/yggdrasil/ipc"
+)
+
+// responseDeadlines tracks messages dispatched to workers that have not yet
+// been answered, keyed by message ID.
+type responseDeadlines struct {
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+}
+
+// track calls expired if done is not called for messageID within timeout.
+func (r *responseDeadlines) track(messageID string, timeout time.Duration, expired func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.timers == nil {
+		r.timers = make(map[string]*time.Timer)
+	}
+	if timer, has := r.timers[messageID]; has {
+		timer.Stop()
+	}
+	r.timers[messageID] = time.AfterFunc(timeout, func() {
+		r.mu.Lock()
+		delete(r.timers, messageID)
+		r.mu.Unlock()
+		expired()
+	})
+}
+
+// done stops tracking messageID, returning true if it was being tracked.
+func (r *responseDeadlines) done(messageID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	timer, has := r.timers[messageID]
+	if !has {
+		return false
+	}
+	timer.Stop()
+	delete(r.timers, messageID)
+	return true
+}
+
+// pending returns the IDs of the tracked messages, sorted.
+func (r *responseDeadlines) pending() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]string, 0, len(r.timers))
+	for id := range r.timers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// AwaitingResponse returns the IDs of the messages dispatched to workers that
+// have not yet been answered.
+func (d *Dispatcher) AwaitingResponse() []string {
+	return d.deadlines.pending()
+}
+
+// responseTimeout returns how long worker is given to respond to a message: the
+// duration in the FeatureResponseTimeout entry of its features or, if it is
+// not set, config.DefaultConfig.ResponseTimeout.
+func (d *Dispatcher) responseTimeout(worker string) time.Duration {
+	if features, has := d.features.Get(worker); has {
+		if v, has := features[ipc.FeatureResponseTimeout]; has {
+			timeout, err := time.ParseDuration(v)
+			if err == nil {
+				return timeout
+			}
+			log.Warnf("cannot parse %v feature of worker %v: %v", ipc.FeatureResponseTimeout, worker, err)
+		}
+	}
+	return config.DefaultConfig.ResponseTimeout
+}
+
+// trackResponse starts waiting for the worker to respond to data, sending data
+// on the Timeouts channel if the worker neither emits an END event nor
+// transmits a message in response before its response timeout.
+func (d *Dispatcher) trackResponse(data yggdrasil.Data) {
+	timeout := d.responseTimeout(data.Directive)
+	if timeout <= 0 || data.MessageID == "" {
+		return
+	}
+	d.deadlines.track(data.MessageID, timeout, func() {
+		logging.With(data.Directive, data.MessageID).Warnf("worker %v did not respond to message %v within %v", data.Directive, data.MessageID, timeout)
+		d.trace(DispatchEventTimeout, data, timeout.String())
+		d.Timeouts <- data
+	})
+}
@@ -0,0 +1,56 @@
+package work
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResponseDeadlines(t *testing.T) {
+	var r responseDeadlines
+
+	expired := make(chan string, 2)
+	r.track("a", 10*time.Millisecond, func() { expired <- "a" })
+	r.track("b", 10*time.Millisecond, func() { expired <- "b" })
+
+	if !r.done("b") {
+		t.Error("expected message b to be tracked")
+	}
+
+	select {
+	case got := <-expired:
+		if got != "a" {
+			t.Errorf("%q != %q", got, "a")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("deadline did not expire")
+	}
+
+	select {
+	case got := <-expired:
+		t.Errorf("unexpected expiry of message %v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if r.done("a") {
+		t.Error("expected expired message a to be untracked")
+	}
+}
+
+func TestResponseDeadlinesPending(t *testing.T) {
+	var r responseDeadlines
+
+	r.track("b", time.Minute, func() {})
+	r.track("a", time.Minute, func() {})
+	r.track("c", time.Minute, func() {})
+	r.done("c")
+
+	got := r.pending()
+	want := []string{"a", "b"}
+	if !cmp.Equal(got, want) {
+		t.Errorf("%v", cmp.Diff(got, want))
+	}
+	r.done("a")
+	r.done("b")
+}
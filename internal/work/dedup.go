@@ -0,0 +1,140 @@
+package work
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/redhatinsights/yggdrasil"
+)
+
+// messageCache is a bounded, least-recently-used set of message IDs, persisted
+// to a file so that duplicates are detected across restarts.
+type messageCache struct {
+	mu    sync.Mutex
+	size  int
+	file  string
+	order *list.List
+	ids   map[string]*list.Element
+}
+
+// newMessageCache creates a messageCache holding at most size IDs, persisted
+// to file. If file is empty, the cache is kept in memory only.
+func newMessageCache(size int, file string) *messageCache {
+	return &messageCache{
+		size:  size,
+		file:  file,
+		order: list.New(),
+		ids:   make(map[string]*list.Element),
+	}
+}
+
+// load reads the IDs persisted to the cache's file. A missing file is treated
+// as an empty cache.
+func (c *messageCache) load() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := os.ReadFile(c.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("cannot read message cache: %w", err)
+	}
+
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return fmt.Errorf("cannot unmarshal message cache: %w", err)
+	}
+	// IDs are persisted most recently seen first.
+	for i := len(ids) - 1; i >= 0; i-- {
+		c.add(ids[i])
+	}
+
+	return nil
+}
+
+// seen records id, returning true if it was already in the cache.
+func (c *messageCache) seen(id string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, has := c.ids[id]; has {
+		c.order.MoveToFront(e)
+		return true, nil
+	}
+	c.add(id)
+
+	return false, c.save()
+}
+
+// add inserts id at the front of the cache, evicting the least recently seen
+// ID if the cache is full. The caller must hold c.mu.
+func (c *messageCache) add(id string) {
+	if _, has := c.ids[id]; has {
+		return
+	}
+	c.ids[id] = c.order.PushFront(id)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.ids, oldest.Value.(string))
+	}
+}
+
+// save writes the IDs in the cache to its file, most recently seen first. The
+// caller must hold c.mu.
+func (c *messageCache) save() error {
+	if c.file == "" {
+		return nil
+	}
+
+	ids := make([]string, 0, c.order.Len())
+	for e := c.order.Front(); e != nil; e = e.Next() {
+		ids = append(ids, e.Value.(string))
+	}
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return fmt.Errorf("cannot marshal message cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.file), 0750); err != nil {
+		return fmt.Errorf("cannot create directory: %w", err)
+	}
+	if err := os.WriteFile(c.file, data, 0640); err != nil {
+		return fmt.Errorf("cannot write message cache: %w", err)
+	}
+	return nil
+}
+
+// LoadSeenMessages enables detection of duplicate messages, remembering the
+// IDs of the last size messages received in file. A size of 0 disables
+// duplicate detection.
+func (d *Dispatcher) LoadSeenMessages(file string, size int) error {
+	if size <= 0 {
+		d.seenMessages = nil
+		return nil
+	}
+	d.seenMessages = newMessageCache(size, file)
+	return d.seenMessages.load()
+}
+
+// duplicate returns true if a message with the same ID as data has already
+// been received, recording the duplicate in the worker's metrics.
+func (d *Dispatcher) duplicate(data yggdrasil.Data) bool {
+	if d.seenMessages == nil || data.MessageID == "" {
+		return false
+	}
+	seen, err := d.seenMessages.seen(data.MessageID)
+	if err != nil {
+		log.Warnf("cannot record message %v: %v", data.MessageID, err)
+	}
+	if seen {
+		d.metrics.duplicated(data.Directive)
+	}
+	return seen
+}
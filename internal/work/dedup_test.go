@@ -0,0 +1,91 @@
+package work
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMessageCacheSeen(t *testing.T) {
+	tests := []struct {
+		description string
+		size        int
+		input       []string
+		want        []bool
+	}{
+		{
+			description: "unique",
+			size:        3,
+			input:       []string{"a", "b", "c"},
+			want:        []bool{false, false, false},
+		},
+		{
+			description: "duplicate",
+			size:        3,
+			input:       []string{"a", "b", "a"},
+			want:        []bool{false, false, true},
+		},
+		{
+			description: "evicted",
+			size:        2,
+			input:       []string{"a", "b", "c", "a"},
+			want:        []bool{false, false, false, false},
+		},
+		{
+			description: "recently seen is kept",
+			size:        2,
+			input:       []string{"a", "b", "a", "c", "a"},
+			want:        []bool{false, false, true, false, true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			c := newMessageCache(test.size, "")
+
+			got := make([]bool, 0, len(test.input))
+			for _, id := range test.input {
+				seen, err := c.seen(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, seen)
+			}
+
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
+
+func TestMessageCachePersist(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "seen-messages.json")
+
+	c := newMessageCache(2, file)
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := c.seen(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c = newMessageCache(2, file)
+	if err := c.load(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]bool, 0, 3)
+	for _, id := range []string{"c", "b", "a"} {
+		seen, err := c.seen(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, seen)
+	}
+	want := []bool{true, true, false}
+
+	if !cmp.Equal(got, want) {
+		t.Errorf("%v", cmp.Diff(got, want))
+	}
+}
@@ -0,0 +1,162 @@
+package work
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"git.sr.ht/~spc/go-log"
+)
+
+// LoadDisabledWorkers reads the list of disabled workers from file and
+// records file as the location to persist future changes. A missing file is
+// treated as an empty list.
+func (d *Dispatcher) LoadDisabledWorkers(file string) error {
+	d.disabledFile = file
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("cannot read disabled workers: %w", err)
+	}
+
+	var workers []string
+	if err := json.Unmarshal(data, &workers); err != nil {
+		return fmt.Errorf("cannot unmarshal disabled workers: %w", err)
+	}
+	for _, worker := range workers {
+		d.disabled.Set(worker, true)
+	}
+
+	return nil
+}
+
+// WorkerDisabled returns true if worker has been disabled.
+func (d *Dispatcher) WorkerDisabled(worker string) bool {
+	_, has := d.disabled.Get(worker)
+	return has
+}
+
+// WorkerExcluded returns true if worker is in the configured exclude list.
+func (d *Dispatcher) WorkerExcluded(worker string) bool {
+	_, has := d.excluded.Get(worker)
+	return has
+}
+
+// SetExcludedWorkers replaces the list of excluded workers. If the dispatcher
+// is connected, running workers that are newly excluded are stopped and
+// workers that are no longer excluded are activated.
+func (d *Dispatcher) SetExcludedWorkers(workers []string) {
+	excluded := make(map[string]bool)
+	for _, worker := range workers {
+		excluded[worker] = true
+	}
+
+	var included []string
+	d.excluded.Visit(func(k string, _ bool) {
+		if !excluded[k] {
+			included = append(included, k)
+		}
+	})
+	for _, worker := range included {
+		d.excluded.Del(worker)
+	}
+
+	var added []string
+	for worker := range excluded {
+		if !d.WorkerExcluded(worker) {
+			d.excluded.Set(worker, true)
+			added = append(added, worker)
+		}
+	}
+
+	if d.conn == nil {
+		return
+	}
+
+	for _, worker := range added {
+		log.Infof("worker %v excluded", worker)
+		present, err := d.nameHasOwner("com.redhat.Yggdrasil1.Worker1." + worker)
+		if err != nil {
+			log.Errorf("cannot find owner for name: %v: %v", worker, err)
+			continue
+		}
+		if present {
+			if err := d.stopWorker(worker); err != nil {
+				log.Errorf("cannot stop worker %v: %v", worker, err)
+			}
+		}
+	}
+	for _, worker := range included {
+		log.Infof("worker %v included", worker)
+		if d.WorkerDisabled(worker) || d.WorkerOnDemand(worker) {
+			continue
+		}
+		if err := d.startWorker(worker); err != nil {
+			log.Errorf("cannot start worker %v: %v", worker, err)
+		}
+	}
+
+	d.Dispatchers <- d.FlattenDispatchers()
+}
+
+// SetWorkerEnabled enables or disables worker, persisting the new state. A
+// disabled worker is not sent any messages and is not advertised in the
+// dispatchers map. Disabling a running worker stops it.
+func (d *Dispatcher) SetWorkerEnabled(worker string, enabled bool) error {
+	if enabled {
+		d.disabled.Del(worker)
+	} else {
+		d.disabled.Set(worker, true)
+	}
+
+	if err := d.saveDisabledWorkers(); err != nil {
+		return err
+	}
+
+	if !enabled {
+		present, err := d.nameHasOwner("com.redhat.Yggdrasil1.Worker1." + worker)
+		if err != nil {
+			log.Errorf("cannot find owner for name: %v: %v", worker, err)
+		} else if present {
+			if err := d.stopWorker(worker); err != nil {
+				log.Errorf("cannot stop worker %v: %v", worker, err)
+			}
+		}
+	}
+
+	d.Dispatchers <- d.FlattenDispatchers()
+
+	return nil
+}
+
+// saveDisabledWorkers writes the list of disabled workers to the file set by
+// LoadDisabledWorkers. If no file is set, the state is kept only in memory.
+func (d *Dispatcher) saveDisabledWorkers() error {
+	if d.disabledFile == "" {
+		return nil
+	}
+
+	workers := []string{}
+	d.disabled.Visit(func(k string, _ bool) {
+		workers = append(workers, k)
+	})
+	sort.Strings(workers)
+
+	data, err := json.Marshal(workers)
+	if err != nil {
+		return fmt.Errorf("cannot marshal disabled workers: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(d.disabledFile), 0755); err != nil {
+		return fmt.Errorf("cannot create directory: %w", err)
+	}
+	if err := os.WriteFile(d.disabledFile, data, 0644); err != nil {
+		return fmt.Errorf("cannot write disabled workers: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package work
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadDisabledWorkers(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		want        []string
+		wantError   bool
+	}{
+		{
+			description: "missing file",
+			want:        []string{"echo"},
+		},
+		{
+			description: "existing file",
+			input:       `["test"]`,
+			want:        []string{"echo", "test"},
+		},
+		{
+			description: "invalid file",
+			input:       `{`,
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "disabled-workers.json")
+			if test.input != "" {
+				if err := os.WriteFile(file, []byte(test.input), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			d := NewDispatcher(nil)
+			err := d.LoadDisabledWorkers(file)
+
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d.disabled.Set("echo", true)
+			if err := d.saveDisabledWorkers(); err != nil {
+				t.Fatal(err)
+			}
+
+			got := NewDispatcher(nil)
+			if err := got.LoadDisabledWorkers(file); err != nil {
+				t.Fatal(err)
+			}
+			for _, worker := range test.want {
+				if !got.WorkerDisabled(worker) {
+					t.Errorf("worker %v is not disabled", worker)
+				}
+			}
+			if !cmp.Equal(len(got.FlattenDispatchers()), 0) {
+				t.Errorf("disabled workers present in dispatchers map")
+			}
+		})
+	}
+}
+
+func TestSetExcludedWorkers(t *testing.T) {
+	tests := []struct {
+		description string
+		input       [][]string
+		want        []string
+	}{
+		{
+			description: "single update",
+			input:       [][]string{{"echo"}},
+			want:        []string{"echo"},
+		},
+		{
+			description: "replace list",
+			input:       [][]string{{"echo", "test"}, {"test", "other"}},
+			want:        []string{"other", "test"},
+		},
+		{
+			description: "clear list",
+			input:       [][]string{{"echo"}, {}},
+			want:        []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			d := NewDispatcher(nil)
+			for _, workers := range test.input {
+				d.SetExcludedWorkers(workers)
+			}
+
+			got := []string{}
+			for _, worker := range []string{"echo", "other", "test"} {
+				if d.WorkerExcluded(worker) {
+					got = append(got, worker)
+				}
+			}
+
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
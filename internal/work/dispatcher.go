@@ -8,15 +8,21 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"git.sr.ht/~spc/go-log"
 	"github.com/godbus/dbus/v5"
 	"github.com/godbus/dbus/v5/introspect"
+	"github.com/google/uuid"
 	"github.com/redhatinsights/yggdrasil"
+	"github.com/redhatinsights/yggdrasil/internal/audit"
 	"github.com/redhatinsights/yggdrasil/internal/config"
+	"github.com/redhatinsights/yggdrasil/internal/history"
 	internalhttp "github.com/redhatinsights/yggdrasil/internal/http"
+	"github.com/redhatinsights/yggdrasil/internal/logging"
 	"github.com/redhatinsights/yggdrasil/internal/messagejournal"
+	"github.com/redhatinsights/yggdrasil/internal/spool"
 	"github.com/redhatinsights/yggdrasil/internal/sync"
 	"github.com/redhatinsights/yggdrasil/ipc"
 )
@@ -33,14 +39,46 @@ const (
 // to the destination worker. It sends values on the 'outbound' channel to relay
 // data received from workers to a remote address.
 type Dispatcher struct {
-	HTTPClient     *internalhttp.Client
-	conn           *dbus.Conn
-	features       sync.RWMutexMap[map[string]string]
-	MessageJournal *messagejournal.MessageJournal
-	Dispatchers    chan map[string]map[string]string
-	WorkerEvents   chan ipc.WorkerEvent
-	Inbound        chan yggdrasil.Data
-	Outbound       chan struct {
+	HTTPClient      *internalhttp.Client
+	conn            *dbus.Conn
+	features        sync.RWMutexMap[map[string]string]
+	disabled        sync.RWMutexMap[bool]
+	excluded        sync.RWMutexMap[bool]
+	aliases         sync.RWMutexMap[string]
+	concurrency     sync.RWMutexMap[int]
+	unacked         unackedMessages
+	tags            sync.RWMutexMap[string]
+	pids            sync.RWMutexMap[uint32]
+	middleware      []Middleware
+	events          eventTrace
+	metrics         metricsRegistry
+	queues          dispatchQueues
+	deadlines       responseDeadlines
+	rateLimits      rateLimiter
+	onDemand        onDemandWorkers
+	lanes           dispatchLanes
+	disabledFile    string
+	seenMessages    *messageCache
+	probe           chan chan struct{}
+	draining        atomic.Bool
+	MessageJournal  *messagejournal.MessageJournal
+	History         *history.History
+	Audit           *audit.Log
+	CrashReportDir  string
+	DeadLetterDir   string
+	PendingDir      string
+	SpoolCipher     *spool.Cipher
+	SchemaDir       string
+	Dispatchers     chan map[string]map[string]string
+	WorkerEvents    chan ipc.WorkerEvent
+	Failures        chan yggdrasil.Data
+	Expired         chan yggdrasil.Data
+	Timeouts        chan yggdrasil.Data
+	Deferred        chan yggdrasil.Data
+	DispatchEvents  chan DispatchEvent
+	WorkerLifecycle chan WorkerLifecycleEvent
+	Inbound         chan yggdrasil.Data
+	Outbound        chan struct {
 		Data yggdrasil.Data
 		Resp chan yggdrasil.Response
 	}
@@ -48,12 +86,19 @@ type Dispatcher struct {
 
 func NewDispatcher(client *internalhttp.Client) *Dispatcher {
 	return &Dispatcher{
-		HTTPClient:     client,
-		features:       sync.RWMutexMap[map[string]string]{},
-		MessageJournal: nil,
-		Dispatchers:    make(chan map[string]map[string]string),
-		WorkerEvents:   make(chan ipc.WorkerEvent),
-		Inbound:        make(chan yggdrasil.Data),
+		HTTPClient:      client,
+		features:        sync.RWMutexMap[map[string]string]{},
+		MessageJournal:  nil,
+		probe:           make(chan chan struct{}),
+		Dispatchers:     make(chan map[string]map[string]string),
+		WorkerEvents:    make(chan ipc.WorkerEvent),
+		Failures:        make(chan yggdrasil.Data),
+		Expired:         make(chan yggdrasil.Data),
+		Timeouts:        make(chan yggdrasil.Data),
+		Deferred:        make(chan yggdrasil.Data),
+		DispatchEvents:  make(chan DispatchEvent),
+		WorkerLifecycle: make(chan WorkerLifecycleEvent),
+		Inbound:         make(chan yggdrasil.Data),
 		Outbound: make(chan struct {
 			Data yggdrasil.Data
 			Resp chan yggdrasil.Response
@@ -131,7 +176,18 @@ func (d *Dispatcher) Connect() error {
 	signals := make(chan *dbus.Signal)
 	d.conn.Signal(signals)
 	go func() {
-		for s := range signals {
+		for {
+			var s *dbus.Signal
+			select {
+			case reply := <-d.probe:
+				close(reply)
+				continue
+			case sig, ok := <-signals:
+				if !ok {
+					return
+				}
+				s = sig
+			}
 			log.Tracef("received signal: %#v", s)
 
 			switch s.Name {
@@ -161,6 +217,16 @@ func (d *Dispatcher) Connect() error {
 				}
 				event.Worker = filepath.Base(string(s.Path))
 
+				if event.Name == ipc.WorkerEventNameEnd {
+					d.deadlines.done(event.MessageID)
+					d.acknowledge(event.Worker, event.MessageID)
+					d.release(event.Worker, event.MessageID)
+					d.onDemand.end(event.Worker, event.MessageID, d.stopIdleWorker)
+				} else {
+					d.unacked.touch(event.Worker, event.MessageID, time.Now())
+					d.onDemand.touch(event.Worker, d.stopIdleWorker)
+				}
+
 				d.WorkerEvents <- *event
 
 				// Start goroutine to add a new message journal entry.
@@ -209,11 +275,27 @@ func (d *Dispatcher) Connect() error {
 				// owner no longer owns the name; clean up the feature map.
 				if oldOwner != "" {
 					d.features.Del(workerName)
+					d.metrics.stopped(workerName, time.Now())
+				}
+
+				// If the name was released without a new owner, the worker
+				// exited; check whether it crashed.
+				if oldOwner != "" && newOwner == "" {
+					pid, _ := d.pids.Get(workerName)
+					d.pids.Del(workerName)
+					go d.workerExited(workerName, pid)
+					d.onDemand.forget(workerName)
+					d.redeliverAbandoned(workerName)
+					if data, ok := d.queues.reset(workerName); ok {
+						go d.dispatchQueued(data)
+					}
 				}
 
 				// If there is a new owner, this signal means a new process
 				// owns the name; add a record to the feature map.
 				if newOwner != "" {
+					restarts := d.metrics.started(workerName, time.Now())
+					d.workerStarted(workerName, newOwner, restarts)
 					obj := d.conn.Object(
 						name,
 						dbus.ObjectPath(
@@ -241,7 +323,8 @@ func (d *Dispatcher) Connect() error {
 	}()
 
 	// start goroutine that finds workers activatable on the bus connection
-	// and get their features if the worker is already running.
+	// and get their features if the worker is already running, then starts
+	// the others if configured to.
 	go func() {
 		workers, err := d.findActivatableWorkers()
 		if err != nil {
@@ -257,6 +340,7 @@ func (d *Dispatcher) Connect() error {
 				continue
 			}
 			if present {
+				d.metrics.started(directive, time.Now())
 				obj := d.conn.Object(
 					worker,
 					dbus.ObjectPath(filepath.Join("/com/redhat/Yggdrasil1/Worker1/", directive)),
@@ -281,29 +365,188 @@ func (d *Dispatcher) Connect() error {
 			}
 		}
 		d.Dispatchers <- d.FlattenDispatchers()
+
+		if n := config.DefaultConfig.WorkerStartConcurrency; n > 0 {
+			d.startActivatableWorkers(workers, n)
+		}
 	}()
 
-	// start goroutine receiving values from the inbound channel and send them
-	// via the Worker D-Bus interface.
+	// start goroutine that periodically pings running workers and restarts
+	// any that stop responding.
+	if config.DefaultConfig.HealthCheckInterval > 0 {
+		go d.monitorWorkerHealth(
+			config.DefaultConfig.HealthCheckInterval,
+			config.DefaultConfig.HealthCheckFailures,
+		)
+	}
+
+	// start goroutine receiving values from the inbound channel and adding
+	// them to the dispatch lane of their priority.
 	go func() {
 		for data := range d.Inbound {
-			if err := d.Dispatch(data); err != nil {
-				log.Errorf("cannot dispatch data: %v", err)
+			d.trace(DispatchEventReceived, data, "")
+			if d.draining.Load() {
+				d.deferUntilRestart(data)
+				continue
+			}
+			if d.duplicate(data) {
+				logging.With(data.Directive, data.MessageID).Infof("dropping duplicate message %v for directive %v", data.MessageID, data.Directive)
+				d.trace(DispatchEventDuplicate, data, "")
 				continue
 			}
+			d.lanes.push(data)
+		}
+	}()
+
+	// start goroutine taking messages from the dispatch lanes, highest
+	// priority first, and sending them via the Worker D-Bus interface.
+	go func() {
+		for {
+			d.process(d.lanes.pop())
+			d.lanes.finish()
 		}
 	}()
 
+	// redeliver messages that were not acknowledged before yggd last exited.
+	go d.replayPending()
+
 	return nil
 }
 
+// process delivers a message received from the server to the worker its
+// directive is routed to, journaling it until the worker acknowledges it by
+// emitting the END event for it.
+func (d *Dispatcher) process(data yggdrasil.Data) {
+	if d.discardExpired(data) {
+		return
+	}
+	if data.Directive == BroadcastDirective {
+		d.replyBroadcast(data)
+		return
+	}
+	data.Directive = d.resolveDirective(data.Directive)
+
+	if err := d.runMiddleware(DirectionInbound, &data); err != nil {
+		log.Warnf("cannot dispatch data: %v", err)
+		d.trace(DispatchEventRejected, data, err.Error())
+		d.Failures <- data
+		return
+	}
+	if err := d.validateContent(data); err != nil {
+		logging.With(data.Directive, data.MessageID).Warnf("rejecting message %v for directive %v: %v", data.MessageID, data.Directive, err)
+		d.trace(DispatchEventRejected, data, err.Error())
+		d.replyInvalidContent(data, err)
+		return
+	}
+
+	d.savePending(data)
+	d.deliver(data)
+}
+
+// discardExpired returns true if data has expired, in which case it is removed
+// from the pending journal and reported on the Expired channel.
+func (d *Dispatcher) discardExpired(data yggdrasil.Data) bool {
+	if !MessageExpired(data.Sent, data.Metadata, config.DefaultConfig.MessageMaxAge, time.Now()) {
+		return false
+	}
+	logging.With(data.Directive, data.MessageID).Warnf("discarding expired message %v for directive %v", data.MessageID, data.Directive)
+	d.removePending(data)
+	d.trace(DispatchEventExpired, data, "")
+	d.Expired <- data
+	return true
+}
+
+// deliver dispatches a message that passed the dispatcher's checks. Messages
+// over their worker's rate limit are deferred, messages for a worker at its
+// concurrency limit are queued, and messages that cannot be delivered are
+// retried in the background. A delivered message stays in the pending journal
+// until its worker acknowledges it.
+func (d *Dispatcher) deliver(data yggdrasil.Data) {
+	if d.deferOverLimit(data) {
+		return
+	}
+	if !d.acquire(data) {
+		return
+	}
+	if err := d.Dispatch(data); err != nil {
+		log.Errorf("cannot dispatch data: %v", err)
+		d.release(data.Directive, data.MessageID)
+		go d.retryDispatch(
+			data,
+			err,
+			config.DefaultConfig.DispatchRetries,
+			config.DefaultConfig.DispatchRetryDelay,
+		)
+		return
+	}
+	if data.Directive == BroadcastDirective {
+		d.removePending(data)
+	}
+}
+
+// Dispatch sends data to the worker named by its directive, recording the
+// outcome in the worker's metrics. If the directive is BroadcastDirective, data
+// is sent to every available worker and an error lists the workers that
+// failed to accept it. If the directive matches no known worker, data is sent
+// to a worker handling its "Content-Type" metadata, if there is one. Data sent
+// to a worker is tracked until the worker emits the END event for it, and is
+// dispatched again if the worker exits first.
 func (d *Dispatcher) Dispatch(data yggdrasil.Data) error {
-	var err error
+	if data.Directive == BroadcastDirective {
+		return broadcastError(d.Broadcast(data))
+	}
+
+	// Route messages for unknown directives to a worker that handles their
+	// content type, if any.
+	if _, known := d.features.Get(data.Directive); !known {
+		if worker, ok := d.routeByContentType(contentTypeMetadata(data.Metadata)); ok {
+			log.Debugf("routing message %v for directive %v to worker %v by content type", data.MessageID, data.Directive, worker)
+			data.Directive = worker
+		}
+	}
+
+	// Track the message before dispatching it, since the worker may emit
+	// the END event before the call returns.
+	worker, _ := ScrubName(data.Directive)
+	if data.MessageID != "" {
+		d.unacked.dispatched(worker, data, time.Now())
+	}
+
+	err := d.dispatch(data)
+	d.metrics.dispatched(data.Directive, err == nil)
+	if err != nil {
+		d.unacked.undo(worker, data.MessageID)
+		d.trace(DispatchEventFailed, data, err.Error())
+		return err
+	}
+	d.trace(DispatchEventDispatched, data, "")
+	d.trackResponse(data)
+	return nil
+}
+
+func (d *Dispatcher) dispatch(data yggdrasil.Data) (err error) {
 	data.Directive, err = ScrubName(data.Directive)
 	if err != nil {
 		log.Debug(err)
 	}
 
+	if d.WorkerDisabled(data.Directive) {
+		return fmt.Errorf("%w: worker %v is disabled", ErrWorkerUnavailable, data.Directive)
+	}
+	if d.WorkerExcluded(data.Directive) {
+		return fmt.Errorf("%w: worker %v is excluded", ErrWorkerUnavailable, data.Directive)
+	}
+
+	// Getting a property of the worker activates it if it is not running. An
+	// on-demand worker is kept running until it emits the END event for the
+	// message and has then been idle for its timeout.
+	d.onDemand.begin(data.Directive, data.MessageID)
+	defer func() {
+		if err != nil || data.MessageID == "" {
+			d.onDemand.end(data.Directive, data.MessageID, d.stopIdleWorker)
+		}
+	}()
+
 	obj := d.conn.Object(
 		"com.redhat.Yggdrasil1.Worker1."+data.Directive,
 		dbus.ObjectPath(filepath.Join("/com/redhat/Yggdrasil1/Worker1/", data.Directive)),
@@ -367,7 +610,8 @@ func (d *Dispatcher) Dispatch(data yggdrasil.Data) error {
 			err,
 		)
 	}
-	log.Debugf("send message %v to worker %v", data.MessageID, data.Directive)
+	logging.With(data.Directive, data.MessageID).Debugf("send message %v to worker %v", data.MessageID, data.Directive)
+	log.Tracef("message %v: %v", data.MessageID, logging.Payload(data.Content, data.Metadata))
 
 	v, err := obj.GetProperty("com.redhat.Yggdrasil1.Worker1.Features")
 	if err != nil {
@@ -383,6 +627,20 @@ func (d *Dispatcher) Dispatch(data yggdrasil.Data) error {
 	return nil
 }
 
+// Responsive returns true if the dispatcher's signal handling loop answers a
+// probe within timeout. A false result indicates the dispatcher is stuck, for
+// example blocked on a channel nobody is receiving from.
+func (d *Dispatcher) Responsive(timeout time.Duration) bool {
+	reply := make(chan struct{})
+	select {
+	case d.probe <- reply:
+	case <-time.After(timeout):
+		return false
+	}
+	<-reply
+	return true
+}
+
 func (d *Dispatcher) DisconnectWorkers() {
 	if err := d.EmitEvent(ipc.DispatcherEventReceivedDisconnect); err != nil {
 		log.Errorf("cannot emit event: %v", err)
@@ -392,6 +650,9 @@ func (d *Dispatcher) DisconnectWorkers() {
 func (d *Dispatcher) FlattenDispatchers() map[string]map[string]string {
 	dispatchers := make(map[string]map[string]string)
 	d.features.Visit(func(k string, v map[string]string) {
+		if d.WorkerDisabled(k) || d.WorkerExcluded(k) {
+			return
+		}
 		dispatchers[k] = v
 		// Include a second entry in the dispatchers map replacing any
 		// underscores with hyphens to support the "legacy" names of workers.
@@ -429,6 +690,45 @@ func (d *Dispatcher) Transmit(
 	}
 
 	directive := strings.TrimPrefix(name, "com.redhat.Yggdrasil1.Worker1.")
+	d.onDemand.touch(directive, d.stopIdleWorker)
+
+	// A message in response to one dispatched to the worker meets its
+	// response deadline.
+	if responseTo != "" {
+		d.deadlines.done(responseTo)
+		d.trace(DispatchEventResponse, yggdrasil.Data{MessageID: responseTo, Directive: directive, Content: data}, messageID)
+	}
+
+	// Deliver messages addressed to another local worker without a round trip
+	// through the server.
+	if target, ok := localWorkerAddr(addr); ok {
+		err := d.routeLocal(directive, yggdrasil.Data{
+			Type:       yggdrasil.MessageTypeData,
+			MessageID:  messageID,
+			ResponseTo: responseTo,
+			Version:    1,
+			Sent:       time.Now(),
+			Directive:  target,
+			Metadata:   metadata,
+			Content:    data,
+		})
+		if err != nil {
+			return TransmitResponseErr, nil, nil, NewDBusError(
+				"Transmit",
+				fmt.Sprintf("cannot route message to worker %v: %v", target, err),
+			)
+		}
+		return TransmitResponseOK, nil, nil, nil
+	}
+
+	// Hold back workers that exceed their byte quota; the worker is expected
+	// to transmit the message again later.
+	if wait := d.rateLimits.reserveBytes(directive, int64(len(data)), time.Now()); wait > 0 {
+		detail := fmt.Sprintf("byte quota exceeded; retry in %v", wait.Round(time.Second))
+		logging.With(directive, messageID).Warnf("deferring message %v from worker %v: %v", messageID, directive, detail)
+		d.trace(DispatchEventDeferred, yggdrasil.Data{MessageID: messageID, Directive: directive, Metadata: metadata, Content: data}, detail)
+		return TransmitResponseErr, nil, nil, NewDBusError("com.redhat.Yggdrasil1.Dispatcher1.QuotaExceeded", detail)
+	}
 
 	obj := d.conn.Object(
 		"com.redhat.Yggdrasil1.Worker1."+directive,
@@ -486,21 +786,26 @@ func (d *Dispatcher) Transmit(
 			return TransmitResponseErr, nil, nil, NewDBusError("Transmit", fmt.Sprintf("URL: '%v' has no scheme", addr))
 		}
 	} else {
+		message := yggdrasil.Data{
+			Type:       yggdrasil.MessageTypeData,
+			MessageID:  messageID,
+			ResponseTo: responseTo,
+			Version:    1,
+			Sent:       time.Now(),
+			Directive:  addr,
+			Metadata:   metadata,
+			Content:    data,
+		}
+		if err := d.runMiddleware(DirectionOutbound, &message); err != nil {
+			return TransmitResponseErr, nil, nil, NewDBusError("Transmit", err.Error())
+		}
+
 		ch := make(chan yggdrasil.Response)
 		d.Outbound <- struct {
 			Data yggdrasil.Data
 			Resp chan yggdrasil.Response
 		}{
-			Data: yggdrasil.Data{
-				Type:       yggdrasil.MessageTypeData,
-				MessageID:  messageID,
-				ResponseTo: responseTo,
-				Version:    1,
-				Sent:       time.Now(),
-				Directive:  addr,
-				Metadata:   metadata,
-				Content:    data,
-			},
+			Data: message,
 			Resp: ch,
 		}
 
@@ -516,6 +821,72 @@ func (d *Dispatcher) Transmit(
 	return
 }
 
+// reply sends content to the server as a data message addressed to directive
+// in response to the message identified by responseTo.
+func (d *Dispatcher) reply(directive, responseTo string, content []byte) error {
+	ch := make(chan yggdrasil.Response)
+	d.Outbound <- struct {
+		Data yggdrasil.Data
+		Resp chan yggdrasil.Response
+	}{
+		Data: yggdrasil.Data{
+			Type:       yggdrasil.MessageTypeData,
+			MessageID:  uuid.New().String(),
+			ResponseTo: responseTo,
+			Version:    1,
+			Sent:       time.Now(),
+			Directive:  directive,
+			Content:    content,
+		},
+		Resp: ch,
+	}
+
+	select {
+	case <-ch:
+		return nil
+	case <-time.After(1 * time.Second):
+		return fmt.Errorf("timeout reached waiting for response")
+	}
+}
+
+// routeLocal adds data, transmitted by the worker sender to another local
+// worker, to the dispatch lanes, so that it is handled like a message received
+// from the server: it passes through the inbound middleware and schema
+// validation, is subject to the target's rate limits and concurrency limit,
+// and is journaled and retried until the target accepts it.
+func (d *Dispatcher) routeLocal(sender string, data yggdrasil.Data) error {
+	if data.Directive == sender || d.resolveDirective(data.Directive) == sender {
+		return fmt.Errorf("worker %v cannot send a message to itself", sender)
+	}
+	d.trace(DispatchEventReceived, data, "")
+	if d.draining.Load() {
+		d.deferUntilRestart(data)
+		return nil
+	}
+	if d.duplicate(data) {
+		logging.With(data.Directive, data.MessageID).Infof("dropping duplicate message %v for directive %v", data.MessageID, data.Directive)
+		d.trace(DispatchEventDuplicate, data, "")
+		return nil
+	}
+	log.Debugf("routing message %v from worker %v to worker %v", data.MessageID, sender, data.Directive)
+	d.lanes.push(data)
+	return nil
+}
+
+// localWorkerAddr returns the directive of the local worker addressed by addr
+// if addr is a "worker://DIRECTIVE" URL.
+func localWorkerAddr(addr string) (string, bool) {
+	URL, err := url.Parse(addr)
+	if err != nil || URL.Scheme != "worker" || URL.Host == "" {
+		return "", false
+	}
+	directive, err := ScrubName(URL.Host)
+	if err != nil {
+		log.Debug(err)
+	}
+	return directive, true
+}
+
 // senderName retrieves a list of names from the bus object, iterating over each
 // name, looking for a name owned by sender, returning the name if one is found.
 func (d *Dispatcher) senderName(sender dbus.Sender) (string, error) {
@@ -647,7 +1018,7 @@ func (d *Dispatcher) CancelMessage(directive, message_id, cancel_id string) erro
 			err,
 		)
 	}
-	log.Debugf("sent cancel message %v to worker %v", cancel_id, directive)
+	logging.With(directive, cancel_id).Debugf("sent cancel message %v to worker %v", cancel_id, directive)
 	d.Dispatchers <- d.FlattenDispatchers()
 	return nil
 }
@@ -2,10 +2,12 @@ package work
 
 import (
 	"testing"
+	"time"
 
 	"github.com/godbus/dbus/v5"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/redhatinsights/yggdrasil"
 	"github.com/redhatinsights/yggdrasil/ipc"
 )
 
@@ -136,3 +138,120 @@ func TestWorkerEventFromSignal(t *testing.T) {
 		})
 	}
 }
+
+func TestResponsive(t *testing.T) {
+	d := NewDispatcher(nil)
+
+	if d.Responsive(10 * time.Millisecond) {
+		t.Errorf("dispatcher without a signal loop reported responsive")
+	}
+
+	go func() {
+		reply := <-d.probe
+		close(reply)
+	}()
+	if !d.Responsive(time.Second) {
+		t.Errorf("dispatcher answering probes reported unresponsive")
+	}
+}
+
+func TestLocalWorkerAddr(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		want        string
+		wantOK      bool
+	}{
+		{
+			description: "worker address",
+			input:       "worker://uploader",
+			want:        "uploader",
+			wantOK:      true,
+		},
+		{
+			description: "hyphenated worker address",
+			input:       "worker://package-manager",
+			want:        "package_manager",
+			wantOK:      true,
+		},
+		{
+			description: "directive",
+			input:       "uploader",
+		},
+		{
+			description: "HTTP URL",
+			input:       "https://example.com/upload",
+		},
+		{
+			description: "missing directive",
+			input:       "worker://",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got, ok := localWorkerAddr(test.input)
+
+			if ok != test.wantOK {
+				t.Errorf("%v != %v", ok, test.wantOK)
+			}
+			if got != test.want {
+				t.Errorf("%v != %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRouteLocal(t *testing.T) {
+	tests := []struct {
+		description string
+		sender      string
+		directive   string
+		wantError   bool
+	}{
+		{
+			description: "other worker",
+			sender:      "collector",
+			directive:   "uploader",
+		},
+		{
+			description: "itself",
+			sender:      "uploader",
+			directive:   "uploader",
+			wantError:   true,
+		},
+		{
+			description: "itself through an alias",
+			sender:      "uploader",
+			directive:   "upload",
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			d := NewDispatcher(nil)
+			d.SetDirectiveAliases(map[string]string{"upload": "uploader"})
+
+			data := yggdrasil.Data{MessageID: "1234", Directive: test.directive}
+			err := d.routeLocal(test.sender, data)
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				if !d.lanes.idle() {
+					t.Errorf("message added to the dispatch lanes")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The message waits in the dispatch lanes, to be processed like a
+			// message received from the server.
+			if got := d.lanes.pop(); !cmp.Equal(got, data) {
+				t.Errorf("%v", cmp.Diff(got, data))
+			}
+		})
+	}
+}
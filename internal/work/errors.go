@@ -1,6 +1,14 @@
 package work
 
-import "reflect"
+import (
+	"errors"
+	"reflect"
+)
+
+// ErrWorkerUnavailable is returned when a message is addressed to a worker
+// that has been disabled or excluded. Delivery to such a worker is not
+// retried.
+var ErrWorkerUnavailable = errors.New("worker unavailable")
 
 // typeConversionError represents a conversion error when converting one type
 // to  another.
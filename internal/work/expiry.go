@@ -0,0 +1,27 @@
+package work
+
+import (
+	"strings"
+	"time"
+)
+
+// MessageExpired returns true if a message sent at sent with the given
+// metadata is no longer valid at now. A message expires at the time given by
+// its "Expires" metadata, in RFC 3339 format, or, if maxAge is greater than
+// zero, once it is older than maxAge. A message without a sent time or expiry
+// never expires.
+func MessageExpired(sent time.Time, metadata map[string]string, maxAge time.Duration, now time.Time) bool {
+	for k, v := range metadata {
+		if !strings.EqualFold(k, "Expires") {
+			continue
+		}
+		expires, err := time.Parse(time.RFC3339, v)
+		if err == nil && now.After(expires) {
+			return true
+		}
+	}
+	if maxAge > 0 && !sent.IsZero() && now.Sub(sent) > maxAge {
+		return true
+	}
+	return false
+}
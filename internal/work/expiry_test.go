@@ -0,0 +1,64 @@
+package work
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMessageExpired(t *testing.T) {
+	now := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		description string
+		sent        time.Time
+		metadata    map[string]string
+		maxAge      time.Duration
+		want        bool
+	}{
+		{
+			description: "no expiry",
+			sent:        now.Add(-7 * 24 * time.Hour),
+		},
+		{
+			description: "within max age",
+			sent:        now.Add(-time.Hour),
+			maxAge:      24 * time.Hour,
+		},
+		{
+			description: "older than max age",
+			sent:        now.Add(-7 * 24 * time.Hour),
+			maxAge:      24 * time.Hour,
+			want:        true,
+		},
+		{
+			description: "no sent time",
+			maxAge:      24 * time.Hour,
+		},
+		{
+			description: "expires in the future",
+			sent:        now.Add(-time.Hour),
+			metadata:    map[string]string{"Expires": "2024-01-09T00:00:00Z"},
+		},
+		{
+			description: "expires in the past",
+			sent:        now.Add(-time.Hour),
+			metadata:    map[string]string{"expires": "2024-01-07T00:00:00Z"},
+			want:        true,
+		},
+		{
+			description: "invalid expires",
+			sent:        now.Add(-time.Hour),
+			metadata:    map[string]string{"Expires": "tomorrow"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got := MessageExpired(test.sent, test.metadata, test.maxAge, now)
+
+			if got != test.want {
+				t.Errorf("%v != %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package work
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/godbus/dbus/v5"
+	"github.com/redhatinsights/yggdrasil/internal/config"
+	"github.com/redhatinsights/yggdrasil/internal/logging"
+	"github.com/redhatinsights/yggdrasil/ipc"
+)
+
+// monitorWorkerHealth checks each running worker every interval. A worker
+// fails a check if it does not answer the org.freedesktop.DBus.Peer.Ping
+// method, which is answered by its bus connection, or if it holds a message
+// it has neither finished nor reported working on, with a BEGIN or WORKING
+// event, within its response timeout, which catches workers whose message
+// handlers are stuck. When a worker fails 'failures' consecutive checks, an
+// UNRESPONSIVE event is emitted and the worker's systemd unit is restarted;
+// the messages it had not finished are then dispatched again. Successive
+// restarts of the same worker are spaced out using an exponential backoff with
+// jitter.
+func (d *Dispatcher) monitorWorkerHealth(interval time.Duration, failures int) {
+	m := healthMonitor{
+		failures: failures,
+		running: func(worker string) (bool, error) {
+			return d.nameHasOwner("com.redhat.Yggdrasil1.Worker1." + worker)
+		},
+		ping: func(worker string) error {
+			return d.pingWorker(worker, interval)
+		},
+		stalled: d.stalledMessages,
+		restart: func(worker string) error {
+			d.WorkerEvents <- ipc.WorkerEvent{
+				Worker: worker,
+				Name:   ipc.WorkerEventNameUnresponsive,
+			}
+			return d.restartWorker(worker)
+		},
+		delay: func(restarts int) time.Duration {
+			return backoffDelay(
+				restarts,
+				config.DefaultConfig.RestartDelay,
+				config.DefaultConfig.RestartMaxDelay,
+			)
+		},
+		now: time.Now,
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		var workers []string
+		d.features.Visit(func(k string, _ map[string]string) {
+			workers = append(workers, k)
+		})
+		m.check(workers)
+	}
+}
+
+// healthMonitor counts the consecutive health checks each worker fails and
+// restarts workers that fail too many.
+type healthMonitor struct {
+	failures int
+
+	running func(worker string) (bool, error)
+	ping    func(worker string) error
+	stalled func(worker string) error
+	restart func(worker string) error
+	delay   func(restarts int) time.Duration
+	now     func() time.Time
+
+	missed      map[string]int
+	restarts    map[string]int
+	nextRestart map[string]time.Time
+}
+
+// check pings each running worker in workers and looks for messages it is
+// stuck on, restarting those that failed m.failures consecutive checks unless
+// they were restarted too recently.
+func (m *healthMonitor) check(workers []string) {
+	if m.missed == nil {
+		m.missed = make(map[string]int)
+		m.restarts = make(map[string]int)
+		m.nextRestart = make(map[string]time.Time)
+	}
+
+	for _, worker := range workers {
+		present, err := m.running(worker)
+		if err != nil {
+			log.Errorf("cannot find owner for name: %v: %v", worker, err)
+			continue
+		}
+		// Workers that are not running are activated on demand; there is
+		// nothing to check until they own their name.
+		if !present {
+			delete(m.missed, worker)
+			continue
+		}
+
+		err = m.ping(worker)
+		if err == nil {
+			err = m.stalled(worker)
+		}
+		if err != nil {
+			m.missed[worker]++
+			log.Warnf(
+				"worker %v failed health check (%v/%v): %v",
+				worker,
+				m.missed[worker],
+				m.failures,
+				err,
+			)
+			if m.missed[worker] < m.failures {
+				continue
+			}
+			if m.now().Before(m.nextRestart[worker]) {
+				log.Debugf(
+					"delaying restart of worker %v until %v",
+					worker,
+					m.nextRestart[worker],
+				)
+				continue
+			}
+
+			logging.With(worker, "").Errorf("worker %v is unresponsive, restarting", worker)
+			if err := m.restart(worker); err != nil {
+				logging.With(worker, "").Errorf("cannot restart worker %v: %v", worker, err)
+			}
+			m.nextRestart[worker] = m.now().Add(m.delay(m.restarts[worker]))
+			m.restarts[worker]++
+			delete(m.missed, worker)
+			continue
+		}
+		delete(m.missed, worker)
+		delete(m.restarts, worker)
+		delete(m.nextRestart, worker)
+	}
+}
+
+// backoffDelay computes the delay before the next retry, given the number of
+// previous attempts. The delay starts at base, doubles with each attempt up to
+// max (or until it would overflow if max is zero), and is jittered randomly
+// between half and the full computed value so that several failing workers do
+// not retry in lockstep.
+func backoffDelay(attempt int, base time.Duration, max time.Duration) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+
+	delay := base
+	for i := 0; i < attempt && (max <= 0 || delay < max) && delay <= math.MaxInt64/2; i++ {
+		delay *= 2
+	}
+	if max > 0 && delay > max {
+		delay = max
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// pingWorker calls the org.freedesktop.DBus.Peer.Ping method on the worker,
+// giving up after timeout.
+func (d *Dispatcher) pingWorker(worker string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	obj := d.conn.Object(
+		"com.redhat.Yggdrasil1.Worker1."+worker,
+		dbus.ObjectPath(filepath.Join("/com/redhat/Yggdrasil1/Worker1/", worker)),
+	)
+	if err := obj.CallWithContext(ctx, "org.freedesktop.DBus.Peer.Ping", 0).Store(); err != nil {
+		return fmt.Errorf("cannot call org.freedesktop.DBus.Peer.Ping: %w", err)
+	}
+	return nil
+}
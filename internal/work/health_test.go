@@ -0,0 +1,181 @@
+package work
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		description string
+		attempt     int
+		base        time.Duration
+		max         time.Duration
+		wantMin     time.Duration
+		wantMax     time.Duration
+	}{
+		{
+			description: "first attempt",
+			attempt:     0,
+			base:        5 * time.Second,
+			max:         time.Minute,
+			wantMin:     2500 * time.Millisecond,
+			wantMax:     5 * time.Second,
+		},
+		{
+			description: "third attempt",
+			attempt:     2,
+			base:        5 * time.Second,
+			max:         time.Minute,
+			wantMin:     10 * time.Second,
+			wantMax:     20 * time.Second,
+		},
+		{
+			description: "capped",
+			attempt:     10,
+			base:        5 * time.Second,
+			max:         time.Minute,
+			wantMin:     30 * time.Second,
+			wantMax:     time.Minute,
+		},
+		{
+			description: "unbounded",
+			attempt:     100,
+			base:        5 * time.Second,
+			max:         0,
+			wantMin:     math.MaxInt64 / 4,
+			wantMax:     math.MaxInt64,
+		},
+		{
+			description: "disabled",
+			attempt:     3,
+			base:        0,
+			max:         time.Minute,
+			wantMin:     0,
+			wantMax:     0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				got := backoffDelay(test.attempt, test.base, test.max)
+				if got < test.wantMin || got > test.wantMax {
+					t.Fatalf("%v not in range [%v, %v]", got, test.wantMin, test.wantMax)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthMonitorCheck(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	failing := map[string]bool{"stuck": true}
+	hung := map[string]bool{}
+	var pinged, restarted []string
+	var delays []int
+
+	m := healthMonitor{
+		failures: 2,
+		running: func(worker string) (bool, error) {
+			return worker != "stopped", nil
+		},
+		ping: func(worker string) error {
+			pinged = append(pinged, worker)
+			if failing[worker] {
+				return fmt.Errorf("no reply")
+			}
+			return nil
+		},
+		stalled: func(worker string) error {
+			if hung[worker] {
+				return fmt.Errorf("no progress")
+			}
+			return nil
+		},
+		restart: func(worker string) error {
+			restarted = append(restarted, worker)
+			return nil
+		},
+		delay: func(restarts int) time.Duration {
+			delays = append(delays, restarts)
+			return time.Duration(restarts+1) * 10 * time.Second
+		},
+		now: func() time.Time { return now },
+	}
+	workers := []string{"healthy", "stopped", "stuck"}
+
+	// The first failure is tolerated; the second restarts the worker.
+	m.check(workers)
+	if len(restarted) != 0 {
+		t.Fatalf("restarted %v after one failure", restarted)
+	}
+	m.check(workers)
+	if !cmp.Equal(restarted, []string{"stuck"}) {
+		t.Fatalf("got restarts %v, want [stuck]", restarted)
+	}
+
+	// Further failures within the backoff delay do not restart it again.
+	now = now.Add(5 * time.Second)
+	m.check(workers)
+	m.check(workers)
+	if len(restarted) != 1 {
+		t.Fatalf("restarted %v within backoff delay", restarted)
+	}
+
+	// Once the delay has passed, it is restarted with a longer delay.
+	now = now.Add(10 * time.Second)
+	m.check(workers)
+	if !cmp.Equal(restarted, []string{"stuck", "stuck"}) {
+		t.Fatalf("got restarts %v, want [stuck stuck]", restarted)
+	}
+	if !cmp.Equal(delays, []int{0, 1}) {
+		t.Errorf("got delays for restarts %v, want [0 1]", delays)
+	}
+
+	// A successful check resets the backoff.
+	failing["stuck"] = false
+	m.check(workers)
+	failing["stuck"] = true
+	m.check(workers)
+	m.check(workers)
+	if !cmp.Equal(delays, []int{0, 1, 0}) {
+		t.Errorf("got delays for restarts %v, want [0 1 0]", delays)
+	}
+
+	for _, worker := range pinged {
+		if worker == "stopped" {
+			t.Fatal("pinged a worker that is not running")
+		}
+	}
+
+	// A worker that answers pings but is stuck on a message is restarted
+	// too.
+	restarted = nil
+	hung["healthy"] = true
+	m.check(workers)
+	m.check(workers)
+	if !slices.Contains(restarted, "healthy") {
+		t.Errorf("got restarts %v, want healthy restarted", restarted)
+	}
+}
+
+func TestPingWorker(t *testing.T) {
+	address := startTestBus(t)
+	connectTestBus(t, address, "com.redhat.Yggdrasil1.Worker1.echo")
+
+	d := NewDispatcher(nil)
+	d.conn = connectTestBus(t, address)
+
+	if err := d.pingWorker("echo", time.Second); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := d.pingWorker("missing", time.Second); err == nil {
+		t.Errorf("expected error pinging a worker that is not running")
+	}
+}
@@ -0,0 +1,83 @@
+package work
+
+import (
+	"fmt"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/redhatinsights/yggdrasil/internal/audit"
+)
+
+// Names of worker lifecycle events.
+const (
+	WorkerLifecycleStarted   = "started"
+	WorkerLifecycleStopped   = "stopped"
+	WorkerLifecycleCrashed   = "crashed"
+	WorkerLifecycleRestarted = "restarted"
+)
+
+// WorkerLifecycleEvent records a worker process starting or stopping.
+type WorkerLifecycleEvent struct {
+	Worker string
+	Name   string
+
+	// PID is the process ID of the worker, if it could be found.
+	PID uint32
+
+	// ExitStatus is the exit code, signal name or systemd service result of a
+	// stopped or crashed worker, if it could be found.
+	ExitStatus string
+
+	// Restarts is the number of times a restarted worker has been restarted
+	// since yggd started.
+	Restarts uint64
+
+	// Report is the path to the crash report of a crashed worker, if one was
+	// written.
+	Report string
+}
+
+// workerStarted sends a started event on the WorkerLifecycle channel for
+// worker, whose bus name is now owned by the unique name owner. If the worker
+// ran before, a restarted event follows, carrying the number of restarts.
+func (d *Dispatcher) workerStarted(worker, owner string, restarts uint64) {
+	e := WorkerLifecycleEvent{Worker: worker, Name: WorkerLifecycleStarted}
+	pid, err := callMethod[uint32](d.conn.BusObject(), "org.freedesktop.DBus.GetConnectionUnixProcessID", owner)
+	if err != nil {
+		log.Debugf("cannot get process ID of worker %v: %v", worker, err)
+	} else {
+		e.PID = *pid
+		d.pids.Set(worker, *pid)
+	}
+	d.audit(audit.ActionWorkerStarted, worker, "", fmt.Sprintf("pid %v", e.PID))
+	d.WorkerLifecycle <- e
+
+	if restarts > 0 {
+		e.Name = WorkerLifecycleRestarted
+		e.Restarts = restarts
+		d.WorkerLifecycle <- e
+	}
+}
+
+// workerExited records the exit status of worker, whose process pid released
+// its bus name, and sends a stopped event on the WorkerLifecycle channel. If
+// the exit was unsuccessful, it reports the crash and sends a crashed event.
+func (d *Dispatcher) workerExited(worker string, pid uint32) {
+	unit := "com.redhat.Yggdrasil1.Worker1." + worker + ".service"
+	e := WorkerLifecycleEvent{Worker: worker, Name: WorkerLifecycleStopped, PID: pid}
+
+	report, err := d.unitExitReport(worker, unit)
+	if err != nil {
+		log.Debugf("cannot inspect unit %v: %v", unit, err)
+	} else {
+		e.ExitStatus = report.exitStatus()
+		d.metrics.exited(worker, e.ExitStatus)
+	}
+	d.audit(audit.ActionWorkerStopped, worker, "", fmt.Sprintf("pid %v exit status %v", pid, e.ExitStatus))
+	d.WorkerLifecycle <- e
+
+	if report != nil && report.Result != "success" {
+		e.Name = WorkerLifecycleCrashed
+		e.Report = d.reportCrash(worker, unit, report)
+		d.WorkerLifecycle <- e
+	}
+}
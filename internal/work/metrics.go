@@ -0,0 +1,128 @@
+package work
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// workerMetrics holds lifecycle counters for a single worker.
+type workerMetrics struct {
+	starts     uint64
+	startedAt  time.Time
+	uptime     time.Duration
+	lastExit   string
+	dispatched uint64
+	failed     uint64
+	duplicates uint64
+}
+
+// metricsRegistry records workerMetrics for each worker seen by the
+// dispatcher during the lifetime of yggd.
+type metricsRegistry struct {
+	mu      sync.Mutex
+	workers map[string]*workerMetrics
+}
+
+// get returns the metrics for worker, creating them if necessary. The caller
+// must hold r.mu.
+func (r *metricsRegistry) get(worker string) *workerMetrics {
+	if r.workers == nil {
+		r.workers = make(map[string]*workerMetrics)
+	}
+	m, has := r.workers[worker]
+	if !has {
+		m = &workerMetrics{}
+		r.workers[worker] = m
+	}
+	return m
+}
+
+// started records that worker acquired its bus name at t, returning the
+// number of times the worker has been restarted.
+func (r *metricsRegistry) started(worker string, t time.Time) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m := r.get(worker)
+	m.starts++
+	m.startedAt = t
+	return m.starts - 1
+}
+
+// stopped records that worker released its bus name at t.
+func (r *metricsRegistry) stopped(worker string, t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m := r.get(worker)
+	if !m.startedAt.IsZero() {
+		m.uptime += t.Sub(m.startedAt)
+		m.startedAt = time.Time{}
+	}
+}
+
+// exited records the exit status of worker's last process.
+func (r *metricsRegistry) exited(worker string, status string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.get(worker).lastExit = status
+}
+
+// dispatched records the outcome of dispatching a message to worker.
+func (r *metricsRegistry) dispatched(worker string, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m := r.get(worker)
+	m.dispatched++
+	if !ok {
+		m.failed++
+	}
+}
+
+// duplicated records that a duplicate message for worker was dropped.
+func (r *metricsRegistry) duplicated(worker string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.get(worker).duplicates++
+}
+
+// snapshot returns the metrics of every worker as string maps, suitable for
+// sending over D-Bus, computing uptime relative to now.
+func (r *metricsRegistry) snapshot(now time.Time) map[string]map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot := make(map[string]map[string]string)
+	for worker, m := range r.workers {
+		var restarts uint64
+		if m.starts > 0 {
+			restarts = m.starts - 1
+		}
+		uptime := m.uptime
+		if !m.startedAt.IsZero() {
+			uptime += now.Sub(m.startedAt)
+		}
+		snapshot[worker] = map[string]string{
+			"restarts":            strconv.FormatUint(restarts, 10),
+			"uptime":              strconv.FormatInt(int64(uptime.Seconds()), 10),
+			"running":             strconv.FormatBool(!m.startedAt.IsZero()),
+			"last_exit":           m.lastExit,
+			"messages_dispatched": strconv.FormatUint(m.dispatched, 10),
+			"messages_failed":     strconv.FormatUint(m.failed, 10),
+			"messages_duplicate":  strconv.FormatUint(m.duplicates, 10),
+		}
+	}
+	return snapshot
+}
+
+// WorkerMetrics returns lifecycle metrics for every worker seen since yggd
+// started. Each worker's map holds the keys "restarts", "uptime" (cumulative
+// seconds), "running", "last_exit", "messages_dispatched", "messages_failed"
+// and "messages_duplicate".
+func (d *Dispatcher) WorkerMetrics() map[string]map[string]string {
+	return d.metrics.snapshot(time.Now())
+}
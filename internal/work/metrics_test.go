@@ -0,0 +1,91 @@
+package work
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMetricsSnapshot(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		description string
+		input       func(r *metricsRegistry)
+		want        map[string]map[string]string
+	}{
+		{
+			description: "running",
+			input: func(r *metricsRegistry) {
+				r.started("echo", start)
+				r.dispatched("echo", true)
+				r.dispatched("echo", false)
+				r.duplicated("echo")
+			},
+			want: map[string]map[string]string{
+				"echo": {
+					"restarts":            "0",
+					"uptime":              "60",
+					"running":             "true",
+					"last_exit":           "",
+					"messages_dispatched": "2",
+					"messages_failed":     "1",
+					"messages_duplicate":  "1",
+				},
+			},
+		},
+		{
+			description: "restarted",
+			input: func(r *metricsRegistry) {
+				r.started("echo", start)
+				r.stopped("echo", start.Add(10*time.Second))
+				r.exited("echo", "1")
+				r.started("echo", start.Add(20*time.Second))
+			},
+			want: map[string]map[string]string{
+				"echo": {
+					"restarts":            "1",
+					"uptime":              "50",
+					"running":             "true",
+					"last_exit":           "1",
+					"messages_dispatched": "0",
+					"messages_failed":     "0",
+					"messages_duplicate":  "0",
+				},
+			},
+		},
+		{
+			description: "stopped",
+			input: func(r *metricsRegistry) {
+				r.started("echo", start)
+				r.stopped("echo", start.Add(30*time.Second))
+				r.exited("echo", "SIGKILL")
+			},
+			want: map[string]map[string]string{
+				"echo": {
+					"restarts":            "0",
+					"uptime":              "30",
+					"running":             "false",
+					"last_exit":           "SIGKILL",
+					"messages_dispatched": "0",
+					"messages_failed":     "0",
+					"messages_duplicate":  "0",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			r := &metricsRegistry{}
+			test.input(r)
+
+			got := r.snapshot(start.Add(time.Minute))
+
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
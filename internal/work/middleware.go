@@ -0,0 +1,109 @@
+package work
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/redhatinsights/yggdrasil"
+)
+
+// Direction identifies whether a message passing through middleware was
+// received from the server or is being sent to it.
+type Direction string
+
+const (
+	// DirectionInbound is a message received from the server.
+	DirectionInbound Direction = "inbound"
+
+	// DirectionOutbound is a message transmitted by a worker to the server.
+	DirectionOutbound Direction = "outbound"
+)
+
+// Middleware processes messages before they are dispatched to a worker or
+// sent to the server. It may modify data in place. Returning an error rejects
+// the message.
+type Middleware interface {
+	Process(direction Direction, data *yggdrasil.Data) error
+}
+
+// MiddlewareFunc is an adapter allowing an ordinary function to be used as
+// Middleware.
+type MiddlewareFunc func(direction Direction, data *yggdrasil.Data) error
+
+// Process calls f(direction, data).
+func (f MiddlewareFunc) Process(direction Direction, data *yggdrasil.Data) error {
+	return f(direction, data)
+}
+
+// Use appends m to the dispatcher's middleware chain. Middleware is run in the
+// order it was added. Use must be called before the dispatcher is connected.
+func (d *Dispatcher) Use(m Middleware) {
+	d.middleware = append(d.middleware, m)
+}
+
+// runMiddleware passes data through the dispatcher's middleware chain,
+// stopping at the first middleware that rejects it.
+func (d *Dispatcher) runMiddleware(direction Direction, data *yggdrasil.Data) error {
+	for _, m := range d.middleware {
+		if err := m.Process(direction, data); err != nil {
+			return fmt.Errorf("message %v rejected: %w", data.MessageID, err)
+		}
+	}
+	return nil
+}
+
+// HookMiddleware runs an external program for each message. The program is
+// invoked with the direction ("inbound" or "outbound") as its only argument
+// and receives the message as JSON on its standard input. It may write a
+// modified message as JSON to its standard output; if it writes nothing, the
+// message is unchanged. A non-zero exit status rejects the message, using the
+// program's standard error as the reason.
+type HookMiddleware struct {
+	Path    string
+	Timeout time.Duration
+}
+
+// Process runs the hook program on data.
+func (h HookMiddleware) Process(direction Direction, data *yggdrasil.Data) error {
+	input, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("cannot marshal message: %w", err)
+	}
+
+	ctx := context.Background()
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, h.Path, string(direction))
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return errors.New(strings.TrimSpace(stderr.String()))
+		}
+		return fmt.Errorf("cannot run hook %v: %w", h.Path, err)
+	}
+
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return nil
+	}
+	var output yggdrasil.Data
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return fmt.Errorf("cannot unmarshal output of hook %v: %w", h.Path, err)
+	}
+	*data = output
+	return nil
+}
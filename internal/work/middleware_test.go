@@ -0,0 +1,136 @@
+package work
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/redhatinsights/yggdrasil"
+)
+
+func TestRunMiddleware(t *testing.T) {
+	tenant := MiddlewareFunc(func(direction Direction, data *yggdrasil.Data) error {
+		data.Metadata["tenant"] = "1234"
+		return nil
+	})
+	deny := MiddlewareFunc(func(direction Direction, data *yggdrasil.Data) error {
+		if direction == DirectionInbound && data.Directive == "forbidden" {
+			return fmt.Errorf("directive %v is not allowed", data.Directive)
+		}
+		return nil
+	})
+
+	tests := []struct {
+		description string
+		direction   Direction
+		input       yggdrasil.Data
+		want        yggdrasil.Data
+		wantError   string
+	}{
+		{
+			description: "modified",
+			direction:   DirectionInbound,
+			input:       yggdrasil.Data{MessageID: "a", Directive: "echo", Metadata: map[string]string{}},
+			want:        yggdrasil.Data{MessageID: "a", Directive: "echo", Metadata: map[string]string{"tenant": "1234"}},
+		},
+		{
+			description: "rejected",
+			direction:   DirectionInbound,
+			input:       yggdrasil.Data{MessageID: "a", Directive: "forbidden", Metadata: map[string]string{}},
+			wantError:   "message a rejected: directive forbidden is not allowed",
+		},
+		{
+			description: "outbound",
+			direction:   DirectionOutbound,
+			input:       yggdrasil.Data{MessageID: "a", Directive: "forbidden", Metadata: map[string]string{}},
+			want:        yggdrasil.Data{MessageID: "a", Directive: "forbidden", Metadata: map[string]string{"tenant": "1234"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			d := NewDispatcher(nil)
+			d.Use(tenant)
+			d.Use(deny)
+
+			err := d.runMiddleware(test.direction, &test.input)
+
+			if test.wantError != "" {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				if err.Error() != test.wantError {
+					t.Errorf("%q != %q", err.Error(), test.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(test.input, test.want) {
+				t.Errorf("%v", cmp.Diff(test.input, test.want))
+			}
+		})
+	}
+}
+
+func TestHookMiddleware(t *testing.T) {
+	modified, err := json.Marshal(yggdrasil.Data{MessageID: "a", Directive: "other", Content: []byte(`"hello"`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		description string
+		script      string
+		want        yggdrasil.Data
+		wantError   string
+	}{
+		{
+			description: "unchanged",
+			script:      "cat > /dev/null",
+			want:        yggdrasil.Data{MessageID: "a", Directive: "echo"},
+		},
+		{
+			description: "modified",
+			script:      fmt.Sprintf("test \"$1\" = inbound && cat > /dev/null && echo '%s'", modified),
+			want:        yggdrasil.Data{MessageID: "a", Directive: "other", Content: []byte(`"hello"`)},
+		},
+		{
+			description: "rejected",
+			script:      "echo 'not allowed' >&2; exit 1",
+			wantError:   "not allowed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			hook := filepath.Join(t.TempDir(), "hook")
+			if err := os.WriteFile(hook, []byte("#!/bin/sh\n"+test.script+"\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			data := yggdrasil.Data{MessageID: "a", Directive: "echo"}
+			err := HookMiddleware{Path: hook}.Process(DirectionInbound, &data)
+
+			if test.wantError != "" {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				if err.Error() != test.wantError {
+					t.Errorf("%q != %q", err.Error(), test.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(data, test.want) {
+				t.Errorf("%v", cmp.Diff(data, test.want))
+			}
+		})
+	}
+}
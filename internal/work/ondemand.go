@@ -0,0 +1,190 @@
+package work
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/redhatinsights/yggdrasil/internal/logging"
+)
+
+// ParseOnDemandWorkers parses a list of "WORKER" or "WORKER=DURATION" entries
+// into a map from worker to the duration it may stay idle before it is
+// stopped. Entries without a duration use idleTimeout.
+func ParseOnDemandWorkers(entries []string, idleTimeout time.Duration) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, entry := range entries {
+		worker, value, hasValue := strings.Cut(entry, "=")
+		worker = strings.TrimSpace(worker)
+		if worker == "" {
+			return nil, fmt.Errorf("invalid on-demand worker '%v': expected WORKER or WORKER=DURATION", entry)
+		}
+		timeout := idleTimeout
+		if hasValue {
+			var err error
+			timeout, err = time.ParseDuration(strings.TrimSpace(value))
+			if err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("invalid on-demand worker '%v': expected a positive idle timeout", entry)
+			}
+		}
+		if timeout <= 0 {
+			return nil, fmt.Errorf("invalid on-demand worker '%v': idle timeout must be positive", entry)
+		}
+		if _, has := timeouts[worker]; has {
+			return nil, fmt.Errorf("duplicate on-demand worker '%v'", worker)
+		}
+		timeouts[worker] = timeout
+	}
+	return timeouts, nil
+}
+
+// onDemandWorkers tracks the activity of workers that are started by their
+// first message instead of when yggd starts, and stops each one once it has
+// been idle for its timeout. A worker is busy from the time a message is
+// dispatched to it until it emits the END event for the message, because
+// workers acknowledge a dispatched message before handling it.
+type onDemandWorkers struct {
+	lock     sync.Mutex
+	timeouts map[string]time.Duration
+	inFlight map[string]map[string]bool
+	timers   map[string]*time.Timer
+}
+
+// set replaces the idle timeout of each on-demand worker. Idle timers of
+// workers that are no longer on demand are cancelled.
+func (o *onDemandWorkers) set(timeouts map[string]time.Duration) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.timeouts = timeouts
+	for worker, timer := range o.timers {
+		if _, has := timeouts[worker]; !has {
+			timer.Stop()
+			delete(o.timers, worker)
+		}
+	}
+}
+
+// has returns true if worker is started on demand.
+func (o *onDemandWorkers) has(worker string) bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	_, has := o.timeouts[worker]
+	return has
+}
+
+// begin records the message identified by messageID being dispatched to
+// worker, holding off its idle timer until end is called for the message.
+func (o *onDemandWorkers) begin(worker, messageID string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if _, has := o.timeouts[worker]; !has {
+		return
+	}
+	if o.inFlight == nil {
+		o.inFlight = make(map[string]map[string]bool)
+	}
+	if o.inFlight[worker] == nil {
+		o.inFlight[worker] = make(map[string]bool)
+	}
+	o.inFlight[worker][messageID] = true
+	if timer, has := o.timers[worker]; has {
+		timer.Stop()
+		delete(o.timers, worker)
+	}
+}
+
+// end records that worker finished handling the message identified by
+// messageID, or that it could not be dispatched, and starts its idle timer if
+// it has no other message in flight.
+func (o *onDemandWorkers) end(worker, messageID string, stop func(worker string)) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	delete(o.inFlight[worker], messageID)
+	o.reset(worker, stop)
+}
+
+// forget drops the messages in flight to worker, because it exited.
+func (o *onDemandWorkers) forget(worker string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	delete(o.inFlight, worker)
+	if timer, has := o.timers[worker]; has {
+		timer.Stop()
+		delete(o.timers, worker)
+	}
+}
+
+// touch restarts the idle timer of worker, because it is still doing work,
+// unless it has messages in flight.
+func (o *onDemandWorkers) touch(worker string, stop func(worker string)) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.reset(worker, stop)
+}
+
+// reset starts the idle timer of worker, replacing any running timer, unless
+// worker has messages in flight. When the timer fires and worker is still
+// idle, stop is called. The caller must hold the lock.
+func (o *onDemandWorkers) reset(worker string, stop func(worker string)) {
+	timeout, has := o.timeouts[worker]
+	if !has || len(o.inFlight[worker]) > 0 {
+		return
+	}
+	if timer, has := o.timers[worker]; has {
+		timer.Stop()
+	}
+	if o.timers == nil {
+		o.timers = make(map[string]*time.Timer)
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(timeout, func() {
+		o.lock.Lock()
+		// The timer was replaced or cancelled after it fired.
+		if o.timers[worker] != timer || len(o.inFlight[worker]) > 0 {
+			o.lock.Unlock()
+			return
+		}
+		delete(o.timers, worker)
+		o.lock.Unlock()
+
+		stop(worker)
+	})
+	o.timers[worker] = timer
+}
+
+// SetOnDemandWorkers replaces the set of workers started on demand and the
+// duration each may stay idle before it is stopped. On-demand workers are not
+// started when yggd starts; they are activated by their first message.
+func (d *Dispatcher) SetOnDemandWorkers(timeouts map[string]time.Duration) {
+	d.onDemand.set(timeouts)
+}
+
+// WorkerOnDemand returns true if worker is started on demand.
+func (d *Dispatcher) WorkerOnDemand(worker string) bool {
+	return d.onDemand.has(worker)
+}
+
+// stopIdleWorker stops worker, an on-demand worker that has been idle for its
+// timeout, if it is running.
+func (d *Dispatcher) stopIdleWorker(worker string) {
+	present, err := d.nameHasOwner("com.redhat.Yggdrasil1.Worker1." + worker)
+	if err != nil {
+		log.Errorf("cannot find owner for name: %v: %v", worker, err)
+		return
+	}
+	if !present {
+		return
+	}
+	logging.With(worker, "").Infof("stopping idle worker %v", worker)
+	if err := d.stopWorker(worker); err != nil {
+		logging.With(worker, "").Errorf("cannot stop worker %v: %v", worker, err)
+	}
+}
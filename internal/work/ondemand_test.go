@@ -0,0 +1,135 @@
+package work
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseOnDemandWorkers(t *testing.T) {
+	tests := []struct {
+		description string
+		entries     []string
+		idleTimeout time.Duration
+		want        map[string]time.Duration
+		wantError   bool
+	}{
+		{
+			description: "empty",
+			idleTimeout: 5 * time.Minute,
+			want:        map[string]time.Duration{},
+		},
+		{
+			description: "default and explicit timeouts",
+			entries:     []string{"echo", "playbook = 30s"},
+			idleTimeout: 5 * time.Minute,
+			want: map[string]time.Duration{
+				"echo":     5 * time.Minute,
+				"playbook": 30 * time.Second,
+			},
+		},
+		{
+			description: "invalid duration",
+			entries:     []string{"echo=soon"},
+			idleTimeout: 5 * time.Minute,
+			wantError:   true,
+		},
+		{
+			description: "zero default",
+			entries:     []string{"echo"},
+			wantError:   true,
+		},
+		{
+			description: "missing worker",
+			entries:     []string{"=1m"},
+			idleTimeout: 5 * time.Minute,
+			wantError:   true,
+		},
+		{
+			description: "duplicate",
+			entries:     []string{"echo", "echo=1m"},
+			idleTimeout: 5 * time.Minute,
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got, err := ParseOnDemandWorkers(test.entries, test.idleTimeout)
+
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
+
+func TestOnDemandWorkersIdle(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+
+	var o onDemandWorkers
+	o.set(map[string]time.Duration{"echo": timeout})
+
+	stopped := make(chan string, 2)
+	stop := func(worker string) { stopped <- worker }
+	expectNoStop := func(reason string) {
+		t.Helper()
+		select {
+		case worker := <-stopped:
+			t.Fatalf("stopped %v %v", worker, reason)
+		case <-time.After(2 * timeout):
+		}
+	}
+
+	// A worker that is not on demand is never stopped.
+	o.begin("playbook", "1")
+	o.end("playbook", "1", stop)
+
+	// A message is in flight until the worker emits its END event, however
+	// long the worker takes and whatever other events it emits.
+	o.begin("echo", "2")
+	o.begin("echo", "3")
+	expectNoStop("while messages were in flight")
+	o.touch("echo", stop)
+	o.end("echo", "2", stop)
+	expectNoStop("while a message was in flight")
+
+	o.end("echo", "3", stop)
+	time.Sleep(timeout / 2)
+	o.touch("echo", stop)
+
+	start := time.Now()
+	select {
+	case worker := <-stopped:
+		if worker != "echo" {
+			t.Errorf("got %v, want echo", worker)
+		}
+		if elapsed := time.Since(start); elapsed < timeout*3/4 {
+			t.Errorf("stopped after %v, want at least %v since last activity", elapsed, timeout)
+		}
+	case <-time.After(10 * timeout):
+		t.Fatal("idle worker was not stopped")
+	}
+	expectNoStop("twice")
+
+	// A worker that exits forgets its messages in flight, so the END event it
+	// never emits does not keep it running when it is activated again.
+	o.begin("echo", "4")
+	o.forget("echo")
+	o.touch("echo", stop)
+	select {
+	case <-stopped:
+	case <-time.After(10 * timeout):
+		t.Fatal("idle worker was not stopped after it exited with a message in flight")
+	}
+}
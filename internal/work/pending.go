@@ -0,0 +1,165 @@
+package work
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/redhatinsights/yggdrasil"
+	"github.com/redhatinsights/yggdrasil/internal/logging"
+	"github.com/redhatinsights/yggdrasil/internal/spool"
+)
+
+// pendingMessage is a message accepted from the server that has not yet been
+// acknowledged by a worker.
+type pendingMessage struct {
+	Data yggdrasil.Data `json:"data"`
+	Time time.Time      `json:"time"`
+}
+
+// pendingStore journals pending messages as JSON files in a directory, one
+// file per message, named after the message ID. Files are encrypted with
+// cipher, if it is set.
+type pendingStore struct {
+	dir    string
+	cipher *spool.Cipher
+}
+
+// save writes data to the store.
+func (s pendingStore) save(data yggdrasil.Data) error {
+	file, err := messageFile(s.dir, data.MessageID)
+	if err != nil {
+		return err
+	}
+	message, err := json.Marshal(pendingMessage{
+		Data: data,
+		Time: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot marshal pending message: %w", err)
+	}
+	message, err = s.cipher.Seal(message)
+	if err != nil {
+		return fmt.Errorf("cannot encrypt pending message: %w", err)
+	}
+	if err := os.MkdirAll(s.dir, 0750); err != nil {
+		return fmt.Errorf("cannot create directory: %w", err)
+	}
+	if err := os.WriteFile(file, message, 0640); err != nil {
+		return fmt.Errorf("cannot write pending message: %w", err)
+	}
+	return nil
+}
+
+// remove deletes the message identified by messageID from the store. Removing
+// a message that is not in the store is not an error.
+func (s pendingStore) remove(messageID string) error {
+	file, err := messageFile(s.dir, messageID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove pending message: %w", err)
+	}
+	return nil
+}
+
+// list returns every message in the store, in the order they were accepted.
+// Files that cannot be read are skipped.
+func (s pendingStore) list() ([]yggdrasil.Data, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []yggdrasil.Data{}, nil
+		}
+		return nil, fmt.Errorf("cannot read directory: %w", err)
+	}
+
+	messages := []pendingMessage{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			log.Warnf("cannot read pending message: %v", err)
+			continue
+		}
+		data, err = s.cipher.Open(data)
+		if err != nil {
+			log.Warnf("cannot read pending message %v: %v", entry.Name(), err)
+			continue
+		}
+		var message pendingMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			log.Warnf("cannot unmarshal pending message %v: %v", entry.Name(), err)
+			continue
+		}
+		messages = append(messages, message)
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Time.Before(messages[j].Time)
+	})
+
+	list := make([]yggdrasil.Data, 0, len(messages))
+	for _, message := range messages {
+		list = append(list, message.Data)
+	}
+	return list, nil
+}
+
+// savePending journals data as pending delivery, if d.PendingDir is set.
+// Messages without an ID cannot be journaled.
+func (d *Dispatcher) savePending(data yggdrasil.Data) {
+	if d.PendingDir == "" || data.MessageID == "" {
+		return
+	}
+	if err := (pendingStore{dir: d.PendingDir, cipher: d.SpoolCipher}).save(data); err != nil {
+		log.Errorf("cannot journal message %v: %v", data.MessageID, err)
+	}
+}
+
+// removePending removes data from the pending journal, if d.PendingDir is
+// set.
+func (d *Dispatcher) removePending(data yggdrasil.Data) {
+	if d.PendingDir == "" || data.MessageID == "" {
+		return
+	}
+	if err := (pendingStore{dir: d.PendingDir, cipher: d.SpoolCipher}).remove(data.MessageID); err != nil {
+		log.Errorf("cannot remove message %v from journal: %v", data.MessageID, err)
+	}
+}
+
+// PendingMessages returns the number of messages accepted from the server
+// that have not yet been acknowledged by a worker.
+func (d *Dispatcher) PendingMessages() (int, error) {
+	if d.PendingDir == "" {
+		return 0, nil
+	}
+	messages, err := pendingStore{dir: d.PendingDir, cipher: d.SpoolCipher}.list()
+	if err != nil {
+		return 0, err
+	}
+	return len(messages), nil
+}
+
+// replayPending dispatches every message left in the pending journal by a
+// previous run of yggd.
+func (d *Dispatcher) replayPending() {
+	if d.PendingDir == "" {
+		return
+	}
+	messages, err := pendingStore{dir: d.PendingDir, cipher: d.SpoolCipher}.list()
+	if err != nil {
+		log.Errorf("cannot read pending messages: %v", err)
+		return
+	}
+	for _, data := range messages {
+		logging.With(data.Directive, data.MessageID).Infof("replaying pending message %v for directive %v", data.MessageID, data.Directive)
+		d.process(data)
+	}
+}
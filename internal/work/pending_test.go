@@ -0,0 +1,89 @@
+package work
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/redhatinsights/yggdrasil"
+	"github.com/redhatinsights/yggdrasil/internal/spool"
+)
+
+func TestPendingStore(t *testing.T) {
+	store := pendingStore{dir: t.TempDir()}
+
+	for _, id := range []string{"a", "b", "c"} {
+		if err := store.save(yggdrasil.Data{MessageID: id, Directive: "echo", Content: []byte(`"hello"`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.remove("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.remove("missing"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []yggdrasil.Data{
+		{MessageID: "a", Directive: "echo", Content: []byte(`"hello"`)},
+		{MessageID: "c", Directive: "echo", Content: []byte(`"hello"`)},
+	}
+
+	if !cmp.Equal(got, want) {
+		t.Errorf("%v", cmp.Diff(got, want))
+	}
+}
+
+func TestPendingStoreMissingDir(t *testing.T) {
+	got, err := pendingStore{dir: "/nonexistent/pending"}.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected no messages, got %v", got)
+	}
+}
+
+func TestPendingStoreEncrypted(t *testing.T) {
+	cipher, err := spool.NewCipher(bytes.Repeat([]byte{1}, spool.KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	store := pendingStore{dir: dir, cipher: cipher}
+
+	data := yggdrasil.Data{MessageID: "a", Directive: "echo", Content: []byte(`"secret"`)}
+	if err := store.save(data); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.ReadFile(filepath.Join(dir, "a.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(file, []byte("secret")) {
+		t.Errorf("pending message is stored in plaintext: %s", file)
+	}
+
+	got, err := store.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []yggdrasil.Data{data}
+	if !cmp.Equal(got, want) {
+		t.Errorf("%v", cmp.Diff(got, want))
+	}
+
+	got, err = pendingStore{dir: dir}.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected no readable messages without a key, got %v", got)
+	}
+}
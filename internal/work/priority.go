@@ -0,0 +1,131 @@
+package work
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/redhatinsights/yggdrasil"
+)
+
+// Message priorities, given by the "Priority" metadata of a message.
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+// priorities lists the priorities in the order their lanes are served.
+var priorities = [...]string{PriorityHigh, PriorityNormal, PriorityLow}
+
+// laneCapacity is the number of received messages the dispatch lanes hold
+// before receiving blocks.
+const laneCapacity = 100
+
+// messagePriority returns the lane index of a message with the given
+// metadata. Messages without a known priority are normal priority.
+func messagePriority(metadata map[string]string) int {
+	for k, v := range metadata {
+		if !strings.EqualFold(k, "Priority") {
+			continue
+		}
+		for i, p := range priorities {
+			if strings.EqualFold(strings.TrimSpace(v), p) {
+				return i
+			}
+		}
+	}
+	return 1
+}
+
+// dispatchLanes holds received messages waiting to be processed in one
+// first-in, first-out lane per priority. Messages are taken from the highest
+// priority lane that is not empty, so urgent messages are not held up behind
+// bulk ones.
+type dispatchLanes struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	lanes [len(priorities)][]yggdrasil.Data
+	len   int
+	busy  int
+}
+
+// init prepares l for use. The caller must hold l.mu.
+func (l *dispatchLanes) init() {
+	if l.cond == nil {
+		l.cond = sync.NewCond(&l.mu)
+	}
+}
+
+// push adds data to the lane of its priority, blocking while the lanes hold
+// laneCapacity messages.
+func (l *dispatchLanes) push(data yggdrasil.Data) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.init()
+
+	for l.len >= laneCapacity {
+		l.cond.Wait()
+	}
+	i := messagePriority(data.Metadata)
+	l.lanes[i] = append(l.lanes[i], data)
+	l.len++
+	l.cond.Broadcast()
+}
+
+// pop removes and returns the oldest message of the highest priority lane
+// that is not empty, blocking while every lane is empty. The message counts as
+// being processed until finish is called.
+func (l *dispatchLanes) pop() yggdrasil.Data {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.init()
+
+	for l.len == 0 {
+		l.cond.Wait()
+	}
+	i := 0
+	for len(l.lanes[i]) == 0 {
+		i++
+	}
+	data := l.lanes[i][0]
+	l.lanes[i] = l.lanes[i][1:]
+	l.len--
+	l.busy++
+	l.cond.Broadcast()
+	return data
+}
+
+// finish marks a message returned by pop as processed.
+func (l *dispatchLanes) finish() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.busy--
+}
+
+// idle returns true if no message is waiting in the lanes or being processed.
+func (l *dispatchLanes) idle() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.len == 0 && l.busy == 0
+}
+
+// depths returns the number of messages waiting in each lane, keyed by
+// priority.
+func (l *dispatchLanes) depths() map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	depths := make(map[string]int, len(priorities))
+	for i, p := range priorities {
+		depths[p] = len(l.lanes[i])
+	}
+	return depths
+}
+
+// LaneDepths returns the number of received messages waiting to be processed
+// in each priority lane.
+func (d *Dispatcher) LaneDepths() map[string]int {
+	return d.lanes.depths()
+}
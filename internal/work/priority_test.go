@@ -0,0 +1,109 @@
+package work
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/redhatinsights/yggdrasil"
+)
+
+func TestMessagePriority(t *testing.T) {
+	tests := []struct {
+		description string
+		input       map[string]string
+		want        int
+	}{
+		{
+			description: "none",
+			input:       nil,
+			want:        1,
+		},
+		{
+			description: "high",
+			input:       map[string]string{"Priority": "high"},
+			want:        0,
+		},
+		{
+			description: "low, case insensitive",
+			input:       map[string]string{"priority": "LOW"},
+			want:        2,
+		},
+		{
+			description: "unknown",
+			input:       map[string]string{"Priority": "urgent"},
+			want:        1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got := messagePriority(test.input)
+			if got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDispatchLanes(t *testing.T) {
+	var l dispatchLanes
+	for _, m := range []struct{ id, priority string }{
+		{"bulk-1", PriorityLow},
+		{"a", ""},
+		{"urgent-1", PriorityHigh},
+		{"bulk-2", PriorityLow},
+		{"b", PriorityNormal},
+		{"urgent-2", PriorityHigh},
+	} {
+		data := yggdrasil.Data{MessageID: m.id}
+		if m.priority != "" {
+			data.Metadata = map[string]string{"Priority": m.priority}
+		}
+		l.push(data)
+	}
+
+	wantDepths := map[string]int{PriorityHigh: 2, PriorityNormal: 2, PriorityLow: 2}
+	if got := l.depths(); !cmp.Equal(got, wantDepths) {
+		t.Errorf("%v", cmp.Diff(got, wantDepths))
+	}
+
+	got := []string{}
+	for i := 0; i < 6; i++ {
+		got = append(got, l.pop().MessageID)
+	}
+	want := []string{"urgent-1", "urgent-2", "a", "b", "bulk-1", "bulk-2"}
+	if !cmp.Equal(got, want) {
+		t.Errorf("%v", cmp.Diff(got, want))
+	}
+}
+
+func TestDispatchQueuesReleasePriority(t *testing.T) {
+	var q dispatchQueues
+	for _, m := range []struct{ id, priority string }{
+		{"a", PriorityNormal},
+		{"bulk", PriorityLow},
+		{"b", PriorityNormal},
+		{"urgent", PriorityHigh},
+	} {
+		q.acquire(yggdrasil.Data{
+			MessageID: m.id,
+			Directive: "echo",
+			Metadata:  map[string]string{"Priority": m.priority},
+		}, 1, 10, OverflowReject)
+	}
+
+	got := []string{}
+	id := "a"
+	for {
+		data, ok := q.release("echo", id)
+		if !ok {
+			break
+		}
+		got = append(got, data.MessageID)
+		id = data.MessageID
+	}
+	want := []string{"urgent", "b", "bulk"}
+	if !cmp.Equal(got, want) {
+		t.Errorf("%v", cmp.Diff(got, want))
+	}
+}
@@ -0,0 +1,225 @@
+package work
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redhatinsights/yggdrasil"
+	"github.com/redhatinsights/yggdrasil/internal/logging"
+)
+
+// Windows over which rate limits are counted.
+const (
+	messageRateWindow = time.Minute
+	byteQuotaWindow   = time.Hour
+)
+
+// RateLimit caps the messages dispatched to a worker and the data it
+// transmits. A zero value means no limit.
+type RateLimit struct {
+	// Messages is the maximum number of messages dispatched per minute.
+	Messages int
+
+	// Bytes is the maximum number of bytes transmitted per hour.
+	Bytes int64
+}
+
+// ParseRateLimits parses lists of "DIRECTIVE=N" message rate limits, in
+// messages per minute, and "DIRECTIVE=SIZE" byte quotas, in bytes per hour,
+// into a map from directive to its limits. A size may end in K, M or G.
+func ParseRateLimits(messages []string, bytes []string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	for _, entry := range messages {
+		directive, value, err := parseLimitEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid rate limit '%v': expected a positive number of messages", entry)
+		}
+		limit := limits[directive]
+		if limit.Messages != 0 {
+			return nil, fmt.Errorf("duplicate rate limit for '%v'", directive)
+		}
+		limit.Messages = n
+		limits[directive] = limit
+	}
+	for _, entry := range bytes {
+		directive, value, err := parseLimitEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		n, err := parseSize(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid byte quota '%v': expected a positive size", entry)
+		}
+		limit := limits[directive]
+		if limit.Bytes != 0 {
+			return nil, fmt.Errorf("duplicate byte quota for '%v'", directive)
+		}
+		limit.Bytes = n
+		limits[directive] = limit
+	}
+	return limits, nil
+}
+
+// parseLimitEntry splits a "DIRECTIVE=VALUE" entry.
+func parseLimitEntry(entry string) (string, string, error) {
+	directive, value, ok := strings.Cut(entry, "=")
+	directive = strings.TrimSpace(directive)
+	value = strings.TrimSpace(value)
+	if !ok || directive == "" || value == "" {
+		return "", "", fmt.Errorf("invalid limit '%v': expected DIRECTIVE=VALUE", entry)
+	}
+	return directive, value, nil
+}
+
+// parseSize parses a number of bytes, optionally followed by K, M or G.
+func parseSize(s string) (int64, error) {
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * multiplier, nil
+}
+
+// usage is an amount counted against a limit at a point in time.
+type usage struct {
+	time   time.Time
+	amount int64
+}
+
+// window counts the usage recorded within a sliding period of time.
+type window struct {
+	entries []usage
+	total   int64
+}
+
+// expire forgets the usage recorded before now-period.
+func (w *window) expire(now time.Time, period time.Duration) {
+	i := 0
+	for ; i < len(w.entries) && !w.entries[i].time.After(now.Add(-period)); i++ {
+		w.total -= w.entries[i].amount
+	}
+	w.entries = w.entries[i:]
+}
+
+// reserve records amount at now and returns 0 if it fits within limit over
+// period. Otherwise nothing is recorded and the time until enough usage
+// expires for amount to fit is returned.
+func (w *window) reserve(amount, limit int64, now time.Time, period time.Duration) time.Duration {
+	w.expire(now, period)
+	if w.total+amount <= limit || len(w.entries) == 0 {
+		w.entries = append(w.entries, usage{time: now, amount: amount})
+		w.total += amount
+		return 0
+	}
+	free := w.total + amount - limit
+	for _, e := range w.entries {
+		free -= e.amount
+		if free <= 0 {
+			return e.time.Add(period).Sub(now)
+		}
+	}
+	return period
+}
+
+// rateLimiter enforces the rate limits of each worker.
+type rateLimiter struct {
+	mu       sync.Mutex
+	limits   map[string]RateLimit
+	messages map[string]*window
+	bytes    map[string]*window
+}
+
+// set replaces the limits, keeping the usage already recorded.
+func (r *rateLimiter) set(limits map[string]RateLimit) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limits = limits
+}
+
+// reserveMessage records a message dispatched to worker at now. If the worker
+// is over its message rate limit, nothing is recorded and the time until the
+// message may be dispatched is returned.
+func (r *rateLimiter) reserveMessage(worker string, now time.Time) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	limit := r.limits[worker].Messages
+	if limit == 0 {
+		return 0
+	}
+	if r.messages == nil {
+		r.messages = make(map[string]*window)
+	}
+	w, has := r.messages[worker]
+	if !has {
+		w = &window{}
+		r.messages[worker] = w
+	}
+	return w.reserve(1, int64(limit), now, messageRateWindow)
+}
+
+// reserveBytes records n bytes transmitted by worker at now. If the worker is
+// over its byte quota, nothing is recorded and the time until the bytes may be
+// transmitted is returned. A single transmission larger than the quota is
+// allowed once no other usage is recorded.
+func (r *rateLimiter) reserveBytes(worker string, n int64, now time.Time) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	limit := r.limits[worker].Bytes
+	if limit == 0 {
+		return 0
+	}
+	if r.bytes == nil {
+		r.bytes = make(map[string]*window)
+	}
+	w, has := r.bytes[worker]
+	if !has {
+		w = &window{}
+		r.bytes[worker] = w
+	}
+	return w.reserve(n, limit, now, byteQuotaWindow)
+}
+
+// SetRateLimits replaces the rate limits of each worker.
+func (d *Dispatcher) SetRateLimits(limits map[string]RateLimit) {
+	d.rateLimits.set(limits)
+}
+
+// deferOverLimit returns true if data is over its worker's message rate limit,
+// in which case it is dispatched again once the limit allows, unless it has
+// expired by then, and reported on the Deferred channel.
+func (d *Dispatcher) deferOverLimit(data yggdrasil.Data) bool {
+	wait := d.rateLimits.reserveMessage(data.Directive, time.Now())
+	if wait == 0 {
+		return false
+	}
+	logging.With(data.Directive, data.MessageID).Warnf("deferring message %v for directive %v by %v: rate limit exceeded", data.MessageID, data.Directive, wait.Round(time.Second))
+	d.trace(DispatchEventDeferred, data, fmt.Sprintf("rate limit exceeded; retrying in %v", wait.Round(time.Second)))
+	time.AfterFunc(wait, func() {
+		if !d.discardExpired(data) {
+			d.deliver(data)
+		}
+	})
+	go func() { d.Deferred <- data }()
+	return true
+}
@@ -0,0 +1,129 @@
+package work
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	tests := []struct {
+		description string
+		messages    []string
+		bytes       []string
+		want        map[string]RateLimit
+		wantError   bool
+	}{
+		{
+			description: "empty",
+			want:        map[string]RateLimit{},
+		},
+		{
+			description: "messages and bytes",
+			messages:    []string{"echo=60", "playbook = 5"},
+			bytes:       []string{"echo=10M", "insights=512"},
+			want: map[string]RateLimit{
+				"echo":     {Messages: 60, Bytes: 10 << 20},
+				"playbook": {Messages: 5},
+				"insights": {Bytes: 512},
+			},
+		},
+		{
+			description: "invalid count",
+			messages:    []string{"echo=0"},
+			wantError:   true,
+		},
+		{
+			description: "invalid size",
+			bytes:       []string{"echo=10X"},
+			wantError:   true,
+		},
+		{
+			description: "missing separator",
+			messages:    []string{"echo"},
+			wantError:   true,
+		},
+		{
+			description: "duplicate",
+			bytes:       []string{"echo=1K", "echo=2K"},
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got, err := ParseRateLimits(test.messages, test.bytes)
+
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
+
+func TestRateLimiterMessages(t *testing.T) {
+	var r rateLimiter
+	r.set(map[string]RateLimit{"echo": {Messages: 2}})
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		description string
+		worker      string
+		at          time.Duration
+		want        time.Duration
+	}{
+		{description: "first", worker: "echo", at: 0, want: 0},
+		{description: "second", worker: "echo", at: 10 * time.Second, want: 0},
+		{description: "over limit", worker: "echo", at: 20 * time.Second, want: 40 * time.Second},
+		{description: "unlimited worker", worker: "other", at: 20 * time.Second, want: 0},
+		{description: "first expired", worker: "echo", at: time.Minute, want: 0},
+		{description: "over limit again", worker: "echo", at: time.Minute, want: 10 * time.Second},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got := r.reserveMessage(test.worker, start.Add(test.at))
+			if got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiterBytes(t *testing.T) {
+	var r rateLimiter
+	r.set(map[string]RateLimit{"echo": {Bytes: 100}})
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		description string
+		n           int64
+		at          time.Duration
+		want        time.Duration
+	}{
+		{description: "within quota", n: 60, at: 0, want: 0},
+		{description: "within quota again", n: 40, at: time.Minute, want: 0},
+		{description: "over quota", n: 50, at: 2 * time.Minute, want: 58 * time.Minute},
+		{description: "all expired", n: 500, at: 2 * time.Hour, want: 0},
+		{description: "after oversized", n: 1, at: 2*time.Hour + time.Minute, want: 59 * time.Minute},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got := r.reserveBytes("echo", test.n, start.Add(test.at))
+			if got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
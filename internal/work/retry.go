@@ -0,0 +1,49 @@
+package work
+
+import (
+	"errors"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/redhatinsights/yggdrasil"
+	"github.com/redhatinsights/yggdrasil/internal/logging"
+)
+
+// retryDispatch retries delivering data, which failed to dispatch with err,
+// up to retries times, waiting an exponentially increasing delay starting at
+// delay between attempts. Once an attempt succeeds, data stays in the pending
+// journal until the worker acknowledges it with its END event; if no attempt
+// succeeds, data is removed from the pending journal, saved to the dead-letter
+// store, if enabled, and sent on the Failures channel.
+func (d *Dispatcher) retryDispatch(data yggdrasil.Data, err error, retries int, delay time.Duration) {
+	for attempt := 0; attempt < retries && retryable(err); attempt++ {
+		time.Sleep(backoffDelay(attempt, delay, 0))
+		logging.With(data.Directive, data.MessageID).Debugf("retrying dispatch of message %v to worker %v (attempt %v)", data.MessageID, data.Directive, attempt+1)
+		if err = d.Dispatch(data); err == nil {
+			return
+		}
+		log.Errorf("cannot dispatch data: %v", err)
+	}
+
+	logging.With(data.Directive, data.MessageID).Errorf("giving up dispatching message %v to worker %v: %v", data.MessageID, data.Directive, err)
+	d.unacked.forget(data.MessageID)
+	d.removePending(data)
+	d.fail(data, err)
+}
+
+// fail saves data, which could not be delivered because of err, to the
+// dead-letter store, if enabled, and sends it on the Failures channel.
+func (d *Dispatcher) fail(data yggdrasil.Data, err error) {
+	if d.DeadLetterDir != "" {
+		if err := (deadLetterStore{dir: d.DeadLetterDir, cipher: d.SpoolCipher}).save(data, err.Error()); err != nil {
+			log.Errorf("cannot save message %v to dead-letter store: %v", data.MessageID, err)
+		}
+	}
+	d.Failures <- data
+}
+
+// retryable returns true if delivery of a message that failed with err should
+// be attempted again.
+func retryable(err error) bool {
+	return !errors.Is(err, ErrWorkerUnavailable)
+}
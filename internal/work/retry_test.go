@@ -0,0 +1,35 @@
+package work
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		description string
+		input       error
+		want        bool
+	}{
+		{
+			description: "call failure",
+			input:       fmt.Errorf("cannot call 'Dispatch' method on worker"),
+			want:        true,
+		},
+		{
+			description: "disabled worker",
+			input:       fmt.Errorf("%w: worker echo is disabled", ErrWorkerUnavailable),
+			want:        false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got := retryable(test.input)
+
+			if got != test.want {
+				t.Errorf("%v != %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package work
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/redhatinsights/yggdrasil"
+	"github.com/redhatinsights/yggdrasil/internal/jsonschema"
+)
+
+// validateContent checks the content of data against the JSON Schema for its
+// directive, read from the file <directive>.json in d.SchemaDir. Messages for
+// directives without a schema are not validated.
+func (d *Dispatcher) validateContent(data yggdrasil.Data) error {
+	if d.SchemaDir == "" || filepath.Base(data.Directive) != data.Directive {
+		return nil
+	}
+
+	schema, err := jsonschema.Load(filepath.Join(d.SchemaDir, data.Directive+".json"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("cannot load schema for directive %v: %w", data.Directive, err)
+	}
+	return schema.Validate(data.Content)
+}
+
+// replyInvalidContent sends an error response to the server for data, whose
+// content failed validation with err.
+func (d *Dispatcher) replyInvalidContent(data yggdrasil.Data, err error) {
+	response := struct {
+		Error   string   `json:"error"`
+		Details []string `json:"details"`
+	}{
+		Error:   "invalid-content",
+		Details: []string{err.Error()},
+	}
+	var validationErr *jsonschema.ValidationError
+	if errors.As(err, &validationErr) {
+		response.Details = validationErr.Errors
+	}
+
+	content, err := json.Marshal(response)
+	if err != nil {
+		log.Errorf("cannot marshal error response: %v", err)
+		return
+	}
+	if err := d.reply(data.Directive, data.MessageID, content); err != nil {
+		log.Errorf("cannot send error response: %v", err)
+	}
+}
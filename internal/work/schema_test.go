@@ -0,0 +1,55 @@
+package work
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/redhatinsights/yggdrasil"
+)
+
+func TestValidateContent(t *testing.T) {
+	d := NewDispatcher(nil)
+	d.SchemaDir = t.TempDir()
+	schema := `{"type": "object", "required": ["command"]}`
+	if err := os.WriteFile(filepath.Join(d.SchemaDir, "echo.json"), []byte(schema), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		description string
+		input       yggdrasil.Data
+		wantError   bool
+	}{
+		{
+			description: "valid",
+			input:       yggdrasil.Data{Directive: "echo", Content: []byte(`{"command": "ls"}`)},
+		},
+		{
+			description: "invalid",
+			input:       yggdrasil.Data{Directive: "echo", Content: []byte(`{}`)},
+			wantError:   true,
+		},
+		{
+			description: "no schema",
+			input:       yggdrasil.Data{Directive: "other", Content: []byte(`{}`)},
+		},
+		{
+			description: "directive with path",
+			input:       yggdrasil.Data{Directive: "../echo", Content: []byte(`{}`)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			err := d.validateContent(test.input)
+
+			if test.wantError && err == nil {
+				t.Error("expected error, got nil")
+			}
+			if !test.wantError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package work
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/redhatinsights/yggdrasil"
+	"github.com/redhatinsights/yggdrasil/internal/logging"
+)
+
+// drainPollInterval is how often Drain checks whether every received message
+// has been handled.
+const drainPollInterval = 100 * time.Millisecond
+
+// workerStopConcurrency is the number of workers StopWorkers stops at the same
+// time.
+const workerStopConcurrency = 8
+
+// deferUntilRestart keeps data, received after Drain was called, in the pending
+// journal so it is dispatched when yggd starts again. Without a pending
+// journal, data is reported as failed.
+func (d *Dispatcher) deferUntilRestart(data yggdrasil.Data) {
+	if d.PendingDir == "" || data.MessageID == "" {
+		logging.With(data.Directive, data.MessageID).Warnf("cannot dispatch message %v: yggd is stopping", data.MessageID)
+		d.trace(DispatchEventFailed, data, "yggd is stopping")
+		go d.fail(data, fmt.Errorf("yggd is stopping"))
+		return
+	}
+	logging.With(data.Directive, data.MessageID).Infof("deferring message %v until yggd starts again", data.MessageID)
+	d.trace(DispatchEventDeferred, data, "yggd is stopping")
+	d.savePending(data)
+}
+
+// idle returns true if no received message is waiting to be dispatched, being
+// dispatched, queued for a busy worker or awaiting a response from a worker.
+func (d *Dispatcher) idle() bool {
+	return d.lanes.idle() && len(d.queues.snapshot()) == 0 && len(d.deadlines.pending()) == 0
+}
+
+// Drain stops dispatching newly received messages and waits up to timeout for
+// the messages already received to be handled by workers. Messages received
+// from then on are kept in the pending journal for the next start. It returns
+// true if every message was handled in time.
+func (d *Dispatcher) Drain(timeout time.Duration) bool {
+	d.draining.Store(true)
+
+	deadline := time.Now().Add(timeout)
+	for !d.idle() {
+		if !time.Now().Before(deadline) {
+			log.Warnf(
+				"stopping with messages in progress: %v awaiting response, queued: %v",
+				d.AwaitingResponse(),
+				d.DispatchQueues(),
+			)
+			return false
+		}
+		time.Sleep(drainPollInterval)
+	}
+	return true
+}
+
+// StopWorkers stops every running worker.
+func (d *Dispatcher) StopWorkers() error {
+	names, err := d.findWorkers()
+	if err != nil {
+		return fmt.Errorf("cannot find running workers: %w", err)
+	}
+
+	workers := make([]string, 0, len(names))
+	for _, name := range names {
+		workers = append(workers, strings.TrimPrefix(name, "com.redhat.Yggdrasil1.Worker1."))
+	}
+	for worker, err := range eachConcurrently(workers, workerStopConcurrency, d.stopWorker) {
+		log.Errorf("cannot stop worker %v: %v", worker, err)
+	}
+	return nil
+}
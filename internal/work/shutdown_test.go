@@ -0,0 +1,50 @@
+package work
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/redhatinsights/yggdrasil"
+)
+
+func TestDrain(t *testing.T) {
+	d := NewDispatcher(nil)
+
+	d.lanes.push(yggdrasil.Data{MessageID: "a"})
+	if d.Drain(10 * time.Millisecond) {
+		t.Errorf("drained with a message waiting in the lanes")
+	}
+
+	d.lanes.pop()
+	if d.Drain(10 * time.Millisecond) {
+		t.Errorf("drained with a message being processed")
+	}
+
+	d.deadlines.track("a", time.Hour, func() {})
+	d.lanes.finish()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		d.deadlines.done("a")
+	}()
+	if !d.Drain(time.Second) {
+		t.Errorf("did not drain after the worker responded")
+	}
+}
+
+func TestDeferUntilRestart(t *testing.T) {
+	d := NewDispatcher(nil)
+	d.PendingDir = t.TempDir()
+
+	data := yggdrasil.Data{MessageID: "a", Directive: "echo", Content: []byte(`"hello"`)}
+	d.deferUntilRestart(data)
+
+	got, err := pendingStore{dir: d.PendingDir}.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []yggdrasil.Data{data}
+	if !cmp.Equal(got, want) {
+		t.Errorf("%v", cmp.Diff(got, want))
+	}
+}
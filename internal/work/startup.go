@@ -0,0 +1,70 @@
+package work
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+)
+
+// eachConcurrently calls fn for each worker in workers, running at most
+// concurrency calls at a time, and returns the errors of the calls that
+// failed, keyed by worker.
+func eachConcurrently(workers []string, concurrency int, fn func(worker string) error) map[string]error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(map[string]error)
+	slots := make(chan struct{}, concurrency)
+	for _, worker := range workers {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(worker string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := fn(worker); err != nil {
+				mu.Lock()
+				errs[worker] = err
+				mu.Unlock()
+			}
+		}(worker)
+	}
+	wg.Wait()
+	return errs
+}
+
+// startActivatableWorkers activates each worker in names, given as bus names,
+// that is not already running, excluded, disabled or started on demand,
+// running at most concurrency activations at a time.
+func (d *Dispatcher) startActivatableWorkers(names []string, concurrency int) {
+	var workers []string
+	for _, name := range names {
+		worker := strings.TrimPrefix(name, "com.redhat.Yggdrasil1.Worker1.")
+		if d.WorkerExcluded(worker) || d.WorkerDisabled(worker) || d.WorkerOnDemand(worker) {
+			continue
+		}
+		present, err := d.nameHasOwner(name)
+		if err != nil {
+			log.Errorf("cannot find owner for name: %v: %v", name, err)
+			continue
+		}
+		if !present {
+			workers = append(workers, worker)
+		}
+	}
+	if len(workers) == 0 {
+		return
+	}
+
+	log.Infof("starting %v workers, %v at a time", len(workers), concurrency)
+	start := time.Now()
+	errs := eachConcurrently(workers, concurrency, d.startWorker)
+	for worker, err := range errs {
+		log.Errorf("cannot start worker %v: %v", worker, err)
+	}
+	log.Infof("started %v of %v workers in %v", len(workers)-len(errs), len(workers), time.Since(start).Round(time.Millisecond))
+}
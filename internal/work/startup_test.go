@@ -0,0 +1,80 @@
+package work
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEachConcurrently(t *testing.T) {
+	tests := []struct {
+		description string
+		workers     []string
+		concurrency int
+		fail        map[string]bool
+		want        []string
+	}{
+		{
+			description: "all start",
+			workers:     []string{"a", "b", "c", "d", "e"},
+			concurrency: 2,
+			want:        []string{},
+		},
+		{
+			description: "failures",
+			workers:     []string{"a", "b", "c"},
+			concurrency: 3,
+			fail:        map[string]bool{"b": true},
+			want:        []string{"b"},
+		},
+		{
+			description: "serial",
+			workers:     []string{"a", "b"},
+			concurrency: 0,
+			want:        []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			var mu sync.Mutex
+			running, peak, started := 0, 0, 0
+			errs := eachConcurrently(test.workers, test.concurrency, func(worker string) error {
+				mu.Lock()
+				running++
+				started++
+				if running > peak {
+					peak = running
+				}
+				mu.Unlock()
+
+				time.Sleep(10 * time.Millisecond)
+
+				mu.Lock()
+				running--
+				mu.Unlock()
+				if test.fail[worker] {
+					return fmt.Errorf("cannot start %v", worker)
+				}
+				return nil
+			})
+
+			if started != len(test.workers) {
+				t.Errorf("started %v workers, want %v", started, len(test.workers))
+			}
+			if limit := max(test.concurrency, 1); peak > limit {
+				t.Errorf("%v workers started at once, want at most %v", peak, limit)
+			}
+			got := []string{}
+			for worker := range errs {
+				got = append(got, worker)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
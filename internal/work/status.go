@@ -0,0 +1,52 @@
+package work
+
+import (
+	"strconv"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+)
+
+// WorkerStatus returns the status of every known worker: its lifecycle
+// metrics, as returned by WorkerMetrics, along with the keys "state"
+// ("enabled", "disabled" or "excluded") and "pid" (the process ID of the
+// running worker, or an empty string).
+func (d *Dispatcher) WorkerStatus() map[string]map[string]string {
+	status := d.metrics.snapshot(time.Now())
+	d.features.Visit(func(k string, _ map[string]string) {
+		if _, has := status[k]; !has {
+			status[k] = map[string]string{"running": "false"}
+		}
+	})
+
+	for worker, s := range status {
+		s["state"] = d.workerState(worker)
+		s["pid"] = ""
+		if s["running"] != "true" || d.conn == nil {
+			continue
+		}
+		pid, err := callMethod[uint32](
+			d.conn.BusObject(),
+			"org.freedesktop.DBus.GetConnectionUnixProcessID",
+			"com.redhat.Yggdrasil1.Worker1."+worker,
+		)
+		if err != nil {
+			log.Debugf("cannot get process ID of worker %v: %v", worker, err)
+			continue
+		}
+		s["pid"] = strconv.FormatUint(uint64(*pid), 10)
+	}
+	return status
+}
+
+// workerState describes whether worker may receive messages.
+func (d *Dispatcher) workerState(worker string) string {
+	switch {
+	case d.WorkerDisabled(worker):
+		return "disabled"
+	case d.WorkerExcluded(worker):
+		return "excluded"
+	default:
+		return "enabled"
+	}
+}
@@ -0,0 +1,38 @@
+package work
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWorkerStatus(t *testing.T) {
+	d := NewDispatcher(nil)
+	d.features.Set("echo", map[string]string{})
+	d.features.Set("test", map[string]string{})
+	d.metrics.started("echo", time.Now())
+	d.metrics.started("gone", time.Now())
+	d.metrics.stopped("gone", time.Now())
+	d.disabled.Set("test", true)
+
+	got := d.WorkerStatus()
+
+	want := map[string]map[string]string{
+		"echo": {"running": "true", "state": "enabled", "pid": ""},
+		"gone": {"running": "false", "state": "enabled", "pid": ""},
+		"test": {"running": "false", "state": "disabled", "pid": ""},
+	}
+	// Only compare the keys added to the metrics.
+	for _, status := range got {
+		for k := range status {
+			if k != "running" && k != "state" && k != "pid" {
+				delete(status, k)
+			}
+		}
+	}
+
+	if !cmp.Equal(got, want) {
+		t.Errorf("%v", cmp.Diff(got, want))
+	}
+}
@@ -0,0 +1,188 @@
+package work
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/redhatinsights/yggdrasil/internal/audit"
+)
+
+// workerStopTimeout is how long a worker is given to release its bus name
+// after being signalled to stop, when systemd is not running.
+const workerStopTimeout = 10 * time.Second
+
+// systemdRunning returns true if the host was booted with systemd, checked the
+// same way as sd_booted(3). Without systemd, workers are started by D-Bus
+// activation and stopped with signals.
+var systemdRunning = func() bool {
+	info, err := os.Lstat("/run/systemd/system")
+	return err == nil && info.IsDir()
+}
+
+// workerPID looks up the ID of the process currently holding the worker's
+// well-known bus name.
+func (d *Dispatcher) workerPID(worker string) (uint32, error) {
+	pid, err := callMethod[uint32](
+		d.conn.BusObject(),
+		"org.freedesktop.DBus.GetConnectionUnixProcessID",
+		"com.redhat.Yggdrasil1.Worker1."+worker,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return *pid, nil
+}
+
+// workerUnit looks up the object path of the systemd unit that owns the
+// process currently holding the worker's well-known bus name.
+func (d *Dispatcher) workerUnit(worker string) (dbus.ObjectPath, error) {
+	pid, err := d.workerPID(worker)
+	if err != nil {
+		return "", err
+	}
+
+	unit, err := callMethod[dbus.ObjectPath](
+		d.conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1"),
+		"org.freedesktop.systemd1.Manager.GetUnitByPID",
+		pid,
+	)
+	if err != nil {
+		return "", err
+	}
+	return *unit, nil
+}
+
+// startWorker asks the bus to activate the worker.
+func (d *Dispatcher) startWorker(worker string) error {
+	if _, err := callMethod[uint32](
+		d.conn.BusObject(),
+		"org.freedesktop.DBus.StartServiceByName",
+		"com.redhat.Yggdrasil1.Worker1."+worker,
+		uint32(0),
+	); err != nil {
+		return err
+	}
+	return nil
+}
+
+// terminateWorker sends SIGTERM to the process holding the worker's bus name
+// and waits for the name to be released.
+func (d *Dispatcher) terminateWorker(worker string) error {
+	pid, err := d.workerPID(worker)
+	if err != nil {
+		return fmt.Errorf("cannot find process for worker %v: %w", worker, err)
+	}
+	if err := syscall.Kill(int(pid), syscall.SIGTERM); err != nil {
+		return fmt.Errorf("cannot signal process %v: %w", pid, err)
+	}
+
+	for deadline := time.Now().Add(workerStopTimeout); time.Now().Before(deadline); {
+		present, err := d.nameHasOwner("com.redhat.Yggdrasil1.Worker1." + worker)
+		if err != nil {
+			return err
+		}
+		if !present {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("worker %v did not exit within %v", worker, workerStopTimeout)
+}
+
+// restartWorker asks systemd to restart the unit running the worker. Without
+// systemd, the worker is terminated and activated again.
+func (d *Dispatcher) restartWorker(worker string) error {
+	if !systemdRunning() {
+		if err := d.terminateWorker(worker); err != nil {
+			return err
+		}
+		return d.startWorker(worker)
+	}
+
+	unit, err := d.workerUnit(worker)
+	if err != nil {
+		return fmt.Errorf("cannot find unit for worker %v: %w", worker, err)
+	}
+
+	if _, err := callMethod[dbus.ObjectPath](
+		d.conn.Object("org.freedesktop.systemd1", unit),
+		"org.freedesktop.systemd1.Unit.Restart",
+		"replace",
+	); err != nil {
+		return fmt.Errorf("cannot restart unit %v: %w", unit, err)
+	}
+	return nil
+}
+
+// stopWorker asks systemd to stop the unit running the worker. Without
+// systemd, the worker is terminated.
+func (d *Dispatcher) stopWorker(worker string) error {
+	if !systemdRunning() {
+		return d.terminateWorker(worker)
+	}
+
+	unit, err := d.workerUnit(worker)
+	if err != nil {
+		return fmt.Errorf("cannot find unit for worker %v: %w", worker, err)
+	}
+
+	if _, err := callMethod[dbus.ObjectPath](
+		d.conn.Object("org.freedesktop.systemd1", unit),
+		"org.freedesktop.systemd1.Unit.Stop",
+		"replace",
+	); err != nil {
+		return fmt.Errorf("cannot stop unit %v: %w", unit, err)
+	}
+	return nil
+}
+
+// ControlWorker asks systemd to start, stop or restart the service unit of
+// worker, according to action. Without systemd, the worker is activated over
+// the bus and stopped with a signal.
+func (d *Dispatcher) ControlWorker(worker string, action string) error {
+	if !systemdRunning() {
+		var err error
+		switch action {
+		case "start":
+			err = d.startWorker(worker)
+		case "stop":
+			err = d.terminateWorker(worker)
+		case "restart":
+			err = d.restartWorker(worker)
+		default:
+			return fmt.Errorf("unsupported action: %v", action)
+		}
+		if err != nil {
+			return fmt.Errorf("cannot %v worker %v: %w", action, worker, err)
+		}
+		d.audit(audit.ActionWorkerControl, worker, "", action)
+		return nil
+	}
+
+	var method string
+	switch action {
+	case "start":
+		method = "org.freedesktop.systemd1.Manager.StartUnit"
+	case "stop":
+		method = "org.freedesktop.systemd1.Manager.StopUnit"
+	case "restart":
+		method = "org.freedesktop.systemd1.Manager.RestartUnit"
+	default:
+		return fmt.Errorf("unsupported action: %v", action)
+	}
+
+	unit := "com.redhat.Yggdrasil1.Worker1." + worker + ".service"
+	if _, err := callMethod[dbus.ObjectPath](
+		d.conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1"),
+		method,
+		unit,
+		"replace",
+	); err != nil {
+		return fmt.Errorf("cannot %v unit %v: %w", action, unit, err)
+	}
+	d.audit(audit.ActionWorkerControl, worker, "", action)
+	return nil
+}
@@ -0,0 +1,177 @@
+package work
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// busConfig configures a private bus that lets every connection own any name,
+// call any method and receive every reply.
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:tmpdir=%v</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// startTestBus starts a private message bus and returns its address. The test
+// is skipped if dbus-daemon is not installed.
+func startTestBus(t *testing.T) string {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "bus.conf")
+	if err := os.WriteFile(conf, []byte(fmt.Sprintf(busConfig, dir)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(daemon, "--config-file="+conf, "--print-address", "--nofork")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("cannot read bus address: %v", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+// connectTestBus connects to the bus at address, owning each of names.
+func connectTestBus(t *testing.T, address string, names ...string) *dbus.Conn {
+	t.Helper()
+	conn, err := dbus.Dial(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if err := conn.Auth([]dbus.Auth{dbus.AuthExternal(strconv.Itoa(os.Getuid()))}); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Hello(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		reply, err := conn.RequestName(name, dbus.NameFlagDoNotQueue)
+		if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+			t.Fatalf("cannot own name %v: %v", name, err)
+		}
+	}
+	return conn
+}
+
+// fakeSystemd implements the methods of the systemd manager and units that
+// the dispatcher calls, recording the units restarted.
+type fakeSystemd struct {
+	restarted chan string
+}
+
+func (s *fakeSystemd) GetUnitByPID(pid uint32) (dbus.ObjectPath, *dbus.Error) {
+	if pid != uint32(os.Getpid()) {
+		return "", dbus.MakeFailedError(fmt.Errorf("no unit for pid %v", pid))
+	}
+	return "/org/freedesktop/systemd1/unit/echo_2eservice", nil
+}
+
+type fakeUnit struct {
+	systemd *fakeSystemd
+	name    string
+}
+
+func (u *fakeUnit) Restart(mode string) (dbus.ObjectPath, *dbus.Error) {
+	u.systemd.restarted <- u.name + " " + mode
+	return "/org/freedesktop/systemd1/job/1", nil
+}
+
+func TestRestartWorker(t *testing.T) {
+	address := startTestBus(t)
+
+	systemd := &fakeSystemd{restarted: make(chan string, 1)}
+	systemdConn := connectTestBus(t, address, "org.freedesktop.systemd1")
+	if err := systemdConn.Export(systemd, "/org/freedesktop/systemd1", "org.freedesktop.systemd1.Manager"); err != nil {
+		t.Fatal(err)
+	}
+	unit := &fakeUnit{systemd: systemd, name: "echo.service"}
+	if err := systemdConn.Export(unit, "/org/freedesktop/systemd1/unit/echo_2eservice", "org.freedesktop.systemd1.Unit"); err != nil {
+		t.Fatal(err)
+	}
+	connectTestBus(t, address, "com.redhat.Yggdrasil1.Worker1.echo")
+
+	running := systemdRunning
+	systemdRunning = func() bool { return true }
+	t.Cleanup(func() { systemdRunning = running })
+
+	d := NewDispatcher(nil)
+	d.conn = connectTestBus(t, address)
+
+	tests := []struct {
+		description string
+		worker      string
+		want        string
+		wantError   bool
+	}{
+		{
+			description: "running worker",
+			worker:      "echo",
+			want:        "echo.service replace",
+		},
+		{
+			description: "worker not running",
+			worker:      "missing",
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			err := d.restartWorker(test.worker)
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				select {
+				case got := <-systemd.restarted:
+					t.Errorf("unexpected restart of %v", got)
+				default:
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case got := <-systemd.restarted:
+				if got != test.want {
+					t.Errorf("got %v, want %v", got, test.want)
+				}
+			default:
+				t.Error("unit was not restarted")
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package work
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+// SetTags replaces the tags describing the host, which workers read with the
+// Tags method.
+func (d *Dispatcher) SetTags(tags map[string]string) {
+	var removed []string
+	d.tags.Visit(func(k string, _ string) {
+		if _, has := tags[k]; !has {
+			removed = append(removed, k)
+		}
+	})
+	for _, k := range removed {
+		d.tags.Del(k)
+	}
+	for k, v := range tags {
+		d.tags.Set(k, v)
+	}
+}
+
+// Tags implements the com.redhat.Yggdrasil1.Dispatcher1.Tags method.
+func (d *Dispatcher) Tags() (map[string]string, *dbus.Error) {
+	tags := make(map[string]string)
+	d.tags.Visit(func(k string, v string) {
+		tags[k] = v
+	})
+	return tags, nil
+}
@@ -0,0 +1,23 @@
+package work
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSetTags(t *testing.T) {
+	d := Dispatcher{}
+
+	d.SetTags(map[string]string{"environment": "dev", "owner": "ops"})
+	d.SetTags(map[string]string{"environment": "prod"})
+
+	got, err := d.Tags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"environment": "prod"}
+	if !cmp.Equal(got, want) {
+		t.Errorf("%v", cmp.Diff(got, want))
+	}
+}
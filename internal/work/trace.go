@@ -0,0 +1,139 @@
+package work
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/redhatinsights/yggdrasil"
+	"github.com/redhatinsights/yggdrasil/internal/audit"
+	"github.com/redhatinsights/yggdrasil/internal/history"
+)
+
+// traceSize is the number of recent dispatch events kept by the dispatcher.
+const traceSize = 100
+
+// Names of dispatch events.
+const (
+	DispatchEventReceived   = "received"
+	DispatchEventDuplicate  = "duplicate"
+	DispatchEventExpired    = "expired"
+	DispatchEventRejected   = "rejected"
+	DispatchEventQueued     = "queued"
+	DispatchEventDeferred   = "deferred"
+	DispatchEventDispatched = "dispatched"
+	DispatchEventFailed     = "failed"
+	DispatchEventResponse   = "response"
+	DispatchEventTimeout    = "timeout"
+)
+
+// DispatchEvent records a step in the handling of a message by the dispatcher.
+type DispatchEvent struct {
+	Time      time.Time `json:"time"`
+	Name      string    `json:"name"`
+	MessageID string    `json:"message_id"`
+	Directive string    `json:"directive"`
+	Detail    string    `json:"detail,omitempty"`
+	Size      int       `json:"size"`
+	TraceID   string    `json:"trace_id,omitempty"`
+}
+
+// Map returns e as a string map, suitable for sending over D-Bus.
+func (e DispatchEvent) Map() map[string]string {
+	return map[string]string{
+		"time":       e.Time.Format(time.RFC3339Nano),
+		"name":       e.Name,
+		"message_id": e.MessageID,
+		"directive":  e.Directive,
+		"detail":     e.Detail,
+		"size":       strconv.Itoa(e.Size),
+		"trace_id":   e.TraceID,
+	}
+}
+
+// outcome reports whether e records the outcome of handling a message, rather
+// than an intermediate step.
+func (e DispatchEvent) outcome() bool {
+	return e.Name != DispatchEventReceived && e.Name != DispatchEventQueued && e.Name != DispatchEventDeferred
+}
+
+// eventTrace is a fixed-size ring of the most recent dispatch events.
+type eventTrace struct {
+	mu     sync.Mutex
+	events []DispatchEvent
+	next   int
+}
+
+// add records e, replacing the oldest event if the trace is full.
+func (t *eventTrace) add(e DispatchEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.events) < traceSize {
+		t.events = append(t.events, e)
+		return
+	}
+	t.events[t.next] = e
+	t.next = (t.next + 1) % traceSize
+}
+
+// list returns the recorded events, oldest first.
+func (t *eventTrace) list() []DispatchEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	events := make([]DispatchEvent, 0, len(t.events))
+	events = append(events, t.events[t.next:]...)
+	events = append(events, t.events[:t.next]...)
+	return events
+}
+
+// trace records a dispatch event for data and sends it on the DispatchEvents
+// channel. If nobody is receiving, the event is only recorded, so that tracing
+// never delays message handling. Outcomes are also added to the message
+// history, if enabled.
+func (d *Dispatcher) trace(name string, data yggdrasil.Data, detail string) {
+	e := DispatchEvent{
+		Time:      time.Now().UTC(),
+		Name:      name,
+		MessageID: data.MessageID,
+		Directive: data.Directive,
+		Detail:    detail,
+		Size:      len(data.Content),
+		TraceID:   traceID(data.Metadata),
+	}
+	d.events.add(e)
+	if d.History != nil && e.outcome() {
+		err := d.History.Add(history.Entry{
+			Time:      e.Time,
+			MessageID: e.MessageID,
+			Directive: e.Directive,
+			Outcome:   e.Name,
+			Detail:    e.Detail,
+			Size:      e.Size,
+		})
+		if err != nil {
+			log.Errorf("cannot add history entry: %v", err)
+		}
+	}
+	if name == DispatchEventDispatched {
+		d.audit(audit.ActionDispatched, data.Directive, data.MessageID, "")
+	}
+	select {
+	case d.DispatchEvents <- e:
+	default:
+	}
+}
+
+// audit records an action in the dispatcher's audit log, if it has one.
+func (d *Dispatcher) audit(action, worker, messageID, detail string) {
+	if err := d.Audit.Append(action, worker, messageID, detail); err != nil {
+		log.Errorf("cannot add audit record: %v", err)
+	}
+}
+
+// RecentDispatchEvents returns the most recent dispatch events, oldest first.
+func (d *Dispatcher) RecentDispatchEvents() []DispatchEvent {
+	return d.events.list()
+}
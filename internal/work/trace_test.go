@@ -0,0 +1,56 @@
+package work
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEventTrace(t *testing.T) {
+	tests := []struct {
+		description string
+		input       int
+		want        []string
+	}{
+		{
+			description: "empty",
+			input:       0,
+			want:        []string{},
+		},
+		{
+			description: "partial",
+			input:       3,
+			want:        []string{"0", "1", "2"},
+		},
+		{
+			description: "wrapped",
+			input:       traceSize + 2,
+			want: func() []string {
+				ids := []string{}
+				for i := 2; i < traceSize+2; i++ {
+					ids = append(ids, strconv.Itoa(i))
+				}
+				return ids
+			}(),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			var trace eventTrace
+			for i := 0; i < test.input; i++ {
+				trace.add(DispatchEvent{MessageID: strconv.Itoa(i)})
+			}
+
+			got := []string{}
+			for _, e := range trace.list() {
+				got = append(got, e.MessageID)
+			}
+
+			if !cmp.Equal(got, test.want) {
+				t.Errorf("%v", cmp.Diff(got, test.want))
+			}
+		})
+	}
+}
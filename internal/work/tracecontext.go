@@ -0,0 +1,148 @@
+package work
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/redhatinsights/yggdrasil"
+)
+
+// TraceParentKey is the message metadata key carrying a W3C Trace Context
+// "traceparent" value.
+const TraceParentKey = "traceparent"
+
+var traceParentRegexp = regexp.MustCompile(`^00-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+
+// spanContext identifies a span within a trace, as carried by a W3C Trace
+// Context "traceparent" value.
+type spanContext struct {
+	TraceID string
+	SpanID  string
+	Flags   string
+}
+
+// parseTraceParent parses a "traceparent" value of version 00. All-zero trace
+// and span IDs are invalid.
+func parseTraceParent(s string) (spanContext, error) {
+	m := traceParentRegexp.FindStringSubmatch(s)
+	if m == nil || m[1] == "00000000000000000000000000000000" || m[2] == "0000000000000000" {
+		return spanContext{}, fmt.Errorf("invalid traceparent '%v'", s)
+	}
+	return spanContext{TraceID: m[1], SpanID: m[2], Flags: m[3]}, nil
+}
+
+// String formats sc as a "traceparent" value.
+func (sc spanContext) String() string {
+	return "00-" + sc.TraceID + "-" + sc.SpanID + "-" + sc.Flags
+}
+
+// child returns a new span context in the same trace as sc. If sc is the zero
+// value, a new sampled trace is started.
+func (sc spanContext) child() spanContext {
+	if sc.TraceID == "" {
+		return spanContext{TraceID: randomHex(16), SpanID: randomHex(8), Flags: "01"}
+	}
+	return spanContext{TraceID: sc.TraceID, SpanID: randomHex(8), Flags: sc.Flags}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// traceID returns the trace ID carried in metadata, if any.
+func traceID(metadata map[string]string) string {
+	sc, err := parseTraceParent(metadata[TraceParentKey])
+	if err != nil {
+		return ""
+	}
+	return sc.TraceID
+}
+
+// span is a message dispatch in progress.
+type span struct {
+	context spanContext
+	parent  string
+	start   time.Time
+}
+
+// TraceContextMiddleware propagates W3C Trace Context through messages. Each
+// message received from the server starts a "dispatch" span, a child of the
+// span in the message's "traceparent" metadata or the root of a new trace.
+// The worker receives the dispatch span as its "traceparent", and a response
+// the worker transmits without a "traceparent" of its own is sent as part of
+// the same span. Completed spans are logged at debug level.
+type TraceContextMiddleware struct {
+	// MaxAge is the duration after which a span without a response is
+	// forgotten.
+	MaxAge time.Duration
+
+	mu    sync.Mutex
+	spans map[string]span
+}
+
+// Process adds or replaces the "traceparent" metadata of data.
+func (m *TraceContextMiddleware) Process(direction Direction, data *yggdrasil.Data) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.spans == nil {
+		m.spans = make(map[string]span)
+	}
+	if data.Metadata == nil {
+		data.Metadata = make(map[string]string)
+	}
+
+	now := time.Now()
+	switch direction {
+	case DirectionInbound:
+		parent, err := parseTraceParent(data.Metadata[TraceParentKey])
+		if err != nil && data.Metadata[TraceParentKey] != "" {
+			log.Debugf("ignoring metadata of message %v: %v", data.MessageID, err)
+		}
+		s := span{context: parent.child(), parent: parent.SpanID, start: now}
+		m.spans[data.MessageID] = s
+		data.Metadata[TraceParentKey] = s.context.String()
+		m.forget(now)
+	case DirectionOutbound:
+		s, ok := m.spans[data.ResponseTo]
+		if !ok {
+			return nil
+		}
+		if _, err := parseTraceParent(data.Metadata[TraceParentKey]); err != nil {
+			data.Metadata[TraceParentKey] = s.context.String()
+		}
+		delete(m.spans, data.ResponseTo)
+		log.Debugf(
+			"span name=dispatch trace_id=%v span_id=%v parent_span_id=%v message_id=%v directive=%v response_id=%v duration=%v",
+			s.context.TraceID,
+			s.context.SpanID,
+			s.parent,
+			data.ResponseTo,
+			data.Directive,
+			data.MessageID,
+			now.Sub(s.start),
+		)
+	}
+	return nil
+}
+
+// forget removes spans started more than MaxAge before now.
+func (m *TraceContextMiddleware) forget(now time.Time) {
+	if m.MaxAge <= 0 {
+		return
+	}
+	for id, s := range m.spans {
+		if now.Sub(s.start) > m.MaxAge {
+			delete(m.spans, id)
+		}
+	}
+}
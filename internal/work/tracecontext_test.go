@@ -0,0 +1,128 @@
+package work
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/redhatinsights/yggdrasil"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		want        spanContext
+		wantError   bool
+	}{
+		{
+			description: "valid",
+			input:       "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			want: spanContext{
+				TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:  "00f067aa0ba902b7",
+				Flags:   "01",
+			},
+		},
+		{
+			description: "unsupported version",
+			input:       "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			wantError:   true,
+		},
+		{
+			description: "zero trace ID",
+			input:       "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			wantError:   true,
+		},
+		{
+			description: "upper case",
+			input:       "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01",
+			wantError:   true,
+		},
+		{
+			description: "empty",
+			input:       "",
+			wantError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got, err := parseTraceParent(test.input)
+
+			if test.wantError {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !cmp.Equal(got, test.want) {
+					t.Errorf("%v", cmp.Diff(got, test.want))
+				}
+				if got.String() != test.input {
+					t.Errorf("%v != %v", got.String(), test.input)
+				}
+			}
+		})
+	}
+}
+
+func TestTraceContextMiddleware(t *testing.T) {
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	tests := []struct {
+		description   string
+		inbound       yggdrasil.Data
+		outbound      yggdrasil.Data
+		wantTraceID   string
+		wantPropagate bool
+	}{
+		{
+			description:   "continues trace",
+			inbound:       yggdrasil.Data{MessageID: "a", Metadata: map[string]string{TraceParentKey: parent}},
+			outbound:      yggdrasil.Data{MessageID: "b", ResponseTo: "a"},
+			wantTraceID:   "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantPropagate: true,
+		},
+		{
+			description:   "starts trace",
+			inbound:       yggdrasil.Data{MessageID: "a"},
+			outbound:      yggdrasil.Data{MessageID: "b", ResponseTo: "a"},
+			wantPropagate: true,
+		},
+		{
+			description: "unrelated response",
+			inbound:     yggdrasil.Data{MessageID: "a"},
+			outbound:    yggdrasil.Data{MessageID: "b", ResponseTo: "c"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			m := &TraceContextMiddleware{}
+
+			if err := m.Process(DirectionInbound, &test.inbound); err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseTraceParent(test.inbound.Metadata[TraceParentKey])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.wantTraceID != "" && got.TraceID != test.wantTraceID {
+				t.Errorf("%v != %v", got.TraceID, test.wantTraceID)
+			}
+			if got.String() == parent {
+				t.Errorf("expected a child span, got %v", got)
+			}
+
+			if err := m.Process(DirectionOutbound, &test.outbound); err != nil {
+				t.Fatal(err)
+			}
+			propagated := test.outbound.Metadata[TraceParentKey] == got.String()
+			if propagated != test.wantPropagate {
+				t.Errorf("%v != %v", propagated, test.wantPropagate)
+			}
+		})
+	}
+}
@@ -24,11 +24,37 @@ const (
 	// DispatcherEventConnectionRestored is emitted when the transport reconnects
 	// to the network.
 	DispatcherEventConnectionRestored DispatcherEvent = 3
+
+	// DispatcherEventConfigReloaded is emitted when the dispatcher reloads its
+	// configuration file.
+	DispatcherEventConfigReloaded DispatcherEvent = 4
+
+	// DispatcherEventShuttingDown is emitted when the dispatcher is about to
+	// stop. Workers should flush any state they need to persist.
+	DispatcherEventShuttingDown DispatcherEvent = 5
 )
 
 //go:embed com.redhat.Yggdrasil1.Worker1.xml
 var InterfaceWorker string
 
+// Keys of a worker's "features" table that the dispatcher interprets.
+const (
+	// FeatureContentTypes holds a comma-separated list of the media types the
+	// worker handles. Messages whose directive matches no worker are routed by
+	// their "Content-Type" metadata to a worker listing that media type.
+	FeatureContentTypes = "content_types"
+
+	// FeatureMaxConcurrency holds the maximum number of messages the worker
+	// handles at once. Further messages are queued by the dispatcher until the
+	// worker emits an END event for one of the messages in progress.
+	FeatureMaxConcurrency = "max_concurrency"
+
+	// FeatureResponseTimeout holds the duration, such as "10m", after which
+	// the dispatcher reports a message the worker has not responded to as
+	// timed out.
+	FeatureResponseTimeout = "response_timeout"
+)
+
 type WorkerEventName uint
 
 const (
@@ -51,6 +77,14 @@ const (
 	// WorkerEventNameStopped is emitted when worker is stopped,
 	// and it cannot process any message.
 	WorkerEventNameStopped WorkerEventName = 5
+
+	// WorkerEventNameUnresponsive is emitted by the dispatcher when a worker
+	// fails consecutive health checks and is being restarted.
+	WorkerEventNameUnresponsive WorkerEventName = 6
+
+	// WorkerEventNameCrashed is emitted by the dispatcher when a worker exits
+	// unsuccessfully. The event data includes the path to a crash report.
+	WorkerEventNameCrashed WorkerEventName = 7
 )
 
 func (e WorkerEventName) String() string {
@@ -65,6 +99,10 @@ func (e WorkerEventName) String() string {
 		return "STARTED"
 	case WorkerEventNameStopped:
 		return "STOPPED"
+	case WorkerEventNameUnresponsive:
+		return "UNRESPONSIVE"
+	case WorkerEventNameCrashed:
+		return "CRASHED"
 	}
 	return fmt.Sprintf("UNKNOWN (value: %d)", e)
 }
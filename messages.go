@@ -46,6 +46,10 @@ const (
 
 	// CommandNameCancel instructs a client to cancel a previous message.
 	CommandNameCancel CommandName = "cancel"
+
+	// CommandNameWorker instructs a client to start, stop or restart the worker
+	// named by the "name" argument, according to the "action" argument.
+	CommandNameWorker CommandName = "worker"
 )
 
 // EventName represents accepted values for the "event" field of an Event
@@ -59,6 +63,40 @@ const (
 	// EventNamePong informs the server that the client has received a "ping"
 	// command.
 	EventNamePong EventName = "pong"
+
+	// EventNameDispatchFailed informs the server that the message identified
+	// by the event's "response_to" field could not be delivered to a worker.
+	EventNameDispatchFailed EventName = "dispatch-failed"
+
+	// EventNameMessageExpired informs the server that the message identified
+	// by the event's "response_to" field was discarded because it expired
+	// before it could be processed.
+	EventNameMessageExpired EventName = "message-expired"
+
+	// EventNameResponseTimeout informs the server that the worker handling the
+	// message identified by the event's "response_to" field did not respond
+	// in time.
+	EventNameResponseTimeout EventName = "response-timeout"
+
+	// EventNameMessageDeferred informs the server that the message identified
+	// by the event's "response_to" field exceeded the rate limit of its
+	// worker and will be dispatched later.
+	EventNameMessageDeferred EventName = "message-deferred"
+
+	// EventNameWorkerControlled informs the server that the "worker" command
+	// identified by the event's "response_to" field was carried out.
+	EventNameWorkerControlled EventName = "worker-controlled"
+
+	// EventNameWorkerControlRejected informs the server that the "worker"
+	// command identified by the event's "response_to" field was refused,
+	// because the worker may not be controlled remotely or the action is
+	// unknown.
+	EventNameWorkerControlRejected EventName = "worker-control-rejected"
+
+	// EventNameWorkerControlFailed informs the server that the "worker"
+	// command identified by the event's "response_to" field was accepted but
+	// could not be carried out.
+	EventNameWorkerControlFailed EventName = "worker-control-failed"
 )
 
 // A ConnectionStatus message is published by the client when it connects to
@@ -96,6 +134,10 @@ type Event struct {
 	Version    int         `json:"version"`
 	Sent       time.Time   `json:"sent"`
 	Content    string      `json:"content"`
+
+	// Reason optionally explains the event, such as why the client is
+	// disconnecting.
+	Reason string `json:"reason,omitempty"`
 }
 
 type Control struct {
@@ -105,6 +147,9 @@ type Control struct {
 	Version    int             `json:"version"`
 	Sent       time.Time       `json:"sent"`
 	Content    json.RawMessage `json:"content"`
+
+	// Metadata optionally carries additional fields, such as a signature.
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 // Data messages are published by both client and server on their respective
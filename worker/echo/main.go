@@ -8,8 +8,6 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/google/uuid"
-
 	"git.sr.ht/~spc/go-log"
 
 	"github.com/redhatinsights/yggdrasil/internal/sync"
@@ -84,10 +82,9 @@ func echo(
 	return nil
 }
 
-// sendEchoMessage opens a new dbus connection and calls the
-// com.redhat.Yggdrasil1.Dispatcher1.Transmit method, returning the
-// metadata and data. New ID is generated for the message, and
-// response_to is set to the ID of the message we received.
+// sendEchoMessage calls the com.redhat.Yggdrasil1.Dispatcher1.Transmit
+// method, logging the metadata and data returned. New ID is generated for the
+// message, and response_to is set to the ID of the message we received.
 func sendEchoMessage(
 	w *worker.Worker,
 	addr string,
@@ -97,15 +94,11 @@ func sendEchoMessage(
 	data []byte,
 	count int,
 ) error {
-	// Set "response_to" according to the rcvId of the message we received
-	echoResponseTo := rcvId
-	// Create new echoId for the message we are going to send
-	echoId := uuid.New().String()
-
-	responseCode, responseMetadata, responseData, err := w.Transmit(
+	// Respond with a new message ID and "response_to" set to the rcvId of the
+	// message we received
+	responseCode, responseMetadata, responseData, err := w.Respond(
 		addr,
-		echoId,
-		echoResponseTo,
+		rcvId,
 		metadata,
 		data,
 	)
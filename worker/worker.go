@@ -1,3 +1,6 @@
+// Package worker implements the com.redhat.Yggdrasil1.Worker1 D-Bus
+// interface, letting a Go program receive messages from yggd, respond to them
+// and report its progress. See the echo worker for a complete example.
 package worker
 
 import (
@@ -5,11 +8,13 @@ import (
 	"os"
 	"path"
 	"regexp"
+	"sync"
 
 	"git.sr.ht/~spc/go-log"
 	"github.com/godbus/dbus/v5"
 	"github.com/godbus/dbus/v5/introspect"
 	"github.com/godbus/dbus/v5/prop"
+	"github.com/google/uuid"
 	"github.com/redhatinsights/yggdrasil/ipc"
 )
 
@@ -35,6 +40,9 @@ type Worker struct {
 	objectPath    dbus.ObjectPath
 	busName       string
 	eventHandler  EventHandlerFunc
+	inFlight      sync.WaitGroup
+	mu            sync.Mutex
+	stopping      bool
 }
 
 // NewWorker creates a new worker.
@@ -69,7 +77,9 @@ func NewWorker(
 // requests a well-known bus name. It connects to a private session bus, if
 // DBUS_SESSION_BUS_ADDRESS is set in the environment. Otherwise it connects to
 // the system bus. It exports w onto the bus and waits until a signal is
-// received on quit.
+// received on quit. Once quit is received, it waits for any in-flight calls to
+// the worker's RxFunc and CancelRxFunc to return before emitting the STOPPED
+// event.
 func (w *Worker) Connect(quit <-chan os.Signal) error {
 	var err error
 
@@ -154,6 +164,11 @@ func (w *Worker) Connect(quit <-chan os.Signal) error {
 
 	<-quit
 
+	// Let messages that are being worked on finish. The service manager is
+	// responsible for killing the worker if this exceeds its stop timeout.
+	log.Debug("waiting for in-flight messages to finish")
+	w.stop()
+
 	// Emit a stopped event
 	err = w.EmitEvent(
 		ipc.WorkerEventNameStopped,
@@ -180,6 +195,22 @@ func (w *Worker) SetFeature(name, value string) error {
 	)
 }
 
+// Credential reads the systemd credential with the given name from the
+// directory named by $CREDENTIALS_DIRECTORY. Credentials are passed to the
+// worker using LoadCredential= or LoadCredentialEncrypted= in its service
+// unit.
+func Credential(name string) ([]byte, error) {
+	dir, ok := os.LookupEnv("CREDENTIALS_DIRECTORY")
+	if !ok {
+		return nil, fmt.Errorf("cannot read credential %v: $CREDENTIALS_DIRECTORY is not set", name)
+	}
+	data, err := os.ReadFile(path.Join(dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read credential %v: %w", name, err)
+	}
+	return data, nil
+}
+
 // GetFeature retrieves the value from the feature map for given key.
 func (w *Worker) GetFeature(name string) string {
 	return w.features[name]
@@ -206,6 +237,28 @@ func (w *Worker) Transmit(
 	return
 }
 
+// Tags wraps a com.redhat.Yggdrasil1.Dispatcher1.Tags method call, returning
+// the tags describing the host.
+func (w *Worker) Tags() (map[string]string, error) {
+	obj := w.conn.Object("com.redhat.Yggdrasil1.Dispatcher1", "/com/redhat/Yggdrasil1/Dispatcher1")
+	var tags map[string]string
+	if err := obj.Call("com.redhat.Yggdrasil1.Dispatcher1.Tags", 0).Store(&tags); err != nil {
+		return nil, fmt.Errorf("cannot get tags: %w", err)
+	}
+	return tags, nil
+}
+
+// Respond transmits data to addr in response to the message identified by
+// responseTo, generating a new message ID for the response.
+func (w *Worker) Respond(
+	addr string,
+	responseTo string,
+	metadata map[string]string,
+	data []byte,
+) (responseCode int, responseMetadata map[string]string, responseData []byte, err error) {
+	return w.Transmit(addr, uuid.New().String(), responseTo, metadata, data)
+}
+
 // EmitEvent emits a WorkerEvent, worker message id, and key-value pairs of optional data.
 func (w *Worker) EmitEvent(
 	event ipc.WorkerEventName,
@@ -226,6 +279,35 @@ func (w *Worker) EmitEvent(
 		args...)
 }
 
+// begin counts a call to the worker's RxFunc or CancelRxFunc as in flight. It
+// returns false once the worker is stopping, in which case the call must not
+// be made.
+func (w *Worker) begin() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopping {
+		return false
+	}
+	w.inFlight.Add(1)
+	return true
+}
+
+// stop refuses further calls to the worker's RxFunc and CancelRxFunc and waits
+// for those in flight to return.
+func (w *Worker) stop() {
+	w.mu.Lock()
+	w.stopping = true
+	w.mu.Unlock()
+	w.inFlight.Wait()
+}
+
+// errStopping is returned to the dispatcher for messages received while the
+// worker is stopping.
+var errStopping = dbus.NewError(
+	"com.redhat.Yggdrasil1.Worker1.Stopping",
+	[]interface{}{"worker is stopping"},
+)
+
 // cancel implements com.redhat.Yggdrasil1.Worker1.Cancel method by calling the
 // worker's cancelRxFunc in a goroutine.
 func (w *Worker) cancel(addr string, id string, cancelID string) *dbus.Error {
@@ -243,13 +325,19 @@ func (w *Worker) cancel(addr string, id string, cancelID string) *dbus.Error {
 	log.Tracef("id = %v", id)
 	log.Tracef("cancel-id = %v", cancelID)
 
+	if !w.begin() {
+		return errStopping
+	}
+
 	// Communicate the worker accepts the message and its starting to
 	// work on it.
 	if err := w.EmitEvent(ipc.WorkerEventNameBegin, id, "", map[string]string{}); err != nil {
+		w.inFlight.Done()
 		return dbus.NewError("com.redhat.Yggdrasil1.Worker1.EventError", []interface{}{err.Error()})
 	}
 
 	go func() {
+		defer w.inFlight.Done()
 		if err := w.cancelRx(w, addr, id, cancelID); err != nil {
 			log.Errorf("callback function cancelRx() was terminated: %v", err)
 		}
@@ -278,11 +366,17 @@ func (w *Worker) dispatch(
 	log.Tracef("metadata = %#v", metadata)
 	log.Tracef("data = %v", data)
 
+	if !w.begin() {
+		return errStopping
+	}
+
 	if err := w.EmitEvent(ipc.WorkerEventNameBegin, id, responseTo, map[string]string{}); err != nil {
+		w.inFlight.Done()
 		return dbus.NewError("com.redhat.Yggdrasil1.Worker1.EventError", []interface{}{err.Error()})
 	}
 
 	go func() {
+		defer w.inFlight.Done()
 		if err := w.rx(w, addr, id, responseTo, metadata, data); err != nil {
 			log.Errorf("cannot call rx: %v", err)
 		}
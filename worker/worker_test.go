@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStop(t *testing.T) {
+	called := false
+	w, err := NewWorker(
+		"echo",
+		false,
+		nil,
+		func(w *Worker, addr, id, cancelID string) error {
+			called = true
+			return nil
+		},
+		func(w *Worker, addr, id, responseTo string, metadata map[string]string, data []byte) error {
+			called = true
+			return nil
+		},
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !w.begin() {
+		t.Fatal("worker refused a call before stopping")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		w.stop()
+		close(stopped)
+	}()
+
+	// Wait for stop to refuse further calls.
+	for {
+		w.mu.Lock()
+		stopping := w.stopping
+		w.mu.Unlock()
+		if stopping {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-stopped:
+		t.Fatal("stop returned with a call in flight")
+	default:
+	}
+
+	if err := w.dispatch("echo", "1", "", map[string]string{}, []byte("hello")); err != errStopping {
+		t.Errorf("dispatch: got %v, want %v", err, errStopping)
+	}
+	if err := w.cancel("echo", "2", "1"); err != errStopping {
+		t.Errorf("cancel: got %v, want %v", err, errStopping)
+	}
+	if called {
+		t.Error("handler called while stopping")
+	}
+
+	w.inFlight.Done()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("stop did not return after the call finished")
+	}
+}